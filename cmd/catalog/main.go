@@ -4,8 +4,10 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	configv1client "github.com/openshift/client-go/config/clientset/versioned/typed/config/v1"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/operators/catalog"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/operatorclient"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/operatorstatus"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/server"
@@ -74,6 +77,30 @@ var (
 
 	installPlanTimeout  = flag.Duration("install-plan-retry-timeout", 1*time.Minute, "time since first attempt at which plan execution errors are considered fatal")
 	bundleUnpackTimeout = flag.Duration("bundle-unpack-timeout", 10*time.Minute, "The time limit for bundle unpacking, after which InstallPlan execution is considered to have failed. 0 is considered as having no timeout.")
+
+	enableResolutionPreview = flag.Bool("enable-resolution-preview", false, "serve dry-run resolutions at "+catalog.ResolutionPreviewPath+" on the health/metric port to users allowed to create Subscriptions in the namespace; requires --tls-cert and --tls-key")
+
+	resolutionTraceLimit = flag.Int("resolution-trace-limit", 0, "record up to this many solver search positions of each namespace's most recent resolution in its "+resolver.ResolutionTraceConfigMapName+" ConfigMap; 0 disables recording")
+
+	clusterWideResolution = flag.Bool("enable-cluster-wide-resolution", false, "consider operators in every namespace when resolving, so that no cluster-scoped API is owned by operators of different packages")
+
+	installPlanRollbackTimeout = flag.Duration("installplan-rollback-timeout", 0, "restore the objects touched by an InstallPlan, except CRDs, if it fails or its ClusterServiceVersions don't succeed within this long of it completing; 0 disables rollback")
+
+	installPlanStepParallelism = flag.Int("installplan-step-parallelism", 4, "number of independent InstallPlan steps to apply at a time")
+
+	installPlanRetentionCount = flag.Int("installplan-retention-count", catalog.DefaultInstallPlanRetentionPolicy().Count, "number of InstallPlans kept in each namespace; overridden by the "+catalog.InstallPlanRetentionCountAnnotationKey+" namespace annotation")
+
+	installPlanRetentionMaxAge = flag.Duration("installplan-retention-max-age", 0, "age beyond which InstallPlans other than the newest are deleted; overridden by the "+catalog.InstallPlanRetentionMaxAgeAnnotationKey+" namespace annotation; 0 disables deletion by age")
+
+	installPlanRetentionDeletesPerSweep = flag.Int("installplan-retention-deletes-per-sweep", catalog.DefaultInstallPlanRetentionPolicy().DeletesPerSweep, "maximum number of InstallPlans deleted from a namespace at a time")
+
+	installPlanRetentionKeepLast = flag.Bool("installplan-retention-keep-last-per-subscription", false, "always keep the last completed and last failed InstallPlan of each Subscription; overridden by the "+catalog.InstallPlanRetentionKeepLastAnnotationKey+" namespace annotation")
+
+	installPlanArchive = flag.Bool("installplan-archive", false, "archive deleted InstallPlans in compressed ConfigMaps labeled "+catalog.InstallPlanArchiveLabelKey+"; overridden by the "+catalog.InstallPlanRetentionArchiveAnnotationKey+" namespace annotation")
+
+	installPlanValidationWebhooks = flag.String("installplan-validation-webhooks", "", "comma-separated https URLs of webhooks that must admit each InstallPlan before it is applied")
+
+	installPlanValidationWebhookCA = flag.String("installplan-validation-webhook-ca", "", "path to the PEM-encoded CA bundle that installplan validation webhook certificates are verified against; the system roots are used if unset")
 )
 
 func init() {
@@ -108,9 +135,18 @@ func main() {
 		*catalogNamespace = catalogNamespaceEnvVarValue
 	}
 
-	listenAndServe, err := server.GetListenAndServeFunc(logger, tlsCertPath, tlsKeyPath, clientCAPath)
+	// The preview handler is set once the operator exists, so that health checks don't wait for it.
+	previewHandler := &server.DeferredHandler{}
+	var serverOptions []server.Option
+	if *enableResolutionPreview {
+		if *tlsCertPath == "" || *tlsKeyPath == "" {
+			logger.Fatal("--enable-resolution-preview requires --tls-cert and --tls-key, since preview requests carry bearer tokens")
+		}
+		serverOptions = append(serverOptions, server.WithHandler(catalog.ResolutionPreviewPath, previewHandler))
+	}
+	listenAndServe, err := server.GetListenAndServeFunc(logger, tlsCertPath, tlsKeyPath, clientCAPath, serverOptions...)
 	if err != nil {
-		logger.Fatal("Error setting up health/metric/pprof service: %v", err)
+		logger.Fatalf("Error setting up health/metric/pprof service: %v", err)
 	}
 
 	go func() {
@@ -135,11 +171,41 @@ func main() {
 	}
 
 	// Create a new instance of the operator.
-	op, err := catalog.NewOperator(ctx, *kubeConfigPath, utilclock.RealClock{}, logger, *wakeupInterval, *configmapServerImage, *opmImage, *utilImage, *catalogNamespace, k8sscheme.Scheme, *installPlanTimeout, *bundleUnpackTimeout)
+	options := []catalog.OperatorOption{catalog.WithResolutionTraces(*resolutionTraceLimit), catalog.WithClusterWideResolution(*clusterWideResolution), catalog.WithInstallPlanRollback(*installPlanRollbackTimeout), catalog.WithInstallPlanStepParallelism(*installPlanStepParallelism), catalog.WithInstallPlanRetention(catalog.InstallPlanRetentionPolicy{
+		Count:                   *installPlanRetentionCount,
+		MaxAge:                  *installPlanRetentionMaxAge,
+		DeletesPerSweep:         *installPlanRetentionDeletesPerSweep,
+		KeepLastPerSubscription: *installPlanRetentionKeepLast,
+		Archive:                 *installPlanArchive,
+	})}
+	var webhookCA []byte
+	if *installPlanValidationWebhookCA != "" {
+		if webhookCA, err = ioutil.ReadFile(*installPlanValidationWebhookCA); err != nil {
+			log.Fatalf("error reading installplan validation webhook ca bundle: %s", err.Error())
+		}
+	}
+	for _, url := range strings.Split(*installPlanValidationWebhooks, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			webhook, err := catalog.NewInstallPlanWebhook(url, webhookCA)
+			if err != nil {
+				log.Fatalf("error configuring installplan validation webhook: %s", err.Error())
+			}
+			options = append(options, catalog.WithInstallPlanValidator(url, webhook))
+		}
+	}
+	op, err := catalog.NewOperator(ctx, *kubeConfigPath, utilclock.RealClock{}, logger, *wakeupInterval, *configmapServerImage, *opmImage, *utilImage, *catalogNamespace, k8sscheme.Scheme, *installPlanTimeout, *bundleUnpackTimeout, options...)
 	if err != nil {
 		log.Panicf("error configuring operator: %s", err.Error())
 	}
 
+	if *enableResolutionPreview {
+		handler, err := op.ResolutionPreviewHandler()
+		if err != nil {
+			logger.Fatalf("error configuring resolution preview: %v", err)
+		}
+		previewHandler.Set(handler)
+	}
+
 	op.Run(ctx)
 	<-op.Ready()
 
@@ -18,6 +18,8 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/certs"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/install"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/operators/olm"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/operators/openshift"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/feature"
@@ -69,6 +71,29 @@ var (
 
 	namespace = pflag.String(
 		"namespace", "", "namespace where cleanup runs")
+
+	certValidFor = pflag.Duration(
+		"cert-valid-for", install.DefaultCertValidFor, "how long the certs issued for APIServices and webhooks are valid for")
+
+	certMinFresh = pflag.Duration(
+		"cert-min-fresh", install.DefaultCertMinFresh, "how long before they expire that the certs issued for APIServices and webhooks are rotated")
+
+	certOrganization = pflag.String(
+		"cert-organization", install.Organization, "the x509 organization of the certs issued for APIServices and webhooks")
+
+	certCASecret = pflag.String(
+		"cert-ca-secret", "", "namespace/name of a kubernetes.io/tls Secret holding the ECDSA CA that signs the certs issued for APIServices and webhooks. "+
+			"If not set, a self-signed CA is generated for each deployment.")
+
+	copiedCSVMode = pflag.String(
+		"copied-csv-mode", string(olm.CopiedCSVModeFull), "how the operators of AllNamespaces OperatorGroups are advertised in the namespaces they target. "+
+			"Full copies their CSVs into every namespace; Reference lists them in an olm-operators ConfigMap in each namespace instead.")
+
+	requirementChecks = pflag.StringSlice(
+		"requirement-checks", []string{olm.RequirementCheckMaxKubeVersion}, "additional requirements that CSVs must meet before they're installed: "+
+			"platform (a schedulable node matches the CSV's operatorframework.io/os and arch labels), "+
+			"max-kube-version (the server is no newer than the CSV's operatorframework.io/max-kube-version annotation) and "+
+			"capacity (schedulable nodes have room for the resource requests of the CSV's deployments)")
 )
 
 func init() {
@@ -152,6 +177,19 @@ func main() {
 		logger.WithError(err).Fatal("error configuring custom resource client")
 	}
 
+	certPolicy := install.CertPolicy{
+		ValidFor:     *certValidFor,
+		MinFresh:     *certMinFresh,
+		Organization: *certOrganization,
+	}
+	if *certCASecret != "" {
+		parts := strings.SplitN(*certCASecret, "/", 2)
+		if len(parts) != 2 {
+			logger.Fatalf("invalid cert-ca-secret %q: must be namespace/name", *certCASecret)
+		}
+		certPolicy.CA = &certs.SecretCA{Client: opClient.KubernetesInterface().CoreV1(), Namespace: parts[0], Name: parts[1]}
+	}
+
 	// Create a new instance of the operator.
 	op, err := olm.NewOperator(
 		ctx,
@@ -162,6 +200,9 @@ func main() {
 		olm.WithOperatorClient(opClient),
 		olm.WithRestConfig(config),
 		olm.WithConfigClient(versionedConfigClient),
+		olm.WithCertPolicy(certPolicy),
+		olm.WithCopiedCSVMode(olm.CopiedCSVMode(*copiedCSVMode)),
+		olm.WithRequirementChecks(*requirementChecks...),
 	)
 	if err != nil {
 		logger.WithError(err).Fatalf("error configuring operator")
@@ -0,0 +1,94 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// CAProvider provides the CA that signs serving certs.
+type CAProvider interface {
+	CA(notAfter time.Time, organization string) (*KeyPair, error)
+}
+
+type CAProviderFunc func(notAfter time.Time, organization string) (*KeyPair, error)
+
+func (f CAProviderFunc) CA(notAfter time.Time, organization string) (*KeyPair, error) {
+	return f(notAfter, organization)
+}
+
+var _ CAProvider = CAProviderFunc(GenerateCA)
+
+// SecretCA provides the CA key pair stored in the tls.crt and tls.key of a Secret, so that serving certs are issued
+// by an existing CA instead of a self-signed one. The private key must be an ECDSA key.
+type SecretCA struct {
+	Client    corev1client.SecretsGetter
+	Namespace string
+	Name      string
+}
+
+var _ CAProvider = &SecretCA{}
+
+// CA returns the key pair of the Secret. The requested expiry and organization are those of the issuer and are ignored.
+func (s *SecretCA) CA(notAfter time.Time, organization string) (*KeyPair, error) {
+	secret, err := s.Client.Secrets(s.Namespace).Get(context.TODO(), s.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting CA secret %s/%s: %v", s.Namespace, s.Name, err)
+	}
+	ca, err := PEMToKeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA secret %s/%s: %v", s.Namespace, s.Name, err)
+	}
+	if !ca.Cert.IsCA {
+		return nil, fmt.Errorf("invalid CA secret %s/%s: certificate is not a CA", s.Namespace, s.Name)
+	}
+	if !Active(ca.Cert) {
+		return nil, fmt.Errorf("invalid CA secret %s/%s: certificate is not valid at this time", s.Namespace, s.Name)
+	}
+	return ca, nil
+}
+
+// PEMToKeyPair converts a PEM encoded cert and ECDSA private key, in SEC 1 or PKCS #8 form, to a key pair
+func PEMToKeyPair(certPEM, privPEM []byte) (*KeyPair, error) {
+	cert, err := PEMToCert(certPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(privPEM)
+	if block == nil {
+		return nil, fmt.Errorf("private key PEM empty")
+	}
+	var priv *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		if priv, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if priv, ok = key.(*ecdsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("private key is not an ECDSA key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+	}
+
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !pub.Equal(&priv.PublicKey) {
+		return nil, fmt.Errorf("private key does not match certificate")
+	}
+
+	return &KeyPair{Cert: cert, Priv: priv}, nil
+}
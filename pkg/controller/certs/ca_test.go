@@ -0,0 +1,76 @@
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func caSecret(t *testing.T, name string, kp *KeyPair, pkcs8 bool) *corev1.Secret {
+	certPEM, privPEM, err := kp.ToPEM()
+	require.NoError(t, err)
+	if pkcs8 {
+		der, err := x509.MarshalPKCS8PrivateKey(kp.Priv)
+		require.NoError(t, err)
+		privPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "olm", Name: name},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: privPEM},
+	}
+}
+
+func TestSecretCA(t *testing.T) {
+	ca, err := GenerateCA(time.Now().Add(time.Hour), "test")
+	require.NoError(t, err)
+	other, err := GenerateCA(time.Now().Add(time.Hour), "test")
+	require.NoError(t, err)
+	serving, err := CreateSignedServingPair(time.Now().Add(time.Hour), "test", ca, []string{"svc"})
+	require.NoError(t, err)
+
+	mismatched := caSecret(t, "mismatched", ca, false)
+	mismatched.Data[corev1.TLSPrivateKeyKey] = caSecret(t, "", other, false).Data[corev1.TLSPrivateKeyKey]
+
+	client := fake.NewSimpleClientset(
+		caSecret(t, "sec1", ca, false),
+		caSecret(t, "pkcs8", ca, true),
+		caSecret(t, "serving", serving, false),
+		mismatched,
+	)
+
+	tests := []struct {
+		name string
+		err  string
+	}{
+		{name: "sec1"},
+		{name: "pkcs8"},
+		{name: "serving", err: "invalid CA secret olm/serving: certificate is not a CA"},
+		{name: "mismatched", err: "invalid CA secret olm/mismatched: private key does not match certificate"},
+		{name: "missing", err: `error getting CA secret olm/missing: secrets "missing" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &SecretCA{Client: client.CoreV1(), Namespace: "olm", Name: tt.name}
+			got, err := provider.CA(time.Now().Add(24*time.Hour), "ignored")
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, ca.Cert.Raw, got.Cert.Raw)
+			require.True(t, ca.Priv.Equal(got.Priv))
+
+			// Serving certs signed by the provided CA verify against it.
+			pair, err := CreateSignedServingPair(time.Now().Add(time.Hour), "test", got, []string{"svc"})
+			require.NoError(t, err)
+			require.NoError(t, VerifyCert(ca.Cert, pair.Cert, "svc"))
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package install
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/certs"
+)
+
+// CertPolicy configures the certs that OLM issues for the APIServices and webhooks of ClusterServiceVersions. The
+// zero value issues certs valid for DefaultCertValidFor from a self-signed CA generated for each deployment.
+type CertPolicy struct {
+	// ValidFor is how long issued certs are valid for, at most.
+	ValidFor time.Duration
+
+	// MinFresh is how long before they expire that certs are rotated.
+	MinFresh time.Duration
+
+	// Organization is the x509 organization of generated certs.
+	Organization string
+
+	// CA provides the CA that signs serving certs, such as a certs.SecretCA holding an existing CA. Serving certs
+	// don't outlive their CA.
+	CA certs.CAProvider
+
+	// Generator issues serving certs signed by the CA.
+	Generator certs.CertGenerator
+}
+
+// Validate returns an error if certs issued under the policy would be due for rotation as soon as they're issued.
+func (p CertPolicy) Validate() error {
+	if p.validFor() <= p.minFresh() {
+		return fmt.Errorf("cert lifetime %s must be longer than the min-fresh period %s", p.validFor(), p.minFresh())
+	}
+	return nil
+}
+
+func (p CertPolicy) validFor() time.Duration {
+	if p.ValidFor > 0 {
+		return p.ValidFor
+	}
+	return DefaultCertValidFor
+}
+
+func (p CertPolicy) minFresh() time.Duration {
+	if p.MinFresh > 0 {
+		return p.MinFresh
+	}
+	return DefaultCertMinFresh
+}
+
+func (p CertPolicy) organization() string {
+	if p.Organization != "" {
+		return p.Organization
+	}
+	return Organization
+}
+
+func (p CertPolicy) ca() certs.CAProvider {
+	if p.CA != nil {
+		return p.CA
+	}
+	return certs.CAProviderFunc(certs.GenerateCA)
+}
+
+func (p CertPolicy) generator() certs.CertGenerator {
+	if p.Generator != nil {
+		return p.Generator
+	}
+	return certGenerator
+}
+
+// newCA returns the CA that signs the serving certs issued at the given time, and when those certs are rotated. Certs
+// are rotated before their CA expires. It returns an error if the CA expires too soon for its certs to be issued
+// before they are due for rotation.
+func (p CertPolicy) newCA(now time.Time) (*certs.KeyPair, time.Time, error) {
+	expiration := now.Add(p.validFor())
+	ca, err := p.ca().CA(expiration, p.organization())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if ca.Cert.NotAfter.Before(expiration) {
+		expiration = ca.Cert.NotAfter
+	}
+	rotateAt := expiration.Add(-1 * p.minFresh())
+	if !rotateAt.After(now) {
+		return nil, time.Time{}, fmt.Errorf("CA expires at %s, within the min-fresh period %s", ca.Cert.NotAfter.Format(time.RFC3339), p.minFresh())
+	}
+	return ca, rotateAt, nil
+}
+
+// RotateAt returns when certs issued at the given time are rotated.
+func (p CertPolicy) RotateAt(issued time.Time) time.Time {
+	return issued.Add(p.validFor() - p.minFresh())
+}
+
+// ShouldRotate returns true if the certs of a CSV are due for rotation, either because the rotation time recorded
+// when they were issued has passed, or because they were issued longer ago than the policy allows, as happens when
+// the configured lifetime is shortened.
+func (p CertPolicy) ShouldRotate(csv *v1alpha1.ClusterServiceVersion) bool {
+	if ShouldRotateCerts(csv) {
+		return true
+	}
+	if last := csv.Status.CertsLastUpdated; !last.IsZero() && p.RotateAt(last.Time).Before(time.Now()) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package install
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/certs"
+)
+
+func TestCertPolicyValidate(t *testing.T) {
+	require.NoError(t, CertPolicy{}.Validate())
+	require.NoError(t, CertPolicy{ValidFor: 90 * 24 * time.Hour, MinFresh: 7 * 24 * time.Hour}.Validate())
+	require.Error(t, CertPolicy{ValidFor: time.Hour}.Validate())
+}
+
+func TestCertPolicyShouldRotate(t *testing.T) {
+	policy := CertPolicy{ValidFor: 90 * 24 * time.Hour, MinFresh: 24 * time.Hour}
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(time.Now().Add(d))
+		return &t
+	}
+
+	tests := []struct {
+		name        string
+		lastUpdated *metav1.Time
+		rotateAt    *metav1.Time
+		want        bool
+	}{
+		{
+			name: "NoCerts",
+		},
+		{
+			name:        "Fresh",
+			lastUpdated: at(-24 * time.Hour),
+			rotateAt:    at(88 * 24 * time.Hour),
+		},
+		{
+			name:        "RotateAtPassed",
+			lastUpdated: at(-24 * time.Hour),
+			rotateAt:    at(-time.Minute),
+			want:        true,
+		},
+		{
+			name:        "IssuedUnderLongerLifetime",
+			lastUpdated: at(-100 * 24 * time.Hour),
+			rotateAt:    at(600 * 24 * time.Hour),
+			want:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csv := &v1alpha1.ClusterServiceVersion{}
+			csv.Status.CertsLastUpdated = tt.lastUpdated
+			csv.Status.CertsRotateAt = tt.rotateAt
+			require.Equal(t, tt.want, policy.ShouldRotate(csv))
+		})
+	}
+}
+
+func TestCertPolicyNewCA(t *testing.T) {
+	now := time.Now()
+	policy := CertPolicy{ValidFor: 90 * 24 * time.Hour, MinFresh: 24 * time.Hour}
+
+	ca, rotateAt, err := policy.newCA(now)
+	require.NoError(t, err)
+	require.True(t, ca.Cert.IsCA)
+	require.WithinDuration(t, now.Add(89*24*time.Hour), rotateAt, time.Second)
+
+	// Certs signed by an existing CA are rotated before the CA expires.
+	existing := keyPair(t, now.Add(30*24*time.Hour))
+	policy.CA = certs.CAProviderFunc(func(time.Time, string) (*certs.KeyPair, error) {
+		return existing, nil
+	})
+	ca, rotateAt, err = policy.newCA(now)
+	require.NoError(t, err)
+	require.Equal(t, existing, ca)
+	require.WithinDuration(t, existing.Cert.NotAfter.Add(-24*time.Hour), rotateAt, 0)
+
+	// Certs aren't issued by a CA that expires within the min-fresh period, since they would be due for rotation
+	// as soon as they were issued.
+	existing = keyPair(t, now.Add(12*time.Hour))
+	_, _, err = policy.newCA(now)
+	require.Error(t, err)
+}
@@ -3,6 +3,7 @@ package install
 import (
 	"fmt"
 	"hash/fnv"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
@@ -29,6 +30,8 @@ type StrategyDeploymentInstaller struct {
 	initializers           DeploymentInitializerFuncChain
 	apiServiceDescriptions []certResource
 	webhookDescriptions    []certResource
+	certPolicy             CertPolicy
+	certsRotateAt          time.Time
 }
 
 var _ Strategy = &v1alpha1.StrategyDetailsDeployment{}
@@ -65,7 +68,17 @@ func (c DeploymentInitializerFuncChain) Apply(deployment *appsv1.Deployment) (er
 // the given context.
 type DeploymentInitializerBuilderFunc func(owner ownerutil.Owner) DeploymentInitializerFunc
 
-func NewStrategyDeploymentInstaller(strategyClient wrappers.InstallStrategyDeploymentInterface, templateAnnotations map[string]string, owner ownerutil.Owner, previousStrategy Strategy, initializers DeploymentInitializerFuncChain, apiServiceDescriptions []v1alpha1.APIServiceDescription, webhookDescriptions []v1alpha1.WebhookDescription) StrategyInstaller {
+// StrategyDeploymentInstallerOption configures optional behavior of a StrategyDeploymentInstaller.
+type StrategyDeploymentInstallerOption func(*StrategyDeploymentInstaller)
+
+// WithCertPolicy issues the certs of APIServices and webhooks under the given policy.
+func WithCertPolicy(policy CertPolicy) StrategyDeploymentInstallerOption {
+	return func(i *StrategyDeploymentInstaller) {
+		i.certPolicy = policy
+	}
+}
+
+func NewStrategyDeploymentInstaller(strategyClient wrappers.InstallStrategyDeploymentInterface, templateAnnotations map[string]string, owner ownerutil.Owner, previousStrategy Strategy, initializers DeploymentInitializerFuncChain, apiServiceDescriptions []v1alpha1.APIServiceDescription, webhookDescriptions []v1alpha1.WebhookDescription, options ...StrategyDeploymentInstallerOption) StrategyInstaller {
 	apiDescs := make([]certResource, len(apiServiceDescriptions))
 	for i := range apiServiceDescriptions {
 		apiDescs[i] = &apiServiceDescriptionsWithCAPEM{apiServiceDescriptions[i], []byte{}}
@@ -76,7 +89,7 @@ func NewStrategyDeploymentInstaller(strategyClient wrappers.InstallStrategyDeplo
 		webhookDescs[i] = &webhookDescriptionWithCAPEM{webhookDescriptions[i], []byte{}}
 	}
 
-	return &StrategyDeploymentInstaller{
+	installer := &StrategyDeploymentInstaller{
 		strategyClient:         strategyClient,
 		owner:                  owner,
 		previousStrategy:       previousStrategy,
@@ -85,6 +98,16 @@ func NewStrategyDeploymentInstaller(strategyClient wrappers.InstallStrategyDeplo
 		apiServiceDescriptions: apiDescs,
 		webhookDescriptions:    webhookDescs,
 	}
+	for _, option := range options {
+		option(installer)
+	}
+	return installer
+}
+
+// CertsRotateAt returns when the certs issued by the last install are due for rotation, or the zero time if none
+// were issued.
+func (i *StrategyDeploymentInstaller) CertsRotateAt() time.Time {
+	return i.certsRotateAt
 }
 
 func (i *StrategyDeploymentInstaller) installDeployments(deps []v1alpha1.StrategyDeploymentSpec) error {
@@ -5,6 +5,7 @@ package install
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/wrappers"
@@ -22,6 +23,12 @@ type StrategyInstaller interface {
 	CheckInstalled(strategy Strategy) (bool, error)
 }
 
+// CertRotator is implemented by StrategyInstallers that issue certs, and reports when the certs issued by the last
+// install are due for rotation.
+type CertRotator interface {
+	CertsRotateAt() time.Time
+}
+
 type StrategyResolverInterface interface {
 	UnmarshalStrategy(s v1alpha1.NamedInstallStrategy) (strategy Strategy, err error)
 	InstallerForStrategy(strategyName string, opClient operatorclient.ClientInterface, opLister operatorlister.OperatorLister, owner ownerutil.Owner, annotations map[string]string, apiServiceDescriptions []v1alpha1.APIServiceDescription, webhookDescriptions []v1alpha1.WebhookDescription, previousStrategy Strategy) StrategyInstaller
@@ -29,6 +36,7 @@ type StrategyResolverInterface interface {
 
 type StrategyResolver struct {
 	OverridesBuilderFunc DeploymentInitializerBuilderFunc
+	CertPolicy           CertPolicy
 }
 
 func (r *StrategyResolver) UnmarshalStrategy(s v1alpha1.NamedInstallStrategy) (strategy Strategy, err error) {
@@ -50,7 +58,7 @@ func (r *StrategyResolver) InstallerForStrategy(strategyName string, opClient op
 			initializers = append(initializers, r.OverridesBuilderFunc(owner))
 		}
 
-		return NewStrategyDeploymentInstaller(strategyClient, annotations, owner, previousStrategy, initializers, apiServiceDescriptions, webhookDescriptions)
+		return NewStrategyDeploymentInstaller(strategyClient, annotations, owner, previousStrategy, initializers, apiServiceDescriptions, webhookDescriptions, WithCertPolicy(r.CertPolicy))
 	}
 
 	// Insurance against these functions being called incorrectly (unmarshal strategy will return a valid strategy name)
@@ -0,0 +1,122 @@
+package catalog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+const (
+	// AllowedKindsConfigMapName is the ConfigMap, in the namespace of the catalog operator, that lists the kinds of
+	// bundle object, beyond the built-in step kinds, that InstallPlans may apply. Without it, only the kinds in
+	// supportedKinds are allowed.
+	AllowedKindsConfigMapName = "olm-allowed-kinds"
+
+	// AllowedKindsDataKey is the data key of the allow-list ConfigMap. It holds one kind per line as "Kind.group", or
+	// as "Kind." for a kind of the core group. Blank lines and lines starting with "#" are ignored.
+	AllowedKindsDataKey = "kinds"
+)
+
+// kindAllowList is the set of kinds, beyond the built-in step kinds, that InstallPlans may apply.
+type kindAllowList struct {
+	// kinds are allowed in any group, groupKinds only in their group. Only the built-in allow-list holds kinds.
+	kinds      map[string]struct{}
+	groupKinds map[schema.GroupKind]struct{}
+}
+
+func defaultKindAllowList() kindAllowList {
+	l := kindAllowList{kinds: make(map[string]struct{}), groupKinds: make(map[schema.GroupKind]struct{})}
+	for kind := range supportedKinds {
+		l.kinds[kind] = struct{}{}
+	}
+	return l
+}
+
+// parseKindAllowList parses the data of the allow-list ConfigMap. Every entry must name the group of its kind, so that
+// allowing a kind doesn't also allow any kind of the same name that a bundle's CustomResourceDefinition might define.
+func parseKindAllowList(data string) (kindAllowList, error) {
+	l := kindAllowList{kinds: make(map[string]struct{}), groupKinds: make(map[schema.GroupKind]struct{})}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		if !strings.Contains(entry, ".") {
+			return kindAllowList{}, fmt.Errorf("allowed kind %q has no group, expected Kind.group, or Kind. for the core group", entry)
+		}
+		gk := schema.ParseGroupKind(entry)
+		if gk.Kind == "" {
+			return kindAllowList{}, fmt.Errorf("allowed kind %q has no kind", entry)
+		}
+		l.groupKinds[gk] = struct{}{}
+	}
+	return l, nil
+}
+
+func (l kindAllowList) allows(gk schema.GroupKind) bool {
+	if _, ok := l.kinds[gk.Kind]; ok {
+		return true
+	}
+	_, ok := l.groupKinds[gk]
+	return ok
+}
+
+// kindAllowList returns the administrator-managed allow-list of bundle kinds, or the built-in one if none is defined.
+func (o *Operator) kindAllowList() (kindAllowList, error) {
+	cm, err := o.lister.CoreV1().ConfigMapLister().ConfigMaps(o.namespace).Get(AllowedKindsConfigMapName)
+	if apierrors.IsNotFound(err) {
+		return defaultKindAllowList(), nil
+	}
+	if err != nil {
+		return kindAllowList{}, fmt.Errorf("error getting allowed kinds: %v", err)
+	}
+	l, err := parseKindAllowList(cm.Data[AllowedKindsDataKey])
+	if err != nil {
+		return kindAllowList{}, fmt.Errorf("invalid %s ConfigMap: %v", AllowedKindsConfigMapName, err)
+	}
+	return l, nil
+}
+
+// ensureServiceAccountCanCreate returns an error unless the given service account may create the given resource in
+// namespace, which is empty for cluster-scoped resources. It surfaces missing permissions of the service account an
+// InstallPlan is attenuated to before the step applying the resource fails with a bare Forbidden error.
+func (o *Operator) ensureServiceAccountCanCreate(sa *corev1.ObjectReference, gvr schema.GroupVersionResource, namespace string) error {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   serviceaccount.MakeUsername(sa.Namespace, sa.Name),
+			Groups: serviceaccount.MakeGroupNames(sa.Namespace),
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "create",
+				Group:     gvr.Group,
+				Version:   gvr.Version,
+				Resource:  gvr.Resource,
+			},
+		},
+	}
+	review, err := o.opClient.KubernetesInterface().AuthorizationV1().SubjectAccessReviews().Create(context.TODO(), review, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("error checking permissions of service account %s/%s: %v", sa.Namespace, sa.Name, err)
+	}
+	if review.Status.Allowed {
+		return nil
+	}
+	where := "cluster-wide"
+	if namespace != "" {
+		where = fmt.Sprintf("in namespace %s", namespace)
+	}
+	err = fmt.Errorf("service account %s/%s is not allowed to create %s %s", sa.Namespace, sa.Name, gvr.GroupResource(), where)
+	if review.Status.Reason != "" {
+		err = fmt.Errorf("%v: %s", err, review.Status.Reason)
+	}
+	return err
+}
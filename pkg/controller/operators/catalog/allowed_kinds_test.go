@@ -0,0 +1,100 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestKindAllowList(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	op, err := NewFakeOperator(ctx, "olm", []string{"olm"})
+	require.NoError(t, err)
+	l, err := op.kindAllowList()
+	require.NoError(t, err)
+	require.True(t, l.allows(schema.GroupKind{Group: "monitoring.coreos.com", Kind: ServiceMonitorKind}))
+	require.False(t, l.allows(schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}))
+
+	op, err = NewFakeOperator(ctx, "olm", []string{"olm"}, withK8sObjs(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: AllowedKindsConfigMapName, Namespace: "olm"},
+		Data: map[string]string{AllowedKindsDataKey: `
+# networking
+NetworkPolicy.networking.k8s.io
+  HorizontalPodAutoscaler.autoscaling
+Widget.example.com
+LimitRange.
+`},
+	}))
+	require.NoError(t, err)
+	l, err = op.kindAllowList()
+	require.NoError(t, err)
+
+	tests := []struct {
+		gk      schema.GroupKind
+		allowed bool
+	}{
+		{gk: schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}, allowed: true},
+		{gk: schema.GroupKind{Group: "example.com", Kind: "NetworkPolicy"}},
+		{gk: schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}, allowed: true},
+		{gk: schema.GroupKind{Group: "example.com", Kind: "HorizontalPodAutoscaler"}},
+		{gk: schema.GroupKind{Kind: "LimitRange"}, allowed: true},
+		{gk: schema.GroupKind{Group: "example.com", Kind: "LimitRange"}},
+		{gk: schema.GroupKind{Group: "example.com", Kind: "Widget"}, allowed: true},
+		{gk: schema.GroupKind{Group: "monitoring.coreos.com", Kind: ServiceMonitorKind}},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.allowed, l.allows(tt.gk), tt.gk.String())
+	}
+
+	for _, data := range []string{"HorizontalPodAutoscaler", ".autoscaling"} {
+		op, err = NewFakeOperator(ctx, "olm", []string{"olm"}, withK8sObjs(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: AllowedKindsConfigMapName, Namespace: "olm"},
+			Data:       map[string]string{AllowedKindsDataKey: data},
+		}))
+		require.NoError(t, err)
+		_, err = op.kindAllowList()
+		require.Error(t, err, data)
+	}
+}
+
+func TestEnsureServiceAccountCanCreate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	op, err := NewFakeOperator(ctx, "ns", []string{"ns"})
+	require.NoError(t, err)
+
+	var reviews []*authorizationv1.SubjectAccessReview
+	op.opClient.KubernetesInterface().(*k8sfake.Clientset).PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview).DeepCopy()
+		reviews = append(reviews, review)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Resource == "networkpolicies"
+		return true, review, nil
+	})
+
+	sa := &corev1.ObjectReference{Namespace: "ns", Name: "installer"}
+	networkPolicies := schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}
+	require.NoError(t, op.ensureServiceAccountCanCreate(sa, networkPolicies, "ns"))
+	require.Equal(t, "system:serviceaccount:ns:installer", reviews[0].Spec.User)
+	require.Equal(t, &authorizationv1.ResourceAttributes{
+		Namespace: "ns",
+		Verb:      "create",
+		Group:     "networking.k8s.io",
+		Version:   "v1",
+		Resource:  "networkpolicies",
+	}, reviews[0].Spec.ResourceAttributes)
+
+	hpas := schema.GroupVersionResource{Group: "autoscaling", Version: "v2beta2", Resource: "horizontalpodautoscalers"}
+	err = op.ensureServiceAccountCanCreate(sa, hpas, "ns")
+	require.EqualError(t, err, "service account ns/installer is not allowed to create horizontalpodautoscalers.autoscaling in namespace ns")
+}
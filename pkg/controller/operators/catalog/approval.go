@@ -0,0 +1,185 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+)
+
+// installPlanGroup is the part of a resolution that is installed by a single InstallPlan.
+type installPlanGroup struct {
+	approval v1alpha1.Approval
+
+	// subs are the Subscriptions whose current bundles the group installs, and own its InstallPlan. updated are the
+	// Subscriptions of the group's approval mode whose current bundle was changed by the resolution.
+	subs    []*v1alpha1.Subscription
+	updated []*v1alpha1.Subscription
+
+	steps         []*v1alpha1.Step
+	bundleLookups []v1alpha1.BundleLookup
+}
+
+func approvalOf(sub *v1alpha1.Subscription) v1alpha1.Approval {
+	if sub.Spec != nil && sub.Spec.InstallPlanApproval == v1alpha1.ApprovalManual {
+		return v1alpha1.ApprovalManual
+	}
+	return v1alpha1.ApprovalAutomatic
+}
+
+// splitByApproval divides the result of a resolution by the approval mode of the Subscriptions that it resolves, so
+// that Subscriptions with automatic approval keep upgrading while those with manual approval wait. A bundle that no
+// Subscription resolves to is a dependency. A dependency is installed automatically if it is needed, directly or
+// through other dependencies, by a bundle with automatic approval, and is approved manually, and subscribed to with
+// manual approval, if it is only needed by bundles with manual approval. A dependency that is not needed by any
+// resolved bundle may have been pulled in by any of them, so it is approved manually if any bundle of a Subscription
+// with manual approval is part of the resolution. Groups are returned with automatic approval first, and groups with
+// nothing to install or update are omitted.
+func splitByApproval(subs, updatedSubs []*v1alpha1.Subscription, steps []*v1alpha1.Step, bundleLookups []v1alpha1.BundleLookup, dependencies resolver.Dependencies) ([]*installPlanGroup, error) {
+	// Subscriptions updated by the resolution take precedence over their listed state.
+	var all []*v1alpha1.Subscription
+	index := make(map[string]int)
+	for _, sub := range subs {
+		index[sub.GetName()] = len(all)
+		all = append(all, sub)
+	}
+	updated := make(map[string]struct{})
+	for _, sub := range updatedSubs {
+		updated[sub.GetName()] = struct{}{}
+		if i, ok := index[sub.GetName()]; ok {
+			all[i] = sub
+			continue
+		}
+		all = append(all, sub)
+	}
+
+	resolving := make(map[string]struct{})
+	for _, step := range steps {
+		resolving[step.Resolving] = struct{}{}
+	}
+	for _, lookup := range bundleLookups {
+		resolving[lookup.Identifier] = struct{}{}
+	}
+
+	approvals := make(map[string]v1alpha1.Approval)
+	unattributed := v1alpha1.ApprovalAutomatic
+	for _, sub := range all {
+		csv := sub.Status.CurrentCSV
+		if _, ok := resolving[csv]; !ok || csv == "" {
+			continue
+		}
+		// A bundle resolved by Subscriptions of both modes waits for approval.
+		if approvals[csv] != v1alpha1.ApprovalManual {
+			approvals[csv] = approvalOf(sub)
+		}
+		if approvals[csv] == v1alpha1.ApprovalManual {
+			unattributed = v1alpha1.ApprovalManual
+		}
+	}
+
+	// Dependencies needed by bundles with automatic approval are visited first, so that they are never held back by
+	// bundles with manual approval that need them too.
+	dependencyApprovals := make(map[string]v1alpha1.Approval)
+	var visit func(csv string, a v1alpha1.Approval)
+	visit = func(csv string, a v1alpha1.Approval) {
+		for _, dep := range dependencies[csv] {
+			if _, ok := approvals[dep]; ok {
+				continue
+			}
+			if _, ok := dependencyApprovals[dep]; ok {
+				continue
+			}
+			dependencyApprovals[dep] = a
+			visit(dep, a)
+		}
+	}
+	for _, a := range []v1alpha1.Approval{v1alpha1.ApprovalAutomatic, v1alpha1.ApprovalManual} {
+		for _, sub := range all {
+			if approvals[sub.Status.CurrentCSV] == a {
+				visit(sub.Status.CurrentCSV, a)
+			}
+		}
+	}
+
+	approval := func(csv string) v1alpha1.Approval {
+		if a, ok := approvals[csv]; ok {
+			return a
+		}
+		if a, ok := dependencyApprovals[csv]; ok {
+			return a
+		}
+		return unattributed
+	}
+
+	groups := map[v1alpha1.Approval]*installPlanGroup{
+		v1alpha1.ApprovalAutomatic: {approval: v1alpha1.ApprovalAutomatic},
+		v1alpha1.ApprovalManual:    {approval: v1alpha1.ApprovalManual},
+	}
+	for _, sub := range all {
+		_, isUpdated := updated[sub.GetName()]
+		a, ok := approvals[sub.Status.CurrentCSV]
+		if !ok {
+			// The Subscription's current bundle needs no steps, but its status still has to be updated.
+			if isUpdated {
+				g := groups[approvalOf(sub)]
+				g.updated = append(g.updated, sub)
+			}
+			continue
+		}
+		g := groups[a]
+		g.subs = append(g.subs, sub)
+		if isUpdated {
+			g.updated = append(g.updated, sub)
+		}
+	}
+	for _, step := range steps {
+		g := groups[approval(step.Resolving)]
+		if step.Resource.Kind == v1alpha1.SubscriptionKind && g.approval == v1alpha1.ApprovalManual {
+			var err error
+			if step, err = withManualApproval(step); err != nil {
+				return nil, err
+			}
+		}
+		g.steps = append(g.steps, step)
+	}
+	for _, lookup := range bundleLookups {
+		g := groups[approval(lookup.Identifier)]
+		g.bundleLookups = append(g.bundleLookups, lookup)
+	}
+
+	var split []*installPlanGroup
+	for _, a := range []v1alpha1.Approval{v1alpha1.ApprovalAutomatic, v1alpha1.ApprovalManual} {
+		g := groups[a]
+		if len(g.steps) == 0 && len(g.bundleLookups) == 0 && len(g.updated) == 0 {
+			continue
+		}
+		if len(g.subs) == 0 {
+			// Only dependencies of already installed bundles are resolved, so they belong to every Subscription.
+			g.subs = subs
+		}
+		split = append(split, g)
+	}
+	return split, nil
+}
+
+// withManualApproval returns a copy of a step that creates a Subscription, with the Subscription's approval mode set
+// to manual.
+func withManualApproval(step *v1alpha1.Step) (*v1alpha1.Step, error) {
+	var sub v1alpha1.Subscription
+	if err := json.Unmarshal([]byte(step.Resource.Manifest), &sub); err != nil {
+		return nil, fmt.Errorf("error parsing step manifest: %s: %v", step.Resource.Name, err)
+	}
+	if sub.Spec == nil {
+		sub.Spec = &v1alpha1.SubscriptionSpec{}
+	}
+	sub.Spec.InstallPlanApproval = v1alpha1.ApprovalManual
+	manifest, err := json.Marshal(&sub)
+	if err != nil {
+		return nil, err
+	}
+	out := step.DeepCopy()
+	out.Resource.Manifest = string(manifest)
+	return out, nil
+}
@@ -0,0 +1,215 @@
+package catalog
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+)
+
+func TestSplitByApproval(t *testing.T) {
+	sub := func(name, csv string, approval v1alpha1.Approval) *v1alpha1.Subscription {
+		return &v1alpha1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       &v1alpha1.SubscriptionSpec{Package: name, InstallPlanApproval: approval},
+			Status:     v1alpha1.SubscriptionStatus{CurrentCSV: csv},
+		}
+	}
+	csvStep := func(csv string) *v1alpha1.Step {
+		return &v1alpha1.Step{Resolving: csv, Resource: v1alpha1.StepResource{Kind: v1alpha1.ClusterServiceVersionKind, Name: csv}}
+	}
+	subStep := func(t *testing.T, csv string) *v1alpha1.Step {
+		resource, err := resolver.NewSubscriptionStepResource("ns", resolver.OperatorSourceInfo{
+			Package:     "dep",
+			Channel:     "stable",
+			StartingCSV: csv,
+			Catalog:     registry.CatalogKey{Name: "catalog", Namespace: "ns"},
+		})
+		require.NoError(t, err)
+		return &v1alpha1.Step{Resolving: csv, Resource: resource}
+	}
+	names := func(steps []*v1alpha1.Step) []string {
+		var out []string
+		for _, s := range steps {
+			out = append(out, s.Resource.Kind+"/"+s.Resolving)
+		}
+		return out
+	}
+	subNames := func(subs []*v1alpha1.Subscription) []string {
+		var out []string
+		for _, s := range subs {
+			out = append(out, s.GetName())
+		}
+		return out
+	}
+
+	type group struct {
+		approval v1alpha1.Approval
+		subs     []string
+		updated  []string
+		steps    []string
+		lookups  []string
+	}
+	tests := []struct {
+		name          string
+		subs          []*v1alpha1.Subscription
+		updated       []*v1alpha1.Subscription
+		steps         func(t *testing.T) []*v1alpha1.Step
+		bundleLookups []v1alpha1.BundleLookup
+		dependencies  resolver.Dependencies
+		want          []group
+	}{
+		{
+			name:    "ManualDoesNotHoldBackAutomatic",
+			subs:    []*v1alpha1.Subscription{sub("a", "", v1alpha1.ApprovalAutomatic), sub("m", "", v1alpha1.ApprovalManual)},
+			updated: []*v1alpha1.Subscription{sub("a", "a.v1", v1alpha1.ApprovalAutomatic), sub("m", "m.v1", v1alpha1.ApprovalManual)},
+			steps: func(t *testing.T) []*v1alpha1.Step {
+				return []*v1alpha1.Step{csvStep("a.v1"), csvStep("m.v1"), csvStep("dep.v1"), subStep(t, "dep.v1")}
+			},
+			bundleLookups: []v1alpha1.BundleLookup{{Identifier: "a.v1"}},
+			want: []group{
+				{
+					approval: v1alpha1.ApprovalAutomatic,
+					subs:     []string{"a"},
+					updated:  []string{"a"},
+					steps:    []string{"ClusterServiceVersion/a.v1"},
+					lookups:  []string{"a.v1"},
+				},
+				{
+					approval: v1alpha1.ApprovalManual,
+					subs:     []string{"m"},
+					updated:  []string{"m"},
+					steps:    []string{"ClusterServiceVersion/m.v1", "ClusterServiceVersion/dep.v1", "Subscription/dep.v1"},
+				},
+			},
+		},
+		{
+			name:    "DependenciesFollowTheSubscriptionsThatNeedThem",
+			subs:    []*v1alpha1.Subscription{sub("a", "", v1alpha1.ApprovalAutomatic), sub("m", "", v1alpha1.ApprovalManual)},
+			updated: []*v1alpha1.Subscription{sub("a", "a.v1", v1alpha1.ApprovalAutomatic), sub("m", "m.v1", v1alpha1.ApprovalManual)},
+			steps: func(t *testing.T) []*v1alpha1.Step {
+				return []*v1alpha1.Step{
+					csvStep("a.v1"), csvStep("m.v1"),
+					csvStep("a-dep.v1"), subStep(t, "a-dep.v1"),
+					csvStep("a-dep-dep.v1"), subStep(t, "a-dep-dep.v1"),
+					csvStep("m-dep.v1"), subStep(t, "m-dep.v1"),
+					csvStep("shared.v1"), subStep(t, "shared.v1"),
+				}
+			},
+			bundleLookups: []v1alpha1.BundleLookup{{Identifier: "m-dep.v1"}},
+			dependencies: resolver.Dependencies{
+				"a.v1":     {"a-dep.v1"},
+				"a-dep.v1": {"a-dep-dep.v1", "shared.v1"},
+				"m.v1":     {"m-dep.v1", "shared.v1"},
+			},
+			want: []group{
+				{
+					approval: v1alpha1.ApprovalAutomatic,
+					subs:     []string{"a"},
+					updated:  []string{"a"},
+					steps: []string{
+						"ClusterServiceVersion/a.v1",
+						"ClusterServiceVersion/a-dep.v1", "Subscription/a-dep.v1",
+						"ClusterServiceVersion/a-dep-dep.v1", "Subscription/a-dep-dep.v1",
+						"ClusterServiceVersion/shared.v1", "Subscription/shared.v1",
+					},
+				},
+				{
+					approval: v1alpha1.ApprovalManual,
+					subs:     []string{"m"},
+					updated:  []string{"m"},
+					steps:    []string{"ClusterServiceVersion/m.v1", "ClusterServiceVersion/m-dep.v1", "Subscription/m-dep.v1"},
+					lookups:  []string{"m-dep.v1"},
+				},
+			},
+		},
+		{
+			name:    "DependenciesOfAutomatic",
+			subs:    []*v1alpha1.Subscription{sub("a", "", v1alpha1.ApprovalAutomatic), sub("m", "m.v1", v1alpha1.ApprovalManual)},
+			updated: []*v1alpha1.Subscription{sub("a", "a.v1", v1alpha1.ApprovalAutomatic)},
+			steps: func(t *testing.T) []*v1alpha1.Step {
+				return []*v1alpha1.Step{csvStep("a.v1"), csvStep("dep.v1"), subStep(t, "dep.v1")}
+			},
+			want: []group{
+				{
+					approval: v1alpha1.ApprovalAutomatic,
+					subs:     []string{"a"},
+					updated:  []string{"a"},
+					steps:    []string{"ClusterServiceVersion/a.v1", "ClusterServiceVersion/dep.v1", "Subscription/dep.v1"},
+				},
+			},
+		},
+		{
+			name:    "PendingManualBundleStaysManual",
+			subs:    []*v1alpha1.Subscription{sub("a", "", v1alpha1.ApprovalAutomatic), sub("m", "m.v1", v1alpha1.ApprovalManual)},
+			updated: []*v1alpha1.Subscription{sub("a", "a.v1", v1alpha1.ApprovalAutomatic)},
+			steps: func(t *testing.T) []*v1alpha1.Step {
+				return []*v1alpha1.Step{csvStep("a.v1"), csvStep("m.v1")}
+			},
+			want: []group{
+				{
+					approval: v1alpha1.ApprovalAutomatic,
+					subs:     []string{"a"},
+					updated:  []string{"a"},
+					steps:    []string{"ClusterServiceVersion/a.v1"},
+				},
+				{
+					approval: v1alpha1.ApprovalManual,
+					subs:     []string{"m"},
+					steps:    []string{"ClusterServiceVersion/m.v1"},
+				},
+			},
+		},
+		{
+			name:    "UpdatedWithoutSteps",
+			subs:    []*v1alpha1.Subscription{sub("m", "", v1alpha1.ApprovalManual)},
+			updated: []*v1alpha1.Subscription{sub("m", "m.v1", v1alpha1.ApprovalManual)},
+			steps: func(t *testing.T) []*v1alpha1.Step {
+				return nil
+			},
+			want: []group{
+				{
+					approval: v1alpha1.ApprovalManual,
+					subs:     []string{"m"},
+					updated:  []string{"m"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := splitByApproval(tt.subs, tt.updated, tt.steps(t), tt.bundleLookups, tt.dependencies)
+			require.NoError(t, err)
+
+			var got []group
+			for _, g := range groups {
+				var lookups []string
+				for _, l := range g.bundleLookups {
+					lookups = append(lookups, l.Identifier)
+				}
+				got = append(got, group{
+					approval: g.approval,
+					subs:     subNames(g.subs),
+					updated:  subNames(g.updated),
+					steps:    names(g.steps),
+					lookups:  lookups,
+				})
+
+				for _, step := range g.steps {
+					if step.Resource.Kind != v1alpha1.SubscriptionKind {
+						continue
+					}
+					var s v1alpha1.Subscription
+					require.NoError(t, json.Unmarshal([]byte(step.Resource.Manifest), &s))
+					require.Equal(t, g.approval, s.Spec.InstallPlanApproval)
+				}
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package catalog
+
+import "time"
+
+// OperatorOption configures optional behavior of the catalog operator.
+type OperatorOption func(*operatorConfig)
+
+type operatorConfig struct {
+	resolutionTraceLimit       int
+	clusterWideResolution      bool
+	installPlanRollbackTimeout time.Duration
+	installPlanStepParallelism int
+	installPlanRetention       InstallPlanRetentionPolicy
+	installPlanValidators      []namedValidator
+}
+
+func (o *operatorConfig) apply(options []OperatorOption) {
+	for _, option := range options {
+		option(o)
+	}
+}
+
+func defaultOperatorConfig() *operatorConfig {
+	return &operatorConfig{
+		installPlanStepParallelism: 4,
+		installPlanRetention:       DefaultInstallPlanRetentionPolicy(),
+	}
+}
+
+// WithResolutionTraces records a trace of each namespace's most recent resolution, bounded to the given number of
+// solver search positions, in a ConfigMap in the namespace. A limit less than one disables recording.
+func WithResolutionTraces(limit int) OperatorOption {
+	return func(config *operatorConfig) {
+		config.resolutionTraceLimit = limit
+	}
+}
+
+// WithClusterWideResolution makes resolution consider the operators in every namespace, so that conflicting owners
+// of a cluster-scoped API are reported as resolution failures before any InstallPlan is written.
+func WithClusterWideResolution(enabled bool) OperatorOption {
+	return func(config *operatorConfig) {
+		config.clusterWideResolution = enabled
+	}
+}
+
+// WithInstallPlanRollback snapshots the objects touched by each InstallPlan step and restores them, except for
+// CustomResourceDefinitions, if the plan fails or its ClusterServiceVersions don't succeed within the given timeout
+// of the plan completing. A timeout of zero disables rollback.
+func WithInstallPlanRollback(timeout time.Duration) OperatorOption {
+	return func(config *operatorConfig) {
+		config.installPlanRollbackTimeout = timeout
+	}
+}
+
+// WithInstallPlanStepParallelism applies up to the given number of independent InstallPlan steps at a time. Steps
+// are still applied after the steps that they depend on. A parallelism less than one applies steps one at a time.
+func WithInstallPlanStepParallelism(parallelism int) OperatorOption {
+	return func(config *operatorConfig) {
+		config.installPlanStepParallelism = parallelism
+	}
+}
+
+// WithInstallPlanRetention sets the policy by which InstallPlans are garbage collected, unless overridden by the
+// annotations of a namespace.
+func WithInstallPlanRetention(policy InstallPlanRetentionPolicy) OperatorOption {
+	return func(config *operatorConfig) {
+		config.installPlanRetention = policy
+	}
+}
+
+// WithInstallPlanValidator asks the given validator to admit each InstallPlan before any of its steps are applied.
+// Plans rejected by a validator fail with the validator's reason. Validators are called in the order in which they're
+// configured, and the name identifies a validator in the reason of a failed plan.
+func WithInstallPlanValidator(name string, validator InstallPlanValidator) OperatorOption {
+	return func(config *operatorConfig) {
+		config.installPlanValidators = append(config.installPlanValidators, namedValidator{name: name, validator: validator})
+	}
+}
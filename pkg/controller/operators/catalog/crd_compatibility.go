@@ -0,0 +1,414 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	crdlib "github.com/operator-framework/operator-lifecycle-manager/pkg/lib/crd"
+)
+
+const (
+	// CRDCompatibilityReportAnnotationKey is the InstallPlan annotation holding, as JSON, the CRDCompatibilityReport
+	// of the CRD updates in the plan.
+	CRDCompatibilityReportAnnotationKey = "olm.crd-compatibility-report"
+
+	// InstallPlanCRDCompatibility is the InstallPlan condition summarizing the CRD compatibility report. It is set
+	// before a plan that requires approval is approved, and again when the plan is installed.
+	InstallPlanCRDCompatibility v1alpha1.InstallPlanConditionType = "CRDCompatibility"
+
+	// InstallPlanReasonCRDsCompatible means that the CRD updates in the plan can be applied.
+	InstallPlanReasonCRDsCompatible v1alpha1.InstallPlanConditionReason = "CRDsCompatible"
+
+	// InstallPlanReasonCRDsIncompatible means that applying the CRD updates in the plan would invalidate objects on
+	// the cluster, so the plan won't be installed.
+	InstallPlanReasonCRDsIncompatible v1alpha1.InstallPlanConditionReason = "CRDsIncompatible"
+
+	// maxReportedObjects bounds the incompatible objects listed per CRD, to keep the report within the size limit of
+	// annotations.
+	maxReportedObjects = 100
+)
+
+// CRDCompatibilityReport describes how the CRD updates of an InstallPlan affect the CRDs and objects on the cluster.
+// Only CRDs with findings are listed.
+type CRDCompatibilityReport struct {
+	CRDs []CRDCompatibility `json:"crds,omitempty"`
+}
+
+// CRDCompatibility describes how the update of a single CRD affects the objects on the cluster.
+type CRDCompatibility struct {
+	Name string `json:"name"`
+
+	// IncompatibleObjectCount is the number of existing objects that the new schema rejects. At most
+	// maxReportedObjects of them are listed in IncompatibleObjects. An object that the new schema rejects in several
+	// served versions is counted and listed once, in the first of them.
+	IncompatibleObjectCount int                  `json:"incompatibleObjectCount,omitempty"`
+	IncompatibleObjects     []IncompatibleObject `json:"incompatibleObjects,omitempty"`
+
+	// RemovedStoredVersions are versions that objects may still be stored in, but that the new CRD removes.
+	RemovedStoredVersions []string `json:"removedStoredVersions,omitempty"`
+
+	// StorageMigration is set if existing objects may be stored in versions other than the new storage version.
+	StorageMigration *StorageMigration `json:"storageMigration,omitempty"`
+}
+
+// IncompatibleObject is an existing object that the new schema of its CRD rejects.
+type IncompatibleObject struct {
+	// Version is the version that the object was read and validated in.
+	Version   string       `json:"version"`
+	Namespace string       `json:"namespace,omitempty"`
+	Name      string       `json:"name"`
+	Errors    []FieldError `json:"errors"`
+}
+
+// FieldError is a schema violation of a single field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// StorageMigration describes the versions that existing objects have to be migrated from to be stored in the new
+// storage version.
+type StorageMigration struct {
+	From []string `json:"from"`
+	To   string   `json:"to"`
+
+	// Automatic is set if the CRD update removes stored versions and the bundle opts in to migrating the objects with
+	// StorageVersionMigrationAnnotationKey, so that the removal doesn't block the update.
+	Automatic bool `json:"automatic,omitempty"`
+}
+
+// compatible returns true if none of the CRD updates would invalidate objects on the cluster. Storage migrations
+// don't make the updates incompatible, and neither do removed stored versions that are migrated automatically.
+func (r CRDCompatibilityReport) compatible() bool {
+	for _, c := range r.CRDs {
+		if c.IncompatibleObjectCount > 0 {
+			return false
+		}
+		if len(c.RemovedStoredVersions) > 0 && (c.StorageMigration == nil || !c.StorageMigration.Automatic) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r CRDCompatibilityReport) String() string {
+	if len(r.CRDs) == 0 {
+		return "no CRD updates affect existing objects"
+	}
+	var crds []string
+	for _, c := range r.CRDs {
+		var findings []string
+		if c.IncompatibleObjectCount > 0 {
+			findings = append(findings, fmt.Sprintf("%d existing objects fail the new schema", c.IncompatibleObjectCount))
+		}
+		if len(c.RemovedStoredVersions) > 0 {
+			findings = append(findings, fmt.Sprintf("removes stored versions %s", strings.Join(c.RemovedStoredVersions, ", ")))
+		}
+		if m := c.StorageMigration; m != nil && m.Automatic {
+			findings = append(findings, fmt.Sprintf("objects stored in %s are migrated to %s", strings.Join(m.From, ", "), m.To))
+		} else if m != nil {
+			findings = append(findings, fmt.Sprintf("objects stored in %s need migration to %s", strings.Join(m.From, ", "), m.To))
+		}
+		crds = append(crds, fmt.Sprintf("%s: %s", c.Name, strings.Join(findings, ", ")))
+	}
+	return strings.Join(crds, "; ")
+}
+
+// internalCRD converts a CRD of any version to the internal representation.
+func internalCRD(crd interface{}) (*apiextensions.CustomResourceDefinition, error) {
+	out := &apiextensions.CustomResourceDefinition{}
+	var err error
+	switch crd := crd.(type) {
+	case *apiextensionsv1.CustomResourceDefinition:
+		err = apiextensionsv1.Convert_v1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(crd, out, nil)
+	case *apiextensionsv1beta1.CustomResourceDefinition:
+		err = apiextensionsv1beta1.Convert_v1beta1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(crd, out, nil)
+	default:
+		err = fmt.Errorf("unsupported CRD type %T", crd)
+	}
+	return out, err
+}
+
+// versionNames returns the names of the versions of a CRD.
+func versionNames(crd *apiextensions.CustomResourceDefinition) map[string]struct{} {
+	names := make(map[string]struct{})
+	if crd.Spec.Version != "" {
+		names[crd.Spec.Version] = struct{}{}
+	}
+	for _, v := range crd.Spec.Versions {
+		names[v.Name] = struct{}{}
+	}
+	return names
+}
+
+// servedVersions returns the names of the versions of a CRD that are served.
+func servedVersions(crd *apiextensions.CustomResourceDefinition) []string {
+	if len(crd.Spec.Versions) == 0 && crd.Spec.Version != "" {
+		return []string{crd.Spec.Version}
+	}
+	var served []string
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			served = append(served, v.Name)
+		}
+	}
+	return served
+}
+
+func storageVersion(crd *apiextensions.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return crd.Spec.Version
+}
+
+// schemaForVersion returns the schema that objects of the given version are validated against. Objects of versions
+// that the CRD doesn't define are validated against the schema of the storage version.
+func schemaForVersion(crd *apiextensions.CustomResourceDefinition, version string) *apiextensions.CustomResourceValidation {
+	if crd.Spec.Validation != nil {
+		return crd.Spec.Validation
+	}
+	for _, v := range crd.Spec.Versions {
+		if v.Name == version {
+			return v.Schema
+		}
+	}
+	if storage := storageVersion(crd); storage != version {
+		return schemaForVersion(crd, storage)
+	}
+	return nil
+}
+
+// checkCRDCompatibility checks the update of oldCRD to newCRD against the objects on the cluster.
+func checkCRDCompatibility(dynamicClient dynamic.Interface, oldCRD, newCRD *apiextensions.CustomResourceDefinition) (CRDCompatibility, error) {
+	c := CRDCompatibility{Name: newCRD.GetName()}
+
+	newVersions := versionNames(newCRD)
+	for _, v := range oldCRD.Status.StoredVersions {
+		if _, ok := newVersions[v]; !ok {
+			c.RemovedStoredVersions = append(c.RemovedStoredVersions, v)
+		}
+	}
+
+	hasObjects := false
+	// Every served version lists the same objects, so objects are identified by namespace and name.
+	seen := make(map[types.NamespacedName]struct{})
+	served := servedVersions(oldCRD)
+	for _, version := range served {
+		newSchema := schemaForVersion(newCRD, version)
+		if equality.Semantic.DeepEqual(schemaForVersion(oldCRD, version), newSchema) {
+			continue
+		}
+		gvr := schema.GroupVersionResource{Group: oldCRD.Spec.Group, Version: version, Resource: oldCRD.Spec.Names.Plural}
+		incompatible, count, err := incompatibleObjects(dynamicClient, gvr, newSchema)
+		if err != nil {
+			return c, err
+		}
+		hasObjects = hasObjects || count > 0
+		for _, obj := range incompatible {
+			key := types.NamespacedName{Namespace: obj.Namespace, Name: obj.Name}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			c.IncompatibleObjectCount++
+			if len(c.IncompatibleObjects) < maxReportedObjects {
+				c.IncompatibleObjects = append(c.IncompatibleObjects, obj)
+			}
+		}
+	}
+
+	newStorage := storageVersion(newCRD)
+	var from []string
+	for _, v := range oldCRD.Status.StoredVersions {
+		if v != newStorage {
+			from = append(from, v)
+		}
+	}
+	if len(from) > 0 && !hasObjects && len(served) > 0 {
+		// The objects haven't been listed because the schemas are unchanged.
+		gvr := schema.GroupVersionResource{Group: oldCRD.Spec.Group, Version: served[0], Resource: oldCRD.Spec.Names.Plural}
+		list, err := dynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{Limit: 1})
+		if err != nil {
+			return c, fmt.Errorf("error listing resources in GroupVersionResource %#v: %s", gvr, err)
+		}
+		hasObjects = len(list.Items) > 0
+	}
+	automatic := storageMigrationEnabled(newCRD) && len(c.RemovedStoredVersions) > 0
+	if len(from) > 0 && (hasObjects || automatic) {
+		c.StorageMigration = &StorageMigration{From: from, To: newStorage, Automatic: automatic}
+	}
+
+	return c, nil
+}
+
+// incompatibleObjects returns the objects of the given resource that fail the given schema, along with the number of
+// objects checked.
+func incompatibleObjects(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, newSchema *apiextensions.CustomResourceValidation) ([]IncompatibleObject, int, error) {
+	crList, err := dynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing resources in GroupVersionResource %#v: %s", gvr, err)
+	}
+	validator, _, err := validation.NewSchemaValidator(newSchema)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating validator for schema %#v: %s", newSchema, err)
+	}
+	var incompatible []IncompatibleObject
+	for _, cr := range crList.Items {
+		errs := validation.ValidateCustomResource(nil, cr.UnstructuredContent(), validator)
+		if len(errs) == 0 {
+			continue
+		}
+		obj := IncompatibleObject{Version: gvr.Version, Namespace: cr.GetNamespace(), Name: cr.GetName()}
+		for _, e := range errs {
+			obj.Errors = append(obj.Errors, FieldError{Field: e.Field, Message: e.ErrorBody()})
+		}
+		incompatible = append(incompatible, obj)
+	}
+	return incompatible, len(crList.Items), nil
+}
+
+// crdCompatibilityReport checks the CRD updates in the steps of a plan that haven't been applied yet against the
+// CRDs and objects on the cluster. CRDs that don't exist yet aren't reported.
+func (o *Operator) crdCompatibilityReport(plan *v1alpha1.InstallPlan, r ManifestResolver) (CRDCompatibilityReport, error) {
+	var report CRDCompatibilityReport
+	for _, step := range plan.Status.Plan {
+		if step.Resource.Kind != crdKind || (step.Status != v1alpha1.StepStatusUnknown && step.Status != v1alpha1.StepStatusNotPresent) {
+			continue
+		}
+		manifest, err := r.ManifestForStep(step)
+		if err != nil {
+			return report, err
+		}
+		version, err := crdlib.Version(&manifest)
+		if err != nil {
+			return report, err
+		}
+
+		var newCRD, oldCRD interface{}
+		switch version {
+		case crdlib.V1Version:
+			crd, err := crdlib.UnmarshalV1(manifest)
+			if err != nil {
+				return report, err
+			}
+			newCRD = crd
+			oldCRD, err = o.opClient.ApiextensionsInterface().ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), crd.GetName(), metav1.GetOptions{})
+			if k8serrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return report, fmt.Errorf("error getting CRD %s: %v", crd.GetName(), err)
+			}
+		case crdlib.V1Beta1Version:
+			crd, err := crdlib.UnmarshalV1Beta1(manifest)
+			if err != nil {
+				return report, err
+			}
+			newCRD = crd
+			oldCRD, err = o.opClient.ApiextensionsInterface().ApiextensionsV1beta1().CustomResourceDefinitions().Get(context.TODO(), crd.GetName(), metav1.GetOptions{})
+			if k8serrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return report, fmt.Errorf("error getting CRD %s: %v", crd.GetName(), err)
+			}
+		default:
+			return report, fmt.Errorf("unsupported CRD version %s: %s", version, step.Resource.Name)
+		}
+
+		oldInternal, err := internalCRD(oldCRD)
+		if err != nil {
+			return report, err
+		}
+		newInternal, err := internalCRD(newCRD)
+		if err != nil {
+			return report, err
+		}
+		c, err := checkCRDCompatibility(o.dynamicClient, oldInternal, newInternal)
+		if err != nil {
+			return report, err
+		}
+		if c.IncompatibleObjectCount > 0 || len(c.RemovedStoredVersions) > 0 || c.StorageMigration != nil {
+			report.CRDs = append(report.CRDs, c)
+		}
+	}
+	return report, nil
+}
+
+// setCRDCompatibility records a CRD compatibility report on a plan.
+func setCRDCompatibility(plan *v1alpha1.InstallPlan, report CRDCompatibilityReport, now *metav1.Time) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	annotations := make(map[string]string, len(plan.GetAnnotations())+1)
+	for k, v := range plan.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[CRDCompatibilityReportAnnotationKey] = string(data)
+	plan.SetAnnotations(annotations)
+
+	status, reason := corev1.ConditionTrue, InstallPlanReasonCRDsCompatible
+	if !report.compatible() {
+		status, reason = corev1.ConditionFalse, InstallPlanReasonCRDsIncompatible
+	}
+	plan.Status.SetCondition(v1alpha1.InstallPlanCondition{
+		Type:               InstallPlanCRDCompatibility,
+		Status:             status,
+		Reason:             reason,
+		Message:            report.String(),
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	})
+	return nil
+}
+
+// hasCRDCompatibility returns true if a CRD compatibility report has been recorded on a plan.
+func hasCRDCompatibility(plan *v1alpha1.InstallPlan) bool {
+	for _, cond := range plan.Status.Conditions {
+		if cond.Type == InstallPlanCRDCompatibility {
+			return true
+		}
+	}
+	return false
+}
+
+// preflightCRDCompatibility records the CRD compatibility report of a plan that awaits approval, so that breaking CRD
+// changes can be seen before the plan is approved.
+func (o *Operator) preflightCRDCompatibility(plan *v1alpha1.InstallPlan, logger logrus.FieldLogger) error {
+	out := plan.DeepCopy()
+	r := newManifestResolver(plan.GetNamespace(), o.lister.CoreV1().ConfigMapLister(), o.logger)
+	report, err := o.crdCompatibilityReport(out, r)
+	if err != nil {
+		return err
+	}
+	now := o.now()
+	if err := setCRDCompatibility(out, report, &now); err != nil {
+		return err
+	}
+	if _, err := o.client.OperatorsV1alpha1().InstallPlans(plan.GetNamespace()).UpdateStatus(context.TODO(), out, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating installplan crd compatibility condition: %v", err)
+	}
+	if err := o.patchInstallPlanAnnotations(plan, out, CRDCompatibilityReportAnnotationKey); err != nil {
+		return fmt.Errorf("error recording installplan crd compatibility report: %v", err)
+	}
+	logger.WithField("report", report.String()).Debug("recorded crd compatibility report")
+	return nil
+}
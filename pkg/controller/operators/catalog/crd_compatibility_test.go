@@ -0,0 +1,219 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+func widgetCRD(storedVersions []string, versions ...apiextensionsv1.CustomResourceDefinitionVersion) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group:    "example.com",
+			Names:    apiextensionsv1.CustomResourceDefinitionNames{Plural: "widgets", Kind: "Widget"},
+			Scope:    apiextensionsv1.NamespaceScoped,
+			Versions: versions,
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+	}
+}
+
+func widgetVersion(name string, storage bool, required ...string) apiextensionsv1.CustomResourceDefinitionVersion {
+	return apiextensionsv1.CustomResourceDefinitionVersion{
+		Name:    name,
+		Served:  true,
+		Storage: storage,
+		Schema: &apiextensionsv1.CustomResourceValidation{
+			OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+				Type: "object",
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"spec": {
+						Type:     "object",
+						Required: required,
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"size":  {Type: "integer"},
+							"color": {Type: "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func widget(version, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	u.SetAPIVersion("example.com/" + version)
+	u.SetKind("Widget")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func widgetClient(objs ...runtime.Object) *fakedynamic.FakeDynamicClient {
+	return fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "example.com", Version: "v1alpha1", Resource: "widgets"}: "WidgetList",
+		{Group: "example.com", Version: "v1", Resource: "widgets"}:       "WidgetList",
+	}, objs...)
+}
+
+func TestCheckCRDCompatibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		oldCRD       *apiextensionsv1.CustomResourceDefinition
+		newCRD       *apiextensionsv1.CustomResourceDefinition
+		objs         []runtime.Object
+		want         CRDCompatibility
+		incompatible bool
+	}{
+		{
+			name:   "Unchanged",
+			oldCRD: widgetCRD([]string{"v1"}, widgetVersion("v1", true)),
+			newCRD: widgetCRD(nil, widgetVersion("v1", true)),
+			objs:   []runtime.Object{widget("v1", "ns", "a", nil)},
+			want:   CRDCompatibility{Name: "widgets.example.com"},
+		},
+		{
+			name:   "IncompatibleObjects",
+			oldCRD: widgetCRD([]string{"v1"}, widgetVersion("v1alpha1", false), widgetVersion("v1", true)),
+			newCRD: widgetCRD(nil, widgetVersion("v1alpha1", false), widgetVersion("v1", true, "color")),
+			objs: []runtime.Object{
+				widget("v1", "ns", "a", map[string]interface{}{"size": int64(1)}),
+				widget("v1", "ns", "b", map[string]interface{}{"color": "red"}),
+			},
+			want: CRDCompatibility{
+				Name:                    "widgets.example.com",
+				IncompatibleObjectCount: 1,
+				IncompatibleObjects: []IncompatibleObject{
+					{Version: "v1", Namespace: "ns", Name: "a", Errors: []FieldError{{Field: "spec.color", Message: "Required value"}}},
+				},
+			},
+			incompatible: true,
+		},
+		{
+			name:   "IncompatibleInSeveralVersions",
+			oldCRD: widgetCRD([]string{"v1"}, widgetVersion("v1alpha1", false), widgetVersion("v1", true)),
+			newCRD: widgetCRD(nil, widgetVersion("v1alpha1", false, "color"), widgetVersion("v1", true, "color")),
+			objs: []runtime.Object{
+				widget("v1alpha1", "ns", "a", map[string]interface{}{"size": int64(1)}),
+				widget("v1", "ns", "a", map[string]interface{}{"size": int64(1)}),
+			},
+			want: CRDCompatibility{
+				Name:                    "widgets.example.com",
+				IncompatibleObjectCount: 1,
+				IncompatibleObjects: []IncompatibleObject{
+					{Version: "v1alpha1", Namespace: "ns", Name: "a", Errors: []FieldError{{Field: "spec.color", Message: "Required value"}}},
+				},
+			},
+			incompatible: true,
+		},
+		{
+			name:   "RemovedStoredVersion",
+			oldCRD: widgetCRD([]string{"v1alpha1", "v1"}, widgetVersion("v1alpha1", false), widgetVersion("v1", true)),
+			newCRD: widgetCRD(nil, widgetVersion("v1", true)),
+			objs:   []runtime.Object{widget("v1alpha1", "ns", "a", nil)},
+			want: CRDCompatibility{
+				Name:                  "widgets.example.com",
+				RemovedStoredVersions: []string{"v1alpha1"},
+				StorageMigration:      &StorageMigration{From: []string{"v1alpha1"}, To: "v1"},
+			},
+			incompatible: true,
+		},
+		{
+			name:   "AutomaticMigration",
+			oldCRD: widgetCRD([]string{"v1alpha1", "v1"}, widgetVersion("v1alpha1", false), widgetVersion("v1", true)),
+			newCRD: func() *apiextensionsv1.CustomResourceDefinition {
+				crd := widgetCRD(nil, widgetVersion("v1", true))
+				crd.SetAnnotations(map[string]string{StorageVersionMigrationAnnotationKey: "true"})
+				return crd
+			}(),
+			want: CRDCompatibility{
+				Name:                  "widgets.example.com",
+				RemovedStoredVersions: []string{"v1alpha1"},
+				StorageMigration:      &StorageMigration{From: []string{"v1alpha1"}, To: "v1", Automatic: true},
+			},
+		},
+		{
+			name:   "StorageMigration",
+			oldCRD: widgetCRD([]string{"v1alpha1"}, widgetVersion("v1alpha1", true), widgetVersion("v1", false)),
+			newCRD: widgetCRD(nil, widgetVersion("v1alpha1", false), widgetVersion("v1", true)),
+			objs:   []runtime.Object{widget("v1alpha1", "ns", "a", nil)},
+			want: CRDCompatibility{
+				Name:             "widgets.example.com",
+				StorageMigration: &StorageMigration{From: []string{"v1alpha1"}, To: "v1"},
+			},
+		},
+		{
+			name:   "NoObjectsToMigrate",
+			oldCRD: widgetCRD([]string{"v1alpha1"}, widgetVersion("v1alpha1", true), widgetVersion("v1", false)),
+			newCRD: widgetCRD(nil, widgetVersion("v1alpha1", false), widgetVersion("v1", true)),
+			want:   CRDCompatibility{Name: "widgets.example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldCRD, err := internalCRD(tt.oldCRD)
+			require.NoError(t, err)
+			newCRD, err := internalCRD(tt.newCRD)
+			require.NoError(t, err)
+
+			got, err := checkCRDCompatibility(widgetClient(tt.objs...), oldCRD, newCRD)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, !tt.incompatible, CRDCompatibilityReport{CRDs: []CRDCompatibility{got}}.compatible())
+		})
+	}
+}
+
+func TestPreflightCRDCompatibility(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	oldCRD := widgetCRD([]string{"v1"}, widgetVersion("v1", true))
+	newCRD := widgetCRD(nil, widgetVersion("v1", true, "color"))
+	manifest, err := json.Marshal(newCRD)
+	require.NoError(t, err)
+
+	plan := installPlan("p", "ns", v1alpha1.InstallPlanPhaseRequiresApproval, "csv")
+	plan.Status.Plan = []*v1alpha1.Step{{
+		Resolving: "csv",
+		Resource:  v1alpha1.StepResource{Kind: crdKind, Name: newCRD.GetName(), Manifest: string(manifest)},
+		Status:    v1alpha1.StepStatusUnknown,
+	}}
+	require.False(t, hasCRDCompatibility(plan))
+
+	op, err := NewFakeOperator(ctx, "ns", []string{"ns"}, withClientObjs(plan), withExtObjs(oldCRD))
+	require.NoError(t, err)
+	op.dynamicClient = widgetClient(widget("v1", "ns", "a", map[string]interface{}{"size": int64(1)}))
+
+	require.NoError(t, op.preflightCRDCompatibility(plan, logrus.New()))
+
+	out, err := op.client.OperatorsV1alpha1().InstallPlans("ns").Get(ctx, "p", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, hasCRDCompatibility(out))
+	cond := out.Status.GetCondition(InstallPlanCRDCompatibility)
+	require.Equal(t, corev1.ConditionFalse, cond.Status)
+	require.Equal(t, InstallPlanReasonCRDsIncompatible, cond.Reason)
+	require.Equal(t, "widgets.example.com: 1 existing objects fail the new schema", cond.Message)
+
+	var report CRDCompatibilityReport
+	require.NoError(t, json.Unmarshal([]byte(out.GetAnnotations()[CRDCompatibilityReportAnnotationKey]), &report))
+	require.Len(t, report.CRDs, 1)
+	require.Equal(t, []IncompatibleObject{
+		{Version: "v1", Namespace: "ns", Name: "a", Errors: []FieldError{{Field: "spec.color", Message: "Required value"}}},
+	}, report.CRDs[0].IncompatibleObjects)
+}
@@ -0,0 +1,246 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+const (
+	// MaintenanceWindowAnnotationKey is the Subscription or OperatorGroup annotation holding the cron schedule, in
+	// the form "minute hour day-of-month month day-of-week", at which maintenance windows open. InstallPlans that
+	// would be approved automatically are only approved while a window is open.
+	MaintenanceWindowAnnotationKey = "olm.maintenance-window"
+
+	// MaintenanceWindowDurationAnnotationKey holds how long each maintenance window stays open, as a Go duration.
+	// Windows stay open for an hour by default.
+	MaintenanceWindowDurationAnnotationKey = "olm.maintenance-window.duration"
+
+	// MaintenanceWindowTimezoneAnnotationKey holds the IANA time zone in which the schedule is interpreted. The
+	// schedule is interpreted in UTC by default.
+	MaintenanceWindowTimezoneAnnotationKey = "olm.maintenance-window.timezone"
+
+	// NextMaintenanceWindowAnnotationKey is set on an InstallPlan that waits for a maintenance window to the time, in
+	// RFC 3339 format, at which the next window opens.
+	NextMaintenanceWindowAnnotationKey = "olm.maintenance-window.next"
+
+	// InstallPlanMaintenanceWindow is the condition set on an InstallPlan whose automatic approval is governed by a
+	// maintenance window.
+	InstallPlanMaintenanceWindow v1alpha1.InstallPlanConditionType = "MaintenanceWindow"
+
+	// InstallPlanReasonWaitingForWindow means that the plan will be approved when the next window opens.
+	InstallPlanReasonWaitingForWindow v1alpha1.InstallPlanConditionReason = "WaitingForMaintenanceWindow"
+
+	// InstallPlanReasonWindowOpen means that the plan was approved during an open window.
+	InstallPlanReasonWindowOpen v1alpha1.InstallPlanConditionReason = "MaintenanceWindowOpen"
+
+	// InstallPlanReasonInvalidWindow means that the plan's window policy can't be parsed, so it won't be approved
+	// automatically.
+	InstallPlanReasonInvalidWindow v1alpha1.InstallPlanConditionReason = "InvalidMaintenanceWindow"
+
+	defaultMaintenanceWindowDuration = time.Hour
+)
+
+var maintenanceWindowAnnotationKeys = []string{
+	MaintenanceWindowAnnotationKey,
+	MaintenanceWindowDurationAnnotationKey,
+	MaintenanceWindowTimezoneAnnotationKey,
+}
+
+// maintenanceWindow is a recurring period during which InstallPlans may be approved automatically.
+type maintenanceWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// parseMaintenanceWindow returns the window described by the given annotations, or nil if they describe none.
+func parseMaintenanceWindow(annotations map[string]string) (*maintenanceWindow, error) {
+	spec, ok := annotations[MaintenanceWindowAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance window schedule %q: %v", spec, err)
+	}
+	w := &maintenanceWindow{
+		schedule: schedule,
+		duration: defaultMaintenanceWindowDuration,
+		location: time.UTC,
+	}
+	if d, ok := annotations[MaintenanceWindowDurationAnnotationKey]; ok {
+		if w.duration, err = time.ParseDuration(d); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window duration %q: %v", d, err)
+		}
+		if w.duration <= 0 {
+			return nil, fmt.Errorf("invalid maintenance window duration %q: must be positive", d)
+		}
+	}
+	if tz, ok := annotations[MaintenanceWindowTimezoneAnnotationKey]; ok {
+		if w.location, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window timezone %q: %v", tz, err)
+		}
+	}
+	return w, nil
+}
+
+// open returns the start of the window that is open at the given time, if any.
+func (w *maintenanceWindow) open(now time.Time) (time.Time, bool) {
+	start := w.schedule.Next(now.In(w.location).Add(-w.duration))
+	if start.IsZero() || start.After(now) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// next returns the start of the first window that opens after the given time, or the zero time if none does.
+func (w *maintenanceWindow) next(now time.Time) time.Time {
+	return w.schedule.Next(now.In(w.location))
+}
+
+// maintenanceWindowAnnotations returns the window policy that governs the automatic approval of an InstallPlan for
+// the given Subscriptions. A policy on a Subscription takes precedence over a policy on the namespace's OperatorGroup.
+// If several Subscriptions declare a policy, the policy of the first by name applies.
+func (o *Operator) maintenanceWindowAnnotations(namespace string, subs []*v1alpha1.Subscription) (map[string]string, error) {
+	sorted := append([]*v1alpha1.Subscription(nil), subs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+	for _, sub := range sorted {
+		if policy := windowPolicy(sub.GetAnnotations()); policy != nil {
+			return policy, nil
+		}
+	}
+
+	groups, err := o.client.OperatorsV1().OperatorGroups(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(groups.Items) != 1 {
+		return nil, nil
+	}
+	return windowPolicy(groups.Items[0].GetAnnotations()), nil
+}
+
+func windowPolicy(annotations map[string]string) map[string]string {
+	if _, ok := annotations[MaintenanceWindowAnnotationKey]; !ok {
+		return nil
+	}
+	policy := make(map[string]string)
+	for _, key := range maintenanceWindowAnnotationKeys {
+		if v, ok := annotations[key]; ok {
+			policy[key] = v
+		}
+	}
+	return policy
+}
+
+// awaitingMaintenanceWindow returns true if the plan is approved automatically, but only during a maintenance window.
+func awaitingMaintenanceWindow(plan *v1alpha1.InstallPlan) bool {
+	if plan.Spec.Approval != v1alpha1.ApprovalAutomatic || plan.Spec.Approved || plan.Status.Phase != v1alpha1.InstallPlanPhaseRequiresApproval {
+		return false
+	}
+	_, ok := plan.GetAnnotations()[MaintenanceWindowAnnotationKey]
+	return ok
+}
+
+// syncMaintenanceWindow approves the plan if one of its maintenance windows is open, and otherwise records when the
+// next window opens and checks again then.
+func (o *Operator) syncMaintenanceWindow(plan *v1alpha1.InstallPlan, logger *logrus.Entry) error {
+	now := o.now()
+	window, err := parseMaintenanceWindow(plan.GetAnnotations())
+	if err != nil {
+		logger.WithError(err).Warn("invalid maintenance window")
+		return o.setMaintenanceWindowCond(plan, corev1.ConditionFalse, InstallPlanReasonInvalidWindow, err.Error())
+	}
+
+	if start, ok := window.open(now.Time); ok {
+		logger.WithField("window", start).Info("approving installplan in maintenance window")
+		out := plan.DeepCopy()
+		out.Spec.Approved = true
+		delete(out.Annotations, NextMaintenanceWindowAnnotationKey)
+		approved, err := o.client.OperatorsV1alpha1().InstallPlans(plan.GetNamespace()).Update(context.TODO(), out, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("error approving installplan in maintenance window: %v", err)
+		}
+		msg := fmt.Sprintf("approved in the maintenance window that opened at %s", start.Format(time.RFC3339))
+		return o.setMaintenanceWindowCond(approved, corev1.ConditionTrue, InstallPlanReasonWindowOpen, msg)
+	}
+
+	next := window.next(now.Time)
+	msg := "no maintenance window opens within five years"
+	if !next.IsZero() {
+		if err := o.ipQueueSet.RequeueAfter(plan.GetNamespace(), plan.GetName(), next.Sub(now.Time)); err != nil {
+			logger.WithError(err).Warn("error requeueing installplan for its next maintenance window")
+		}
+		msg = fmt.Sprintf("next maintenance window opens at %s", next.Format(time.RFC3339))
+	}
+	if err := o.setMaintenanceWindowCond(plan, corev1.ConditionFalse, InstallPlanReasonWaitingForWindow, msg); err != nil {
+		return err
+	}
+	return o.setNextMaintenanceWindow(plan, next)
+}
+
+// setNextMaintenanceWindow records when the next maintenance window of the plan opens, or removes the record if no
+// window opens.
+func (o *Operator) setNextMaintenanceWindow(plan *v1alpha1.InstallPlan, next time.Time) error {
+	var value interface{}
+	current, ok := plan.GetAnnotations()[NextMaintenanceWindowAnnotationKey]
+	if !next.IsZero() {
+		formatted := next.Format(time.RFC3339)
+		if ok && current == formatted {
+			return nil
+		}
+		value = formatted
+	} else if !ok {
+		return nil
+	}
+
+	// A merge patch leaves the rest of the plan, including the status that was just updated, untouched.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				NextMaintenanceWindowAnnotationKey: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := o.client.OperatorsV1alpha1().InstallPlans(plan.GetNamespace()).Patch(context.TODO(), plan.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("error recording next maintenance window: %v", err)
+	}
+	return nil
+}
+
+func (o *Operator) setMaintenanceWindowCond(plan *v1alpha1.InstallPlan, status corev1.ConditionStatus, reason v1alpha1.InstallPlanConditionReason, message string) error {
+	cond := plan.Status.GetCondition(InstallPlanMaintenanceWindow)
+	if cond.Status == status && cond.Reason == reason && cond.Message == message {
+		return nil
+	}
+	now := o.now()
+	out := plan.DeepCopy()
+	out.Status.SetCondition(v1alpha1.InstallPlanCondition{
+		Type:               InstallPlanMaintenanceWindow,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastUpdateTime:     &now,
+		LastTransitionTime: &now,
+	})
+	if _, err := o.client.OperatorsV1alpha1().InstallPlans(plan.GetNamespace()).UpdateStatus(context.TODO(), out, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating installplan maintenance window condition: %v", err)
+	}
+	return nil
+}
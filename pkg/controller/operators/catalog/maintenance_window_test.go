@@ -0,0 +1,245 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilclock "k8s.io/apimachinery/pkg/util/clock"
+
+	operatorsv1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/queueinformer"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	// 2021-03-06 is a Saturday.
+	saturday := time.Date(2021, 3, 6, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		now         time.Time
+		open        bool
+		next        time.Time
+		err         bool
+	}{
+		{
+			name: "NoWindow",
+		},
+		{
+			name:        "Open",
+			annotations: map[string]string{MaintenanceWindowAnnotationKey: "0 2 * * sat"},
+			now:         saturday.Add(2*time.Hour + 30*time.Minute),
+			open:        true,
+		},
+		{
+			name:        "ClosedAfterDefaultDuration",
+			annotations: map[string]string{MaintenanceWindowAnnotationKey: "0 2 * * sat"},
+			now:         saturday.Add(3 * time.Hour),
+			next:        saturday.Add(7*24*time.Hour + 2*time.Hour),
+		},
+		{
+			name: "OpenWithDuration",
+			annotations: map[string]string{
+				MaintenanceWindowAnnotationKey:         "0 2 * * sat",
+				MaintenanceWindowDurationAnnotationKey: "4h",
+			},
+			now:  saturday.Add(5 * time.Hour),
+			open: true,
+		},
+		{
+			name: "Timezone",
+			annotations: map[string]string{
+				MaintenanceWindowAnnotationKey:         "0 2 * * sat",
+				MaintenanceWindowTimezoneAnnotationKey: "Etc/GMT-3",
+			},
+			now:  saturday.Add(-2 * time.Hour),
+			next: saturday.Add(-time.Hour),
+		},
+		{
+			name: "InvalidDuration",
+			annotations: map[string]string{
+				MaintenanceWindowAnnotationKey:         "0 2 * * sat",
+				MaintenanceWindowDurationAnnotationKey: "-1h",
+			},
+			err: true,
+		},
+		{
+			name:        "InvalidSchedule",
+			annotations: map[string]string{MaintenanceWindowAnnotationKey: "0 2 * *"},
+			err:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := parseMaintenanceWindow(tt.annotations)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.annotations == nil {
+				require.Nil(t, w)
+				return
+			}
+
+			_, open := w.open(tt.now)
+			require.Equal(t, tt.open, open)
+			if !tt.open && !tt.next.IsZero() {
+				require.True(t, tt.next.Equal(w.next(tt.now)), "expected %s, got %s", tt.next, w.next(tt.now))
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowAnnotations(t *testing.T) {
+	namespace := "ns"
+	subWindow := map[string]string{MaintenanceWindowAnnotationKey: "0 2 * * *", "unrelated": "value"}
+	groupWindow := map[string]string{MaintenanceWindowAnnotationKey: "0 4 * * *", MaintenanceWindowTimezoneAnnotationKey: "Europe/Berlin"}
+
+	sub := func(name string, annotations map[string]string) *v1alpha1.Subscription {
+		return &v1alpha1.Subscription{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations}}
+	}
+	group := &operatorsv1.OperatorGroup{ObjectMeta: metav1.ObjectMeta{Name: "og", Namespace: namespace, Annotations: groupWindow}}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	op, err := NewFakeOperator(ctx, namespace, []string{namespace}, withClientObjs(group))
+	require.NoError(t, err)
+
+	policy, err := op.maintenanceWindowAnnotations(namespace, []*v1alpha1.Subscription{sub("b", nil), sub("a", subWindow)})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{MaintenanceWindowAnnotationKey: "0 2 * * *"}, policy)
+
+	policy, err = op.maintenanceWindowAnnotations(namespace, []*v1alpha1.Subscription{sub("a", nil)})
+	require.NoError(t, err)
+	require.Equal(t, groupWindow, policy)
+}
+
+func TestSyncMaintenanceWindow(t *testing.T) {
+	namespace := "ns"
+	// 2021-03-06 is a Saturday.
+	saturday := time.Date(2021, 3, 6, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		schedule string
+		approved bool
+		reason   v1alpha1.InstallPlanConditionReason
+		message  string
+		next     string
+	}{
+		{
+			name:     "Open",
+			now:      saturday.Add(2*time.Hour + 10*time.Minute),
+			schedule: "0 2 * * sat",
+			approved: true,
+			reason:   InstallPlanReasonWindowOpen,
+			message:  "approved in the maintenance window that opened at 2021-03-06T02:00:00Z",
+		},
+		{
+			name:     "Closed",
+			now:      saturday.Add(12 * time.Hour),
+			schedule: "0 2 * * sat",
+			reason:   InstallPlanReasonWaitingForWindow,
+			message:  "next maintenance window opens at 2021-03-13T02:00:00Z",
+			next:     "2021-03-13T02:00:00Z",
+		},
+		{
+			name:     "Invalid",
+			now:      saturday,
+			schedule: "whenever",
+			reason:   InstallPlanReasonInvalidWindow,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+
+			plan := installPlan("p", namespace, v1alpha1.InstallPlanPhaseRequiresApproval, "csv")
+			plan.Spec.Approval = v1alpha1.ApprovalAutomatic
+			plan.SetAnnotations(map[string]string{MaintenanceWindowAnnotationKey: tt.schedule, NextMaintenanceWindowAnnotationKey: "2021-03-06T02:00:00Z"})
+			require.True(t, awaitingMaintenanceWindow(plan))
+
+			op, err := NewFakeOperator(ctx, namespace, []string{namespace}, withClock(utilclock.NewFakeClock(tt.now)), withClientObjs(plan))
+			require.NoError(t, err)
+			op.ipQueueSet = queueinformer.NewEmptyResourceQueueSet()
+
+			require.NoError(t, op.syncMaintenanceWindow(plan, logrus.NewEntry(op.logger)))
+
+			out, err := op.client.OperatorsV1alpha1().InstallPlans(namespace).Get(context.TODO(), "p", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, tt.approved, out.Spec.Approved)
+			cond := out.Status.GetCondition(InstallPlanMaintenanceWindow)
+			require.Equal(t, tt.reason, cond.Reason)
+			if tt.message != "" {
+				require.Equal(t, tt.message, cond.Message)
+			}
+			if tt.approved {
+				require.Equal(t, corev1.ConditionTrue, cond.Status)
+			} else {
+				require.Equal(t, corev1.ConditionFalse, cond.Status)
+			}
+			if tt.next != "" {
+				require.Equal(t, tt.next, out.GetAnnotations()[NextMaintenanceWindowAnnotationKey])
+			} else if tt.approved {
+				require.NotContains(t, out.GetAnnotations(), NextMaintenanceWindowAnnotationKey)
+			}
+		})
+	}
+}
+
+func TestSyncInstallPlansPreparesPlansWaitingForMaintenanceWindow(t *testing.T) {
+	namespace := "ns"
+	// 2021-03-06 is a Saturday.
+	saturday := time.Date(2021, 3, 6, 0, 0, 0, 0, time.UTC)
+
+	plan := withSteps(installPlan("p", namespace, v1alpha1.InstallPlanPhaseRequiresApproval, "csv"),
+		[]*v1alpha1.Step{
+			{
+				Resource: v1alpha1.StepResource{
+					CatalogSource:          "catalog",
+					CatalogSourceNamespace: namespace,
+					Version:                "v1",
+					Kind:                   "ServiceAccount",
+					Name:                   "sa",
+					Manifest:               toManifest(t, serviceAccount("sa", namespace, "", nil)),
+				},
+				Status: v1alpha1.StepStatusUnknown,
+			},
+		},
+	)
+	plan.Spec.Approval = v1alpha1.ApprovalAutomatic
+	plan.SetAnnotations(map[string]string{MaintenanceWindowAnnotationKey: "0 2 * * sat"})
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	op, err := NewFakeOperator(ctx, namespace, []string{namespace},
+		withClock(utilclock.NewFakeClock(saturday.Add(12*time.Hour))),
+		withClientObjs(plan, operatorGroup("og", "", namespace, nil)),
+	)
+	require.NoError(t, err)
+	op.ipQueueSet = queueinformer.NewEmptyResourceQueueSet()
+
+	// The CRD compatibility preflight and the step diff run before the plan waits for its window.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, op.syncInstallPlans(plan))
+		plan, err = op.client.OperatorsV1alpha1().InstallPlans(namespace).Get(ctx, "p", metav1.GetOptions{})
+		require.NoError(t, err)
+	}
+
+	require.True(t, hasCRDCompatibility(plan))
+	_, err = op.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Get(ctx, stepDiffConfigMapName(plan), metav1.GetOptions{})
+	require.NoError(t, err)
+
+	require.False(t, plan.Spec.Approved)
+	require.Equal(t, v1alpha1.InstallPlanPhaseRequiresApproval, plan.Status.Phase)
+	require.Equal(t, InstallPlanReasonWaitingForWindow, plan.Status.GetCondition(InstallPlanMaintenanceWindow).Reason)
+	require.Equal(t, "2021-03-13T02:00:00Z", plan.GetAnnotations()[NextMaintenanceWindowAnnotationKey])
+}
@@ -3,6 +3,7 @@ package catalog
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
 	"github.com/operator-framework/operator-registry/pkg/configmap"
@@ -23,6 +24,7 @@ type ManifestResolver interface {
 // manifestResolver caches manifest from unpacked bundles (via configmaps)
 type manifestResolver struct {
 	configMapLister v1.ConfigMapLister
+	mu              sync.Mutex
 	unpackedSteps   map[string][]v1alpha1.StepResource
 	namespace       string
 	logger          logrus.FieldLogger
@@ -73,6 +75,8 @@ func (r *manifestResolver) ManifestForStep(step *v1alpha1.Step) (string, error)
 }
 
 func (r *manifestResolver) unpackedStepsForBundle(bundleName string, ref *UnpackedBundleReference) ([]v1alpha1.StepResource, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	usteps, ok := r.unpackedSteps[bundleName]
 	if ok {
 		return usteps, nil
@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,20 +15,15 @@ import (
 	"google.golang.org/grpc/connectivity"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
-	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
-	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
-	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
-	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
 	extinf "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	utilclock "k8s.io/apimachinery/pkg/util/clock"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
@@ -86,36 +80,46 @@ const (
 type Operator struct {
 	queueinformer.Operator
 
-	logger                   *logrus.Logger
-	clock                    utilclock.Clock
-	opClient                 operatorclient.ClientInterface
-	client                   versioned.Interface
-	dynamicClient            dynamic.Interface
-	lister                   operatorlister.OperatorLister
-	catsrcQueueSet           *queueinformer.ResourceQueueSet
-	subQueueSet              *queueinformer.ResourceQueueSet
-	ipQueueSet               *queueinformer.ResourceQueueSet
-	nsResolveQueue           workqueue.RateLimitingInterface
-	namespace                string
-	recorder                 record.EventRecorder
-	sources                  *grpc.SourceStore
-	sourcesLastUpdate        sharedtime.SharedTime
-	resolver                 resolver.StepResolver
-	reconciler               reconciler.RegistryReconcilerFactory
-	csvProvidedAPIsIndexer   map[string]cache.Indexer
-	catalogSubscriberIndexer map[string]cache.Indexer
-	clientAttenuator         *scoped.ClientAttenuator
-	serviceAccountQuerier    *scoped.UserDefinedServiceAccountQuerier
-	bundleUnpacker           bundle.Unpacker
-	installPlanTimeout       time.Duration
-	bundleUnpackTimeout      time.Duration
-	clientFactory            clients.Factory
+	logger                     *logrus.Logger
+	clock                      utilclock.Clock
+	opClient                   operatorclient.ClientInterface
+	client                     versioned.Interface
+	dynamicClient              dynamic.Interface
+	lister                     operatorlister.OperatorLister
+	catsrcQueueSet             *queueinformer.ResourceQueueSet
+	subQueueSet                *queueinformer.ResourceQueueSet
+	ipQueueSet                 *queueinformer.ResourceQueueSet
+	nsResolveQueue             workqueue.RateLimitingInterface
+	namespace                  string
+	recorder                   record.EventRecorder
+	sources                    *grpc.SourceStore
+	sourcesLastUpdate          sharedtime.SharedTime
+	resolver                   resolver.StepResolver
+	previewer                  resolver.StepPreviewer
+	clusterWideResolution      bool
+	reconciler                 reconciler.RegistryReconcilerFactory
+	csvProvidedAPIsIndexer     map[string]cache.Indexer
+	catalogSubscriberIndexer   map[string]cache.Indexer
+	clientAttenuator           *scoped.ClientAttenuator
+	serviceAccountQuerier      *scoped.UserDefinedServiceAccountQuerier
+	bundleUnpacker             bundle.Unpacker
+	installPlanTimeout         time.Duration
+	installPlanRollbackTimeout time.Duration
+	installPlanStepParallelism int
+	installPlanRetention       InstallPlanRetentionPolicy
+	installPlanValidators      []namedValidator
+	bundleUnpackTimeout        time.Duration
+	clientFactory              clients.Factory
+	resolutionPolicies         resolutionPolicies
 }
 
 type CatalogSourceSyncFunc func(logger *logrus.Entry, in *v1alpha1.CatalogSource) (out *v1alpha1.CatalogSource, continueSync bool, syncError error)
 
 // NewOperator creates a new Catalog Operator.
-func NewOperator(ctx context.Context, kubeconfigPath string, clock utilclock.Clock, logger *logrus.Logger, resync time.Duration, configmapRegistryImage, opmImage, utilImage string, operatorNamespace string, scheme *runtime.Scheme, installPlanTimeout time.Duration, bundleUnpackTimeout time.Duration) (*Operator, error) {
+func NewOperator(ctx context.Context, kubeconfigPath string, clock utilclock.Clock, logger *logrus.Logger, resync time.Duration, configmapRegistryImage, opmImage, utilImage string, operatorNamespace string, scheme *runtime.Scheme, installPlanTimeout time.Duration, bundleUnpackTimeout time.Duration, options ...OperatorOption) (*Operator, error) {
+	operatorConfig := defaultOperatorConfig()
+	operatorConfig.apply(options)
+
 	resyncPeriod := queueinformer.ResyncWithJitter(resync, 0.2)
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
 	if err != nil {
@@ -185,6 +189,18 @@ func NewOperator(ctx context.Context, kubeconfigPath string, clock utilclock.Clo
 	op.reconciler = reconciler.NewRegistryReconcilerFactory(lister, opClient, configmapRegistryImage, op.now, ssaClient)
 	res := resolver.NewOperatorStepResolver(lister, crClient, opClient.KubernetesInterface(), operatorNamespace, op.sources, logger)
 	op.resolver = resolver.NewInstrumentedResolver(res, metrics.RegisterDependencyResolutionSuccess, metrics.RegisterDependencyResolutionFailure)
+	op.previewer = res
+	if operatorConfig.resolutionTraceLimit > 0 {
+		res.RecordResolutionTraces(resolver.NewConfigMapTraceRecorder(opClient.KubernetesInterface(), lister.CoreV1().ConfigMapLister()), operatorConfig.resolutionTraceLimit)
+	}
+	if operatorConfig.clusterWideResolution {
+		res.EnableClusterWideResolution()
+		op.clusterWideResolution = true
+	}
+	op.installPlanRollbackTimeout = operatorConfig.installPlanRollbackTimeout
+	op.installPlanStepParallelism = operatorConfig.installPlanStepParallelism
+	op.installPlanRetention = operatorConfig.installPlanRetention
+	op.installPlanValidators = operatorConfig.installPlanValidators
 
 	// Wire OLM CR sharedIndexInformers
 	crInformerFactory := externalversions.NewSharedInformerFactoryWithOptions(op.client, resyncPeriod())
@@ -473,6 +489,7 @@ func (o *Operator) syncObject(obj interface{}) (syncError error) {
 	}
 
 	o.requeueOwners(metaObj)
+	o.requeueForResolutionPolicy(metaObj, false)
 
 	return o.triggerInstallPlanRetry(obj)
 }
@@ -499,6 +516,7 @@ func (o *Operator) handleDeletion(obj interface{}) {
 	}).Debug("handling object deletion")
 
 	o.requeueOwners(metaObj)
+	o.requeueForResolutionPolicy(metaObj, true)
 
 	return
 }
@@ -898,7 +916,7 @@ func (o *Operator) syncResolvingNamespace(obj interface{}) error {
 	logger.Debug("resolving subscriptions in namespace")
 
 	// resolve a set of steps to apply to a cluster, a set of subscriptions to create/update, and any errors
-	steps, bundleLookups, updatedSubs, err := o.resolver.ResolveSteps(namespace, querier)
+	steps, bundleLookups, updatedSubs, dependencies, err := o.resolver.ResolveSteps(namespace, querier)
 	if err != nil {
 		go o.recorder.Event(ns, corev1.EventTypeWarning, "ResolutionFailed", err.Error())
 		// If the error is constraints not satisfiable, then simply project the
@@ -907,8 +925,13 @@ func (o *Operator) syncResolvingNamespace(obj interface{}) error {
 		// given not-satisfiable error is terminal and most likely require intervention
 		// from users/admins. Resyncing the namespace again is unlikely to resolve
 		// not-satisfiable error
-		if _, ok := err.(solver.NotSatisfiable); ok {
+		if ns, ok := err.(solver.NotSatisfiable); ok {
 			logger.WithError(err).Debug("resolution failed")
+			explanation := resolver.ExplainNotSatisfiable(ns)
+			if err := o.setSubscriptionResolutionConditions(namespace, subs, &explanation); err != nil {
+				logger.WithError(err).Debug("error recording resolution failure on subscriptions")
+				return err
+			}
 			return nil
 		}
 		return err
@@ -928,29 +951,37 @@ func (o *Operator) syncResolvingNamespace(obj interface{}) error {
 			}
 		}
 
-		// any subscription in the namespace with manual approval will force generated installplans to be manual
-		// TODO: this is an odd artifact of the older resolver, and will probably confuse users. approval mode could be on the operatorgroup?
-		installPlanApproval := v1alpha1.ApprovalAutomatic
-		for _, sub := range subs {
-			if sub.Spec.InstallPlanApproval == v1alpha1.ApprovalManual {
-				installPlanApproval = v1alpha1.ApprovalManual
-				break
-			}
-		}
-
-		installPlanReference, err := o.ensureInstallPlan(logger, namespace, maxGeneration+1, subs, installPlanApproval, steps, bundleLookups)
+		// generate separate installplans for subscriptions with automatic and manual approval, so that manual
+		// subscriptions don't hold back automatic ones
+		groups, err := splitByApproval(subs, updatedSubs, steps, bundleLookups, dependencies)
 		if err != nil {
-			logger.WithError(err).Debug("error ensuring installplan")
 			return err
 		}
-		if err := o.updateSubscriptionStatus(namespace, maxGeneration+1, updatedSubs, installPlanReference); err != nil {
-			logger.WithError(err).Debug("error ensuring subscription installplan state")
-			return err
+		gen := maxGeneration
+		for _, g := range groups {
+			var installPlanReference *corev1.ObjectReference
+			if len(g.steps) > 0 || len(g.bundleLookups) > 0 {
+				gen++
+				installPlanReference, err = o.ensureInstallPlan(logger, namespace, gen, g.subs, g.approval, g.steps, g.bundleLookups)
+				if err != nil {
+					logger.WithError(err).Debug("error ensuring installplan")
+					return err
+				}
+			}
+			if err := o.updateSubscriptionStatus(namespace, gen, g.updated, installPlanReference); err != nil {
+				logger.WithError(err).Debug("error ensuring subscription installplan state")
+				return err
+			}
 		}
 	} else {
 		logger.Debugf("no subscriptions were updated")
 	}
 
+	if err := o.setSubscriptionResolutionConditions(namespace, subs, nil); err != nil {
+		logger.WithError(err).Debug("error clearing resolution failures from subscriptions")
+		return err
+	}
+
 	return nil
 }
 
@@ -1035,7 +1066,14 @@ func (o *Operator) ensureSubscriptionCSVState(logger *logrus.Entry, sub *v1alpha
 		if err := querier.Queryable(); err != nil {
 			return nil, false, err
 		}
-		b, _, _ := querier.FindReplacement(&csv.Spec.Version.Version, sub.Status.CurrentCSV, sub.Spec.Package, sub.Spec.Channel, registry.CatalogKey{Name: sub.Spec.CatalogSource, Namespace: sub.Spec.CatalogSourceNamespace})
+		catalog := registry.CatalogKey{Name: sub.Spec.CatalogSource, Namespace: sub.Spec.CatalogSourceNamespace}
+		b, _, _ := querier.FindReplacement(&csv.Spec.Version.Version, sub.Status.CurrentCSV, sub.Spec.Package, sub.Spec.Channel, catalog)
+		if b != nil {
+			if inRange, err := resolver.BundleInVersionRange(sub, b); err != nil || !inRange {
+				o.logger.Tracef("replacement %s bundle found for current bundle %s is outside the subscription's version range", b.CsvName, sub.Status.CurrentCSV)
+				b = nil
+			}
+		}
 		if b != nil {
 			o.logger.Tracef("replacement %s bundle found for current bundle %s", b.CsvName, sub.Status.CurrentCSV)
 			out.Status.State = v1alpha1.SubscriptionStateUpgradeAvailable
@@ -1044,9 +1082,11 @@ func (o *Operator) ensureSubscriptionCSVState(logger *logrus.Entry, sub *v1alpha
 		}
 
 		out.Status.InstalledCSV = sub.Status.CurrentCSV
+
+		o.ensureVersionRangeCondition(out, querier, catalog)
 	}
 
-	if sub.Status.State == out.Status.State {
+	if sub.Status.State == out.Status.State && reflect.DeepEqual(sub.Status.Conditions, out.Status.Conditions) {
 		// The subscription status represents the cluster state
 		return sub, false, nil
 	}
@@ -1063,6 +1103,80 @@ func (o *Operator) ensureSubscriptionCSVState(logger *logrus.Entry, sub *v1alpha
 	return updatedSub, true, nil
 }
 
+// setSubscriptionResolutionConditions records the outcome of a namespace resolution on its subscriptions. If the
+// resolution failed, every subscription the explanation involves is given a ResolutionFailed condition (or every
+// subscription, if the conflict involves none in particular); otherwise stale ResolutionFailed conditions are removed.
+func (o *Operator) setSubscriptionResolutionConditions(namespace string, subs []*v1alpha1.Subscription, explanation *resolver.ResolutionExplanation) error {
+	var (
+		errs    []error
+		getOpts = metav1.GetOptions{}
+		now     = o.now()
+	)
+	for _, sub := range subs {
+		var cond *v1alpha1.SubscriptionCondition
+		if explanation != nil && (len(explanation.Subscriptions) == 0 || explanation.Involves(sub.GetName())) {
+			c := explanation.Condition(&now)
+			cond = &c
+		}
+
+		existing := sub.Status.GetCondition(resolver.SubscriptionResolutionFailed)
+		if cond == nil && existing.Status == corev1.ConditionUnknown {
+			// No condition to remove
+			continue
+		}
+		if cond != nil && cond.Equals(existing) {
+			// Nothing to do
+			continue
+		}
+
+		update := func() error {
+			latest, err := o.client.OperatorsV1alpha1().Subscriptions(namespace).Get(context.TODO(), sub.GetName(), getOpts)
+			if err != nil {
+				return err
+			}
+			if cond == nil {
+				latest.Status.RemoveConditions(resolver.SubscriptionResolutionFailed)
+			} else {
+				latest.Status.SetCondition(*cond)
+			}
+			_, err = o.client.OperatorsV1alpha1().Subscriptions(namespace).UpdateStatus(context.TODO(), latest, metav1.UpdateOptions{})
+			return err
+		}
+		if err := retry.RetryOnConflict(retry.DefaultRetry, update); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// ensureVersionRangeCondition sets a VersionRangeExceeded condition on a subscription whose channel head is outside
+// of its version range, and removes the condition otherwise.
+func (o *Operator) ensureVersionRangeCondition(sub *v1alpha1.Subscription, querier resolver.SourceQuerier, catalog registry.CatalogKey) {
+	if _, ok, err := resolver.SubscriptionVersionRange(sub); err != nil || !ok {
+		// invalid ranges are reported by resolution
+		sub.Status.RemoveConditions(resolver.SubscriptionVersionRangeExceeded)
+		return
+	}
+
+	head, _, err := querier.FindLatestBundle(sub.Spec.Package, sub.Spec.Channel, catalog)
+	if err != nil || head == nil {
+		// leave the condition as-is until the catalog can be queried
+		return
+	}
+	if inRange, _ := resolver.BundleInVersionRange(sub, head); inRange {
+		sub.Status.RemoveConditions(resolver.SubscriptionVersionRangeExceeded)
+		return
+	}
+
+	now := o.now()
+	cond := resolver.VersionRangeExceededCondition(sub, head, &now)
+	if existing := sub.Status.GetCondition(resolver.SubscriptionVersionRangeExceeded); existing.Equals(cond) {
+		return
+	}
+	sub.Status.SetCondition(cond)
+}
+
 func (o *Operator) updateSubscriptionStatus(namespace string, gen int, subs []*v1alpha1.Subscription, installPlanRef *corev1.ObjectReference) error {
 	var (
 		errs        []error
@@ -1166,6 +1280,19 @@ func (o *Operator) createInstallPlan(namespace string, gen int, subs []*v1alpha1
 			Generation:                 gen,
 		},
 	}
+
+	// Automatic approval waits for a maintenance window, if one is declared.
+	if installPlanApproval == v1alpha1.ApprovalAutomatic {
+		window, err := o.maintenanceWindowAnnotations(namespace, subs)
+		if err != nil {
+			return nil, err
+		}
+		if window != nil {
+			ip.SetAnnotations(window)
+			ip.Spec.Approved = false
+			phase = v1alpha1.InstallPlanPhaseRequiresApproval
+		}
+	}
 	for _, sub := range subs {
 		ownerutil.AddNonBlockingOwner(ip, sub)
 	}
@@ -1288,77 +1415,6 @@ func (o *Operator) unpackBundles(plan *v1alpha1.InstallPlan) (bool, *v1alpha1.In
 	return unpacked, out, nil
 }
 
-// gcInstallPlans garbage collects installplans that are too old
-// installplans are ownerrefd to all subscription inputs, so they will not otherwise
-// be GCd unless all inputs have been deleted.
-func (o *Operator) gcInstallPlans(log logrus.FieldLogger, namespace string) {
-	allIps, err := o.lister.OperatorsV1alpha1().InstallPlanLister().InstallPlans(namespace).List(labels.Everything())
-	if err != nil {
-		log.Warn("unable to list installplans for GC")
-	}
-
-	if len(allIps) <= maxInstallPlanCount {
-		return
-	}
-
-	// we only consider maxDeletesPerSweep more than the allowed number of installplans for delete at one time
-	ips := allIps
-	if len(ips) > maxInstallPlanCount+maxDeletesPerSweep {
-		ips = allIps[:maxInstallPlanCount+maxDeletesPerSweep]
-	}
-
-	byGen := map[int][]*v1alpha1.InstallPlan{}
-	for _, ip := range ips {
-		gen, ok := byGen[ip.Spec.Generation]
-		if !ok {
-			gen = make([]*v1alpha1.InstallPlan, 0)
-		}
-		byGen[ip.Spec.Generation] = append(gen, ip)
-	}
-
-	gens := make([]int, 0)
-	for i := range byGen {
-		gens = append(gens, i)
-	}
-
-	sort.Ints(gens)
-
-	toDelete := make([]*v1alpha1.InstallPlan, 0)
-
-	for _, i := range gens {
-		g := byGen[i]
-
-		if len(ips)-len(toDelete) <= maxInstallPlanCount {
-			break
-		}
-
-		// if removing all installplans at this generation doesn't dip below the max, safe to delete all of them
-		if len(ips)-len(toDelete)-len(g) >= maxInstallPlanCount {
-			toDelete = append(toDelete, g...)
-			continue
-		}
-
-		// CreationTimestamp sorting shouldn't ever be hit unless there is a bug that causes installplans to be
-		// generated without bumping the generation. It is here as a safeguard only.
-
-		// sort by creation time
-		sort.Slice(g, func(i, j int) bool {
-			if !g[i].CreationTimestamp.Equal(&g[j].CreationTimestamp) {
-				return g[i].CreationTimestamp.Before(&g[j].CreationTimestamp)
-			}
-			// final fallback to lexicographic sort, in case many installplans are created with the same timestamp
-			return g[i].GetName() < g[j].GetName()
-		})
-		toDelete = append(toDelete, g[:len(ips)-len(toDelete)-maxInstallPlanCount]...)
-	}
-
-	for _, i := range toDelete {
-		if err := o.client.OperatorsV1alpha1().InstallPlans(namespace).Delete(context.TODO(), i.GetName(), metav1.DeleteOptions{}); err != nil {
-			log.WithField("deleting", i.GetName()).WithError(err).Warn("error GCing old installplan - may have already been deleted")
-		}
-	}
-}
-
 func (o *Operator) syncInstallPlans(obj interface{}) (syncError error) {
 	plan, ok := obj.(*v1alpha1.InstallPlan)
 	if !ok {
@@ -1380,8 +1436,9 @@ func (o *Operator) syncInstallPlans(obj interface{}) (syncError error) {
 		return
 	}
 
-	// Complete and Failed are terminal phases
+	// Complete and Failed are terminal phases, after which a plan may only be rolled back
 	if plan.Status.Phase == v1alpha1.InstallPlanPhaseFailed || plan.Status.Phase == v1alpha1.InstallPlanPhaseComplete {
+		syncError = o.syncInstallPlanRollback(plan, logger)
 		return
 	}
 
@@ -1485,6 +1542,32 @@ func (o *Operator) syncInstallPlans(obj interface{}) (syncError error) {
 		}
 	}
 
+	if plan.Status.Phase == v1alpha1.InstallPlanPhaseRequiresApproval && !plan.Spec.Approved && !hasCRDCompatibility(plan) {
+		if err := o.preflightCRDCompatibility(plan, logger); err != nil {
+			logger.WithError(err).Warn("error checking crd compatibility before approval")
+		} else {
+			return
+		}
+	}
+
+	if plan.Status.Phase == v1alpha1.InstallPlanPhaseRequiresApproval && !plan.Spec.Approved && !o.hasStepDiff(plan) {
+		if err := o.recordStepDiff(plan, logger); err != nil {
+			logger.WithError(err).Warn("error recording installplan diff before approval")
+		}
+	}
+
+	if plan.Status.Phase == v1alpha1.InstallPlanPhaseInstalling && len(o.installPlanValidators) > 0 && !validated(plan) {
+		syncError = o.validateInstallPlan(plan, logger)
+		return
+	}
+
+	// A plan waiting for its maintenance window is prepared like any other plan awaiting approval, but isn't approved,
+	// and so doesn't start installing, until the window opens.
+	if awaitingMaintenanceWindow(plan) {
+		syncError = o.syncMaintenanceWindow(plan, logger)
+		return
+	}
+
 	outInstallPlan, syncError := transitionInstallPlanState(logger.Logger, o, *plan, o.now(), o.installPlanTimeout)
 
 	if syncError != nil {
@@ -1509,9 +1592,37 @@ func (o *Operator) syncInstallPlans(obj interface{}) (syncError error) {
 		syncError = fmt.Errorf("error transitioning InstallPlan: %s and error updating InstallPlan status: %s", syncError, updateErr)
 	}
 
+	if err := o.patchInstallPlanAnnotations(plan, outInstallPlan, StepDurationsAnnotationKey, CRDCompatibilityReportAnnotationKey); err != nil {
+		logger.WithError(err).Warn("error recording step durations and crd compatibility report")
+	}
+
 	return
 }
 
+// patchInstallPlanAnnotations writes the given annotations of out that differ from in, since annotations aren't
+// written by a status update.
+func (o *Operator) patchInstallPlanAnnotations(in, out *v1alpha1.InstallPlan, keys ...string) error {
+	changed := make(map[string]string)
+	for _, key := range keys {
+		if v, ok := out.GetAnnotations()[key]; ok && v != in.GetAnnotations()[key] {
+			changed[key] = v
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": changed,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = o.client.OperatorsV1alpha1().InstallPlans(in.GetNamespace()).Patch(context.TODO(), in.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
 func hasBundleLookupFailureCondition(plan *v1alpha1.InstallPlan) (bool, *v1alpha1.BundleLookupCondition) {
 	for _, bundleLookup := range plan.Status.BundleLookups {
 		for _, cond := range bundleLookup.Conditions {
@@ -1599,7 +1710,9 @@ func transitionInstallPlanState(log logrus.FieldLogger, transitioner installPlan
 		}
 		log.Debug("attempting to install")
 		if err := transitioner.ExecutePlan(out); err != nil {
-			if now.Sub(out.Status.StartTime.Time) >= timeout {
+			// Retrying can't make steps that depend on one another schedulable.
+			var unschedulable *unschedulableStepsError
+			if now.Sub(out.Status.StartTime.Time) >= timeout || errors.As(err, &unschedulable) {
 				out.Status.SetCondition(v1alpha1.ConditionFailed(v1alpha1.InstallPlanInstalled,
 					v1alpha1.InstallPlanReasonComponentFailed, err.Error(), &now))
 				out.Status.Phase = v1alpha1.InstallPlanPhaseFailed
@@ -1608,7 +1721,7 @@ func transitionInstallPlanState(log logrus.FieldLogger, transitioner installPlan
 				out.Status.Message = fmt.Sprintf("retrying execution due to error: %s", err.Error())
 			}
 			return out, err
-		} else if !out.Status.NeedsRequeue() {
+		} else if !out.Status.NeedsRequeue() && !migratingStorage(out.Status) {
 			// Loop over one final time to check and see if everything is good.
 			out.Status.SetCondition(v1alpha1.ConditionMet(v1alpha1.InstallPlanInstalled, &now))
 			out.Status.Phase = v1alpha1.InstallPlanPhaseComplete
@@ -1620,90 +1733,6 @@ func transitionInstallPlanState(log logrus.FieldLogger, transitioner installPlan
 	}
 }
 
-// Validate all existing served versions against new CRD's validation (if changed)
-func validateV1CRDCompatibility(dynamicClient dynamic.Interface, oldCRD *apiextensionsv1.CustomResourceDefinition, newCRD *apiextensionsv1.CustomResourceDefinition) error {
-	logrus.Debugf("Comparing %#v to %#v", oldCRD.Spec.Versions, newCRD.Spec.Versions)
-
-	// If validation schema is unchanged, return right away
-	newestSchema := newCRD.Spec.Versions[len(newCRD.Spec.Versions)-1].Schema
-	for i, oldVersion := range oldCRD.Spec.Versions {
-		if !reflect.DeepEqual(oldVersion.Schema, newestSchema) {
-			break
-		}
-		if i == len(oldCRD.Spec.Versions)-1 {
-			// we are on the last iteration
-			// schema has not changed between versions at this point.
-			return nil
-		}
-	}
-
-	convertedCRD := &apiextensions.CustomResourceDefinition{}
-	if err := apiextensionsv1.Convert_v1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(newCRD, convertedCRD, nil); err != nil {
-		return err
-	}
-	for _, version := range oldCRD.Spec.Versions {
-		if version.Served {
-			gvr := schema.GroupVersionResource{Group: oldCRD.Spec.Group, Version: version.Name, Resource: oldCRD.Spec.Names.Plural}
-			err := validateExistingCRs(dynamicClient, gvr, convertedCRD)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	logrus.Debugf("Successfully validated CRD %s\n", newCRD.Name)
-	return nil
-}
-
-// Validate all existing served versions against new CRD's validation (if changed)
-func validateV1Beta1CRDCompatibility(dynamicClient dynamic.Interface, oldCRD *apiextensionsv1beta1.CustomResourceDefinition, newCRD *apiextensionsv1beta1.CustomResourceDefinition) error {
-	logrus.Debugf("Comparing %#v to %#v", oldCRD.Spec.Validation, newCRD.Spec.Validation)
-
-	// TODO return early of all versions are equal
-	convertedCRD := &apiextensions.CustomResourceDefinition{}
-	if err := apiextensionsv1beta1.Convert_v1beta1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(newCRD, convertedCRD, nil); err != nil {
-		return err
-	}
-	for _, version := range oldCRD.Spec.Versions {
-		if version.Served {
-			gvr := schema.GroupVersionResource{Group: oldCRD.Spec.Group, Version: version.Name, Resource: oldCRD.Spec.Names.Plural}
-			err := validateExistingCRs(dynamicClient, gvr, convertedCRD)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	if oldCRD.Spec.Version != "" {
-		gvr := schema.GroupVersionResource{Group: oldCRD.Spec.Group, Version: oldCRD.Spec.Version, Resource: oldCRD.Spec.Names.Plural}
-		err := validateExistingCRs(dynamicClient, gvr, convertedCRD)
-		if err != nil {
-			return err
-		}
-	}
-	logrus.Debugf("Successfully validated CRD %s\n", newCRD.Name)
-	return nil
-}
-
-func validateExistingCRs(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, newCRD *apiextensions.CustomResourceDefinition) error {
-	// make dynamic client
-	crList, err := dynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("error listing resources in GroupVersionResource %#v: %s", gvr, err)
-	}
-	for _, cr := range crList.Items {
-		validator, _, err := validation.NewSchemaValidator(newCRD.Spec.Validation)
-		if err != nil {
-			return fmt.Errorf("error creating validator for schema %#v: %s", newCRD.Spec.Validation, err)
-		}
-		err = validation.ValidateCustomResource(field.NewPath(""), cr.UnstructuredContent(), validator).ToAggregate()
-		if err != nil {
-			return fmt.Errorf("error validating custom resource against new schema for %s %s/%s: %v", newCRD.Spec.Names.Kind, cr.GetNamespace(), cr.GetName(), err)
-		}
-	}
-	return nil
-}
-
 type warningRecorder struct {
 	m        sync.Mutex
 	warnings []string
@@ -1728,7 +1757,7 @@ func (wr *warningRecorder) PopWarnings() []string {
 }
 
 // ExecutePlan applies a planned InstallPlan to a namespace.
-func (o *Operator) ExecutePlan(plan *v1alpha1.InstallPlan) error {
+func (o *Operator) ExecutePlan(plan *v1alpha1.InstallPlan) (err error) {
 	if plan.Status.Phase != v1alpha1.InstallPlanPhaseInstalling {
 		panic("attempted to install a plan that wasn't in the installing phase")
 	}
@@ -1743,9 +1772,6 @@ func (o *Operator) ExecutePlan(plan *v1alpha1.InstallPlan) error {
 		return err
 	}
 
-	var wr warningRecorder
-	factory := o.clientFactory.WithConfigTransformer(clients.SetWarningHandler(&wr))
-
 	// Does the namespace have an operator group that specifies a user defined
 	// service account? If so, then we should use a scoped client for plan
 	// execution.
@@ -1754,427 +1780,463 @@ func (o *Operator) ExecutePlan(plan *v1alpha1.InstallPlan) error {
 		o.logger.Errorf("failed to get a client for plan execution: %v", err)
 		return err
 	}
-	attenuatedFactory := factory.WithConfigTransformer(attenuate)
-	kubeclient, err := attenuatedFactory.NewOperatorClient()
+
+	allowedKinds, err := o.kindAllowList()
 	if err != nil {
-		o.logger.Errorf("failed to get a client for plan execution: %v", err)
 		return err
 	}
-	crclient, err := attenuatedFactory.NewKubernetesClient()
-	if err != nil {
-		o.logger.Errorf("failed to get a client for plan execution: %v", err)
-		return err
+
+	r := newManifestResolver(plan.GetNamespace(), o.lister.CoreV1().ConfigMapLister(), o.logger)
+
+	parallelism := o.installPlanStepParallelism
+	if parallelism > len(plan.Status.Plan) {
+		parallelism = len(plan.Status.Plan)
+	}
+	if parallelism < 1 {
+		parallelism = 1
 	}
-	dynamicClient, err := attenuatedFactory.NewDynamicClient()
+	workers := make([]*stepWorker, parallelism)
+	for i := range workers {
+		if workers[i], err = o.newStepWorker(plan, attenuate, r); err != nil {
+			return err
+		}
+	}
+
+	rollback, err := o.newRollbackRecorder(plan, workers[0].dynamicClient)
 	if err != nil {
-		o.logger.Errorf("failed to get a client for plan execution: %v", err)
 		return err
 	}
+	defer func() {
+		if persistErr := rollback.persist(); persistErr != nil && err == nil {
+			err = persistErr
+		}
+	}()
 
-	ensurer := newStepEnsurer(kubeclient, crclient, dynamicClient)
-	r := newManifestResolver(plan.GetNamespace(), o.lister.CoreV1().ConfigMapLister(), o.logger)
-
-	// CRDs should be installed via the default OLM (cluster-admin) client and not the scoped client specified by the AttenuatedServiceAccount
-	// the StepBuilder is currently only implemented for CRD types
-	// TODO give the StepBuilder both OLM and scoped clients when it supports new scoped types
-	builderKubeClient, err := factory.NewOperatorClient()
+	// Check every CRD update before applying any of them, so that all of the objects they would invalidate are
+	// reported at once.
+	report, err := o.crdCompatibilityReport(plan, r)
 	if err != nil {
-		o.logger.Errorf("failed to get a client for plan execution- %v", err)
 		return err
 	}
-	builderDynamicClient, err := factory.NewDynamicClient()
-	if err != nil {
-		o.logger.Errorf("failed to get a client for plan execution- %v", err)
+	now := o.now()
+	if err := setCRDCompatibility(plan, report, &now); err != nil {
 		return err
 	}
-	b := newBuilder(plan, o.lister.OperatorsV1alpha1().ClusterServiceVersionLister(), builderKubeClient, builderDynamicClient, r, o.logger)
+	if !report.compatible() {
+		return fmt.Errorf("error validating existing CRs against new CRD schemas: %s", report)
+	}
 
-	for i, step := range plan.Status.Plan {
-		if err := func(i int, step *v1alpha1.Step) error {
-			wr.PopWarnings()
+	execute := func(w *stepWorker, i int, step *v1alpha1.Step) error {
+		w.wr.PopWarnings()
+		defer func() {
+			warnings := w.wr.PopWarnings()
+			if len(warnings) == 0 {
+				return
+			}
+			var obj runtime.Object
+			if ref, err := reference.GetReference(plan); err != nil {
+				o.logger.WithError(err).Warnf("error getting plan reference")
+				obj = plan
+			} else {
+				ref.FieldPath = fmt.Sprintf("status.plan[%d]", i)
+				obj = ref
+			}
+			msg := fmt.Sprintf("%d warning(s) generated during operator installation (%s %q): %s", len(warnings), step.Resource.Kind, step.Resource.Name, strings.Join(warnings, ", "))
+			if step.Resolving != "" {
+				msg = fmt.Sprintf("%d warning(s) generated during installation of operator %q (%s %q): %s", len(warnings), step.Resolving, step.Resource.Kind, step.Resource.Name, strings.Join(warnings, ", "))
+			}
+			o.recorder.Event(obj, corev1.EventTypeWarning, "AppliedWithWarnings", msg)
+			metrics.EmitInstallPlanWarning()
+		}()
+
+		doStep := true
+		s, err := w.builder.create(*step)
+		if err != nil {
+			if _, ok := err.(notSupportedStepperErr); ok {
+				// stepper not implemented for this type yet
+				// stepper currently only implemented for CRD types
+				doStep = false
+			} else {
+				return err
+			}
+		}
+		if doStep {
+			status, err := s.Status()
+			if err != nil {
+				return err
+			}
+			plan.Status.Plan[i].Status = status
+			return nil
+		}
+
+		switch step.Status {
+		case v1alpha1.StepStatusPresent, v1alpha1.StepStatusCreated, v1alpha1.StepStatusWaitingForAPI:
+			return nil
+		case v1alpha1.StepStatusUnknown, v1alpha1.StepStatusNotPresent:
+			manifest, err := r.ManifestForStep(step)
+			if err != nil {
+				return err
+			}
+			o.logger.WithFields(logrus.Fields{"kind": step.Resource.Kind, "name": step.Resource.Name}).Debug("execute resource")
+			if err := rollback.capture(i, step); err != nil {
+				return err
+			}
 			defer func() {
-				warnings := wr.PopWarnings()
-				if len(warnings) == 0 {
-					return
-				}
-				var obj runtime.Object
-				if ref, err := reference.GetReference(plan); err != nil {
-					o.logger.WithError(err).Warnf("error getting plan reference")
-					obj = plan
-				} else {
-					ref.FieldPath = fmt.Sprintf("status.plan[%d]", i)
-					obj = ref
+				if err := rollback.record(i, plan.Status.Plan[i].Status); err != nil {
+					o.logger.WithError(err).Warn("failed to record step for rollback")
 				}
-				msg := fmt.Sprintf("%d warning(s) generated during operator installation (%s %q): %s", len(warnings), step.Resource.Kind, step.Resource.Name, strings.Join(warnings, ", "))
-				if step.Resolving != "" {
-					msg = fmt.Sprintf("%d warning(s) generated during installation of operator %q (%s %q): %s", len(warnings), step.Resolving, step.Resource.Kind, step.Resource.Name, strings.Join(warnings, ", "))
-				}
-				o.recorder.Event(obj, corev1.EventTypeWarning, "AppliedWithWarnings", msg)
-				metrics.EmitInstallPlanWarning()
 			}()
+			switch step.Resource.Kind {
+			case v1alpha1.ClusterServiceVersionKind:
+				// Marshal the manifest into a CSV instance.
+				var csv v1alpha1.ClusterServiceVersion
+				err := json.Unmarshal([]byte(manifest), &csv)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-			doStep := true
-			s, err := b.create(*step)
-			if err != nil {
-				if _, ok := err.(notSupportedStepperErr); ok {
-					// stepper not implemented for this type yet
-					// stepper currently only implemented for CRD types
-					doStep = false
-				} else {
-					return err
+				// Check if the resolved CSV is in the initial set. Cluster-wide resolution has
+				// already ruled out competing owners, in every namespace, before the plan was written.
+				if _, ok := initialCSVNames[csv.GetName()]; !ok && !o.clusterWideResolution {
+					// Check for pre-existing CSVs that own the same CRDs
+					competingOwners, err := competingCRDOwnersExist(plan.GetNamespace(), &csv, existingCRDOwners)
+					if err != nil {
+						return errorwrap.Wrapf(err, "error checking crd owners for: %s", csv.GetName())
+					}
+
+					// TODO: decide on fail/continue logic for pre-existing dependent CSVs that own the same CRD(s)
+					if competingOwners {
+						// For now, error out
+						return fmt.Errorf("pre-existing CRD owners found for owned CRD(s) of dependent CSV %s", csv.GetName())
+					}
 				}
-			}
-			if doStep {
-				status, err := s.Status()
+
+				// Attempt to create the CSV.
+				csv.SetNamespace(namespace)
+
+				status, err := w.ensurer.EnsureClusterServiceVersion(&csv)
 				if err != nil {
 					return err
 				}
+
 				plan.Status.Plan[i].Status = status
-				return nil
-			}
 
-			switch step.Status {
-			case v1alpha1.StepStatusPresent, v1alpha1.StepStatusCreated, v1alpha1.StepStatusWaitingForAPI:
-				return nil
-			case v1alpha1.StepStatusUnknown, v1alpha1.StepStatusNotPresent:
-				manifest, err := r.ManifestForStep(step)
+			case v1alpha1.SubscriptionKind:
+				// Marshal the manifest into a subscription instance.
+				var sub v1alpha1.Subscription
+				err := json.Unmarshal([]byte(manifest), &sub)
 				if err != nil {
-					return err
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
 				}
-				o.logger.WithFields(logrus.Fields{"kind": step.Resource.Kind, "name": step.Resource.Name}).Debug("execute resource")
-				switch step.Resource.Kind {
-				case v1alpha1.ClusterServiceVersionKind:
-					// Marshal the manifest into a CSV instance.
-					var csv v1alpha1.ClusterServiceVersion
-					err := json.Unmarshal([]byte(manifest), &csv)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
 
-					// Check if the resolved CSV is in the initial set
-					if _, ok := initialCSVNames[csv.GetName()]; !ok {
-						// Check for pre-existing CSVs that own the same CRDs
-						competingOwners, err := competingCRDOwnersExist(plan.GetNamespace(), &csv, existingCRDOwners)
-						if err != nil {
-							return errorwrap.Wrapf(err, "error checking crd owners for: %s", csv.GetName())
-						}
-
-						// TODO: decide on fail/continue logic for pre-existing dependent CSVs that own the same CRD(s)
-						if competingOwners {
-							// For now, error out
-							return fmt.Errorf("pre-existing CRD owners found for owned CRD(s) of dependent CSV %s", csv.GetName())
-						}
-					}
-
-					// Attempt to create the CSV.
-					csv.SetNamespace(namespace)
-
-					status, err := ensurer.EnsureClusterServiceVersion(&csv)
-					if err != nil {
-						return err
-					}
+				// Add the InstallPlan's name as an annotation
+				if annotations := sub.GetAnnotations(); annotations != nil {
+					annotations[generatedByKey] = plan.GetName()
+				} else {
+					sub.SetAnnotations(map[string]string{generatedByKey: plan.GetName()})
+				}
 
-					plan.Status.Plan[i].Status = status
+				// Attempt to create the Subscription
+				sub.SetNamespace(namespace)
 
-				case v1alpha1.SubscriptionKind:
-					// Marshal the manifest into a subscription instance.
-					var sub v1alpha1.Subscription
-					err := json.Unmarshal([]byte(manifest), &sub)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+				status, err := w.ensurer.EnsureSubscription(&sub)
+				if err != nil {
+					return err
+				}
 
-					// Add the InstallPlan's name as an annotation
-					if annotations := sub.GetAnnotations(); annotations != nil {
-						annotations[generatedByKey] = plan.GetName()
-					} else {
-						sub.SetAnnotations(map[string]string{generatedByKey: plan.GetName()})
-					}
+				plan.Status.Plan[i].Status = status
 
-					// Attempt to create the Subscription
-					sub.SetNamespace(namespace)
+			case resolver.BundleSecretKind:
+				var s corev1.Secret
+				err := json.Unmarshal([]byte(manifest), &s)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-					status, err := ensurer.EnsureSubscription(&sub)
-					if err != nil {
-						return err
-					}
+				// add ownerrefs on the secret that point to the CSV in the bundle
+				if step.Resolving != "" {
+					owner := &v1alpha1.ClusterServiceVersion{}
+					owner.SetNamespace(plan.GetNamespace())
+					owner.SetName(step.Resolving)
+					ownerutil.AddNonBlockingOwner(&s, owner)
+				}
 
-					plan.Status.Plan[i].Status = status
+				// Update UIDs on all CSV OwnerReferences
+				updated, err := o.getUpdatedOwnerReferences(s.OwnerReferences, plan.Namespace)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error generating ownerrefs for secret %s", s.GetName())
+				}
+				s.SetOwnerReferences(updated)
+				s.SetNamespace(namespace)
 
-				case resolver.BundleSecretKind:
-					var s corev1.Secret
-					err := json.Unmarshal([]byte(manifest), &s)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+				status, err := w.ensurer.EnsureBundleSecret(plan.Namespace, &s)
+				if err != nil {
+					return err
+				}
 
-					// add ownerrefs on the secret that point to the CSV in the bundle
-					if step.Resolving != "" {
-						owner := &v1alpha1.ClusterServiceVersion{}
-						owner.SetNamespace(plan.GetNamespace())
-						owner.SetName(step.Resolving)
-						ownerutil.AddNonBlockingOwner(&s, owner)
-					}
+				plan.Status.Plan[i].Status = status
 
-					// Update UIDs on all CSV OwnerReferences
-					updated, err := o.getUpdatedOwnerReferences(s.OwnerReferences, plan.Namespace)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error generating ownerrefs for secret %s", s.GetName())
-					}
-					s.SetOwnerReferences(updated)
-					s.SetNamespace(namespace)
+			case secretKind:
+				status, err := w.ensurer.EnsureSecret(o.namespace, plan.GetNamespace(), step.Resource.Name)
+				if err != nil {
+					return err
+				}
 
-					status, err := ensurer.EnsureBundleSecret(plan.Namespace, &s)
-					if err != nil {
-						return err
-					}
+				plan.Status.Plan[i].Status = status
 
-					plan.Status.Plan[i].Status = status
+			case clusterRoleKind:
+				// Marshal the manifest into a ClusterRole instance.
+				var cr rbacv1.ClusterRole
+				err := json.Unmarshal([]byte(manifest), &cr)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-				case secretKind:
-					status, err := ensurer.EnsureSecret(o.namespace, plan.GetNamespace(), step.Resource.Name)
-					if err != nil {
-						return err
-					}
+				status, err := w.ensurer.EnsureClusterRole(&cr, step)
+				if err != nil {
+					return err
+				}
 
-					plan.Status.Plan[i].Status = status
+				plan.Status.Plan[i].Status = status
 
-				case clusterRoleKind:
-					// Marshal the manifest into a ClusterRole instance.
-					var cr rbacv1.ClusterRole
-					err := json.Unmarshal([]byte(manifest), &cr)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+			case clusterRoleBindingKind:
+				// Marshal the manifest into a RoleBinding instance.
+				var rb rbacv1.ClusterRoleBinding
+				err := json.Unmarshal([]byte(manifest), &rb)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-					status, err := ensurer.EnsureClusterRole(&cr, step)
-					if err != nil {
-						return err
-					}
+				status, err := w.ensurer.EnsureClusterRoleBinding(&rb, step)
+				if err != nil {
+					return err
+				}
 
-					plan.Status.Plan[i].Status = status
+				plan.Status.Plan[i].Status = status
 
-				case clusterRoleBindingKind:
-					// Marshal the manifest into a RoleBinding instance.
-					var rb rbacv1.ClusterRoleBinding
-					err := json.Unmarshal([]byte(manifest), &rb)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+			case roleKind:
+				// Marshal the manifest into a Role instance.
+				var r rbacv1.Role
+				err := json.Unmarshal([]byte(manifest), &r)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-					status, err := ensurer.EnsureClusterRoleBinding(&rb, step)
-					if err != nil {
-						return err
-					}
+				// Update UIDs on all CSV OwnerReferences
+				updated, err := o.getUpdatedOwnerReferences(r.OwnerReferences, plan.Namespace)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error generating ownerrefs for role %s", r.GetName())
+				}
+				r.SetOwnerReferences(updated)
+				r.SetNamespace(namespace)
 
-					plan.Status.Plan[i].Status = status
+				status, err := w.ensurer.EnsureRole(plan.Namespace, &r)
+				if err != nil {
+					return err
+				}
 
-				case roleKind:
-					// Marshal the manifest into a Role instance.
-					var r rbacv1.Role
-					err := json.Unmarshal([]byte(manifest), &r)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+				plan.Status.Plan[i].Status = status
 
-					// Update UIDs on all CSV OwnerReferences
-					updated, err := o.getUpdatedOwnerReferences(r.OwnerReferences, plan.Namespace)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error generating ownerrefs for role %s", r.GetName())
-					}
-					r.SetOwnerReferences(updated)
-					r.SetNamespace(namespace)
+			case roleBindingKind:
+				// Marshal the manifest into a RoleBinding instance.
+				var rb rbacv1.RoleBinding
+				err := json.Unmarshal([]byte(manifest), &rb)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-					status, err := ensurer.EnsureRole(plan.Namespace, &r)
-					if err != nil {
-						return err
-					}
+				// Update UIDs on all CSV OwnerReferences
+				updated, err := o.getUpdatedOwnerReferences(rb.OwnerReferences, plan.Namespace)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error generating ownerrefs for rolebinding %s", rb.GetName())
+				}
+				rb.SetOwnerReferences(updated)
+				rb.SetNamespace(namespace)
 
-					plan.Status.Plan[i].Status = status
+				status, err := w.ensurer.EnsureRoleBinding(plan.Namespace, &rb)
+				if err != nil {
+					return err
+				}
 
-				case roleBindingKind:
-					// Marshal the manifest into a RoleBinding instance.
-					var rb rbacv1.RoleBinding
-					err := json.Unmarshal([]byte(manifest), &rb)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+				plan.Status.Plan[i].Status = status
 
-					// Update UIDs on all CSV OwnerReferences
-					updated, err := o.getUpdatedOwnerReferences(rb.OwnerReferences, plan.Namespace)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error generating ownerrefs for rolebinding %s", rb.GetName())
-					}
-					rb.SetOwnerReferences(updated)
-					rb.SetNamespace(namespace)
+			case serviceAccountKind:
+				// Marshal the manifest into a ServiceAccount instance.
+				var sa corev1.ServiceAccount
+				err := json.Unmarshal([]byte(manifest), &sa)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-					status, err := ensurer.EnsureRoleBinding(plan.Namespace, &rb)
-					if err != nil {
-						return err
-					}
+				// Update UIDs on all CSV OwnerReferences
+				updated, err := o.getUpdatedOwnerReferences(sa.OwnerReferences, plan.Namespace)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error generating ownerrefs for service account: %s", sa.GetName())
+				}
+				sa.SetOwnerReferences(updated)
+				sa.SetNamespace(namespace)
 
-					plan.Status.Plan[i].Status = status
+				status, err := w.ensurer.EnsureServiceAccount(namespace, &sa)
+				if err != nil {
+					return err
+				}
 
-				case serviceAccountKind:
-					// Marshal the manifest into a ServiceAccount instance.
-					var sa corev1.ServiceAccount
-					err := json.Unmarshal([]byte(manifest), &sa)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+				plan.Status.Plan[i].Status = status
 
-					// Update UIDs on all CSV OwnerReferences
-					updated, err := o.getUpdatedOwnerReferences(sa.OwnerReferences, plan.Namespace)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error generating ownerrefs for service account: %s", sa.GetName())
-					}
-					sa.SetOwnerReferences(updated)
-					sa.SetNamespace(namespace)
+			case serviceKind:
+				// Marshal the manifest into a Service instance
+				var s corev1.Service
+				err := json.Unmarshal([]byte(manifest), &s)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-					status, err := ensurer.EnsureServiceAccount(namespace, &sa)
-					if err != nil {
-						return err
-					}
+				// add ownerrefs on the service that point to the CSV in the bundle
+				if step.Resolving != "" {
+					owner := &v1alpha1.ClusterServiceVersion{}
+					owner.SetNamespace(plan.GetNamespace())
+					owner.SetName(step.Resolving)
+					ownerutil.AddNonBlockingOwner(&s, owner)
+				}
 
-					plan.Status.Plan[i].Status = status
+				// Update UIDs on all CSV OwnerReferences
+				updated, err := o.getUpdatedOwnerReferences(s.OwnerReferences, plan.Namespace)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error generating ownerrefs for service: %s", s.GetName())
+				}
+				s.SetOwnerReferences(updated)
+				s.SetNamespace(namespace)
 
-				case serviceKind:
-					// Marshal the manifest into a Service instance
-					var s corev1.Service
-					err := json.Unmarshal([]byte(manifest), &s)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+				status, err := w.ensurer.EnsureService(namespace, &s)
+				if err != nil {
+					return err
+				}
 
-					// add ownerrefs on the service that point to the CSV in the bundle
-					if step.Resolving != "" {
-						owner := &v1alpha1.ClusterServiceVersion{}
-						owner.SetNamespace(plan.GetNamespace())
-						owner.SetName(step.Resolving)
-						ownerutil.AddNonBlockingOwner(&s, owner)
-					}
+				plan.Status.Plan[i].Status = status
 
-					// Update UIDs on all CSV OwnerReferences
-					updated, err := o.getUpdatedOwnerReferences(s.OwnerReferences, plan.Namespace)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error generating ownerrefs for service: %s", s.GetName())
-					}
-					s.SetOwnerReferences(updated)
-					s.SetNamespace(namespace)
+			case configMapKind:
+				var cfg corev1.ConfigMap
+				err := json.Unmarshal([]byte(manifest), &cfg)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
+				}
 
-					status, err := ensurer.EnsureService(namespace, &s)
-					if err != nil {
-						return err
-					}
+				// add ownerrefs on the configmap that point to the CSV in the bundle
+				if step.Resolving != "" {
+					owner := &v1alpha1.ClusterServiceVersion{}
+					owner.SetNamespace(plan.GetNamespace())
+					owner.SetName(step.Resolving)
+					ownerutil.AddNonBlockingOwner(&cfg, owner)
+				}
 
-					plan.Status.Plan[i].Status = status
+				// Update UIDs on all CSV OwnerReferences
+				updated, err := o.getUpdatedOwnerReferences(cfg.OwnerReferences, plan.Namespace)
+				if err != nil {
+					return errorwrap.Wrapf(err, "error generating ownerrefs for configmap: %s", cfg.GetName())
+				}
+				cfg.SetOwnerReferences(updated)
+				cfg.SetNamespace(namespace)
 
-				case configMapKind:
-					var cfg corev1.ConfigMap
-					err := json.Unmarshal([]byte(manifest), &cfg)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error parsing step manifest: %s", step.Resource.Name)
-					}
+				status, err := w.ensurer.EnsureConfigMap(plan.Namespace, &cfg)
+				if err != nil {
+					return err
+				}
 
-					// add ownerrefs on the configmap that point to the CSV in the bundle
-					if step.Resolving != "" {
-						owner := &v1alpha1.ClusterServiceVersion{}
-						owner.SetNamespace(plan.GetNamespace())
-						owner.SetName(step.Resolving)
-						ownerutil.AddNonBlockingOwner(&cfg, owner)
-					}
+				plan.Status.Plan[i].Status = status
 
-					// Update UIDs on all CSV OwnerReferences
-					updated, err := o.getUpdatedOwnerReferences(cfg.OwnerReferences, plan.Namespace)
-					if err != nil {
-						return errorwrap.Wrapf(err, "error generating ownerrefs for configmap: %s", cfg.GetName())
-					}
-					cfg.SetOwnerReferences(updated)
-					cfg.SetNamespace(namespace)
+			default:
+				if !allowedKinds.allows(schema.GroupKind{Group: step.Resource.Group, Kind: step.Resource.Kind}) {
+					// Not an allowed resource
+					plan.Status.Plan[i].Status = v1alpha1.StepStatusUnsupportedResource
+					return v1alpha1.ErrInvalidInstallPlan
+				}
 
-					status, err := ensurer.EnsureConfigMap(plan.Namespace, &cfg)
-					if err != nil {
-						return err
-					}
+				// Marshal the manifest into an unstructured object
+				dec := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 10)
+				unstructuredObject := &unstructured.Unstructured{}
+				if err := dec.Decode(unstructuredObject); err != nil {
+					return errorwrap.Wrapf(err, "error decoding %s object to an unstructured object", step.Resource.Name)
+				}
 
-					plan.Status.Plan[i].Status = status
+				// Get the resource from the GVK.
+				gvk := unstructuredObject.GroupVersionKind()
+				r, err := o.apiresourceFromGVK(gvk)
+				if err != nil {
+					return err
+				}
 
-				default:
-					if !isSupported(step.Resource.Kind) {
-						// Not a supported resource
-						plan.Status.Plan[i].Status = v1alpha1.StepStatusUnsupportedResource
-						return v1alpha1.ErrInvalidInstallPlan
-					}
+				// Create the GVR
+				gvr := schema.GroupVersionResource{
+					Group:    gvk.Group,
+					Version:  gvk.Version,
+					Resource: r.Name,
+				}
 
-					// Marshal the manifest into an unstructured object
-					dec := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 10)
-					unstructuredObject := &unstructured.Unstructured{}
-					if err := dec.Decode(unstructuredObject); err != nil {
-						return errorwrap.Wrapf(err, "error decoding %s object to an unstructured object", step.Resource.Name)
+				if sa := plan.Status.AttenuatedServiceAccountRef; sa != nil {
+					createNamespace := ""
+					if r.Namespaced {
+						createNamespace = namespace
 					}
-
-					// Get the resource from the GVK.
-					gvk := unstructuredObject.GroupVersionKind()
-					r, err := o.apiresourceFromGVK(gvk)
-					if err != nil {
+					if err := o.ensureServiceAccountCanCreate(sa, gvr, createNamespace); err != nil {
 						return err
 					}
+				}
 
-					// Create the GVR
-					gvr := schema.GroupVersionResource{
-						Group:    gvk.Group,
-						Version:  gvk.Version,
-						Resource: r.Name,
-					}
+				if step.Resolving != "" {
+					owner := &v1alpha1.ClusterServiceVersion{}
+					owner.SetNamespace(plan.GetNamespace())
+					owner.SetName(step.Resolving)
 
-					if step.Resolving != "" {
-						owner := &v1alpha1.ClusterServiceVersion{}
-						owner.SetNamespace(plan.GetNamespace())
-						owner.SetName(step.Resolving)
-
-						if r.Namespaced {
-							// Set OwnerReferences for namespace-scoped resource
-							ownerutil.AddNonBlockingOwner(unstructuredObject, owner)
-
-							// Update UIDs on all CSV OwnerReferences
-							updated, err := o.getUpdatedOwnerReferences(unstructuredObject.GetOwnerReferences(), plan.Namespace)
-							if err != nil {
-								return errorwrap.Wrapf(err, "error generating ownerrefs for unstructured object: %s", unstructuredObject.GetName())
-							}
-
-							unstructuredObject.SetOwnerReferences(updated)
-						} else {
-							// Add owner labels to cluster-scoped resource
-							if err := ownerutil.AddOwnerLabels(unstructuredObject, owner); err != nil {
-								return err
-							}
+					if r.Namespaced {
+						// Set OwnerReferences for namespace-scoped resource
+						ownerutil.AddNonBlockingOwner(unstructuredObject, owner)
+
+						// Update UIDs on all CSV OwnerReferences
+						updated, err := o.getUpdatedOwnerReferences(unstructuredObject.GetOwnerReferences(), plan.Namespace)
+						if err != nil {
+							return errorwrap.Wrapf(err, "error generating ownerrefs for unstructured object: %s", unstructuredObject.GetName())
 						}
-					}
 
-					// Set up the dynamic client ResourceInterface and set ownerrefs
-					var resourceInterface dynamic.ResourceInterface
-					if r.Namespaced {
-						unstructuredObject.SetNamespace(namespace)
-						resourceInterface = dynamicClient.Resource(gvr).Namespace(namespace)
+						unstructuredObject.SetOwnerReferences(updated)
 					} else {
-						resourceInterface = dynamicClient.Resource(gvr)
+						// Add owner labels to cluster-scoped resource
+						if err := ownerutil.AddOwnerLabels(unstructuredObject, owner); err != nil {
+							return err
+						}
 					}
+				}
 
-					// Ensure Unstructured Object
-					status, err := ensurer.EnsureUnstructuredObject(resourceInterface, unstructuredObject)
-					if err != nil {
-						return err
-					}
+				// Set up the dynamic client ResourceInterface and set ownerrefs
+				var resourceInterface dynamic.ResourceInterface
+				if r.Namespaced {
+					unstructuredObject.SetNamespace(namespace)
+					resourceInterface = w.dynamicClient.Resource(gvr).Namespace(namespace)
+				} else {
+					resourceInterface = w.dynamicClient.Resource(gvr)
+				}
 
-					plan.Status.Plan[i].Status = status
+				// Ensure Unstructured Object
+				status, err := w.ensurer.EnsureUnstructuredObject(resourceInterface, unstructuredObject)
+				if err != nil {
+					return err
 				}
-			default:
-				return v1alpha1.ErrInvalidInstallPlan
+
+				plan.Status.Plan[i].Status = status
 			}
-			return nil
-		}(i, step); err != nil {
-			return err
+		default:
+			return v1alpha1.ErrInvalidInstallPlan
 		}
+		return nil
+	}
+
+	durations, err := newStepGraph(plan.Status.Plan).run(len(workers), func(worker, i int) error {
+		return execute(workers[worker], i, plan.Status.Plan[i])
+	})
+	if durationErr := setStepDurations(plan, durations); durationErr != nil {
+		o.logger.WithError(durationErr).Warn("failed to record step durations")
+	}
+	if err != nil {
+		return err
 	}
 
 	// Loop over one final time to check and see if everything is good.
@@ -2316,6 +2378,8 @@ const (
 	ConsoleYAMLSampleKind     = "ConsoleYAMLSample"
 )
 
+// supportedKinds are the kinds that InstallPlans may apply, beyond the built-in step kinds, unless an allow-list is
+// configured with AllowedKindsConfigMapName.
 var supportedKinds = map[string]struct{}{
 	PrometheusRuleKind:        {},
 	ServiceMonitorKind:        {},
@@ -2324,9 +2388,3 @@ var supportedKinds = map[string]struct{}{
 	VerticalPodAutoscalerKind: {},
 	ConsoleYAMLSampleKind:     {},
 }
-
-// isSupported returns true if OLM supports this type of CustomResource.
-func isSupported(kind string) bool {
-	_, ok := supportedKinds[kind]
-	return ok
-}
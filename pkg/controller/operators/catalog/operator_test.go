@@ -50,6 +50,7 @@ import (
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/fake"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/informers/externalversions"
 	olmerrors "github.com/operator-framework/operator-lifecycle-manager/pkg/controller/errors"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/grpc"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/reconciler"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
@@ -62,6 +63,7 @@ import (
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/ownerutil"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/queueinformer"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/scoped"
+	"github.com/operator-framework/operator-registry/pkg/api"
 )
 
 type mockTransitioner struct {
@@ -90,6 +92,13 @@ func TestTransitionInstallPlan(t *testing.T) {
 		Reason:  v1alpha1.InstallPlanReasonComponentFailed,
 		Message: errMsg,
 	}
+	unschedulable := &unschedulableStepsError{steps: []string{"Widget widget"}}
+	failedUnschedulable := &v1alpha1.InstallPlanCondition{
+		Type:    v1alpha1.InstallPlanInstalled,
+		Status:  corev1.ConditionFalse,
+		Reason:  v1alpha1.InstallPlanReasonComponentFailed,
+		Message: unschedulable.Error(),
+	}
 
 	tests := []struct {
 		initial    v1alpha1.InstallPlanPhase
@@ -106,6 +115,7 @@ func TestTransitionInstallPlan(t *testing.T) {
 		{v1alpha1.InstallPlanPhaseInstalling, err, v1alpha1.ApprovalAutomatic, true, v1alpha1.InstallPlanPhaseFailed, failed, 0},
 		{v1alpha1.InstallPlanPhaseInstalling, err, v1alpha1.ApprovalAutomatic, false, v1alpha1.InstallPlanPhaseInstalling, nil, 1},
 		{v1alpha1.InstallPlanPhaseInstalling, err, v1alpha1.ApprovalAutomatic, true, v1alpha1.InstallPlanPhaseInstalling, nil, 1},
+		{v1alpha1.InstallPlanPhaseInstalling, unschedulable, v1alpha1.ApprovalAutomatic, true, v1alpha1.InstallPlanPhaseFailed, failedUnschedulable, 1},
 
 		{v1alpha1.InstallPlanPhaseRequiresApproval, nil, v1alpha1.ApprovalManual, false, v1alpha1.InstallPlanPhaseRequiresApproval, nil, 0},
 		{v1alpha1.InstallPlanPhaseRequiresApproval, nil, v1alpha1.ApprovalManual, true, v1alpha1.InstallPlanPhaseInstalling, nil, 0},
@@ -269,6 +279,10 @@ func (ipSet) Generate(rand *rand.Rand, size int) reflect.Value {
 				Spec: v1alpha1.InstallPlanSpec{
 					Generation: i,
 				},
+				// only finished plans are garbage collected
+				Status: v1alpha1.InstallPlanStatus{
+					Phase: v1alpha1.InstallPlanPhaseComplete,
+				},
 			})
 		}
 	}
@@ -709,7 +723,7 @@ func TestSupportedDynamicResources(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.testName, func(t *testing.T) {
-			require.Equal(t, tt.expectedResult, isSupported(tt.resource.Kind))
+			require.Equal(t, tt.expectedResult, defaultKindAllowList().allows(schema.GroupKind{Group: tt.resource.Group, Kind: tt.resource.Kind}))
 		})
 	}
 }
@@ -1083,9 +1097,10 @@ func TestSyncResolvingNamespace(t *testing.T) {
 		obj interface{}
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		wantErr error
+		name           string
+		fields         fields
+		wantErr        error
+		wantConditions []v1alpha1.SubscriptionCondition
 	}{
 		{
 			name: "NoError",
@@ -1137,6 +1152,42 @@ func TestSyncResolvingNamespace(t *testing.T) {
 						},
 					},
 				},
+				resolveErr: solver.NotSatisfiable{
+					{
+						Installable: resolver.NewSubscriptionInstallable("sub", nil),
+						Constraint:  resolver.PrettyConstraint(solver.Mandatory(), "something"),
+					},
+				},
+			},
+			wantConditions: []v1alpha1.SubscriptionCondition{
+				{
+					Type:    resolver.SubscriptionResolutionFailed,
+					Status:  corev1.ConditionTrue,
+					Reason:  resolver.ConstraintsNotSatisfiable,
+					Message: "constraints not satisfiable: something (subscriptions: sub)",
+				},
+			},
+		},
+		{
+			name: "NotSatisfiableError/OtherSubscription",
+			fields: fields{
+				clientOptions: []clientfake.Option{clientfake.WithSelfLinks(t)},
+				existingOLMObjs: []runtime.Object{
+					&v1alpha1.Subscription{
+						TypeMeta: metav1.TypeMeta{
+							Kind:       v1alpha1.SubscriptionKind,
+							APIVersion: v1alpha1.SchemeGroupVersion.String(),
+						},
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "sub",
+							Namespace: testNamespace,
+						},
+						Spec: &v1alpha1.SubscriptionSpec{
+							CatalogSource:          "src",
+							CatalogSourceNamespace: testNamespace,
+						},
+					},
+				},
 				resolveErr: solver.NotSatisfiable{
 					{
 						Installable: resolver.NewSubscriptionInstallable("a", nil),
@@ -1145,6 +1196,38 @@ func TestSyncResolvingNamespace(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "NoError/ClearsResolutionFailed",
+			fields: fields{
+				clientOptions: []clientfake.Option{clientfake.WithSelfLinks(t)},
+				existingOLMObjs: []runtime.Object{
+					&v1alpha1.Subscription{
+						TypeMeta: metav1.TypeMeta{
+							Kind:       v1alpha1.SubscriptionKind,
+							APIVersion: v1alpha1.SchemeGroupVersion.String(),
+						},
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "sub",
+							Namespace: testNamespace,
+						},
+						Spec: &v1alpha1.SubscriptionSpec{
+							CatalogSource:          "src",
+							CatalogSourceNamespace: testNamespace,
+						},
+						Status: v1alpha1.SubscriptionStatus{
+							Conditions: []v1alpha1.SubscriptionCondition{
+								{
+									Type:    resolver.SubscriptionResolutionFailed,
+									Status:  corev1.ConditionTrue,
+									Reason:  resolver.ConstraintsNotSatisfiable,
+									Message: "constraints not satisfiable: something",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "OtherError",
 			fields: fields{
@@ -1204,8 +1287,8 @@ func TestSyncResolvingNamespace(t *testing.T) {
 
 			o.sourcesLastUpdate.Set(tt.fields.sourcesLastUpdate.Time)
 			o.resolver = &fakes.FakeStepResolver{
-				ResolveStepsStub: func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
-					return nil, nil, nil, tt.fields.resolveErr
+				ResolveStepsStub: func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, resolver.Dependencies, error) {
+					return nil, nil, nil, nil, tt.fields.resolveErr
 				},
 			}
 
@@ -1218,9 +1301,16 @@ func TestSyncResolvingNamespace(t *testing.T) {
 			err = o.syncResolvingNamespace(namespace)
 			if tt.wantErr != nil {
 				require.Equal(t, tt.wantErr, err)
-			} else {
-				require.NoError(t, err)
+				return
 			}
+			require.NoError(t, err)
+
+			sub, err := o.client.OperatorsV1alpha1().Subscriptions(testNamespace).Get(ctx, "sub", metav1.GetOptions{})
+			require.NoError(t, err)
+			for i := range sub.Status.Conditions {
+				sub.Status.Conditions[i].LastTransitionTime = nil
+			}
+			require.Equal(t, tt.wantConditions, sub.Status.Conditions)
 		})
 	}
 }
@@ -1298,7 +1388,7 @@ func TestCompetingCRDOwnersExist(t *testing.T) {
 	}
 }
 
-func TestValidateExistingCRs(t *testing.T) {
+func TestIncompatibleObjects(t *testing.T) {
 	unstructuredForFile := func(file string) *unstructured.Unstructured {
 		data, err := ioutil.ReadFile(file)
 		require.NoError(t, err)
@@ -1324,7 +1414,7 @@ func TestValidateExistingCRs(t *testing.T) {
 		existingObjects []runtime.Object
 		gvr             schema.GroupVersionResource
 		newCRD          *apiextensions.CustomResourceDefinition
-		want            error
+		want            []IncompatibleObject
 	}{
 		{
 			name: "label validation",
@@ -1349,7 +1439,17 @@ func TestValidateExistingCRs(t *testing.T) {
 				Resource: "machinepools",
 			},
 			newCRD: unversionedCRDForV1beta1File("testdata/hivebug/crd.yaml"),
-			want:   fmt.Errorf("error validating custom resource against new schema for MachinePool /test: [[].spec.clusterDeploymentRef: Invalid value: \"null\": spec.clusterDeploymentRef in body must be of type object: \"null\", [].spec.name: Required value, [].spec.platform: Required value]"),
+			want: []IncompatibleObject{
+				{
+					Version: "v1",
+					Name:    "test",
+					Errors: []FieldError{
+						{Field: "spec.clusterDeploymentRef", Message: "Invalid value: \"null\": spec.clusterDeploymentRef in body must be of type object: \"null\""},
+						{Field: "spec.name", Message: "Required value"},
+						{Field: "spec.platform", Message: "Required value"},
+					},
+				},
+			},
 		},
 	}
 	for _, tt := range tests {
@@ -1357,7 +1457,10 @@ func TestValidateExistingCRs(t *testing.T) {
 			client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
 				tt.gvr: "UnstructuredList",
 			}, tt.existingObjects...)
-			require.Equal(t, tt.want, validateExistingCRs(client, tt.gvr, tt.newCRD))
+			incompatible, count, err := incompatibleObjects(client, tt.gvr, tt.newCRD.Spec.Validation)
+			require.NoError(t, err)
+			require.Equal(t, len(tt.existingObjects), count)
+			require.Equal(t, tt.want, incompatible)
 		})
 	}
 }
@@ -1529,6 +1632,7 @@ func NewFakeOperator(ctx context.Context, namespace string, namespaces []string,
 		clientAttenuator:      scoped.NewClientAttenuator(logger, &rest.Config{}, opClientFake),
 		serviceAccountQuerier: scoped.NewUserDefinedServiceAccountQuerier(logger, clientFake),
 		catsrcQueueSet:        queueinformer.NewEmptyResourceQueueSet(),
+		installPlanRetention:  DefaultInstallPlanRetentionPolicy(),
 		clientFactory: &stubClientFactory{
 			operatorClient:   opClientFake,
 			kubernetesClient: clientFake,
@@ -1850,3 +1954,77 @@ func hasExpectedCondition(ip *v1alpha1.InstallPlan, expectedCondition v1alpha1.I
 	}
 	return false
 }
+
+type stubSourceQuerier struct {
+	resolver.SourceQuerier
+	latest *api.Bundle
+}
+
+func (q stubSourceQuerier) FindLatestBundle(pkgName, channelName string, initialSource registry.CatalogKey) (*api.Bundle, *registry.CatalogKey, error) {
+	if q.latest == nil {
+		return nil, nil, fmt.Errorf("not found")
+	}
+	return q.latest, &initialSource, nil
+}
+
+func TestEnsureVersionRangeCondition(t *testing.T) {
+	clockFake := utilclock.NewFakeClock(time.Date(2018, time.January, 26, 20, 40, 0, 0, time.UTC))
+	now := metav1.NewTime(clockFake.Now())
+	exceeded := v1alpha1.SubscriptionCondition{
+		Type:               resolver.SubscriptionVersionRangeExceeded,
+		Status:             corev1.ConditionTrue,
+		Reason:             resolver.ChannelHeadOutOfRange,
+		Message:            `head of channel stable of package pkg is pkg.v1.6.0 (version 1.6.0), which is outside the version range "<1.6.0"`,
+		LastTransitionTime: &now,
+	}
+
+	tests := []struct {
+		name         string
+		versionRange string
+		existing     []v1alpha1.SubscriptionCondition
+		latest       *api.Bundle
+		expected     []v1alpha1.SubscriptionCondition
+	}{
+		{
+			name:   "NoRange",
+			latest: &api.Bundle{CsvName: "pkg.v1.6.0", Version: "1.6.0"},
+		},
+		{
+			name:         "HeadInRange",
+			versionRange: "<2.0.0",
+			existing:     []v1alpha1.SubscriptionCondition{exceeded},
+			latest:       &api.Bundle{CsvName: "pkg.v1.6.0", Version: "1.6.0"},
+		},
+		{
+			name:         "HeadOutOfRange",
+			versionRange: "<1.6.0",
+			latest:       &api.Bundle{CsvName: "pkg.v1.6.0", Version: "1.6.0"},
+			expected:     []v1alpha1.SubscriptionCondition{exceeded},
+		},
+		{
+			name:         "CatalogUnavailable",
+			versionRange: "<1.6.0",
+			existing:     []v1alpha1.SubscriptionCondition{exceeded},
+			expected:     []v1alpha1.SubscriptionCondition{exceeded},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Operator{clock: clockFake}
+			sub := &v1alpha1.Subscription{
+				ObjectMeta: metav1.ObjectMeta{Name: "sub", Namespace: "ns"},
+				Spec: &v1alpha1.SubscriptionSpec{
+					Package: "pkg",
+					Channel: "stable",
+				},
+				Status: v1alpha1.SubscriptionStatus{Conditions: tt.existing},
+			}
+			if tt.versionRange != "" {
+				sub.SetAnnotations(map[string]string{resolver.SubscriptionVersionRangeAnnotationKey: tt.versionRange})
+			}
+
+			o.ensureVersionRangeCondition(sub, stubSourceQuerier{latest: tt.latest}, registry.CatalogKey{Name: "src", Namespace: "ns"})
+			require.Equal(t, tt.expected, sub.Status.Conditions)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/authenticatorfactory"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+const (
+	// ResolutionPreviewPath is the path at which the catalog operator serves dry-run resolutions.
+	ResolutionPreviewPath = "/resolution/preview"
+
+	maxResolutionPreviewRequestBytes = 1 << 20
+
+	resolutionPreviewAuthCacheTTL = 10 * time.Second
+)
+
+// resolutionPreviewAttributes are what a user must be allowed to do in a namespace to preview its resolution: a
+// preview shows the operators in the namespace and what subscribing would install.
+var resolutionPreviewAttributes = []authorizer.AttributesRecord{
+	{Verb: "create", APIGroup: v1alpha1.GroupName, Resource: "subscriptions", ResourceRequest: true},
+	{Verb: "list", APIGroup: v1alpha1.GroupName, Resource: "clusterserviceversions", ResourceRequest: true},
+}
+
+// ResolutionPreviewRequest is the body of a dry-run resolution request.
+type ResolutionPreviewRequest struct {
+	// Namespace is the namespace to resolve.
+	Namespace string `json:"namespace"`
+
+	// Subscriptions are candidate subscriptions to resolve alongside those that already exist in the namespace.
+	Subscriptions []v1alpha1.Subscription `json:"subscriptions,omitempty"`
+}
+
+// ResolutionPreviewError is returned in place of a ResolutionPreview when resolution fails.
+type ResolutionPreviewError struct {
+	Error string `json:"error"`
+
+	// Conflicts is the minimal set of constraints that made resolution impossible, if the failure was due to
+	// the constraints not being satisfiable.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// ResolutionPreviewHandler returns an http.Handler serving dry-run resolutions. Requests are authenticated with
+// TokenReviews and authorized with SubjectAccessReviews against the cluster.
+func (o *Operator) ResolutionPreviewHandler() (http.Handler, error) {
+	kubeClient := o.opClient.KubernetesInterface()
+	authn, _, err := authenticatorfactory.DelegatingAuthenticatorConfig{
+		TokenAccessReviewClient: kubeClient.AuthenticationV1().TokenReviews(),
+		WebhookRetryBackoff:     genericoptions.DefaultAuthWebhookRetryBackoff(),
+		CacheTTL:                resolutionPreviewAuthCacheTTL,
+	}.New()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring resolution preview authentication: %v", err)
+	}
+	authz, err := authorizerfactory.DelegatingAuthorizerConfig{
+		SubjectAccessReviewClient: kubeClient.AuthorizationV1().SubjectAccessReviews(),
+		WebhookRetryBackoff:       genericoptions.DefaultAuthWebhookRetryBackoff(),
+		AllowCacheTTL:             resolutionPreviewAuthCacheTTL,
+		DenyCacheTTL:              resolutionPreviewAuthCacheTTL,
+	}.New()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring resolution preview authorization: %v", err)
+	}
+	return NewResolutionPreviewHandler(o.previewer, authn, authz, o.logger), nil
+}
+
+// NewResolutionPreviewHandler returns an http.Handler that accepts a ResolutionPreviewRequest via POST and
+// responds with the resolver.ResolutionPreview that the given previewer computes for it. Only authenticated users
+// that are allowed to create Subscriptions and list ClusterServiceVersions in the requested namespace get a preview.
+func NewResolutionPreviewHandler(previewer resolver.StepPreviewer, authn authenticator.Request, authz authorizer.Authorizer, logger logrus.FieldLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writePreviewError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		resp, ok, err := authn.AuthenticateRequest(r)
+		if err != nil || !ok {
+			if err != nil {
+				logger.WithError(err).Debug("resolution preview authentication failed")
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writePreviewError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+			return
+		}
+
+		var req ResolutionPreviewRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxResolutionPreviewRequestBytes)).Decode(&req); err != nil {
+			writePreviewError(w, http.StatusBadRequest, fmt.Errorf("error decoding request: %w", err))
+			return
+		}
+		if req.Namespace == "" {
+			writePreviewError(w, http.StatusBadRequest, fmt.Errorf("namespace is required"))
+			return
+		}
+
+		for _, attrs := range resolutionPreviewAttributes {
+			attrs.User = resp.User
+			attrs.Namespace = req.Namespace
+			decision, reason, err := authz.Authorize(r.Context(), attrs)
+			if err != nil {
+				logger.WithError(err).Warn("resolution preview authorization failed")
+				writePreviewError(w, http.StatusInternalServerError, fmt.Errorf("error authorizing request"))
+				return
+			}
+			if decision != authorizer.DecisionAllow {
+				writePreviewError(w, http.StatusForbidden, fmt.Errorf("user %q cannot %s %s.%s in namespace %q: %s", resp.User.GetName(), attrs.Verb, attrs.Resource, attrs.APIGroup, req.Namespace, reason))
+				return
+			}
+		}
+
+		candidates := make([]*v1alpha1.Subscription, len(req.Subscriptions))
+		for i := range req.Subscriptions {
+			candidates[i] = &req.Subscriptions[i]
+		}
+
+		logger := logger.WithField("namespace", req.Namespace)
+		preview, err := previewer.PreviewSteps(req.Namespace, candidates)
+		if err != nil {
+			logger.WithError(err).Debug("resolution preview failed")
+			if _, ok := err.(solver.NotSatisfiable); ok {
+				writePreviewError(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+			writePreviewError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		writePreviewJSON(w, http.StatusOK, preview)
+	})
+}
+
+func writePreviewError(w http.ResponseWriter, status int, err error) {
+	body := ResolutionPreviewError{Error: err.Error()}
+	if ns, ok := err.(solver.NotSatisfiable); ok {
+		for _, c := range ns {
+			body.Conflicts = append(body.Conflicts, c.String())
+		}
+	}
+	writePreviewJSON(w, status, body)
+}
+
+func writePreviewJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
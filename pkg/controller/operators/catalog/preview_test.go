@@ -0,0 +1,179 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+type previewerFunc func(namespace string, candidates []*v1alpha1.Subscription) (*resolver.ResolutionPreview, error)
+
+func (f previewerFunc) PreviewSteps(namespace string, candidates []*v1alpha1.Subscription) (*resolver.ResolutionPreview, error) {
+	return f(namespace, candidates)
+}
+
+// previewAuthn authenticates requests bearing the token "alice" as the user alice, whom previewAuthz only allows to
+// preview resolutions in the namespace "ns".
+var (
+	previewAuthn = authenticator.RequestFunc(func(r *http.Request) (*authenticator.Response, bool, error) {
+		if r.Header.Get("Authorization") != "Bearer alice" {
+			return nil, false, nil
+		}
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "alice"}}, true, nil
+	})
+	previewAuthz = authorizer.AuthorizerFunc(func(_ context.Context, a authorizer.Attributes) (authorizer.Decision, string, error) {
+		if a.GetUser().GetName() == "alice" && a.GetNamespace() == "ns" {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionNoOpinion, "no rule", nil
+	})
+)
+
+func TestResolutionPreviewHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		token          string
+		body           string
+		previewer      previewerFunc
+		expectedStatus int
+		expectedBody   interface{}
+	}{
+		{
+			name:           "WrongMethod",
+			method:         http.MethodGet,
+			token:          "alice",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Unauthenticated",
+			method:         http.MethodPost,
+			body:           `{"namespace":"ns"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   &ResolutionPreviewError{Error: "unauthorized"},
+		},
+		{
+			name:           "InvalidToken",
+			method:         http.MethodPost,
+			token:          "mallory",
+			body:           `{"namespace":"ns"}`,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   &ResolutionPreviewError{Error: "unauthorized"},
+		},
+		{
+			name:   "Forbidden",
+			method: http.MethodPost,
+			token:  "alice",
+			body:   `{"namespace":"other"}`,
+			previewer: func(string, []*v1alpha1.Subscription) (*resolver.ResolutionPreview, error) {
+				return nil, fmt.Errorf("previewed a forbidden namespace")
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   &ResolutionPreviewError{Error: `user "alice" cannot create subscriptions.operators.coreos.com in namespace "other": no rule`},
+		},
+		{
+			name:           "MalformedBody",
+			method:         http.MethodPost,
+			token:          "alice",
+			body:           "{",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "MissingNamespace",
+			method:         http.MethodPost,
+			token:          "alice",
+			body:           `{"subscriptions":[]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Resolved",
+			method: http.MethodPost,
+			token:  "alice",
+			body:   `{"namespace":"ns","subscriptions":[{"metadata":{"name":"sub"},"spec":{"name":"pkg"}}]}`,
+			previewer: func(namespace string, candidates []*v1alpha1.Subscription) (*resolver.ResolutionPreview, error) {
+				if namespace != "ns" || len(candidates) != 1 || candidates[0].GetName() != "sub" || candidates[0].Spec.Package != "pkg" {
+					return nil, fmt.Errorf("unexpected input")
+				}
+				return &resolver.ResolutionPreview{
+					Namespace: namespace,
+					Steps:     []*v1alpha1.Step{{Resolving: "pkg.v1"}},
+				}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: &resolver.ResolutionPreview{
+				Namespace: "ns",
+				Steps:     []*v1alpha1.Step{{Resolving: "pkg.v1"}},
+			},
+		},
+		{
+			name:   "NotSatisfiable",
+			method: http.MethodPost,
+			token:  "alice",
+			body:   `{"namespace":"ns"}`,
+			previewer: func(string, []*v1alpha1.Subscription) (*resolver.ResolutionPreview, error) {
+				return nil, solver.NotSatisfiable{
+					{
+						Installable: resolver.NewSubscriptionInstallable("sub", nil),
+						Constraint:  resolver.PrettyConstraint(solver.Mandatory(), "subscription sub exists"),
+					},
+				}
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody: &ResolutionPreviewError{
+				Error:     "constraints not satisfiable: subscription sub exists",
+				Conflicts: []string{"subscription sub exists"},
+			},
+		},
+		{
+			name:   "InternalError",
+			method: http.MethodPost,
+			token:  "alice",
+			body:   `{"namespace":"ns"}`,
+			previewer: func(string, []*v1alpha1.Subscription) (*resolver.ResolutionPreview, error) {
+				return nil, fmt.Errorf("boom")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   &ResolutionPreviewError{Error: "boom"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewResolutionPreviewHandler(tt.previewer, previewAuthn, previewAuthz, logrus.New())
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, ResolutionPreviewPath, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedStatus, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			if tt.expectedBody == nil {
+				return
+			}
+			actual := tt.expectedBody
+			switch tt.expectedBody.(type) {
+			case *resolver.ResolutionPreview:
+				actual = &resolver.ResolutionPreview{}
+			case *ResolutionPreviewError:
+				actual = &ResolutionPreviewError{}
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), actual))
+			require.Equal(t, tt.expectedBody, actual)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+)
+
+// resolutionPolicyLabelKeys are the labels that identify ConfigMaps in the global catalog namespace as policies that
+// apply to resolution in every namespace.
+var resolutionPolicyLabelKeys = []string{
+	resolver.DenyListLabelKey,
+	resolver.PreferencePolicyLabelKey,
+}
+
+func isResolutionPolicy(cm *corev1.ConfigMap) bool {
+	for _, key := range resolutionPolicyLabelKeys {
+		if _, ok := cm.GetLabels()[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolutionPolicies tracks the resource versions of the resolution policy ConfigMaps, so that periodic resyncs of
+// unchanged policies, and ConfigMaps that have never been policies, don't requeue any namespace.
+type resolutionPolicies struct {
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// changed records the state of a ConfigMap and returns true if it is a policy that is new or has changed since it was
+// last seen, or if it was a policy and has since been deleted or lost its label.
+func (p *resolutionPolicies) changed(cm *corev1.ConfigMap, deleted bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	version, seen := p.versions[cm.GetName()]
+	if deleted || !isResolutionPolicy(cm) {
+		delete(p.versions, cm.GetName())
+		return seen
+	}
+	if seen && version == cm.GetResourceVersion() {
+		return false
+	}
+	if p.versions == nil {
+		p.versions = make(map[string]string)
+	}
+	p.versions[cm.GetName()] = cm.GetResourceVersion()
+	return true
+}
+
+// requeueForResolutionPolicy requeues the resolution of every namespace with Subscriptions when a policy ConfigMap in
+// the global catalog namespace changes.
+func (o *Operator) requeueForResolutionPolicy(obj metav1.Object, deleted bool) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || cm.GetNamespace() != o.namespace || !o.resolutionPolicies.changed(cm, deleted) {
+		return
+	}
+
+	subs, err := o.lister.OperatorsV1alpha1().SubscriptionLister().List(labels.Everything())
+	if err != nil {
+		o.logger.WithError(err).Warn("couldn't list subscriptions to requeue for resolution policy change")
+		return
+	}
+	namespaces := make(map[string]struct{})
+	for _, sub := range subs {
+		namespaces[sub.GetNamespace()] = struct{}{}
+	}
+	o.logger.WithField("configmap", cm.GetName()).Debugf("resolution policy changed, requeueing %d namespaces", len(namespaces))
+	for ns := range namespaces {
+		o.nsResolveQueue.Add(ns)
+	}
+}
@@ -0,0 +1,74 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+)
+
+func TestRequeueForResolutionPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	sub := func(namespace, name string) *v1alpha1.Subscription {
+		return &v1alpha1.Subscription{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(namespace + "/" + name)}}
+	}
+	op, err := NewFakeOperator(ctx, "olm", []string{"olm", "ns1", "ns2", "ns3"},
+		withClientObjs(sub("ns1", "a"), sub("ns1", "b"), sub("ns2", "c")))
+	require.NoError(t, err)
+
+	configMap := func(namespace, version string, labels map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            "policy",
+			ResourceVersion: version,
+			Labels:          labels,
+		}}
+	}
+	denyList := map[string]string{resolver.DenyListLabelKey: ""}
+	// requeued drains the resolution queue and returns the namespaces that were in it.
+	requeued := func() []string {
+		var namespaces []string
+		for op.nsResolveQueue.Len() > 0 {
+			item, _ := op.nsResolveQueue.Get()
+			namespaces = append(namespaces, item.(string))
+			op.nsResolveQueue.Done(item)
+			op.nsResolveQueue.Forget(item)
+		}
+		return namespaces
+	}
+
+	require.NoError(t, op.syncObject(configMap("olm", "1", nil)))
+	require.Empty(t, requeued(), "not a policy")
+
+	require.NoError(t, op.syncObject(configMap("ns1", "1", denyList)))
+	require.Empty(t, requeued(), "not in the global catalog namespace")
+
+	require.NoError(t, op.syncObject(configMap("olm", "2", denyList)))
+	require.ElementsMatch(t, []string{"ns1", "ns2"}, requeued(), "new policy")
+
+	require.NoError(t, op.syncObject(configMap("olm", "2", denyList)))
+	require.Empty(t, requeued(), "resync of an unchanged policy")
+
+	require.NoError(t, op.syncObject(configMap("olm", "3", denyList)))
+	require.ElementsMatch(t, []string{"ns1", "ns2"}, requeued(), "changed policy")
+
+	require.NoError(t, op.syncObject(configMap("olm", "4", nil)))
+	require.ElementsMatch(t, []string{"ns1", "ns2"}, requeued(), "label removed")
+
+	require.NoError(t, op.syncObject(configMap("olm", "5", denyList)))
+	require.ElementsMatch(t, []string{"ns1", "ns2"}, requeued(), "label added")
+
+	op.handleDeletion(configMap("olm", "5", denyList))
+	require.ElementsMatch(t, []string{"ns1", "ns2"}, requeued(), "policy deleted")
+
+	require.NoError(t, op.syncObject(configMap("olm", "6", map[string]string{resolver.PreferencePolicyLabelKey: ""})))
+	require.ElementsMatch(t, []string{"ns1", "ns2"}, requeued(), "new preference policy")
+}
@@ -0,0 +1,313 @@
+package catalog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+const (
+	// InstallPlanRetentionCountAnnotationKey is the Namespace annotation overriding the number of InstallPlans kept
+	// in the namespace.
+	InstallPlanRetentionCountAnnotationKey = "olm.installplan-retention.count"
+
+	// InstallPlanRetentionMaxAgeAnnotationKey is the Namespace annotation overriding the age, as a Go duration,
+	// beyond which InstallPlans in the namespace are deleted.
+	InstallPlanRetentionMaxAgeAnnotationKey = "olm.installplan-retention.max-age"
+
+	// InstallPlanRetentionKeepLastAnnotationKey is the Namespace annotation overriding whether the last completed and
+	// the last failed InstallPlan of each Subscription in the namespace are always kept.
+	InstallPlanRetentionKeepLastAnnotationKey = "olm.installplan-retention.keep-last-per-subscription"
+
+	// InstallPlanRetentionArchiveAnnotationKey is the Namespace annotation overriding whether InstallPlans deleted
+	// from the namespace are archived.
+	InstallPlanRetentionArchiveAnnotationKey = "olm.installplan-retention.archive"
+
+	// InstallPlanArchiveLabelKey is the label that identifies the ConfigMaps holding archived InstallPlans. Each
+	// plan is stored gzipped, as JSON, under the binary data key "<name>.json.gz".
+	InstallPlanArchiveLabelKey = "olm.installplan-archive"
+
+	installPlanArchivePrefix = "olm-installplan-archive-"
+	installPlanArchiveSuffix = ".json.gz"
+
+	// maxInstallPlanArchiveBytes bounds the data of a single archive ConfigMap well below the size limit of a
+	// ConfigMap. Plans that don't fit are archived in a new ConfigMap.
+	maxInstallPlanArchiveBytes = 512 * 1024
+)
+
+// InstallPlanRetentionPolicy configures the garbage collection of InstallPlans. Only completed and failed plans are
+// deleted: plans that are waiting for approval or for a maintenance window, or that are being planned or installed,
+// are always kept.
+type InstallPlanRetentionPolicy struct {
+	// Count is the number of completed and failed InstallPlans kept in a namespace, newest first, not counting plans
+	// kept because of KeepLastPerSubscription.
+	Count int
+
+	// MaxAge is the age beyond which InstallPlans are deleted even if within Count. The plans of the newest
+	// generation are never deleted because of their age. Zero disables deletion by age.
+	MaxAge time.Duration
+
+	// DeletesPerSweep bounds the number of InstallPlans deleted from a namespace at a time.
+	DeletesPerSweep int
+
+	// KeepLastPerSubscription always keeps the last completed and the last failed InstallPlan of each Subscription.
+	KeepLastPerSubscription bool
+
+	// Archive stores each deleted InstallPlan in a ConfigMap labeled with InstallPlanArchiveLabelKey, for audit.
+	// Archive ConfigMaps aren't garbage collected.
+	Archive bool
+}
+
+// DefaultInstallPlanRetentionPolicy returns the retention policy that is used unless configured otherwise.
+func DefaultInstallPlanRetentionPolicy() InstallPlanRetentionPolicy {
+	return InstallPlanRetentionPolicy{
+		Count:           maxInstallPlanCount,
+		DeletesPerSweep: maxDeletesPerSweep,
+	}
+}
+
+// forNamespace returns the policy with the overrides of the given namespace annotations applied. Invalid overrides
+// are logged and ignored.
+func (p InstallPlanRetentionPolicy) forNamespace(annotations map[string]string, log logrus.FieldLogger) InstallPlanRetentionPolicy {
+	if v, ok := annotations[InstallPlanRetentionCountAnnotationKey]; ok {
+		if count, err := strconv.Atoi(v); err != nil || count < 0 {
+			log.WithField("annotation", InstallPlanRetentionCountAnnotationKey).Warnf("ignoring invalid installplan retention count %q", v)
+		} else {
+			p.Count = count
+		}
+	}
+	if v, ok := annotations[InstallPlanRetentionMaxAgeAnnotationKey]; ok {
+		if age, err := time.ParseDuration(v); err != nil || age < 0 {
+			log.WithField("annotation", InstallPlanRetentionMaxAgeAnnotationKey).Warnf("ignoring invalid installplan retention age %q", v)
+		} else {
+			p.MaxAge = age
+		}
+	}
+	for key, field := range map[string]*bool{
+		InstallPlanRetentionKeepLastAnnotationKey: &p.KeepLastPerSubscription,
+		InstallPlanRetentionArchiveAnnotationKey:  &p.Archive,
+	} {
+		v, ok := annotations[key]
+		if !ok {
+			continue
+		}
+		if b, err := strconv.ParseBool(v); err != nil {
+			log.WithField("annotation", key).Warnf("ignoring invalid installplan retention setting %q", v)
+		} else {
+			*field = b
+		}
+	}
+	return p
+}
+
+func olderInstallPlan(a, b *v1alpha1.InstallPlan) bool {
+	if a.Spec.Generation != b.Spec.Generation {
+		return a.Spec.Generation < b.Spec.Generation
+	}
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	// final fallback to lexicographic sort, in case many installplans are created with the same timestamp
+	return a.GetName() < b.GetName()
+}
+
+// lastPlansPerSubscription returns the names of the last completed and the last failed InstallPlan owned by each
+// Subscription, given plans sorted oldest first.
+func lastPlansPerSubscription(ips []*v1alpha1.InstallPlan) map[string]struct{} {
+	type key struct {
+		sub   string
+		phase v1alpha1.InstallPlanPhase
+	}
+	last := make(map[key]string)
+	for _, ip := range ips {
+		if !installPlanFinished(ip) {
+			continue
+		}
+		for _, owner := range ip.GetOwnerReferences() {
+			if owner.Kind == v1alpha1.SubscriptionKind {
+				last[key{sub: owner.Name, phase: ip.Status.Phase}] = ip.GetName()
+			}
+		}
+	}
+	kept := make(map[string]struct{})
+	for _, name := range last {
+		kept[name] = struct{}{}
+	}
+	return kept
+}
+
+// installPlanFinished returns true if the plan has completed or failed, and so may be garbage collected.
+func installPlanFinished(ip *v1alpha1.InstallPlan) bool {
+	return ip.Status.Phase == v1alpha1.InstallPlanPhaseComplete || ip.Status.Phase == v1alpha1.InstallPlanPhaseFailed
+}
+
+// installPlansToPrune returns the InstallPlans that the policy deletes, oldest first.
+func (p InstallPlanRetentionPolicy) installPlansToPrune(ips []*v1alpha1.InstallPlan, now time.Time) []*v1alpha1.InstallPlan {
+	if len(ips) == 0 {
+		return nil
+	}
+	sorted := append([]*v1alpha1.InstallPlan(nil), ips...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return olderInstallPlan(sorted[i], sorted[j])
+	})
+	kept := make(map[string]struct{})
+	if p.KeepLastPerSubscription {
+		kept = lastPlansPerSubscription(sorted)
+	}
+	newest := sorted[len(sorted)-1].Spec.Generation
+
+	var candidates []*v1alpha1.InstallPlan
+	for _, ip := range sorted {
+		if _, ok := kept[ip.GetName()]; !ok && installPlanFinished(ip) {
+			candidates = append(candidates, ip)
+		}
+	}
+
+	var prune []*v1alpha1.InstallPlan
+	for i, ip := range candidates {
+		if len(prune) >= p.DeletesPerSweep {
+			break
+		}
+		tooMany := len(candidates)-i > p.Count
+		tooOld := p.MaxAge > 0 && ip.Spec.Generation != newest && now.Sub(ip.CreationTimestamp.Time) > p.MaxAge
+		if tooMany || tooOld {
+			prune = append(prune, ip)
+		}
+	}
+	return prune
+}
+
+// gcInstallPlans garbage collects installplans that are too old
+// installplans are ownerrefd to all subscription inputs, so they will not otherwise
+// be GCd unless all inputs have been deleted.
+func (o *Operator) gcInstallPlans(log logrus.FieldLogger, namespace string) {
+	allIps, err := o.lister.OperatorsV1alpha1().InstallPlanLister().InstallPlans(namespace).List(labels.Everything())
+	if err != nil {
+		log.Warn("unable to list installplans for GC")
+	}
+
+	policy := o.installPlanRetention
+	if ns, err := o.lister.CoreV1().NamespaceLister().Get(namespace); err != nil {
+		log.WithError(err).Debug("unable to get namespace installplan retention policy, using default")
+	} else {
+		policy = policy.forNamespace(ns.GetAnnotations(), log)
+	}
+
+	toDelete := policy.installPlansToPrune(allIps, o.now().Time)
+	if len(toDelete) == 0 {
+		return
+	}
+
+	if policy.Archive {
+		if err := o.archiveInstallPlans(namespace, toDelete); err != nil {
+			log.WithError(err).Warn("error archiving installplans, not GCing them")
+			return
+		}
+	}
+
+	for _, i := range toDelete {
+		if err := o.client.OperatorsV1alpha1().InstallPlans(namespace).Delete(context.TODO(), i.GetName(), metav1.DeleteOptions{}); err != nil {
+			log.WithField("deleting", i.GetName()).WithError(err).Warn("error GCing old installplan - may have already been deleted")
+		}
+	}
+}
+
+func archivedInstallPlan(ip *v1alpha1.InstallPlan) ([]byte, error) {
+	out := ip.DeepCopy()
+	out.SetManagedFields(nil)
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func archiveSize(cm *corev1.ConfigMap) int {
+	size := 0
+	for _, data := range cm.BinaryData {
+		size += len(data)
+	}
+	return size
+}
+
+// archiveInstallPlans stores the given InstallPlans in the newest archive ConfigMap of the namespace, creating new
+// archive ConfigMaps as existing ones fill up.
+func (o *Operator) archiveInstallPlans(namespace string, ips []*v1alpha1.InstallPlan) error {
+	archives, err := o.lister.CoreV1().ConfigMapLister().ConfigMaps(namespace).List(labels.SelectorFromSet(labels.Set{InstallPlanArchiveLabelKey: "true"}))
+	if err != nil {
+		return err
+	}
+	var current *corev1.ConfigMap
+	for _, cm := range archives {
+		if current == nil || current.CreationTimestamp.Before(&cm.CreationTimestamp) ||
+			(current.CreationTimestamp.Equal(&cm.CreationTimestamp) && current.GetName() < cm.GetName()) {
+			current = cm
+		}
+	}
+	if current != nil {
+		current = current.DeepCopy()
+	}
+
+	client := o.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace)
+	save := func(cm *corev1.ConfigMap) error {
+		if cm.GetName() == "" {
+			_, err := client.Create(context.TODO(), cm, metav1.CreateOptions{})
+			return err
+		}
+		_, err := client.Update(context.TODO(), cm, metav1.UpdateOptions{})
+		return err
+	}
+
+	dirty := false
+	for _, ip := range ips {
+		data, err := archivedInstallPlan(ip)
+		if err != nil {
+			return fmt.Errorf("error archiving installplan %s: %v", ip.GetName(), err)
+		}
+		if current == nil || archiveSize(current)+len(data) > maxInstallPlanArchiveBytes {
+			if current != nil && dirty {
+				if err := save(current); err != nil {
+					return fmt.Errorf("error archiving installplans: %v", err)
+				}
+			}
+			current = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					GenerateName: installPlanArchivePrefix,
+					Namespace:    namespace,
+					Labels:       map[string]string{InstallPlanArchiveLabelKey: "true"},
+				},
+			}
+		}
+		if current.BinaryData == nil {
+			current.BinaryData = map[string][]byte{}
+		}
+		current.BinaryData[ip.GetName()+installPlanArchiveSuffix] = data
+		dirty = true
+	}
+	if dirty {
+		if err := save(current); err != nil {
+			return fmt.Errorf("error archiving installplans: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package catalog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+func TestInstallPlansToPrune(t *testing.T) {
+	now := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	plan := func(name string, gen int, phase v1alpha1.InstallPlanPhase, age time.Duration, subs ...string) *v1alpha1.InstallPlan {
+		ip := installPlan(name, "ns", phase)
+		ip.Spec.Generation = gen
+		ip.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		for _, sub := range subs {
+			ip.OwnerReferences = append(ip.OwnerReferences, metav1.OwnerReference{Kind: v1alpha1.SubscriptionKind, Name: sub})
+		}
+		return ip
+	}
+	day := 24 * time.Hour
+	ips := []*v1alpha1.InstallPlan{
+		plan("p5", 5, v1alpha1.InstallPlanPhaseComplete, 1*day, "a"),
+		// Plans that haven't finished are never deleted, however stale.
+		plan("manual", 1, v1alpha1.InstallPlanPhaseRequiresApproval, 6*day, "m"),
+		plan("installing", 5, v1alpha1.InstallPlanPhaseInstalling, 2*day, "a"),
+		plan("p1", 1, v1alpha1.InstallPlanPhaseFailed, 5*day, "a"),
+		plan("p3", 3, v1alpha1.InstallPlanPhaseComplete, 3*day, "a"),
+		plan("p2", 2, v1alpha1.InstallPlanPhaseComplete, 4*day, "a", "b"),
+		plan("p4", 4, v1alpha1.InstallPlanPhaseComplete, 2*day, "a"),
+	}
+
+	tests := []struct {
+		name   string
+		policy InstallPlanRetentionPolicy
+		want   []string
+	}{
+		{
+			name:   "WithinCount",
+			policy: DefaultInstallPlanRetentionPolicy(),
+		},
+		{
+			name:   "Count",
+			policy: InstallPlanRetentionPolicy{Count: 2, DeletesPerSweep: 5},
+			want:   []string{"p1", "p2", "p3"},
+		},
+		{
+			name:   "DeletesPerSweep",
+			policy: InstallPlanRetentionPolicy{Count: 2, DeletesPerSweep: 2},
+			want:   []string{"p1", "p2"},
+		},
+		{
+			name:   "MaxAge",
+			policy: InstallPlanRetentionPolicy{Count: 5, DeletesPerSweep: 5, MaxAge: 36 * time.Hour},
+			want:   []string{"p1", "p2", "p3", "p4"},
+		},
+		{
+			name:   "NewestGenerationIsNeverTooOld",
+			policy: InstallPlanRetentionPolicy{Count: 5, DeletesPerSweep: 5, MaxAge: time.Hour},
+			want:   []string{"p1", "p2", "p3", "p4"},
+		},
+		{
+			name:   "KeepLastPerSubscription",
+			policy: InstallPlanRetentionPolicy{Count: 1, DeletesPerSweep: 5, KeepLastPerSubscription: true},
+			want:   []string{"p3"},
+		},
+		{
+			name:   "UnfinishedPlansAreKept",
+			policy: InstallPlanRetentionPolicy{Count: 0, DeletesPerSweep: 10, MaxAge: time.Hour},
+			want:   []string{"p1", "p2", "p3", "p4", "p5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, ip := range tt.policy.installPlansToPrune(ips, now) {
+				got = append(got, ip.GetName())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInstallPlanRetentionForNamespace(t *testing.T) {
+	policy := DefaultInstallPlanRetentionPolicy().forNamespace(map[string]string{
+		InstallPlanRetentionCountAnnotationKey:    "10",
+		InstallPlanRetentionMaxAgeAnnotationKey:   "not-a-duration",
+		InstallPlanRetentionKeepLastAnnotationKey: "true",
+		InstallPlanRetentionArchiveAnnotationKey:  "true",
+	}, logrus.New())
+	require.Equal(t, InstallPlanRetentionPolicy{
+		Count:                   10,
+		DeletesPerSweep:         maxDeletesPerSweep,
+		KeepLastPerSubscription: true,
+		Archive:                 true,
+	}, policy)
+}
+
+func TestGCInstallPlansArchive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	var objs []runtime.Object
+	for i, name := range []string{"p1", "p2", "p3"} {
+		ip := installPlan(name, "ns", v1alpha1.InstallPlanPhaseComplete, "csv")
+		ip.Spec.Generation = i + 1
+		objs = append(objs, ip)
+	}
+	op, err := NewFakeOperator(ctx, "ns", []string{"ns"}, withClientObjs(objs...))
+	require.NoError(t, err)
+	op.installPlanRetention = InstallPlanRetentionPolicy{Count: 1, DeletesPerSweep: 5, Archive: true}
+
+	op.gcInstallPlans(logrus.New(), "ns")
+
+	ips, err := op.client.OperatorsV1alpha1().InstallPlans("ns").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, ips.Items, 1)
+	require.Equal(t, "p3", ips.Items[0].GetName())
+
+	archives, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps("ns").List(ctx, metav1.ListOptions{LabelSelector: InstallPlanArchiveLabelKey + "=true"})
+	require.NoError(t, err)
+	require.Len(t, archives.Items, 1)
+
+	var archived []string
+	for key, data := range archives.Items[0].BinaryData {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		decompressed, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		var ip v1alpha1.InstallPlan
+		require.NoError(t, json.Unmarshal(decompressed, &ip))
+		require.Equal(t, ip.GetName()+installPlanArchiveSuffix, key)
+		require.Equal(t, []string{"csv"}, ip.Spec.ClusterServiceVersionNames)
+		archived = append(archived, ip.GetName())
+	}
+	sort.Strings(archived)
+	require.Equal(t, []string{"p1", "p2"}, archived)
+}
@@ -0,0 +1,416 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/ownerutil"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/scoped"
+)
+
+const (
+	// InstallPlanRolledBack is the condition set on an InstallPlan whose steps were undone.
+	InstallPlanRolledBack v1alpha1.InstallPlanConditionType = "RolledBack"
+
+	// InstallPlanReasonPlanFailed means that the plan was rolled back because it failed.
+	InstallPlanReasonPlanFailed v1alpha1.InstallPlanConditionReason = "InstallPlanFailed"
+
+	// InstallPlanReasonCSVTimeout means that the plan was rolled back because a ClusterServiceVersion that it
+	// installed did not succeed in time.
+	InstallPlanReasonCSVTimeout v1alpha1.InstallPlanConditionReason = "ClusterServiceVersionTimeout"
+
+	// RollbackLabelKey identifies the ConfigMaps holding the state of the objects touched by an InstallPlan.
+	RollbackLabelKey = "olm.installplan-rollback"
+
+	rollbackConfigMapSuffix = "-rollback"
+	rollbackStepKeyPrefix   = "step-"
+
+	// maxRollbackBytes bounds the size of the records of a plan well below the size limit of a ConfigMap. Steps whose
+	// records don't fit are not rolled back.
+	maxRollbackBytes = 900 * 1024
+)
+
+// rollbackRecord is the state of an object before a step of an InstallPlan touched it.
+type rollbackRecord struct {
+	Step      int    `json:"step"`
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+
+	// Created is true if the step created the object, which is deleted on rollback.
+	Created bool `json:"created"`
+
+	// Prior is the object before the step updated it, which is restored on rollback. The contents of Secrets are
+	// never recorded, so Secrets that a step updated are not restored.
+	Prior *unstructured.Unstructured `json:"prior,omitempty"`
+}
+
+func (r rollbackRecord) resourceInterface(client dynamic.Interface) dynamic.ResourceInterface {
+	gvr := schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+	if r.Namespace != "" {
+		return client.Resource(gvr).Namespace(r.Namespace)
+	}
+	return client.Resource(gvr)
+}
+
+func rollbackConfigMapName(plan *v1alpha1.InstallPlan) string {
+	return plan.GetName() + rollbackConfigMapSuffix
+}
+
+// rollbackRecorder captures the objects that the steps of an InstallPlan are about to touch and, once the
+// StepEnsurer has applied a step, records how to undo it. A nil rollbackRecorder records nothing.
+type rollbackRecorder struct {
+	o        *Operator
+	client   dynamic.Interface
+	plan     *v1alpha1.InstallPlan
+	existing *corev1.ConfigMap
+
+	// mu guards the records of steps that are executed concurrently.
+	mu       sync.Mutex
+	captured map[int]rollbackRecord
+	recorded map[string]string
+	size     int
+}
+
+// newRollbackRecorder returns a recorder for the execution of the given plan, or nil if rollback is disabled. The
+// client, which should be the one that applies the steps, is used to read the objects before each step.
+func (o *Operator) newRollbackRecorder(plan *v1alpha1.InstallPlan, client dynamic.Interface) (*rollbackRecorder, error) {
+	if o.installPlanRollbackTimeout <= 0 {
+		return nil, nil
+	}
+	existing, err := o.opClient.KubernetesInterface().CoreV1().ConfigMaps(plan.GetNamespace()).Get(context.TODO(), rollbackConfigMapName(plan), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading rollback snapshots: %v", err)
+	}
+	r := &rollbackRecorder{
+		o:        o,
+		client:   client,
+		plan:     plan,
+		existing: existing,
+		captured: make(map[int]rollbackRecord),
+		recorded: make(map[string]string),
+	}
+	if existing != nil {
+		for k, v := range existing.Data {
+			r.size += len(k) + len(v)
+		}
+	}
+	return r, nil
+}
+
+// capture reads the object that the i'th step is about to apply. Objects captured by an earlier execution of the
+// plan are kept as they were first seen.
+func (r *rollbackRecorder) capture(i int, step *v1alpha1.Step) error {
+	if r == nil {
+		return nil
+	}
+	key := rollbackStepKeyPrefix + strconv.Itoa(i)
+	if r.existing != nil {
+		if _, ok := r.existing.Data[key]; ok {
+			return nil
+		}
+	}
+
+	gvk := schema.GroupVersionKind{Group: step.Resource.Group, Version: step.Resource.Version, Kind: step.Resource.Kind}
+	if gvk.Kind == resolver.BundleSecretKind {
+		// Bundle Secrets are steps of a synthetic kind that are applied as plain Secrets.
+		gvk.Kind = secretKind
+	}
+	resource, err := r.o.apiresourceFromGVK(gvk)
+	if err != nil {
+		return fmt.Errorf("error capturing %s %s for rollback: %v", step.Resource.Kind, step.Resource.Name, err)
+	}
+	record := rollbackRecord{
+		Step:     i,
+		Group:    gvk.Group,
+		Version:  gvk.Version,
+		Resource: resource.Name,
+		Name:     step.Resource.Name,
+	}
+	if resource.Namespaced {
+		record.Namespace = r.plan.GetNamespace()
+	}
+	if gvk.Group == "" && gvk.Kind == secretKind {
+		// Secrets created by the step are still deleted on rollback, but their prior contents are not stored in a
+		// ConfigMap that anyone who can read ConfigMaps can read.
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.captured[i] = record
+		return nil
+	}
+
+	prior, err := record.resourceInterface(r.client).Get(context.TODO(), record.Name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error capturing %s %s for rollback: %v", step.Resource.Kind, step.Resource.Name, err)
+	}
+	if err == nil {
+		prior.SetResourceVersion("")
+		prior.SetUID("")
+		prior.SetCreationTimestamp(metav1.Time{})
+		prior.SetManagedFields(nil)
+		unstructured.RemoveNestedField(prior.Object, "status")
+		record.Prior = prior
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.captured[i] = record
+	return nil
+}
+
+// record notes how to undo the i'th step, given the status that the StepEnsurer reported for it.
+func (r *rollbackRecorder) record(i int, status v1alpha1.StepStatus) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record, ok := r.captured[i]
+	if !ok {
+		return nil
+	}
+	switch status {
+	case v1alpha1.StepStatusCreated:
+		record.Created = true
+		record.Prior = nil
+	case v1alpha1.StepStatusPresent:
+		if record.Prior == nil {
+			// Nothing is known about the object before the step.
+			return nil
+		}
+	default:
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	key := rollbackStepKeyPrefix + strconv.Itoa(i)
+	size := len(key) + len(data)
+	if r.size+size > maxRollbackBytes {
+		return fmt.Errorf("not recording %s %s for rollback: %d byte snapshot exceeds the remaining %d bytes", record.Resource, record.Name, size, maxRollbackBytes-r.size)
+	}
+	r.size += size
+	r.recorded[key] = string(data)
+	return nil
+}
+
+// persist adds the records of this execution to the plan's rollback ConfigMap.
+func (r *rollbackRecorder) persist() error {
+	if r == nil || len(r.recorded) == 0 {
+		return nil
+	}
+	client := r.o.opClient.KubernetesInterface().CoreV1().ConfigMaps(r.plan.GetNamespace())
+
+	if r.existing != nil {
+		cm := r.existing.DeepCopy()
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		for k, v := range r.recorded {
+			cm.Data[k] = v
+		}
+		if _, err := client.Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("error recording rollback snapshots: %v", err)
+		}
+		return nil
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rollbackConfigMapName(r.plan),
+			Namespace: r.plan.GetNamespace(),
+			Labels:    map[string]string{RollbackLabelKey: r.plan.GetName()},
+		},
+		Data: r.recorded,
+	}
+	owner := r.plan.DeepCopy()
+	owner.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.InstallPlanKind))
+	ownerutil.AddNonBlockingOwner(cm, owner)
+	if _, err := client.Create(context.TODO(), cm, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("error recording rollback snapshots: %v", err)
+	}
+	return nil
+}
+
+// syncInstallPlanRollback rolls back a plan in a terminal phase that failed, or that completed but whose
+// ClusterServiceVersions did not succeed within the rollback timeout. Plans without recorded snapshots, or that were
+// already rolled back, are left alone.
+func (o *Operator) syncInstallPlanRollback(plan *v1alpha1.InstallPlan, logger *logrus.Entry) error {
+	if o.installPlanRollbackTimeout <= 0 {
+		return nil
+	}
+	if plan.Status.GetCondition(InstallPlanRolledBack).Status == corev1.ConditionTrue {
+		return nil
+	}
+	cm, err := o.lister.CoreV1().ConfigMapLister().ConfigMaps(plan.GetNamespace()).Get(rollbackConfigMapName(plan))
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var reason v1alpha1.InstallPlanConditionReason
+	var message string
+	switch plan.Status.Phase {
+	case v1alpha1.InstallPlanPhaseFailed:
+		reason = InstallPlanReasonPlanFailed
+		message = plan.Status.GetCondition(v1alpha1.InstallPlanInstalled).Message
+		if message == "" {
+			message = plan.Status.Message
+		}
+	case v1alpha1.InstallPlanPhaseComplete:
+		pending, done := o.pendingClusterServiceVersions(plan)
+		if done {
+			// Every ClusterServiceVersion succeeded, so the snapshots are no longer needed.
+			return o.deleteRollbackConfigMap(cm)
+		}
+		completed := plan.Status.GetCondition(v1alpha1.InstallPlanInstalled).LastTransitionTime
+		if completed == nil {
+			completed = plan.Status.StartTime
+		}
+		if completed != nil && o.now().Sub(completed.Time) < o.installPlanRollbackTimeout {
+			if err := o.ipQueueSet.RequeueAfter(plan.GetNamespace(), plan.GetName(), 5*time.Second); err != nil {
+				logger.WithError(err).Warn("error requeueing installplan to check its clusterserviceversions")
+			}
+			return nil
+		}
+		reason = InstallPlanReasonCSVTimeout
+		message = fmt.Sprintf("clusterserviceversions did not succeed within %s: %s", o.installPlanRollbackTimeout, strings.Join(pending, ", "))
+	default:
+		return nil
+	}
+
+	logger.WithField("reason", reason).Info("rolling back installplan")
+	client, err := o.attenuatedDynamicClient(plan)
+	if err != nil {
+		return fmt.Errorf("error rolling back installplan: %v", err)
+	}
+	if err := o.restoreRollbackRecords(client, cm); err != nil {
+		return fmt.Errorf("error rolling back installplan: %v", err)
+	}
+
+	now := o.now()
+	out := plan.DeepCopy()
+	out.Status.Phase = v1alpha1.InstallPlanPhaseFailed
+	if reason == InstallPlanReasonCSVTimeout {
+		out.Status.SetCondition(v1alpha1.ConditionFailed(v1alpha1.InstallPlanInstalled, v1alpha1.InstallPlanReasonComponentFailed, message, &now))
+		out.Status.Message = message
+	}
+	out.Status.SetCondition(v1alpha1.InstallPlanCondition{
+		Type:               InstallPlanRolledBack,
+		Status:             corev1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		LastUpdateTime:     &now,
+		LastTransitionTime: &now,
+	})
+	if _, err := o.client.OperatorsV1alpha1().InstallPlans(out.GetNamespace()).UpdateStatus(context.TODO(), out, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating installplan status after rollback: %v", err)
+	}
+	o.requeueSubscriptionForInstallPlan(out, logger)
+
+	return o.deleteRollbackConfigMap(cm)
+}
+
+// pendingClusterServiceVersions returns the ClusterServiceVersions of the plan that have not succeeded, and whether
+// all of them have.
+func (o *Operator) pendingClusterServiceVersions(plan *v1alpha1.InstallPlan) ([]string, bool) {
+	var pending []string
+	for _, step := range plan.Status.Plan {
+		if step.Resource.Kind != v1alpha1.ClusterServiceVersionKind {
+			continue
+		}
+		csv, err := o.lister.OperatorsV1alpha1().ClusterServiceVersionLister().ClusterServiceVersions(plan.GetNamespace()).Get(step.Resource.Name)
+		if err != nil {
+			pending = append(pending, fmt.Sprintf("%s (not found)", step.Resource.Name))
+			continue
+		}
+		if csv.Status.Phase != v1alpha1.CSVPhaseSucceeded {
+			pending = append(pending, fmt.Sprintf("%s (%s)", csv.GetName(), csv.Status.Phase))
+		}
+	}
+	return pending, len(pending) == 0
+}
+
+// attenuatedDynamicClient returns a dynamic client with the privileges that the steps of the plan were applied with.
+func (o *Operator) attenuatedDynamicClient(plan *v1alpha1.InstallPlan) (dynamic.Interface, error) {
+	attenuate, err := o.clientAttenuator.AttenuateToServiceAccount(scoped.StaticQuerier(plan.Status.AttenuatedServiceAccountRef))
+	if err != nil {
+		return nil, err
+	}
+	return o.clientFactory.WithConfigTransformer(attenuate).NewDynamicClient()
+}
+
+// restoreRollbackRecords undoes the recorded steps in reverse order with the given client: objects that the plan
+// created are deleted and objects that it updated are returned to their prior state. CustomResourceDefinitions are
+// never recorded, since existing custom resources may already be stored in a new version.
+func (o *Operator) restoreRollbackRecords(client dynamic.Interface, cm *corev1.ConfigMap) error {
+	var records []rollbackRecord
+	for key, data := range cm.Data {
+		if !strings.HasPrefix(key, rollbackStepKeyPrefix) {
+			continue
+		}
+		var record rollbackRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return fmt.Errorf("error parsing rollback snapshot %s: %v", key, err)
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Step > records[j].Step
+	})
+
+	var errs []error
+	for _, record := range records {
+		ri := record.resourceInterface(client)
+		if record.Created {
+			if err := ri.Delete(context.TODO(), record.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if record.Prior == nil {
+			continue
+		}
+		current, err := ri.Get(context.TODO(), record.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = ri.Create(context.TODO(), record.Prior, metav1.CreateOptions{})
+		} else if err == nil {
+			record.Prior.SetResourceVersion(current.GetResourceVersion())
+			_, err = ri.Update(context.TODO(), record.Prior, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (o *Operator) deleteRollbackConfigMap(cm *corev1.ConfigMap) error {
+	err := o.opClient.KubernetesInterface().CoreV1().ConfigMaps(cm.GetNamespace()).Delete(context.TODO(), cm.GetName(), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilclock "k8s.io/apimachinery/pkg/util/clock"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/queueinformer"
+)
+
+var (
+	servicesGVR   = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+)
+
+// withRollback enables rollback on a fake operator whose attenuated dynamic client holds the given objects. The
+// operator's own dynamic client doesn't hold them, since plans are applied and rolled back with the attenuated one.
+func withRollback(t *testing.T, op *Operator, timeout time.Duration, objs ...runtime.Object) {
+	op.installPlanRollbackTimeout = timeout
+	op.ipQueueSet = queueinformer.NewEmptyResourceQueueSet()
+	op.opClient.KubernetesInterface().(*k8sfake.Clientset).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "services", Namespaced: true, Kind: serviceKind},
+			{Name: "configmaps", Namespaced: true, Kind: configMapKind},
+			{Name: "secrets", Namespaced: true, Kind: secretKind},
+		},
+	}}
+	op.clientFactory.(*stubClientFactory).dynamicClient = fakedynamic.NewSimpleDynamicClient(k8sscheme.Scheme, objs...)
+}
+
+func configMapWithData(name, namespace string, data map[string]string) *corev1.ConfigMap {
+	cm := configMap(name, namespace)
+	cm.APIVersion = "v1"
+	cm.Data = data
+	return cm
+}
+
+func rollbackRecordData(t *testing.T, record rollbackRecord) string {
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestExecutePlanRecordsRollback(t *testing.T) {
+	namespace := "ns"
+	old := configMapWithData("cfg", namespace, map[string]string{"a": "old"})
+	plan := withSteps(installPlan("p", namespace, v1alpha1.InstallPlanPhaseInstalling, "csv"),
+		[]*v1alpha1.Step{
+			{
+				Resource: v1alpha1.StepResource{
+					Version:  "v1",
+					Kind:     serviceKind,
+					Name:     "service",
+					Manifest: toManifest(t, service("service", namespace)),
+				},
+				Status: v1alpha1.StepStatusUnknown,
+			},
+			{
+				Resource: v1alpha1.StepResource{
+					Version:  "v1",
+					Kind:     configMapKind,
+					Name:     "cfg",
+					Manifest: toManifest(t, configMapWithData("cfg", namespace, map[string]string{"a": "new"})),
+				},
+				Status: v1alpha1.StepStatusUnknown,
+			},
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	op, err := NewFakeOperator(ctx, namespace, []string{namespace}, withClientObjs(plan), withK8sObjs(old))
+	require.NoError(t, err)
+	withRollback(t, op, time.Minute, old)
+
+	require.NoError(t, op.ExecutePlan(plan))
+
+	cm, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Get(context.TODO(), "p"+rollbackConfigMapSuffix, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "p", cm.GetLabels()[RollbackLabelKey])
+	require.Len(t, cm.GetOwnerReferences(), 1)
+	require.Equal(t, v1alpha1.InstallPlanKind, cm.GetOwnerReferences()[0].Kind)
+	require.Len(t, cm.Data, 2)
+
+	var created, updated rollbackRecord
+	require.NoError(t, json.Unmarshal([]byte(cm.Data["step-0"]), &created))
+	require.True(t, created.Created)
+	require.Equal(t, "services", created.Resource)
+	require.Equal(t, namespace, created.Namespace)
+	require.Nil(t, created.Prior)
+
+	require.NoError(t, json.Unmarshal([]byte(cm.Data["step-1"]), &updated))
+	require.False(t, updated.Created)
+	require.NotNil(t, updated.Prior)
+	data, _, err := unstructured.NestedStringMap(updated.Prior.Object, "data")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "old"}, data)
+}
+
+func TestExecutePlanRecordsRollbackForBundleSecret(t *testing.T) {
+	namespace := "ns"
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: secretKind},
+		ObjectMeta: metav1.ObjectMeta{Name: "bundle-secret", Namespace: namespace},
+		Data:       map[string][]byte{"token": []byte("value")},
+	}
+	plan := withSteps(installPlan("p", namespace, v1alpha1.InstallPlanPhaseInstalling, "csv"),
+		[]*v1alpha1.Step{
+			{
+				Resource: v1alpha1.StepResource{
+					Version:  "v1",
+					Kind:     resolver.BundleSecretKind,
+					Name:     "bundle-secret",
+					Manifest: toManifest(t, secret),
+				},
+				Status: v1alpha1.StepStatusUnknown,
+			},
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	op, err := NewFakeOperator(ctx, namespace, []string{namespace}, withClientObjs(plan))
+	require.NoError(t, err)
+	withRollback(t, op, time.Minute)
+
+	require.NoError(t, op.ExecutePlan(plan))
+	require.Equal(t, v1alpha1.StepStatusCreated, plan.Status.Plan[0].Status)
+
+	cm, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Get(context.TODO(), "p"+rollbackConfigMapSuffix, metav1.GetOptions{})
+	require.NoError(t, err)
+	var created rollbackRecord
+	require.NoError(t, json.Unmarshal([]byte(cm.Data["step-0"]), &created))
+	require.True(t, created.Created)
+	require.Equal(t, "secrets", created.Resource)
+	require.Equal(t, namespace, created.Namespace)
+}
+
+func TestExecutePlanDoesNotRecordSecretContents(t *testing.T) {
+	namespace := "ns"
+	secret := func(value string) *corev1.Secret {
+		return &corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: secretKind},
+			ObjectMeta: metav1.ObjectMeta{Name: "bundle-secret", Namespace: namespace},
+			Data:       map[string][]byte{"token": []byte(value)},
+		}
+	}
+	old := secret("old-value")
+	plan := withSteps(installPlan("p", namespace, v1alpha1.InstallPlanPhaseInstalling, "csv"),
+		[]*v1alpha1.Step{
+			{
+				Resource: v1alpha1.StepResource{
+					Version:  "v1",
+					Kind:     resolver.BundleSecretKind,
+					Name:     "bundle-secret",
+					Manifest: toManifest(t, secret("new-value")),
+				},
+				Status: v1alpha1.StepStatusUnknown,
+			},
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	op, err := NewFakeOperator(ctx, namespace, []string{namespace}, withClientObjs(plan), withK8sObjs(old))
+	require.NoError(t, err)
+	withRollback(t, op, time.Minute, old)
+
+	require.NoError(t, op.ExecutePlan(plan))
+	require.Equal(t, v1alpha1.StepStatusPresent, plan.Status.Plan[0].Status)
+
+	// The updated Secret can't be restored without its contents, so nothing is recorded for it.
+	_, err = op.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Get(context.TODO(), "p"+rollbackConfigMapSuffix, metav1.GetOptions{})
+	require.True(t, apierrors.IsNotFound(err), "unexpected rollback configmap: %v", err)
+}
+
+func TestRollbackRecorderSizeLimit(t *testing.T) {
+	plan := installPlan("p", "ns", v1alpha1.InstallPlanPhaseInstalling, "csv")
+	prior := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       configMapKind,
+		"metadata":   map[string]interface{}{"name": "cfg", "namespace": "ns"},
+		"data":       map[string]interface{}{"a": strings.Repeat("x", maxRollbackBytes/2)},
+	}}
+	r := &rollbackRecorder{
+		plan: plan,
+		captured: map[int]rollbackRecord{
+			0: {Step: 0, Version: "v1", Resource: "configmaps", Namespace: "ns", Name: "cfg", Prior: prior},
+			1: {Step: 1, Version: "v1", Resource: "configmaps", Namespace: "ns", Name: "cfg2", Prior: prior},
+			2: {Step: 2, Version: "v1", Resource: "services", Namespace: "ns", Name: "service"},
+		},
+		recorded: make(map[string]string),
+	}
+
+	require.NoError(t, r.record(0, v1alpha1.StepStatusPresent))
+	require.Error(t, r.record(1, v1alpha1.StepStatusPresent))
+	require.NoError(t, r.record(2, v1alpha1.StepStatusCreated))
+	require.Contains(t, r.recorded, "step-0")
+	require.NotContains(t, r.recorded, "step-1")
+	require.Contains(t, r.recorded, "step-2")
+	require.LessOrEqual(t, r.size, maxRollbackBytes)
+}
+
+func TestSyncInstallPlanRollback(t *testing.T) {
+	namespace := "ns"
+	now := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	minuteAgo := metav1.NewTime(now.Add(-time.Minute))
+
+	failed := installPlan("p", namespace, v1alpha1.InstallPlanPhaseFailed, "csv")
+	failed.Status.SetCondition(v1alpha1.ConditionFailed(v1alpha1.InstallPlanInstalled, v1alpha1.InstallPlanReasonComponentFailed, "step failed", &minuteAgo))
+
+	completed := func() *v1alpha1.InstallPlan {
+		plan := withSteps(installPlan("p", namespace, v1alpha1.InstallPlanPhaseComplete, "csv"), []*v1alpha1.Step{{
+			Resource: v1alpha1.StepResource{
+				Group:   v1alpha1.GroupName,
+				Version: v1alpha1.GroupVersion,
+				Kind:    v1alpha1.ClusterServiceVersionKind,
+				Name:    "csv",
+			},
+			Status: v1alpha1.StepStatusCreated,
+		}})
+		plan.Status.SetCondition(v1alpha1.ConditionMet(v1alpha1.InstallPlanInstalled, &minuteAgo))
+		return plan
+	}
+	installing := csv("csv", namespace, nil, nil)
+	installing.Status.Phase = v1alpha1.CSVPhaseInstalling
+	succeeded := csv("csv", namespace, nil, nil)
+	succeeded.Status.Phase = v1alpha1.CSVPhaseSucceeded
+
+	tests := []struct {
+		name       string
+		plan       *v1alpha1.InstallPlan
+		csv        *v1alpha1.ClusterServiceVersion
+		timeout    time.Duration
+		rolledBack v1alpha1.InstallPlanConditionReason
+		kept       bool
+	}{
+		{
+			name:       "FailedPlan",
+			plan:       failed,
+			timeout:    time.Hour,
+			rolledBack: InstallPlanReasonPlanFailed,
+		},
+		{
+			name:    "CompletedPlanWithinTimeout",
+			plan:    completed(),
+			csv:     installing,
+			timeout: time.Hour,
+			kept:    true,
+		},
+		{
+			name:       "CompletedPlanAfterTimeout",
+			plan:       completed(),
+			csv:        installing,
+			timeout:    time.Second,
+			rolledBack: InstallPlanReasonCSVTimeout,
+		},
+		{
+			name:    "CompletedPlanSucceeded",
+			plan:    completed(),
+			csv:     succeeded,
+			timeout: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+
+			snapshots := configMapWithData("p"+rollbackConfigMapSuffix, namespace, map[string]string{
+				"step-0": rollbackRecordData(t, rollbackRecord{Step: 0, Version: "v1", Resource: "services", Namespace: namespace, Name: "service", Created: true}),
+				"step-1": rollbackRecordData(t, rollbackRecord{Step: 1, Version: "v1", Resource: "configmaps", Namespace: namespace, Name: "cfg", Prior: &unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       configMapKind,
+					"metadata":   map[string]interface{}{"name": "cfg", "namespace": namespace},
+					"data":       map[string]interface{}{"a": "old"},
+				}}}),
+			})
+			clientObjs := []runtime.Object{tt.plan}
+			if tt.csv != nil {
+				clientObjs = append(clientObjs, tt.csv)
+			}
+			op, err := NewFakeOperator(ctx, namespace, []string{namespace}, withClock(utilclock.NewFakeClock(now.Time)), withClientObjs(clientObjs...), withK8sObjs(snapshots))
+			require.NoError(t, err)
+			svc := service("service", namespace)
+			svc.APIVersion = "v1"
+			withRollback(t, op, tt.timeout, svc, configMapWithData("cfg", namespace, map[string]string{"a": "new"}))
+
+			require.NoError(t, op.syncInstallPlanRollback(tt.plan, logrus.NewEntry(op.logger)))
+
+			plan, err := op.client.OperatorsV1alpha1().InstallPlans(namespace).Get(context.TODO(), "p", metav1.GetOptions{})
+			require.NoError(t, err)
+			_, err = op.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Get(context.TODO(), snapshots.GetName(), metav1.GetOptions{})
+			require.Equal(t, tt.kept, err == nil, "unexpected rollback configmap state: %v", err)
+			dynamicClient := op.clientFactory.(*stubClientFactory).dynamicClient
+			_, svcErr := dynamicClient.Resource(servicesGVR).Namespace(namespace).Get(context.TODO(), "service", metav1.GetOptions{})
+			cfg, err := dynamicClient.Resource(configMapsGVR).Namespace(namespace).Get(context.TODO(), "cfg", metav1.GetOptions{})
+			require.NoError(t, err)
+			data, _, err := unstructured.NestedStringMap(cfg.Object, "data")
+			require.NoError(t, err)
+
+			cond := plan.Status.GetCondition(InstallPlanRolledBack)
+			if tt.rolledBack == "" {
+				require.NotEqual(t, corev1.ConditionTrue, cond.Status)
+				require.NoError(t, svcErr)
+				require.Equal(t, map[string]string{"a": "new"}, data)
+				return
+			}
+			require.Equal(t, corev1.ConditionTrue, cond.Status)
+			require.Equal(t, tt.rolledBack, cond.Reason)
+			require.Equal(t, v1alpha1.InstallPlanPhaseFailed, plan.Status.Phase)
+			require.True(t, apierrors.IsNotFound(svcErr), "expected created service to be deleted: %v", svcErr)
+			require.Equal(t, map[string]string{"a": "old"}, data)
+		})
+	}
+}
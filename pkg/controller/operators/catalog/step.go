@@ -122,7 +122,7 @@ func (b *builder) NewCRDV1Step(client apiextensionsv1client.ApiextensionsV1Inter
 			if established && namesAccepted {
 				return v1alpha1.StepStatusCreated, nil
 			}
-		case v1alpha1.StepStatusUnknown, v1alpha1.StepStatusNotPresent:
+		case v1alpha1.StepStatusUnknown, v1alpha1.StepStatusNotPresent, StepStatusMigratingStorage:
 			crd, err := crdlib.UnmarshalV1(manifest)
 			if err != nil {
 				return v1alpha1.StepStatusUnknown, err
@@ -134,12 +134,22 @@ func (b *builder) NewCRDV1Step(client apiextensionsv1client.ApiextensionsV1Inter
 			if k8serrors.IsAlreadyExists(createError) {
 				currentCRD, _ := client.CustomResourceDefinitions().Get(context.TODO(), crd.GetName(), metav1.GetOptions{})
 				crd.SetResourceVersion(currentCRD.GetResourceVersion())
-				if err = validateV1CRDCompatibility(b.dynamicClient, currentCRD, crd); err != nil {
-					return v1alpha1.StepStatusUnknown, errors.Wrapf(err, "error validating existing CRs against new CRD's schema: %s", step.Resource.Name)
-				}
-
 				// check to see if stored versions changed and whether the upgrade could cause potential data loss
 				safe, err := crdlib.SafeStorageVersionUpgrade(currentCRD, crd)
+				if !safe && storageMigrationEnabled(crd) {
+					var done bool
+					if done, err = b.migrateStorageVersion(client.CustomResourceDefinitions(), currentCRD, crd); err != nil {
+						return StepStatusMigratingStorage, errors.Wrapf(err, "error migrating storage version of %s", step.Resource.Name)
+					}
+					if !done {
+						return StepStatusMigratingStorage, nil
+					}
+					if currentCRD, err = client.CustomResourceDefinitions().Get(context.TODO(), crd.GetName(), metav1.GetOptions{}); err != nil {
+						return StepStatusMigratingStorage, errors.Wrapf(err, "error finding the %s CRD", step.Resource.Name)
+					}
+					crd.SetResourceVersion(currentCRD.GetResourceVersion())
+					safe, err = crdlib.SafeStorageVersionUpgrade(currentCRD, crd)
+				}
 				if !safe {
 					b.logger.Errorf("risk of data loss updating %s: %s", step.Resource.Name, err)
 					return v1alpha1.StepStatusUnknown, errors.Wrapf(err, "risk of data loss updating %s", step.Resource.Name)
@@ -213,12 +223,11 @@ func (b *builder) NewCRDV1Beta1Step(client apiextensionsv1beta1client.Apiextensi
 				currentCRD, _ := client.CustomResourceDefinitions().Get(context.TODO(), crd.GetName(), metav1.GetOptions{})
 				crd.SetResourceVersion(currentCRD.GetResourceVersion())
 
-				if err = validateV1Beta1CRDCompatibility(b.dynamicClient, currentCRD, crd); err != nil {
-					return v1alpha1.StepStatusUnknown, errors.Wrapf(err, "error validating existing CRs against new CRD's schema: %s", step.Resource.Name)
-				}
-
 				// check to see if stored versions changed and whether the upgrade could cause potential data loss
 				safe, err := crdlib.SafeStorageVersionUpgrade(currentCRD, crd)
+				if !safe && storageMigrationEnabled(crd) {
+					err = fmt.Errorf("%v, and storage version migration requires an apiextensions.k8s.io/v1 CRD", err)
+				}
 				if !safe {
 					b.logger.Errorf("risk of data loss updating %s: %s", step.Resource.Name, err)
 					return v1alpha1.StepStatusUnknown, errors.Wrapf(err, "risk of data loss updating %s", step.Resource.Name)
@@ -0,0 +1,418 @@
+package catalog
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/ownerutil"
+)
+
+const (
+	// StepDiffLabelKey identifies the ConfigMaps holding the diff of an InstallPlan against the objects on the
+	// cluster. Its value is the name of the InstallPlan. The diff is computed when the plan starts requiring approval.
+	StepDiffLabelKey = "olm.installplan-diff"
+
+	// StepDiffDataKey is the data key of the diff ConfigMap holding the JSON-encoded InstallPlanDiff.
+	StepDiffDataKey = "diff"
+
+	stepDiffConfigMapSuffix = "-diff"
+
+	// maxStepDiffBytes bounds the size of an encoded diff well below the size limit of a ConfigMap.
+	maxStepDiffBytes = 512 * 1024
+)
+
+// StepDiffAction is what applying a step does to the object on the cluster.
+type StepDiffAction string
+
+const (
+	StepDiffCreate    StepDiffAction = "Create"
+	StepDiffUpdate    StepDiffAction = "Update"
+	StepDiffUnchanged StepDiffAction = "Unchanged"
+	StepDiffUnknown   StepDiffAction = "Unknown"
+)
+
+// InstallPlanDiff describes what the steps of an InstallPlan would change on the cluster.
+type InstallPlanDiff struct {
+	Steps []StepDiff `json:"steps"`
+
+	// Truncated is set if changed fields were left out to bound the size of the diff. Permission changes are always
+	// kept.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// StepDiff describes what a single step would change on the cluster.
+type StepDiff struct {
+	Step      int            `json:"step"`
+	Kind      string         `json:"kind"`
+	Name      string         `json:"name"`
+	Namespace string         `json:"namespace,omitempty"`
+	Action    StepDiffAction `json:"action"`
+
+	// Message explains why the action of a step is unknown.
+	Message string `json:"message,omitempty"`
+
+	// ChangedFields are the fields set by the step's manifest whose values differ from those of the existing object.
+	ChangedFields []FieldChange `json:"changedFields,omitempty"`
+
+	// AddedPermissions and RemovedPermissions are the changes to the permissions granted by a Role or ClusterRole.
+	AddedPermissions   []Permission `json:"addedPermissions,omitempty"`
+	RemovedPermissions []Permission `json:"removedPermissions,omitempty"`
+}
+
+// FieldChange is a changed field, with its JSON-encoded values. Old is empty for fields that aren't set yet. The values
+// of Secret data are left out and Redacted is set instead.
+type FieldChange struct {
+	Path     string `json:"path"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// Permission is a single verb granted on a resource or non-resource URL.
+type Permission struct {
+	APIGroup       string `json:"apiGroup,omitempty"`
+	Resource       string `json:"resource,omitempty"`
+	ResourceName   string `json:"resourceName,omitempty"`
+	NonResourceURL string `json:"nonResourceURL,omitempty"`
+	Verb           string `json:"verb"`
+}
+
+func stepDiffConfigMapName(plan *v1alpha1.InstallPlan) string {
+	return plan.GetName() + stepDiffConfigMapSuffix
+}
+
+// changedFields returns the fields set by desired whose values differ from those of existing. Metadata other than
+// labels and annotations, and status, are ignored.
+func changedFields(existing, desired map[string]interface{}) []FieldChange {
+	var changes []FieldChange
+	for key, value := range desired {
+		switch key {
+		case "apiVersion", "kind", "status":
+		case "metadata":
+			existingMeta, _ := existing["metadata"].(map[string]interface{})
+			desiredMeta, _ := value.(map[string]interface{})
+			for _, metaKey := range []string{"labels", "annotations"} {
+				if v, ok := desiredMeta[metaKey]; ok {
+					ev, eok := existingMeta[metaKey]
+					diffValue("metadata."+metaKey, ev, eok, v, &changes)
+				}
+			}
+		default:
+			ev, eok := existing[key]
+			diffValue(key, ev, eok, value, &changes)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func diffValue(path string, existing interface{}, exists bool, desired interface{}, changes *[]FieldChange) {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		if e, ok := existing.(map[string]interface{}); ok {
+			for k, v := range d {
+				ev, eok := e[k]
+				diffValue(path+"."+k, ev, eok, v, changes)
+			}
+			return
+		}
+	case []interface{}:
+		if e, ok := existing.([]interface{}); ok && len(e) == len(d) {
+			for i := range d {
+				diffValue(fmt.Sprintf("%s[%d]", path, i), e[i], true, d[i], changes)
+			}
+			return
+		}
+	}
+	if exists && reflect.DeepEqual(existing, desired) {
+		return
+	}
+	change := FieldChange{Path: path, New: encodeFieldValue(desired)}
+	if exists {
+		change.Old = encodeFieldValue(existing)
+	}
+	*changes = append(*changes, change)
+}
+
+func encodeFieldValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func isSecret(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// secretDataChanges returns the keys of the data of a Secret that desired sets to different values than existing,
+// without their values.
+func secretDataChanges(existing, desired map[string]interface{}) []FieldChange {
+	existingData, _ := existing["data"].(map[string]interface{})
+	var changes []FieldChange
+	data, _ := desired["data"].(map[string]interface{})
+	for key, value := range data {
+		if ev, ok := existingData[key]; !ok || ev != value {
+			changes = append(changes, FieldChange{Path: "data." + key, Redacted: true})
+		}
+	}
+	// stringData is written into data, base64 encoded.
+	stringData, _ := desired["stringData"].(map[string]interface{})
+	for key, value := range stringData {
+		str, _ := value.(string)
+		if ev, ok := existingData[key]; !ok || ev != base64.StdEncoding.EncodeToString([]byte(str)) {
+			changes = append(changes, FieldChange{Path: "stringData." + key, Redacted: true})
+		}
+	}
+	return changes
+}
+
+// permissions returns the permissions granted by the rules of a Role or ClusterRole, sorted.
+func permissions(obj map[string]interface{}) ([]Permission, error) {
+	var role struct {
+		Rules []rbacv1.PolicyRule `json:"rules"`
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, &role); err != nil {
+		return nil, err
+	}
+	set := make(map[Permission]struct{})
+	for _, rule := range role.Rules {
+		for _, verb := range rule.Verbs {
+			for _, url := range rule.NonResourceURLs {
+				set[Permission{NonResourceURL: url, Verb: verb}] = struct{}{}
+			}
+			names := rule.ResourceNames
+			if len(names) == 0 {
+				names = []string{""}
+			}
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					for _, name := range names {
+						set[Permission{APIGroup: group, Resource: resource, ResourceName: name, Verb: verb}] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+	perms := make([]Permission, 0, len(set))
+	for p := range set {
+		perms = append(perms, p)
+	}
+	sort.Slice(perms, func(i, j int) bool {
+		return permissionKey(perms[i]) < permissionKey(perms[j])
+	})
+	return perms, nil
+}
+
+func permissionKey(p Permission) string {
+	return strings.Join([]string{p.APIGroup, p.Resource, p.ResourceName, p.NonResourceURL, p.Verb}, "/")
+}
+
+// subtractPermissions returns the permissions in a that aren't in b.
+func subtractPermissions(a, b []Permission) []Permission {
+	in := make(map[Permission]struct{}, len(b))
+	for _, p := range b {
+		in[p] = struct{}{}
+	}
+	var out []Permission
+	for _, p := range a {
+		if _, ok := in[p]; !ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// diffObjects returns the diff of applying desired over existing, which is nil if the object doesn't exist yet.
+func diffObjects(diff *StepDiff, existing, desired *unstructured.Unstructured) error {
+	var existingContent map[string]interface{}
+	if existing != nil {
+		existingContent = existing.UnstructuredContent()
+		desiredContent := desired.UnstructuredContent()
+		if isSecret(desired) {
+			// The values of Secrets must not end up in the diff, which anyone who can read ConfigMaps can read.
+			desiredContent = make(map[string]interface{}, len(desired.Object))
+			for key, value := range desired.Object {
+				if key != "data" && key != "stringData" {
+					desiredContent[key] = value
+				}
+			}
+		}
+		diff.ChangedFields = changedFields(existingContent, desiredContent)
+		if isSecret(desired) {
+			diff.ChangedFields = append(diff.ChangedFields, secretDataChanges(existingContent, desired.UnstructuredContent())...)
+			sort.Slice(diff.ChangedFields, func(i, j int) bool {
+				return diff.ChangedFields[i].Path < diff.ChangedFields[j].Path
+			})
+		}
+	}
+
+	if kind := desired.GetKind(); kind == roleKind || kind == clusterRoleKind {
+		// The changes to the rules are reported as permissions instead.
+		fields := diff.ChangedFields[:0]
+		for _, change := range diff.ChangedFields {
+			if change.Path != "rules" && !strings.HasPrefix(change.Path, "rules[") {
+				fields = append(fields, change)
+			}
+		}
+		diff.ChangedFields = fields
+		desiredPerms, err := permissions(desired.UnstructuredContent())
+		if err != nil {
+			return fmt.Errorf("error reading rules: %v", err)
+		}
+		var existingPerms []Permission
+		if existing != nil {
+			if existingPerms, err = permissions(existingContent); err != nil {
+				return fmt.Errorf("error reading rules: %v", err)
+			}
+		}
+		diff.AddedPermissions = subtractPermissions(desiredPerms, existingPerms)
+		diff.RemovedPermissions = subtractPermissions(existingPerms, desiredPerms)
+	}
+
+	switch {
+	case existing == nil:
+		diff.Action = StepDiffCreate
+	case len(diff.ChangedFields) > 0 || len(diff.AddedPermissions) > 0 || len(diff.RemovedPermissions) > 0:
+		diff.Action = StepDiffUpdate
+	default:
+		diff.Action = StepDiffUnchanged
+	}
+	return nil
+}
+
+// stepDiff compares the manifest of a step with the object on the cluster.
+func (o *Operator) stepDiff(plan *v1alpha1.InstallPlan, i int, r ManifestResolver) StepDiff {
+	step := plan.Status.Plan[i]
+	diff := StepDiff{Step: i, Kind: step.Resource.Kind, Name: step.Resource.Name, Action: StepDiffUnknown}
+
+	manifest, err := r.ManifestForStep(step)
+	if err != nil {
+		diff.Message = err.Error()
+		return diff
+	}
+	if manifest == "" {
+		diff.Message = "step has no manifest"
+		return diff
+	}
+	desired := &unstructured.Unstructured{}
+	if err := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 10).Decode(desired); err != nil {
+		diff.Message = fmt.Sprintf("error decoding manifest: %v", err)
+		return diff
+	}
+	gvk := desired.GroupVersionKind()
+	if gvk.Kind == "" {
+		gvk = schema.GroupVersionKind{Group: step.Resource.Group, Version: step.Resource.Version, Kind: step.Resource.Kind}
+		desired.SetGroupVersionKind(gvk)
+	}
+	resource, err := o.apiresourceFromGVK(gvk)
+	if err != nil {
+		diff.Message = err.Error()
+		return diff
+	}
+
+	gvr := gvk.GroupVersion().WithResource(resource.Name)
+	client := o.dynamicClient.Resource(gvr)
+	var existing *unstructured.Unstructured
+	if resource.Namespaced {
+		diff.Namespace = plan.GetNamespace()
+		existing, err = client.Namespace(diff.Namespace).Get(context.TODO(), desired.GetName(), metav1.GetOptions{})
+	} else {
+		existing, err = client.Get(context.TODO(), desired.GetName(), metav1.GetOptions{})
+	}
+	if apierrors.IsNotFound(err) {
+		existing, err = nil, nil
+	}
+	if err != nil {
+		diff.Message = err.Error()
+		return diff
+	}
+
+	if err := diffObjects(&diff, existing, desired); err != nil {
+		diff.Action = StepDiffUnknown
+		diff.Message = err.Error()
+	}
+	return diff
+}
+
+// encode returns the JSON encoding of the diff, leaving out changed fields as necessary to fit within
+// maxStepDiffBytes.
+func (d *InstallPlanDiff) encode() ([]byte, error) {
+	data, err := json.Marshal(d)
+	if err != nil || len(data) <= maxStepDiffBytes {
+		return data, err
+	}
+	d.Truncated = true
+	// Drop the changed fields of the largest steps first.
+	order := make([]int, len(d.Steps))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return len(d.Steps[order[i]].ChangedFields) > len(d.Steps[order[j]].ChangedFields)
+	})
+	for _, i := range order {
+		d.Steps[i].ChangedFields = nil
+		if data, err = json.Marshal(d); err != nil || len(data) <= maxStepDiffBytes {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// recordStepDiff stores the diff of a plan that requires approval against the objects on the cluster, so that
+// approvers can see what the plan will change.
+func (o *Operator) recordStepDiff(plan *v1alpha1.InstallPlan, logger logrus.FieldLogger) error {
+	r := newManifestResolver(plan.GetNamespace(), o.lister.CoreV1().ConfigMapLister(), o.logger)
+	diff := &InstallPlanDiff{Steps: make([]StepDiff, 0, len(plan.Status.Plan))}
+	for i := range plan.Status.Plan {
+		diff.Steps = append(diff.Steps, o.stepDiff(plan, i, r))
+	}
+	data, err := diff.encode()
+	if err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stepDiffConfigMapName(plan),
+			Namespace: plan.GetNamespace(),
+			Labels:    map[string]string{StepDiffLabelKey: plan.GetName()},
+		},
+		Data: map[string]string{StepDiffDataKey: string(data)},
+	}
+	owner := plan.DeepCopy()
+	owner.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind(v1alpha1.InstallPlanKind))
+	ownerutil.AddNonBlockingOwner(cm, owner)
+	if _, err := o.opClient.KubernetesInterface().CoreV1().ConfigMaps(plan.GetNamespace()).Create(context.TODO(), cm, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("error recording installplan diff: %v", err)
+	}
+	logger.WithField("configmap", cm.GetName()).Debug("recorded installplan diff")
+	return nil
+}
+
+// hasStepDiff returns true if the diff of a plan has been recorded.
+func (o *Operator) hasStepDiff(plan *v1alpha1.InstallPlan) bool {
+	_, err := o.lister.CoreV1().ConfigMapLister().ConfigMaps(plan.GetNamespace()).Get(stepDiffConfigMapName(plan))
+	return err == nil
+}
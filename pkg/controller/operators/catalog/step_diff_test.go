@@ -0,0 +1,232 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+)
+
+func TestChangedFields(t *testing.T) {
+	existing := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":            "a",
+			"resourceVersion": "7",
+			"labels":          map[string]interface{}{"app": "a"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"ports":    []interface{}{int64(80)},
+			"extra":    "kept",
+		},
+		"status": map[string]interface{}{"ready": true},
+	}
+	desired := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Widget",
+		"metadata": map[string]interface{}{
+			"name":   "a",
+			"labels": map[string]interface{}{"app": "a", "tier": "web"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"ports":    []interface{}{int64(80), int64(443)},
+		},
+		"status": map[string]interface{}{},
+	}
+
+	require.Equal(t, []FieldChange{
+		{Path: "metadata.labels.tier", New: `"web"`},
+		{Path: "spec.ports", Old: "[80]", New: "[80,443]"},
+		{Path: "spec.replicas", Old: "1", New: "2"},
+	}, changedFields(existing, desired))
+	require.Empty(t, changedFields(existing, existing))
+}
+
+func clusterRole(name string, rules ...rbacv1.PolicyRule) *unstructured.Unstructured {
+	role := &rbacv1.ClusterRole{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: clusterRoleKind},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      rules,
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(role)
+	if err != nil {
+		panic(err)
+	}
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestDiffObjectsPermissions(t *testing.T) {
+	existing := clusterRole("r", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "list"}})
+	desired := clusterRole("r",
+		rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+		rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+		rbacv1.PolicyRule{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}},
+	)
+
+	var diff StepDiff
+	require.NoError(t, diffObjects(&diff, existing, desired))
+	require.Equal(t, StepDiffUpdate, diff.Action)
+	require.Equal(t, []Permission{
+		{NonResourceURL: "/metrics", Verb: "get"},
+		{Resource: "secrets", Verb: "get"},
+	}, diff.AddedPermissions)
+	require.Equal(t, []Permission{{Resource: "configmaps", Verb: "list"}}, diff.RemovedPermissions)
+
+	// Every permission of a new role is added.
+	diff = StepDiff{}
+	require.NoError(t, diffObjects(&diff, nil, existing))
+	require.Equal(t, StepDiffCreate, diff.Action)
+	require.Len(t, diff.AddedPermissions, 2)
+	require.Empty(t, diff.RemovedPermissions)
+
+	diff = StepDiff{}
+	require.NoError(t, diffObjects(&diff, existing, existing))
+	require.Equal(t, StepDiffUnchanged, diff.Action)
+}
+
+func TestRecordStepDiff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	existing := clusterRole("r", rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}})
+	desired := clusterRole("r",
+		rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+		rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+	)
+	manifest, err := json.Marshal(desired)
+	require.NoError(t, err)
+
+	plan := installPlan("p", "ns", v1alpha1.InstallPlanPhaseRequiresApproval, "csv")
+	plan.Status.Plan = []*v1alpha1.Step{
+		{
+			Resolving: "csv",
+			Resource:  v1alpha1.StepResource{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: clusterRoleKind, Name: "r", Manifest: string(manifest)},
+			Status:    v1alpha1.StepStatusUnknown,
+		},
+		{
+			Resolving: "csv",
+			Resource:  v1alpha1.StepResource{Version: "v1", Kind: "ServiceAccount", Name: "sa", Manifest: `{"apiVersion":"v1","kind":"ServiceAccount","metadata":{"name":"sa"}}`},
+			Status:    v1alpha1.StepStatusUnknown,
+		},
+	}
+
+	op, err := NewFakeOperator(ctx, "ns", []string{"ns"}, withClientObjs(plan))
+	require.NoError(t, err)
+	fakeClient := op.opClient.KubernetesInterface().(*k8sfake.Clientset)
+	fakeClient.Resources = append(fakeClient.Resources,
+		&metav1.APIResourceList{
+			GroupVersion: "rbac.authorization.k8s.io/v1",
+			APIResources: []metav1.APIResource{{Name: "clusterroles", Kind: clusterRoleKind}},
+		},
+		&metav1.APIResourceList{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true}},
+		},
+	)
+	op.dynamicClient = fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}: "ClusterRoleList",
+		{Version: "v1", Resource: "serviceaccounts"}:                                  "ServiceAccountList",
+	}, existing)
+
+	require.NoError(t, op.recordStepDiff(plan, logrus.New()))
+
+	cm, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps("ns").Get(ctx, stepDiffConfigMapName(plan), metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "p", cm.GetLabels()[StepDiffLabelKey])
+	require.Len(t, cm.GetOwnerReferences(), 1)
+
+	var diff InstallPlanDiff
+	require.NoError(t, json.Unmarshal([]byte(cm.Data[StepDiffDataKey]), &diff))
+	require.Equal(t, InstallPlanDiff{Steps: []StepDiff{
+		{
+			Step:             0,
+			Kind:             clusterRoleKind,
+			Name:             "r",
+			Action:           StepDiffUpdate,
+			AddedPermissions: []Permission{{Resource: "secrets", Verb: "get"}},
+		},
+		{
+			Step:      1,
+			Kind:      "ServiceAccount",
+			Name:      "sa",
+			Namespace: "ns",
+			Action:    StepDiffCreate,
+		},
+	}}, diff)
+}
+
+func TestRecordStepDiffSecret(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	existing := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "s", "namespace": "ns"},
+		"data": map[string]interface{}{
+			"user":     "YWRtaW4=",
+			"password": "b2xkLXBhc3N3b3Jk",
+		},
+	}}
+	manifest := `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"s"},"type":"Opaque",` +
+		`"data":{"user":"YWRtaW4=","password":"bmV3LXBhc3N3b3Jk","token":"dG9rZW4="},"stringData":{"extra":"plain-value"}}`
+
+	plan := installPlan("p", "ns", v1alpha1.InstallPlanPhaseRequiresApproval, "csv")
+	plan.Status.Plan = []*v1alpha1.Step{
+		{
+			Resolving: "csv",
+			Resource:  v1alpha1.StepResource{Version: "v1", Kind: resolver.BundleSecretKind, Name: "s", Manifest: manifest},
+			Status:    v1alpha1.StepStatusUnknown,
+		},
+	}
+
+	op, err := NewFakeOperator(ctx, "ns", []string{"ns"}, withClientObjs(plan))
+	require.NoError(t, err)
+	fakeClient := op.opClient.KubernetesInterface().(*k8sfake.Clientset)
+	fakeClient.Resources = append(fakeClient.Resources, &metav1.APIResourceList{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{{Name: "secrets", Kind: "Secret", Namespaced: true}},
+	})
+	op.dynamicClient = fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "secrets"}: "SecretList",
+	}, existing)
+
+	require.NoError(t, op.recordStepDiff(plan, logrus.New()))
+
+	cm, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps("ns").Get(ctx, stepDiffConfigMapName(plan), metav1.GetOptions{})
+	require.NoError(t, err)
+	for _, value := range []string{"YWRtaW4=", "b2xkLXBhc3N3b3Jk", "bmV3LXBhc3N3b3Jk", "dG9rZW4=", "plain-value"} {
+		require.NotContains(t, cm.Data[StepDiffDataKey], value)
+	}
+
+	var diff InstallPlanDiff
+	require.NoError(t, json.Unmarshal([]byte(cm.Data[StepDiffDataKey]), &diff))
+	require.Equal(t, InstallPlanDiff{Steps: []StepDiff{
+		{
+			Step:      0,
+			Kind:      resolver.BundleSecretKind,
+			Name:      "s",
+			Namespace: "ns",
+			Action:    StepDiffUpdate,
+			ChangedFields: []FieldChange{
+				{Path: "data.password", Redacted: true},
+				{Path: "data.token", Redacted: true},
+				{Path: "stringData.extra", Redacted: true},
+				{Path: "type", New: `"Opaque"`},
+			},
+		},
+	}}, diff)
+}
@@ -0,0 +1,320 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/clients"
+)
+
+// StepDurationsAnnotationKey is the InstallPlan annotation holding how long each of its steps took to apply.
+const StepDurationsAnnotationKey = "olm.step-durations"
+
+// stepWorker holds the clients with which a step is applied. A worker applies one step at a time, so that the API
+// warnings that its clients receive can be attributed to that step.
+type stepWorker struct {
+	wr                   warningRecorder
+	ensurer              *StepEnsurer
+	dynamicClient        dynamic.Interface
+	builder              *builder
+	builderDynamicClient dynamic.Interface
+}
+
+func (o *Operator) newStepWorker(plan *v1alpha1.InstallPlan, attenuate clients.ConfigTransformer, r ManifestResolver) (*stepWorker, error) {
+	w := &stepWorker{}
+	factory := o.clientFactory.WithConfigTransformer(clients.SetWarningHandler(&w.wr))
+
+	attenuatedFactory := factory.WithConfigTransformer(attenuate)
+	kubeclient, err := attenuatedFactory.NewOperatorClient()
+	if err != nil {
+		o.logger.Errorf("failed to get a client for plan execution: %v", err)
+		return nil, err
+	}
+	crclient, err := attenuatedFactory.NewKubernetesClient()
+	if err != nil {
+		o.logger.Errorf("failed to get a client for plan execution: %v", err)
+		return nil, err
+	}
+	w.dynamicClient, err = attenuatedFactory.NewDynamicClient()
+	if err != nil {
+		o.logger.Errorf("failed to get a client for plan execution: %v", err)
+		return nil, err
+	}
+	w.ensurer = newStepEnsurer(kubeclient, crclient, w.dynamicClient)
+
+	// CRDs should be installed via the default OLM (cluster-admin) client and not the scoped client specified by the AttenuatedServiceAccount
+	// the StepBuilder is currently only implemented for CRD types
+	// TODO give the StepBuilder both OLM and scoped clients when it supports new scoped types
+	builderKubeClient, err := factory.NewOperatorClient()
+	if err != nil {
+		o.logger.Errorf("failed to get a client for plan execution- %v", err)
+		return nil, err
+	}
+	w.builderDynamicClient, err = factory.NewDynamicClient()
+	if err != nil {
+		o.logger.Errorf("failed to get a client for plan execution- %v", err)
+		return nil, err
+	}
+	w.builder = newBuilder(plan, o.lister.OperatorsV1alpha1().ClusterServiceVersionLister(), builderKubeClient, w.builderDynamicClient, r, o.logger)
+	return w, nil
+}
+
+// stepApplied returns true if a step with the given status needs no further work.
+func stepApplied(status v1alpha1.StepStatus) bool {
+	return status == v1alpha1.StepStatusCreated || status == v1alpha1.StepStatusPresent
+}
+
+// builtinStepKinds are the kinds of step that are neither custom resources nor ClusterServiceVersions.
+var builtinStepKinds = map[string]struct{}{
+	crdKind:                            {},
+	secretKind:                         {},
+	clusterRoleKind:                    {},
+	clusterRoleBindingKind:             {},
+	roleKind:                           {},
+	roleBindingKind:                    {},
+	serviceAccountKind:                 {},
+	serviceKind:                        {},
+	configMapKind:                      {},
+	resolver.BundleSecretKind:          {},
+	v1alpha1.SubscriptionKind:          {},
+	v1alpha1.ClusterServiceVersionKind: {},
+}
+
+// stepOwner returns the name of the ClusterServiceVersion that must exist before the step is applied, because the
+// applied object is owned by it, or an empty string if there is none.
+func stepOwner(step *v1alpha1.Step) string {
+	switch step.Resource.Kind {
+	case crdKind, v1alpha1.SubscriptionKind, v1alpha1.ClusterServiceVersionKind:
+		return ""
+	}
+	return step.Resolving
+}
+
+// dependsOn returns true if step must be applied after other. Custom resources are applied after
+// CustomResourceDefinitions and bindings after the ServiceAccounts and roles of the same operator. A
+// ClusterServiceVersion is applied last among the steps that don't need it, and before the steps that it owns.
+func dependsOn(step, other *v1alpha1.Step) bool {
+	if owner := stepOwner(step); owner != "" && other.Resource.Kind == v1alpha1.ClusterServiceVersionKind && other.Resource.Name == owner {
+		return true
+	}
+	switch step.Resource.Kind {
+	case v1alpha1.ClusterServiceVersionKind:
+		return other.Resource.Kind != v1alpha1.ClusterServiceVersionKind && stepOwner(other) == ""
+	case roleBindingKind, clusterRoleBindingKind:
+		switch other.Resource.Kind {
+		case serviceAccountKind, roleKind, clusterRoleKind:
+			return other.Resolving == step.Resolving
+		}
+		return false
+	}
+	if _, ok := builtinStepKinds[step.Resource.Kind]; ok {
+		return false
+	}
+	return other.Resource.Kind == crdKind
+}
+
+// stepGraph orders the steps of an InstallPlan by their dependencies on one another.
+type stepGraph struct {
+	steps      []*v1alpha1.Step
+	deps       []int
+	dependents [][]int
+}
+
+func newStepGraph(steps []*v1alpha1.Step) *stepGraph {
+	g := &stepGraph{
+		steps:      steps,
+		deps:       make([]int, len(steps)),
+		dependents: make([][]int, len(steps)),
+	}
+	for i, step := range steps {
+		for j, other := range steps {
+			if i != j && dependsOn(step, other) {
+				g.deps[i]++
+				g.dependents[j] = append(g.dependents[j], i)
+			}
+		}
+	}
+	return g
+}
+
+// unschedulable returns the steps that can never be started because they are part of, or depend on, a cycle of
+// steps that depend on one another.
+func (g *stepGraph) unschedulable() []int {
+	deps := append([]int(nil), g.deps...)
+	var ready []int
+	for i := range g.steps {
+		if deps[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		step := ready[0]
+		ready = ready[1:]
+		for _, dependent := range g.dependents[step] {
+			if deps[dependent]--; deps[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	var blocked []int
+	for i, n := range deps {
+		if n > 0 {
+			blocked = append(blocked, i)
+		}
+	}
+	return blocked
+}
+
+// unschedulableStepsError is returned when the steps of an InstallPlan can't be ordered. Unlike a failure to apply a
+// step, it doesn't go away with retries.
+type unschedulableStepsError struct {
+	steps []string
+}
+
+func (e *unschedulableStepsError) Error() string {
+	return fmt.Sprintf("steps can't be applied because they depend on one another: %s", strings.Join(e.steps, ", "))
+}
+
+type stepResult struct {
+	step     int
+	worker   int
+	duration time.Duration
+	err      error
+}
+
+// run executes up to parallelism steps at a time, each on a distinct worker, starting a step once every step that
+// it depends on has been applied. Steps that depend on a step that failed, or that isn't applied yet, are left for a
+// later sync. It returns how long each step that wasn't already applied took to apply successfully, and the error of
+// the first failed step in plan order. No step is executed if parallelism is less than one or if some steps can never
+// be started.
+func (g *stepGraph) run(parallelism int, execute func(worker, step int) error) (map[int]time.Duration, error) {
+	if parallelism < 1 {
+		return nil, fmt.Errorf("invalid step parallelism %d", parallelism)
+	}
+	if blocked := g.unschedulable(); len(blocked) > 0 {
+		err := &unschedulableStepsError{}
+		for _, i := range blocked {
+			err.steps = append(err.steps, fmt.Sprintf("%s %s", g.steps[i].Resource.Kind, g.steps[i].Resource.Name))
+		}
+		return nil, err
+	}
+
+	deps := append([]int(nil), g.deps...)
+	applied := make([]bool, len(g.steps))
+	var ready []int
+	for i, step := range g.steps {
+		applied[i] = stepApplied(step.Status)
+		if deps[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	free := make([]int, parallelism)
+	for i := range free {
+		free[i] = i
+	}
+
+	results := make(chan stepResult)
+	durations := make(map[int]time.Duration)
+	errs := make(map[int]error)
+	var running int
+	for len(ready) > 0 || running > 0 {
+		for len(ready) > 0 && len(free) > 0 {
+			step, worker := ready[0], free[len(free)-1]
+			ready, free = ready[1:], free[:len(free)-1]
+			running++
+			go func() {
+				start := time.Now()
+				err := execute(worker, step)
+				results <- stepResult{step: step, worker: worker, duration: time.Since(start), err: err}
+			}()
+		}
+
+		result := <-results
+		running--
+		free = append(free, result.worker)
+		if result.err != nil {
+			errs[result.step] = result.err
+			continue
+		}
+		if !stepApplied(g.steps[result.step].Status) {
+			continue
+		}
+		if !applied[result.step] {
+			durations[result.step] = result.duration
+		}
+		for _, dependent := range g.dependents[result.step] {
+			if deps[dependent]--; deps[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		sort.Ints(ready)
+	}
+
+	for i := range g.steps {
+		if err, ok := errs[i]; ok {
+			return durations, err
+		}
+	}
+	return durations, nil
+}
+
+// stepDuration is how long a step of an InstallPlan took to apply.
+type stepDuration struct {
+	Step     int    `json:"step"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Duration string `json:"duration"`
+}
+
+// setStepDurations merges the given step durations into the plan's StepDurationsAnnotationKey annotation.
+func setStepDurations(plan *v1alpha1.InstallPlan, durations map[int]time.Duration) error {
+	if len(durations) == 0 {
+		return nil
+	}
+
+	byStep := make(map[int]stepDuration)
+	if data, ok := plan.GetAnnotations()[StepDurationsAnnotationKey]; ok {
+		var recorded []stepDuration
+		if err := json.Unmarshal([]byte(data), &recorded); err == nil {
+			for _, d := range recorded {
+				byStep[d.Step] = d
+			}
+		}
+	}
+	for i, d := range durations {
+		byStep[i] = stepDuration{
+			Step:     i,
+			Kind:     plan.Status.Plan[i].Resource.Kind,
+			Name:     plan.Status.Plan[i].Resource.Name,
+			Duration: d.Round(time.Millisecond).String(),
+		}
+	}
+
+	all := make([]stepDuration, 0, len(byStep))
+	for _, d := range byStep {
+		all = append(all, d)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Step < all[j].Step
+	})
+	data, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	// The plan may share its annotations with a cached copy.
+	annotations := make(map[string]string, len(plan.GetAnnotations())+1)
+	for k, v := range plan.GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[StepDurationsAnnotationKey] = string(data)
+	plan.SetAnnotations(annotations)
+	return nil
+}
@@ -0,0 +1,188 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+func step(kind, name string) *v1alpha1.Step {
+	return &v1alpha1.Step{
+		Resource: v1alpha1.StepResource{Kind: kind, Name: name},
+		Status:   v1alpha1.StepStatusUnknown,
+	}
+}
+
+func resolvingStep(kind, name, resolving string) *v1alpha1.Step {
+	s := step(kind, name)
+	s.Resolving = resolving
+	return s
+}
+
+func TestStepGraphRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       []*v1alpha1.Step
+		fail        map[int]bool
+		parallelism int
+		// before maps each step to the steps that must have been applied before it is executed.
+		before   map[int][]int
+		executed []int
+		err      bool
+	}{
+		{
+			name: "DependencyOrder",
+			steps: []*v1alpha1.Step{
+				step(v1alpha1.ClusterServiceVersionKind, "csv"),
+				step("Widget", "widget"),
+				step(roleBindingKind, "binding"),
+				step(crdKind, "widgets.example.com"),
+				step(serviceAccountKind, "sa"),
+				step(roleKind, "role"),
+				step(configMapKind, "cfg"),
+			},
+			parallelism: 3,
+			before: map[int][]int{
+				0: {1, 2, 3, 4, 5, 6},
+				1: {3},
+				2: {4, 5},
+			},
+			executed: []int{0, 1, 2, 3, 4, 5, 6},
+		},
+		{
+			name: "OwnedSteps",
+			steps: []*v1alpha1.Step{
+				resolvingStep(roleBindingKind, "binding", "csv"),
+				resolvingStep(v1alpha1.ClusterServiceVersionKind, "csv", "csv"),
+				resolvingStep(serviceAccountKind, "sa", "csv"),
+				resolvingStep(crdKind, "widgets.example.com", "csv"),
+				resolvingStep("Widget", "widget", "csv"),
+				resolvingStep(serviceAccountKind, "other", "other-csv"),
+			},
+			parallelism: 4,
+			before: map[int][]int{
+				0: {1, 2},
+				1: {3},
+				2: {1},
+				4: {1, 3},
+			},
+			executed: []int{0, 1, 2, 3, 4, 5},
+		},
+		{
+			name: "FailedDependency",
+			steps: []*v1alpha1.Step{
+				step(crdKind, "widgets.example.com"),
+				step("Widget", "widget"),
+				step(serviceKind, "service"),
+				step(v1alpha1.ClusterServiceVersionKind, "csv"),
+			},
+			fail:        map[int]bool{0: true},
+			parallelism: 2,
+			executed:    []int{0, 2},
+			err:         true,
+		},
+		{
+			name: "AppliedStepsReleaseDependents",
+			steps: []*v1alpha1.Step{
+				{Resource: v1alpha1.StepResource{Kind: crdKind, Name: "widgets.example.com"}, Status: v1alpha1.StepStatusPresent},
+				step("Widget", "widget"),
+			},
+			parallelism: 1,
+			before:      map[int][]int{1: {0}},
+			executed:    []int{0, 1},
+		},
+		{
+			name: "NoWorkers",
+			steps: []*v1alpha1.Step{
+				step(serviceAccountKind, "sa"),
+			},
+			parallelism: 0,
+			err:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var executed []int
+			durations, err := newStepGraph(tt.steps).run(tt.parallelism, func(worker, i int) error {
+				mu.Lock()
+				assert.Less(t, worker, tt.parallelism)
+				for _, dep := range tt.before[i] {
+					assert.True(t, stepApplied(tt.steps[dep].Status), "step %d executed before step %d", i, dep)
+				}
+				executed = append(executed, i)
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if tt.fail[i] {
+					return errors.New("failed")
+				}
+				if !stepApplied(tt.steps[i].Status) {
+					tt.steps[i].Status = v1alpha1.StepStatusCreated
+				}
+				return nil
+			})
+			require.Equal(t, tt.err, err != nil)
+			require.ElementsMatch(t, tt.executed, executed)
+			for i := range durations {
+				require.NotContains(t, tt.fail, i)
+			}
+		})
+	}
+}
+
+func TestSetStepDurations(t *testing.T) {
+	plan := withSteps(installPlan("p", "ns", v1alpha1.InstallPlanPhaseInstalling, "csv"), []*v1alpha1.Step{
+		step(crdKind, "widgets.example.com"),
+		step(v1alpha1.ClusterServiceVersionKind, "csv"),
+	})
+	cached := map[string]string{"other": "value"}
+	plan.SetAnnotations(cached)
+
+	require.NoError(t, setStepDurations(plan, map[int]time.Duration{0: time.Second}))
+	require.NoError(t, setStepDurations(plan, map[int]time.Duration{1: 2 * time.Second}))
+	require.NotContains(t, cached, StepDurationsAnnotationKey)
+
+	var durations []stepDuration
+	require.NoError(t, json.Unmarshal([]byte(plan.GetAnnotations()[StepDurationsAnnotationKey]), &durations))
+	require.Equal(t, []stepDuration{
+		{Step: 0, Kind: crdKind, Name: "widgets.example.com", Duration: "1s"},
+		{Step: 1, Kind: v1alpha1.ClusterServiceVersionKind, Name: "csv", Duration: "2s"},
+	}, durations)
+	require.Equal(t, "value", plan.GetAnnotations()["other"])
+}
+
+func TestStepGraphRunUnschedulable(t *testing.T) {
+	// The second and third steps depend on one another, and the fourth on the third.
+	g := &stepGraph{
+		steps: []*v1alpha1.Step{
+			step(serviceAccountKind, "sa"),
+			step("Widget", "a"),
+			step("Widget", "b"),
+			step("Widget", "c"),
+		},
+		deps:       []int{0, 1, 1, 1},
+		dependents: [][]int{nil, {2}, {1, 3}, nil},
+	}
+	require.ElementsMatch(t, []int{1, 2, 3}, g.unschedulable())
+
+	var executed []int
+	_, err := g.run(2, func(worker, i int) error {
+		executed = append(executed, i)
+		return nil
+	})
+	var unschedulable *unschedulableStepsError
+	require.True(t, errors.As(err, &unschedulable))
+	require.Equal(t, []string{"Widget a", "Widget b", "Widget c"}, unschedulable.steps)
+	require.Empty(t, executed)
+}
@@ -0,0 +1,135 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+const (
+	// StorageVersionMigrationAnnotationKey is the CRD annotation with which a bundle opts in to storage version
+	// migration. When set to "true", an update of the CRD that drops versions still listed in its stored versions
+	// rewrites every existing object in the new storage version and trims the stored versions, instead of failing.
+	StorageVersionMigrationAnnotationKey = "olm.storage-version-migration"
+
+	// StepStatusMigratingStorage is the status of a CRD step while existing objects are being migrated to the new
+	// storage version.
+	StepStatusMigratingStorage v1alpha1.StepStatus = "MigratingStorage"
+
+	// storageMigrationContinueAnnotationKey is the CRD annotation holding the list continue token of a migration in
+	// progress, so that every sync migrates the next page of objects.
+	storageMigrationContinueAnnotationKey = "olm.storage-version-migration.continue"
+
+	storageMigrationPageSize = 500
+)
+
+// storageMigrationEnabled returns true if the given CRD opts in to storage version migration.
+func storageMigrationEnabled(crd metav1.Object) bool {
+	return crd.GetAnnotations()[StorageVersionMigrationAnnotationKey] == "true"
+}
+
+// migratingStorage returns true if a step of the plan is migrating objects to a new storage version.
+func migratingStorage(status v1alpha1.InstallPlanStatus) bool {
+	for _, step := range status.Plan {
+		if step.Status == StepStatusMigratingStorage {
+			return true
+		}
+	}
+	return false
+}
+
+func v1StorageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// intermediateCRD returns the current CRD with the spec of the new CRD, plus the versions that the new CRD removes but
+// that objects may still be stored in, as unserved versions. Applying it switches the storage version without failing the API server's check
+// that every stored version is defined.
+func intermediateCRD(current, crd *apiextensionsv1.CustomResourceDefinition) *apiextensionsv1.CustomResourceDefinition {
+	out := current.DeepCopy()
+	crd.Spec.DeepCopyInto(&out.Spec)
+	defined := make(map[string]struct{})
+	for _, v := range out.Spec.Versions {
+		defined[v.Name] = struct{}{}
+	}
+	for _, stored := range current.Status.StoredVersions {
+		if _, ok := defined[stored]; ok {
+			continue
+		}
+		for _, v := range current.Spec.Versions {
+			if v.Name == stored {
+				v.Served, v.Storage = false, false
+				out.Spec.Versions = append(out.Spec.Versions, v)
+			}
+		}
+	}
+	return out
+}
+
+// migrateStorageVersion advances the migration of the objects of current to the storage version of crd by one step:
+// switching the storage version, rewriting a page of objects, or trimming the stored versions once every object has
+// been rewritten. It returns true when the migration is complete.
+func (b *builder) migrateStorageVersion(client apiextensionsv1client.CustomResourceDefinitionInterface, current, crd *apiextensionsv1.CustomResourceDefinition) (bool, error) {
+	storage := v1StorageVersion(crd)
+	if storage == "" {
+		return false, fmt.Errorf("CRD %s defines no storage version", crd.GetName())
+	}
+	if v1StorageVersion(current) != storage {
+		b.logger.WithField("crd", crd.GetName()).Infof("switching storage version to %s for migration", storage)
+		_, err := client.Update(context.TODO(), intermediateCRD(current, crd), metav1.UpdateOptions{})
+		return false, err
+	}
+
+	gvr := schema.GroupVersionResource{Group: crd.Spec.Group, Version: storage, Resource: crd.Spec.Names.Plural}
+	opts := metav1.ListOptions{Limit: storageMigrationPageSize, Continue: current.GetAnnotations()[storageMigrationContinueAnnotationKey]}
+	list, err := b.dynamicClient.Resource(gvr).List(context.TODO(), opts)
+	if k8serrors.IsResourceExpired(err) {
+		// The continue token has expired, so start over. Objects are rewritten idempotently.
+		opts.Continue = ""
+		list, err = b.dynamicClient.Resource(gvr).List(context.TODO(), opts)
+	}
+	if err != nil {
+		return false, fmt.Errorf("error listing %s for storage version migration: %v", gvr, err)
+	}
+	for i := range list.Items {
+		obj := &list.Items[i]
+		// An unchanged update is enough for the API server to store the object in the current storage version.
+		// Objects that were deleted or changed since they were listed don't need to be rewritten.
+		_, err := b.dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Update(context.TODO(), obj, metav1.UpdateOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) && !k8serrors.IsConflict(err) {
+			return false, fmt.Errorf("error migrating %s %s/%s to storage version %s: %v", crd.Spec.Names.Kind, obj.GetNamespace(), obj.GetName(), storage, err)
+		}
+	}
+
+	if next := list.GetContinue(); next != "" {
+		out := current.DeepCopy()
+		annotations := make(map[string]string, len(out.GetAnnotations())+1)
+		for k, v := range out.GetAnnotations() {
+			annotations[k] = v
+		}
+		annotations[storageMigrationContinueAnnotationKey] = next
+		out.SetAnnotations(annotations)
+		_, err := client.Update(context.TODO(), out, metav1.UpdateOptions{})
+		return false, err
+	}
+
+	out := current.DeepCopy()
+	out.Status.StoredVersions = []string{storage}
+	if _, err := client.UpdateStatus(context.TODO(), out, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("error trimming stored versions of CRD %s: %v", crd.GetName(), err)
+	}
+	b.logger.WithField("crd", crd.GetName()).Infof("migrated objects to storage version %s", storage)
+	return true, nil
+}
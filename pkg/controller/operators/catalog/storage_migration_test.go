@@ -0,0 +1,96 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+func TestCRDStepStorageVersionMigration(t *testing.T) {
+	oldCRD := widgetCRD([]string{"v1alpha1"}, widgetVersion("v1alpha1", true), widgetVersion("v1", false))
+	newCRD := widgetCRD(nil, widgetVersion("v1", true))
+	newCRD.SetAnnotations(map[string]string{StorageVersionMigrationAnnotationKey: "true"})
+	manifest, err := json.Marshal(newCRD)
+	require.NoError(t, err)
+
+	client := apiextensionsfake.NewSimpleClientset(oldCRD)
+	dynamicClient := widgetClient(widget("v1", "ns", "a", nil), widget("v1", "other", "b", nil))
+	b := &builder{
+		plan:          installPlan("p", "ns", v1alpha1.InstallPlanPhaseInstalling),
+		dynamicClient: dynamicClient,
+		logger:        logrus.New(),
+	}
+	step := &v1alpha1.Step{
+		Resource: v1alpha1.StepResource{Kind: crdKind, Name: newCRD.GetName(), Manifest: string(manifest)},
+		Status:   v1alpha1.StepStatusUnknown,
+	}
+	getCRD := func() *apiextensionsv1.CustomResourceDefinition {
+		crd, err := client.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), newCRD.GetName(), metav1.GetOptions{})
+		require.NoError(t, err)
+		return crd
+	}
+
+	// The first sync switches the storage version, keeping the removed version unserved.
+	status, err := b.NewCRDV1Step(client.ApiextensionsV1(), step, string(manifest)).Status()
+	require.NoError(t, err)
+	require.Equal(t, StepStatusMigratingStorage, status)
+	crd := getCRD()
+	require.Equal(t, "v1", v1StorageVersion(crd))
+	require.Len(t, crd.Spec.Versions, 2)
+	require.Equal(t, "v1alpha1", crd.Spec.Versions[1].Name)
+	require.False(t, crd.Spec.Versions[1].Served)
+
+	// The next sync rewrites the objects, trims the stored versions and applies the new CRD.
+	step.Status = status
+	status, err = b.NewCRDV1Step(client.ApiextensionsV1(), step, string(manifest)).Status()
+	require.NoError(t, err)
+	require.Equal(t, v1alpha1.StepStatusPresent, status)
+	require.Len(t, getCRD().Spec.Versions, 1)
+
+	var storedVersions []string
+	for _, action := range client.Actions() {
+		if update, ok := action.(k8stesting.UpdateAction); ok && update.GetSubresource() == "status" {
+			storedVersions = update.GetObject().(*apiextensionsv1.CustomResourceDefinition).Status.StoredVersions
+		}
+	}
+	require.Equal(t, []string{"v1"}, storedVersions)
+
+	var updated []string
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "update" {
+			updated = append(updated, action.GetNamespace())
+		}
+	}
+	require.ElementsMatch(t, []string{"ns", "other"}, updated)
+}
+
+func TestCRDStepStorageVersionMigrationOptIn(t *testing.T) {
+	oldCRD := widgetCRD([]string{"v1alpha1"}, widgetVersion("v1alpha1", true), widgetVersion("v1", false))
+	newCRD := widgetCRD(nil, widgetVersion("v1", true))
+	manifest, err := json.Marshal(newCRD)
+	require.NoError(t, err)
+
+	client := apiextensionsfake.NewSimpleClientset(oldCRD)
+	b := &builder{
+		plan:          installPlan("p", "ns", v1alpha1.InstallPlanPhaseInstalling),
+		dynamicClient: widgetClient(),
+		logger:        logrus.New(),
+	}
+	step := &v1alpha1.Step{
+		Resource: v1alpha1.StepResource{Kind: crdKind, Name: newCRD.GetName(), Manifest: string(manifest)},
+		Status:   v1alpha1.StepStatusUnknown,
+	}
+
+	_, err = b.NewCRDV1Step(client.ApiextensionsV1(), step, string(manifest)).Status()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "risk of data loss")
+}
@@ -1031,8 +1031,8 @@ func TestSyncSubscriptions(t *testing.T) {
 
 			o.sourcesLastUpdate.Set(tt.fields.sourcesLastUpdate.Time)
 			o.resolver = &fakes.FakeStepResolver{
-				ResolveStepsStub: func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
-					return tt.fields.resolveSteps, tt.fields.bundleLookups, tt.fields.resolveSubs, tt.fields.resolveErr
+				ResolveStepsStub: func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, resolver.Dependencies, error) {
+					return tt.fields.resolveSteps, tt.fields.bundleLookups, tt.fields.resolveSubs, nil, tt.fields.resolveErr
 				},
 			}
 
@@ -1167,7 +1167,7 @@ func BenchmarkSyncResolvingNamespace(b *testing.B) {
 				},
 			},
 			resolver: &fakes.FakeStepResolver{
-				ResolveStepsStub: func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
+				ResolveStepsStub: func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, resolver.Dependencies, error) {
 					steps := []*v1alpha1.Step{
 						{
 							Resolving: "csv.v.2",
@@ -1203,7 +1203,7 @@ func BenchmarkSyncResolvingNamespace(b *testing.B) {
 						},
 					}
 
-					return steps, nil, subs, nil
+					return steps, nil, subs, nil, nil
 				},
 			},
 		},
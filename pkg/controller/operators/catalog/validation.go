@@ -0,0 +1,251 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+const (
+	// InstallPlanValidated is the condition recording that the validators configured with WithInstallPlanValidator
+	// admitted a plan. Plans are validated once, before any of their steps are applied.
+	InstallPlanValidated v1alpha1.InstallPlanConditionType = "Validated"
+
+	InstallPlanReasonValidated v1alpha1.InstallPlanConditionReason = "Validated"
+
+	defaultInstallPlanWebhookTimeout = 10 * time.Second
+)
+
+// InstallPlanReview is what validators are asked to admit: the resolved steps of a plan, grouped by the bundle
+// that they install.
+type InstallPlanReview struct {
+	Namespace   string         `json:"namespace"`
+	InstallPlan string         `json:"installPlan"`
+	Bundles     []BundleReview `json:"bundles"`
+}
+
+// BundleReview is the unpacked content of a bundle, as the steps that install it.
+type BundleReview struct {
+	// Name is the name of the ClusterServiceVersion of the bundle.
+	Name                   string       `json:"name"`
+	CatalogSource          string       `json:"catalogSource,omitempty"`
+	CatalogSourceNamespace string       `json:"catalogSourceNamespace,omitempty"`
+	Steps                  []StepReview `json:"steps"`
+}
+
+// StepReview is a step with its resolved manifest. The values of the data of a Secret are left out of its manifest and
+// Redacted is set instead.
+type StepReview struct {
+	Group    string `json:"group,omitempty"`
+	Version  string `json:"version"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Manifest string `json:"manifest"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// ValidationResult is the verdict of a validator. Reason explains why a plan isn't allowed.
+type ValidationResult struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// InstallPlanValidator admits or rejects InstallPlans before they're applied. An error is retried, and blocks the
+// plan until the validator returns a verdict.
+type InstallPlanValidator interface {
+	ValidateInstallPlan(ctx context.Context, review *InstallPlanReview) (ValidationResult, error)
+}
+
+// InstallPlanValidatorFunc is an in-process InstallPlanValidator.
+type InstallPlanValidatorFunc func(ctx context.Context, review *InstallPlanReview) (ValidationResult, error)
+
+func (f InstallPlanValidatorFunc) ValidateInstallPlan(ctx context.Context, review *InstallPlanReview) (ValidationResult, error) {
+	return f(ctx, review)
+}
+
+type namedValidator struct {
+	name      string
+	validator InstallPlanValidator
+}
+
+type installPlanWebhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewInstallPlanWebhook returns a validator that POSTs the JSON-encoded InstallPlanReview to the given https URL and
+// expects a JSON-encoded ValidationResult in a 200 response. The webhook's certificate is verified against the given
+// PEM-encoded CA bundle, or against the system roots if the bundle is empty.
+func NewInstallPlanWebhook(rawURL string, caBundle []byte) (InstallPlanValidator, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid installplan webhook url %q: %v", rawURL, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid installplan webhook url %q: only https urls are allowed", rawURL)
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caBundle) > 0 {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("invalid installplan webhook ca bundle: no certificates found")
+		}
+	}
+	return &installPlanWebhook{
+		url: u.String(),
+		client: &http.Client{
+			Timeout: defaultInstallPlanWebhookTimeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: config,
+			},
+			// Redirects could leave https, so they're returned as a response that isn't admitted.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+func (w *installPlanWebhook) ValidateInstallPlan(ctx context.Context, review *InstallPlanReview) (ValidationResult, error) {
+	var result ValidationResult
+	body, err := json.Marshal(review)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return result, fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("error decoding webhook response: %v", err)
+	}
+	return result, nil
+}
+
+// installPlanReview returns the review of the resolved steps of a plan.
+func (o *Operator) installPlanReview(plan *v1alpha1.InstallPlan) (*InstallPlanReview, error) {
+	r := newManifestResolver(plan.GetNamespace(), o.lister.CoreV1().ConfigMapLister(), o.logger)
+	review := &InstallPlanReview{Namespace: plan.GetNamespace(), InstallPlan: plan.GetName()}
+	bundles := make(map[string]int)
+	for _, step := range plan.Status.Plan {
+		manifest, err := r.ManifestForStep(step)
+		if err != nil {
+			return nil, err
+		}
+		redacted := step.Resource.Group == "" && step.Resource.Kind == secretKind
+		if redacted {
+			if manifest, err = redactSecretManifest(manifest); err != nil {
+				return nil, fmt.Errorf("error redacting secret %s: %v", step.Resource.Name, err)
+			}
+		}
+		i, ok := bundles[step.Resolving]
+		if !ok {
+			i = len(review.Bundles)
+			bundles[step.Resolving] = i
+			review.Bundles = append(review.Bundles, BundleReview{
+				Name:                   step.Resolving,
+				CatalogSource:          step.Resource.CatalogSource,
+				CatalogSourceNamespace: step.Resource.CatalogSourceNamespace,
+			})
+		}
+		review.Bundles[i].Steps = append(review.Bundles[i].Steps, StepReview{
+			Group:    step.Resource.Group,
+			Version:  step.Resource.Version,
+			Kind:     step.Resource.Kind,
+			Name:     step.Resource.Name,
+			Manifest: manifest,
+			Redacted: redacted,
+		})
+	}
+	return review, nil
+}
+
+// redactSecretManifest blanks the values of the data of a Secret manifest, so that validators see which keys a Secret
+// sets but not their values.
+func redactSecretManifest(manifest string) (string, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(manifest), &obj); err != nil {
+		return "", err
+	}
+	for _, field := range []string{"data", "stringData"} {
+		values, _ := obj[field].(map[string]interface{})
+		for key := range values {
+			values[key] = ""
+		}
+	}
+	redacted, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(redacted), nil
+}
+
+// validated returns true if the validators have admitted a plan.
+func validated(plan *v1alpha1.InstallPlan) bool {
+	return plan.Status.GetCondition(InstallPlanValidated).Status == corev1.ConditionTrue
+}
+
+// validateInstallPlan asks each validator to admit a plan that is about to be applied, failing the plan with the
+// reason of the first validator to reject it.
+func (o *Operator) validateInstallPlan(plan *v1alpha1.InstallPlan, logger *logrus.Entry) error {
+	review, err := o.installPlanReview(plan)
+	if err != nil {
+		return fmt.Errorf("error resolving installplan steps for validation: %v", err)
+	}
+	for _, v := range o.installPlanValidators {
+		result, err := v.validator.ValidateInstallPlan(context.TODO(), review)
+		if err != nil {
+			return fmt.Errorf("error calling installplan validator %s: %v", v.name, err)
+		}
+		if result.Allowed {
+			continue
+		}
+
+		logger.WithField("validator", v.name).Info("installplan rejected")
+		if err := o.transitionInstallPlanToFailed(plan, logger, v1alpha1.InstallPlanReasonInstallCheckFailed, fmt.Sprintf("rejected by %s: %s", v.name, result.Reason)); err != nil {
+			return err
+		}
+		o.requeueSubscriptionForInstallPlan(plan, logger)
+		return nil
+	}
+
+	now := o.now()
+	out := plan.DeepCopy()
+	out.Status.SetCondition(v1alpha1.InstallPlanCondition{
+		Type:               InstallPlanValidated,
+		Status:             corev1.ConditionTrue,
+		Reason:             InstallPlanReasonValidated,
+		LastUpdateTime:     &now,
+		LastTransitionTime: &now,
+	})
+	if _, err := o.client.OperatorsV1alpha1().InstallPlans(out.GetNamespace()).UpdateStatus(context.TODO(), out, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating installplan validation status: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+func TestValidateInstallPlan(t *testing.T) {
+	allow := InstallPlanValidatorFunc(func(context.Context, *InstallPlanReview) (ValidationResult, error) {
+		return ValidationResult{Allowed: true}, nil
+	})
+	reject := InstallPlanValidatorFunc(func(context.Context, *InstallPlanReview) (ValidationResult, error) {
+		return ValidationResult{Reason: "image is not signed"}, nil
+	})
+	fail := InstallPlanValidatorFunc(func(context.Context, *InstallPlanReview) (ValidationResult, error) {
+		return ValidationResult{}, errors.New("unavailable")
+	})
+
+	tests := []struct {
+		name       string
+		validators []namedValidator
+		err        string
+		phase      v1alpha1.InstallPlanPhase
+		validated  bool
+		message    string
+	}{
+		{
+			name:       "Allowed",
+			validators: []namedValidator{{name: "a", validator: allow}, {name: "b", validator: allow}},
+			phase:      v1alpha1.InstallPlanPhaseInstalling,
+			validated:  true,
+		},
+		{
+			name:       "Rejected",
+			validators: []namedValidator{{name: "a", validator: allow}, {name: "signatures", validator: reject}},
+			phase:      v1alpha1.InstallPlanPhaseFailed,
+			message:    "rejected by signatures: image is not signed",
+		},
+		{
+			name:       "Error",
+			validators: []namedValidator{{name: "a", validator: fail}, {name: "signatures", validator: reject}},
+			err:        "error calling installplan validator a: unavailable",
+			phase:      v1alpha1.InstallPlanPhaseInstalling,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+
+			plan := installPlan("p", "ns", v1alpha1.InstallPlanPhaseInstalling, "csv")
+			op, err := NewFakeOperator(ctx, "ns", []string{"ns"}, withClientObjs(plan))
+			require.NoError(t, err)
+			op.installPlanValidators = tt.validators
+
+			err = op.validateInstallPlan(plan, logrus.NewEntry(logrus.New()))
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			out, err := op.client.OperatorsV1alpha1().InstallPlans("ns").Get(ctx, "p", metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, tt.phase, out.Status.Phase)
+			require.Equal(t, tt.validated, validated(out))
+			if tt.message != "" {
+				cond := out.Status.GetCondition(v1alpha1.InstallPlanInstalled)
+				require.Equal(t, corev1.ConditionFalse, cond.Status)
+				require.Equal(t, v1alpha1.InstallPlanReasonInstallCheckFailed, cond.Reason)
+				require.Equal(t, tt.message, cond.Message)
+			}
+		})
+	}
+}
+
+func TestInstallPlanReview(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	plan := installPlan("p", "ns", v1alpha1.InstallPlanPhaseInstalling, "a", "b")
+	plan.Status.Plan = []*v1alpha1.Step{
+		{Resolving: "a", Resource: v1alpha1.StepResource{CatalogSource: "cs", CatalogSourceNamespace: "olm", Version: "v1alpha1", Kind: "ClusterServiceVersion", Name: "a", Manifest: "{}"}},
+		{Resolving: "b", Resource: v1alpha1.StepResource{CatalogSource: "cs", CatalogSourceNamespace: "olm", Version: "v1alpha1", Kind: "ClusterServiceVersion", Name: "b", Manifest: "{}"}},
+		{Resolving: "a", Resource: v1alpha1.StepResource{CatalogSource: "cs", CatalogSourceNamespace: "olm", Version: "v1", Kind: "ServiceAccount", Name: "sa", Manifest: "{}"}},
+		{Resolving: "a", Resource: v1alpha1.StepResource{CatalogSource: "cs", CatalogSourceNamespace: "olm", Version: "v1", Kind: "Secret", Name: "s", Manifest: `{"kind":"Secret","data":{"password":"aHVudGVyMg=="},"stringData":{"token":"t0ken"}}`}},
+	}
+	op, err := NewFakeOperator(ctx, "ns", []string{"ns"}, withClientObjs(plan))
+	require.NoError(t, err)
+
+	review, err := op.installPlanReview(plan)
+	require.NoError(t, err)
+	require.Equal(t, "p", review.InstallPlan)
+	require.Len(t, review.Bundles, 2)
+	require.Equal(t, BundleReview{
+		Name:                   "a",
+		CatalogSource:          "cs",
+		CatalogSourceNamespace: "olm",
+		Steps: []StepReview{
+			{Version: "v1alpha1", Kind: "ClusterServiceVersion", Name: "a", Manifest: "{}"},
+			{Version: "v1", Kind: "ServiceAccount", Name: "sa", Manifest: "{}"},
+			{Version: "v1", Kind: "Secret", Name: "s", Manifest: `{"data":{"password":""},"kind":"Secret","stringData":{"token":""}}`, Redacted: true},
+		},
+	}, review.Bundles[0])
+	require.Equal(t, "b", review.Bundles[1].Name)
+}
+
+func TestInstallPlanWebhook(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review InstallPlanReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if review.Namespace == "broken" {
+			http.Error(w, "policy unavailable", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(ValidationResult{Allowed: review.Namespace == "trusted", Reason: "untrusted namespace"})
+	}))
+	defer server.Close()
+
+	_, err := NewInstallPlanWebhook(strings.Replace(server.URL, "https://", "http://", 1), nil)
+	require.EqualError(t, err, fmt.Sprintf("invalid installplan webhook url %q: only https urls are allowed", strings.Replace(server.URL, "https://", "http://", 1)))
+	_, err = NewInstallPlanWebhook(server.URL, []byte("not a certificate"))
+	require.Error(t, err)
+
+	// The test server's certificate isn't trusted without its CA.
+	untrusted, err := NewInstallPlanWebhook(server.URL, nil)
+	require.NoError(t, err)
+	_, err = untrusted.ValidateInstallPlan(context.TODO(), &InstallPlanReview{Namespace: "trusted"})
+	require.Error(t, err)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	webhook, err := NewInstallPlanWebhook(server.URL, ca)
+	require.NoError(t, err)
+
+	result, err := webhook.ValidateInstallPlan(context.TODO(), &InstallPlanReview{Namespace: "trusted"})
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	result, err = webhook.ValidateInstallPlan(context.TODO(), &InstallPlanReview{Namespace: "other"})
+	require.NoError(t, err)
+	require.Equal(t, ValidationResult{Reason: "untrusted namespace"}, result)
+
+	_, err = webhook.ValidateInstallPlan(context.TODO(), &InstallPlanReview{Namespace: "broken"})
+	require.EqualError(t, err, "webhook returned 500 Internal Server Error: policy unavailable")
+}
@@ -0,0 +1,180 @@
+package olm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/ownerutil"
+)
+
+const (
+	// UpgradeStrategyAnnotationKey on a CSV selects how it replaces the CSV it replaces.
+	UpgradeStrategyAnnotationKey = "operatorframework.io/upgrade-strategy"
+
+	// UpgradeStrategyCanary runs the deployments of the new CSV alongside those of the CSV it replaces, and only
+	// replaces the old CSV once the new one has stayed healthy and upgradeable for its soak period. If the new CSV fails
+	// first, its deployments are removed and the old CSV is reinstated.
+	UpgradeStrategyCanary = "Canary"
+
+	// CanarySoakPeriodAnnotationKey on a CSV sets how long it must stay healthy before it replaces the CSV it replaces,
+	// as a duration such as "10m".
+	CanarySoakPeriodAnnotationKey = "operatorframework.io/canary-soak-period"
+
+	// DefaultCanarySoakPeriod is the soak period of CSVs that don't set one.
+	DefaultCanarySoakPeriod = 5 * time.Minute
+
+	// canaryRecheckInterval is how often a soaked canary that isn't upgradeable yet is checked again.
+	canaryRecheckInterval = 30 * time.Second
+
+	// CSVPhaseCanary means that the CSV is installed and running alongside the CSV it replaces, which is retired once
+	// the soak period of the CSV passes.
+	CSVPhaseCanary v1alpha1.ClusterServiceVersionPhase = "Canary"
+
+	CSVReasonCanarySoaking v1alpha1.ConditionReason = "CanarySoaking"
+	CSVReasonCanaryFailed  v1alpha1.ConditionReason = "CanaryFailed"
+	CSVReasonCanaryAborted v1alpha1.ConditionReason = "CanaryAborted"
+)
+
+// canaryFailureReasons are the reasons for which a failed canary is aborted rather than reinstalled.
+var canaryFailureReasons = map[v1alpha1.ConditionReason]struct{}{
+	v1alpha1.CSVReasonInstallCheckFailed:     {},
+	v1alpha1.CSVReasonComponentFailed:        {},
+	v1alpha1.CSVReasonComponentFailedNoRetry: {},
+	v1alpha1.CSVReasonComponentUnhealthy:     {},
+}
+
+// canaryReplacing returns the CSV that the given CSV replaces if it does so as a canary. CSVs with APIServices or
+// webhooks, and CSVs that share deployment names with the CSV they replace, can't run alongside it and are upgraded
+// in place.
+func (a *Operator) canaryReplacing(csv *v1alpha1.ClusterServiceVersion, logger *logrus.Entry) *v1alpha1.ClusterServiceVersion {
+	if csv.GetAnnotations()[UpgradeStrategyAnnotationKey] != UpgradeStrategyCanary {
+		return nil
+	}
+	prev := a.isReplacing(csv)
+	if prev == nil {
+		return nil
+	}
+	if csv.HasCAResources() || prev.HasCAResources() {
+		logger.Debug("csv owns apiservices or webhooks, upgrading in place instead of as a canary")
+		return nil
+	}
+	names := map[string]struct{}{}
+	for _, spec := range prev.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		names[spec.Name] = struct{}{}
+	}
+	for _, spec := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		if _, ok := names[spec.Name]; ok {
+			logger.WithField("deployment", spec.Name).Debug("csv shares a deployment with the csv it replaces, upgrading in place instead of as a canary")
+			return nil
+		}
+	}
+	return prev
+}
+
+// canarySoakPeriod returns how long the given canary CSV must stay healthy before it replaces the CSV it replaces.
+func canarySoakPeriod(csv *v1alpha1.ClusterServiceVersion, logger *logrus.Entry) time.Duration {
+	value, ok := csv.GetAnnotations()[CanarySoakPeriodAnnotationKey]
+	if !ok {
+		return DefaultCanarySoakPeriod
+	}
+	soak, err := time.ParseDuration(value)
+	if err != nil || soak < 0 {
+		logger.WithField(CanarySoakPeriodAnnotationKey, value).Warnf("invalid canary soak period, using %s", DefaultCanarySoakPeriod)
+		return DefaultCanarySoakPeriod
+	}
+	return soak
+}
+
+// canaryAborted returns true if the given CSV is a canary that failed and was aborted.
+func canaryAborted(csv *v1alpha1.ClusterServiceVersion) bool {
+	return csv.Status.Phase == v1alpha1.CSVPhaseFailed && csv.Status.Reason == CSVReasonCanaryFailed
+}
+
+// startCanary moves a canary CSV whose install succeeded into the canary phase, where it soaks before replacing prev.
+func (a *Operator) startCanary(csv, prev *v1alpha1.ClusterServiceVersion, logger *logrus.Entry) {
+	soak := canarySoakPeriod(csv, logger)
+	csv.SetPhaseWithEvent(CSVPhaseCanary, CSVReasonCanarySoaking, fmt.Sprintf("running alongside %s for %s before replacing it", prev.GetName(), soak), a.now(), a.recorder)
+	if err := a.csvQueueSet.RequeueAfter(csv.GetNamespace(), csv.GetName(), soak); err != nil {
+		logger.WithError(err).Warn("unable to requeue")
+	}
+}
+
+// syncCanary checks the health of a CSV in the canary phase, aborting it if it's unhealthy and promoting it once its
+// soak period has passed and it reports that it's upgradeable.
+func (a *Operator) syncCanary(csv *v1alpha1.ClusterServiceVersion, logger *logrus.Entry) error {
+	now := a.now()
+	prev := a.isReplacing(csv)
+	if prev == nil {
+		csv.SetPhaseWithEvent(v1alpha1.CSVPhaseSucceeded, v1alpha1.CSVReasonInstallSuccessful, "install strategy completed with no errors", now, a.recorder)
+		return nil
+	}
+
+	installer, strategy := a.parseStrategiesAndUpdateStatus(csv)
+	if strategy == nil {
+		return nil
+	}
+	installed, err := installer.CheckInstalled(strategy)
+	if k8serrors.IsServiceUnavailable(err) {
+		return err
+	}
+	if !installed {
+		return a.abortCanary(csv, prev, fmt.Sprintf("canary became unhealthy: %v", err), logger)
+	}
+
+	remaining := canarySoakPeriod(csv, logger)
+	if started := csv.Status.LastTransitionTime; started != nil {
+		remaining -= now.Sub(started.Time)
+	}
+	if remaining > 0 {
+		if err := a.csvQueueSet.RequeueAfter(csv.GetNamespace(), csv.GetName(), remaining); err != nil {
+			logger.WithError(err).Warn("unable to requeue")
+		}
+		return nil
+	}
+
+	if upgradeable, err := a.isOperatorUpgradeable(csv); !upgradeable {
+		csv.SetPhaseWithEventIfChanged(CSVPhaseCanary, CSVReasonCanarySoaking, fmt.Sprintf("waiting to replace %s until the operator is upgradeable: %v", prev.GetName(), err), now, a.recorder)
+		if err := a.csvQueueSet.RequeueAfter(csv.GetNamespace(), csv.GetName(), canaryRecheckInterval); err != nil {
+			logger.WithError(err).Warn("unable to requeue")
+		}
+		return nil
+	}
+
+	logger.WithField("replacing", prev.GetName()).Info("canary soak completed")
+	csv.SetPhaseWithEvent(v1alpha1.CSVPhaseSucceeded, v1alpha1.CSVReasonInstallSuccessful, fmt.Sprintf("canary soak completed, replacing %s", prev.GetName()), now, a.recorder)
+	if err := a.csvQueueSet.Requeue(prev.GetNamespace(), prev.GetName()); err != nil {
+		logger.WithError(err).Warn("unable to requeue")
+	}
+	return nil
+}
+
+// abortCanary fails a canary CSV and removes its deployments, leaving the CSV it replaces to be reinstated.
+func (a *Operator) abortCanary(csv, prev *v1alpha1.ClusterServiceVersion, message string, logger *logrus.Entry) error {
+	logger.WithField("replacing", prev.GetName()).Warnf("aborting canary: %s", message)
+	for _, spec := range csv.Spec.InstallStrategy.StrategySpec.DeploymentSpecs {
+		deployment, err := a.lister.AppsV1().DeploymentLister().Deployments(csv.GetNamespace()).Get(spec.Name)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if name, namespace, ok := ownerutil.GetOwnerByKindLabel(deployment, v1alpha1.ClusterServiceVersionKind); !ok || name != csv.GetName() || namespace != csv.GetNamespace() {
+			continue
+		}
+		if err := a.opClient.DeleteDeployment(deployment.GetNamespace(), deployment.GetName(), &metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	csv.SetPhaseWithEvent(v1alpha1.CSVPhaseFailed, CSVReasonCanaryFailed, message, a.now(), a.recorder)
+	if err := a.csvQueueSet.Requeue(prev.GetNamespace(), prev.GetName()); err != nil {
+		logger.WithError(err).Warn("unable to requeue")
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+package olm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/ownerutil"
+)
+
+const (
+	// CleanupPolicyAnnotationKey on a Subscription selects what happens to the operands, CRDs and cluster RBAC of the
+	// CSV it installed when that CSV is deleted. It is only read from Subscriptions, which are created by cluster
+	// admins, and never from CSVs, whose metadata is shipped by bundle authors.
+	CleanupPolicyAnnotationKey = "operatorframework.io/cleanup-policy"
+
+	// CleanupPolicyOrphan leaves the operands, CRDs and cluster RBAC of a deleted CSV in place. It is the default.
+	CleanupPolicyOrphan = "Orphan"
+
+	// CleanupPolicyDelete holds the deletion of a CSV until the custom resources of its owned CRDs are deleted, then
+	// deletes the owned CRDs that no other CSV owns or requires and the cluster RBAC that OLM created for it. CSVs that
+	// are deleted because they were replaced are not cleaned up.
+	CleanupPolicyDelete = "Delete"
+
+	// CleanupFinalizer is added to CSVs installed by a Subscription with the Delete cleanup policy, and removed once
+	// their cleanup is done. It records the policy of a CSV whose Subscription is deleted first.
+	CleanupFinalizer = "operatorframework.io/cleanup"
+
+	CSVReasonCleanupPending  v1alpha1.ConditionReason = "CleanupPending"
+	CSVReasonCleanupOrphaned v1alpha1.ConditionReason = "CleanupOrphaned"
+
+	// cleanupRecheckInterval is how often a CSV waiting for its custom resources to be deleted is checked again.
+	cleanupRecheckInterval = 10 * time.Second
+
+	// cleanupTimeout is how long a CSV waits for its custom resources to be deleted before the remaining ones, and
+	// their CRDs, are orphaned.
+	cleanupTimeout = 10 * time.Minute
+
+	// maxReportedBlockingResources caps the resources listed in the status message of a CSV waiting for cleanup.
+	maxReportedBlockingResources = 10
+)
+
+// cleanupPolicy returns the cleanup policy of a CSV, taken from the Subscriptions that installed it. It returns false
+// if no Subscription installed the CSV.
+func (a *Operator) cleanupPolicy(csv *v1alpha1.ClusterServiceVersion) (string, bool, error) {
+	subs, err := a.lister.OperatorsV1alpha1().SubscriptionLister().Subscriptions(csv.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return "", false, err
+	}
+	policy, found := CleanupPolicyOrphan, false
+	for _, sub := range subs {
+		if sub.Status.InstalledCSV != csv.GetName() && sub.Status.CurrentCSV != csv.GetName() {
+			continue
+		}
+		found = true
+		if sub.GetAnnotations()[CleanupPolicyAnnotationKey] == CleanupPolicyDelete {
+			policy = CleanupPolicyDelete
+		}
+	}
+	return policy, found, nil
+}
+
+func hasCleanupFinalizer(csv *v1alpha1.ClusterServiceVersion) bool {
+	for _, f := range csv.GetFinalizers() {
+		if f == CleanupFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureCleanupFinalizer adds the cleanup finalizer to a CSV with the Delete cleanup policy, or removes it from a CSV
+// that no longer has that policy. The finalizer of a CSV that no Subscription installed is left as it is. It returns
+// true if the CSV was updated, in which case the sync should stop and wait for the update to be observed.
+func (a *Operator) ensureCleanupFinalizer(csv *v1alpha1.ClusterServiceVersion) (bool, error) {
+	if csv.GetDeletionTimestamp() != nil {
+		return false, nil
+	}
+	policy, found, err := a.cleanupPolicy(csv)
+	if err != nil || !found {
+		return false, err
+	}
+	wantsCleanup := policy == CleanupPolicyDelete
+	if wantsCleanup == hasCleanupFinalizer(csv) {
+		return false, nil
+	}
+
+	out := csv.DeepCopy()
+	if wantsCleanup {
+		out.SetFinalizers(append(out.GetFinalizers(), CleanupFinalizer))
+	} else {
+		out.SetFinalizers(removeFinalizer(out.GetFinalizers(), CleanupFinalizer))
+	}
+	_, err = a.client.OperatorsV1alpha1().ClusterServiceVersions(out.GetNamespace()).Update(context.TODO(), out, metav1.UpdateOptions{})
+	return err == nil, err
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	var out []string
+	for _, f := range finalizers {
+		if f != finalizer {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// syncCleanup cleans up after a CSV that is being deleted with the Delete cleanup policy, and removes its finalizer
+// once it's done. Until then the resources blocking the cleanup are reported in the status of the CSV. Custom
+// resources that are still there after cleanupTimeout, or once the namespace of the CSV is terminating and its
+// operator can no longer finalize them, are orphaned along with their CRDs.
+func (a *Operator) syncCleanup(csv *v1alpha1.ClusterServiceVersion, logger *logrus.Entry) error {
+	if next := a.isBeingReplaced(csv, a.csvSet(csv.GetNamespace(), v1alpha1.CSVPhaseAny)); next != nil {
+		logger.WithField("replacedBy", next.GetName()).Debug("csv was replaced, skipping cleanup")
+		return a.removeCleanupFinalizer(csv)
+	}
+
+	crds, err := a.unsharedOwnedCRDs(csv, logger)
+	if err != nil {
+		return err
+	}
+
+	blocking, err := a.deleteCustomResources(crds, logger)
+	if err != nil {
+		return err
+	}
+	if len(blocking) > 0 {
+		orphan, reason, err := a.shouldOrphanCleanup(csv)
+		if err != nil {
+			return err
+		}
+		if orphan {
+			logger.WithField("reason", reason).Warnf("orphaning %d custom resources and their crds", len(blocking))
+			a.recorder.Eventf(csv, corev1.EventTypeWarning, string(CSVReasonCleanupOrphaned), "%s, orphaning %d custom resources and their CRDs", reason, len(blocking))
+			if err := a.deleteClusterRBAC(csv, logger); err != nil {
+				return err
+			}
+			return a.removeCleanupFinalizer(csv)
+		}
+
+		if len(blocking) > maxReportedBlockingResources {
+			blocking = append(blocking[:maxReportedBlockingResources], fmt.Sprintf("and %d more", len(blocking)-maxReportedBlockingResources))
+		}
+		message := fmt.Sprintf("waiting for custom resources to be deleted: %s", strings.Join(blocking, ", "))
+		out := csv.DeepCopy()
+		out.SetPhaseWithEventIfChanged(v1alpha1.CSVPhaseDeleting, CSVReasonCleanupPending, message, a.now(), a.recorder)
+		if out.Status.Message != csv.Status.Message || out.Status.Reason != csv.Status.Reason || out.Status.Phase != csv.Status.Phase {
+			if _, err := a.client.OperatorsV1alpha1().ClusterServiceVersions(out.GetNamespace()).UpdateStatus(context.TODO(), out, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		if err := a.csvQueueSet.RequeueAfter(csv.GetNamespace(), csv.GetName(), cleanupRecheckInterval); err != nil {
+			logger.WithError(err).Warn("unable to requeue")
+		}
+		return nil
+	}
+
+	for _, crd := range crds {
+		if err := a.deleteCRD(crd, logger); err != nil {
+			return err
+		}
+	}
+
+	if err := a.deleteClusterRBAC(csv, logger); err != nil {
+		return err
+	}
+
+	logger.Info("cleanup completed")
+	return a.removeCleanupFinalizer(csv)
+}
+
+// shouldOrphanCleanup returns true, along with the reason, if a CSV should stop waiting for its custom resources to be
+// deleted.
+func (a *Operator) shouldOrphanCleanup(csv *v1alpha1.ClusterServiceVersion) (bool, string, error) {
+	ns, err := a.lister.CoreV1().NamespaceLister().Get(csv.GetNamespace())
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, "", err
+	}
+	if ns != nil && (ns.GetDeletionTimestamp() != nil || ns.Status.Phase == corev1.NamespaceTerminating) {
+		return true, fmt.Sprintf("namespace %s is terminating", ns.GetName()), nil
+	}
+	if deleted := csv.GetDeletionTimestamp(); deleted != nil && a.now().Sub(deleted.Time) >= cleanupTimeout {
+		return true, fmt.Sprintf("custom resources were not deleted within %s", cleanupTimeout), nil
+	}
+	return false, "", nil
+}
+
+func (a *Operator) removeCleanupFinalizer(csv *v1alpha1.ClusterServiceVersion) error {
+	out := csv.DeepCopy()
+	out.SetFinalizers(removeFinalizer(out.GetFinalizers(), CleanupFinalizer))
+	_, err := a.client.OperatorsV1alpha1().ClusterServiceVersions(out.GetNamespace()).Update(context.TODO(), out, metav1.UpdateOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// unsharedOwnedCRDs returns the CRDs owned by the given CSV that no other CSV owns or requires. Only those CRDs and
+// their custom resources are deleted. Copies of the CSV don't count, but installs of a CSV of the same name in other
+// namespaces do.
+func (a *Operator) unsharedOwnedCRDs(csv *v1alpha1.ClusterServiceVersion, logger *logrus.Entry) ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	owned := map[string]struct{}{}
+	required := map[string]struct{}{}
+	csvs, err := a.lister.OperatorsV1alpha1().ClusterServiceVersionLister().List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range csvs {
+		if other.IsCopied() || (other.GetName() == csv.GetName() && other.GetNamespace() == csv.GetNamespace()) {
+			continue
+		}
+		for _, desc := range other.Spec.CustomResourceDefinitions.Owned {
+			owned[desc.Name] = struct{}{}
+		}
+		for _, desc := range other.Spec.CustomResourceDefinitions.Required {
+			required[desc.Name] = struct{}{}
+		}
+	}
+
+	var crds []*apiextensionsv1.CustomResourceDefinition
+	for _, desc := range csv.Spec.CustomResourceDefinitions.Owned {
+		crd, err := a.lister.APIExtensionsV1().CustomResourceDefinitionLister().Get(desc.Name)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := required[crd.GetName()]; ok {
+			logger.WithField("crd", crd.GetName()).Debug("crd is required by another csv, leaving it in place")
+			continue
+		}
+		if _, ok := owned[crd.GetName()]; ok {
+			logger.WithField("crd", crd.GetName()).Debug("crd is owned by another csv, leaving it in place")
+			continue
+		}
+		crds = append(crds, crd)
+	}
+	return crds, nil
+}
+
+// deleteCustomResources deletes the custom resources of the given CRDs in all namespaces, and returns the ones that
+// still exist afterwards.
+func (a *Operator) deleteCustomResources(crds []*apiextensionsv1.CustomResourceDefinition, logger *logrus.Entry) ([]string, error) {
+	var blocking []string
+	for _, crd := range crds {
+		gvr := schema.GroupVersionResource{Group: crd.Spec.Group, Version: storageVersion(crd), Resource: crd.Spec.Names.Plural}
+		client := a.dynamicClient.Resource(gvr)
+		crs, err := client.List(context.TODO(), metav1.ListOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, cr := range crs.Items {
+			if cr.GetDeletionTimestamp() != nil {
+				continue
+			}
+			logger.WithField("cr", fmt.Sprintf("%s/%s", cr.GetNamespace(), cr.GetName())).Infof("deleting %s", crd.Spec.Names.Kind)
+			if cr.GetNamespace() != "" {
+				err = client.Namespace(cr.GetNamespace()).Delete(context.TODO(), cr.GetName(), metav1.DeleteOptions{})
+			} else {
+				err = client.Delete(context.TODO(), cr.GetName(), metav1.DeleteOptions{})
+			}
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return nil, err
+			}
+		}
+
+		remaining, err := client.List(context.TODO(), metav1.ListOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, cr := range remaining.Items {
+			name := cr.GetName()
+			if cr.GetNamespace() != "" {
+				name = cr.GetNamespace() + "/" + name
+			}
+			blocking = append(blocking, fmt.Sprintf("%s %s", crd.Spec.Names.Kind, name))
+		}
+	}
+	sort.Strings(blocking)
+	return blocking, nil
+}
+
+func storageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	if len(crd.Spec.Versions) > 0 {
+		return crd.Spec.Versions[0].Name
+	}
+	return ""
+}
+
+// deleteCRD deletes a CRD along with the aggregated ClusterRoles that OLM created for it.
+func (a *Operator) deleteCRD(crd *apiextensionsv1.CustomResourceDefinition, logger *logrus.Entry) error {
+	for _, v := range crd.Spec.Versions {
+		namePrefix := fmt.Sprintf("%s-%s-", crd.GetName(), v.Name)
+		names := []string{namePrefix + "crd" + ViewSuffix}
+		for suffix := range VerbsForSuffix {
+			names = append(names, namePrefix+suffix)
+		}
+		for _, name := range names {
+			role, err := a.lister.RbacV1().ClusterRoleLister().Get(name)
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !ownerutil.IsOwnedBy(role, crd) {
+				continue
+			}
+			if err := a.opClient.DeleteClusterRole(name, &metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	logger.WithField("crd", crd.GetName()).Info("deleting crd")
+	err := a.opClient.ApiextensionsInterface().ApiextensionsV1().CustomResourceDefinitions().Delete(context.TODO(), crd.GetName(), metav1.DeleteOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// deleteClusterRBAC deletes the ClusterRoles and ClusterRoleBindings that OLM created for a CSV.
+func (a *Operator) deleteClusterRBAC(csv *v1alpha1.ClusterServiceVersion, logger *logrus.Entry) error {
+	ownerSelector := ownerutil.CSVOwnerSelector(csv)
+	crbs, err := a.lister.RbacV1().ClusterRoleBindingLister().List(ownerSelector)
+	if err != nil {
+		return err
+	}
+	for _, crb := range crbs {
+		logger.WithField("clusterrolebinding", crb.GetName()).Debug("deleting cluster role binding")
+		if err := a.opClient.DeleteClusterRoleBinding(crb.GetName(), &metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	crs, err := a.lister.RbacV1().ClusterRoleLister().List(ownerSelector)
+	if err != nil {
+		return err
+	}
+	for _, cr := range crs {
+		logger.WithField("clusterrole", cr.GetName()).Debug("deleting cluster role")
+		if err := a.opClient.DeleteClusterRole(cr.GetName(), &metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
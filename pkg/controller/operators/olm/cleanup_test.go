@@ -0,0 +1,287 @@
+package olm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilclock "k8s.io/apimachinery/pkg/util/clock"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/ownerutil"
+)
+
+func TestEnsureCleanupFinalizer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscription := func(name, csv, policy string) *v1alpha1.Subscription {
+		sub := &v1alpha1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Spec:       &v1alpha1.SubscriptionSpec{Package: name},
+			Status:     v1alpha1.SubscriptionStatus{CurrentCSV: csv, InstalledCSV: csv},
+		}
+		if policy != "" {
+			sub.SetAnnotations(map[string]string{CleanupPolicyAnnotationKey: policy})
+		}
+		return sub
+	}
+	newCSV := func(name string, finalizers ...string) *v1alpha1.ClusterServiceVersion {
+		c := csv(name, "ns", "", "", v1alpha1.NamedInstallStrategy{}, nil, nil, v1alpha1.CSVPhasePending)
+		c.SetFinalizers(finalizers)
+		return c
+	}
+
+	tests := []struct {
+		description string
+		csv         *v1alpha1.ClusterServiceVersion
+		sub         *v1alpha1.Subscription
+		updated     bool
+		finalizers  []string
+	}{
+		{
+			description: "AddsFinalizerForSubscriptionPolicy",
+			csv:         newCSV("csv1"),
+			sub:         subscription("sub1", "csv1", CleanupPolicyDelete),
+			updated:     true,
+			finalizers:  []string{CleanupFinalizer},
+		},
+		{
+			description: "KeepsFinalizer",
+			csv:         newCSV("csv1", CleanupFinalizer),
+			sub:         subscription("sub1", "csv1", CleanupPolicyDelete),
+			finalizers:  []string{CleanupFinalizer},
+		},
+		{
+			description: "RemovesFinalizerWithoutSubscriptionPolicy",
+			csv:         newCSV("csv1", "other", CleanupFinalizer),
+			sub:         subscription("sub1", "csv1", CleanupPolicyOrphan),
+			updated:     true,
+			finalizers:  []string{"other"},
+		},
+		{
+			description: "IgnoresPolicyShippedInCSV",
+			csv: func() *v1alpha1.ClusterServiceVersion {
+				c := newCSV("csv1")
+				c.SetAnnotations(map[string]string{CleanupPolicyAnnotationKey: CleanupPolicyDelete})
+				return c
+			}(),
+			sub: subscription("sub1", "csv1", ""),
+		},
+		{
+			description: "KeepsFinalizerOfDeletedSubscription",
+			csv:         newCSV("csv1", CleanupFinalizer),
+			finalizers:  []string{CleanupFinalizer},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			objs := []runtime.Object{tt.csv}
+			if tt.sub != nil {
+				objs = append(objs, tt.sub)
+			}
+			op, err := NewFakeOperator(ctx, withNamespaces("ns"), withClientObjs(objs...))
+			require.NoError(t, err)
+
+			updated, err := op.ensureCleanupFinalizer(tt.csv)
+			require.NoError(t, err)
+			require.Equal(t, tt.updated, updated)
+
+			got, err := op.client.OperatorsV1alpha1().ClusterServiceVersions("ns").Get(ctx, tt.csv.GetName(), metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, tt.finalizers, got.GetFinalizers())
+		})
+	}
+}
+
+func TestSyncCleanup(t *testing.T) {
+	widgets := crd("Widget", "v1", "example.com")
+	widgets.SetName("widgets.example.com")
+	widgets.Spec.Names.Plural = "widgets"
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+	widget := func(namespace, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("example.com/v1")
+		u.SetKind("Widget")
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+
+	now := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	deletingSince := func(name string, deleted time.Time) *v1alpha1.ClusterServiceVersion {
+		c := csv(name, "ns", "", "", v1alpha1.NamedInstallStrategy{}, []*apiextensionsv1.CustomResourceDefinition{widgets}, nil, v1alpha1.CSVPhaseSucceeded)
+		c.SetUID("csv-uid")
+		c.SetFinalizers([]string{CleanupFinalizer})
+		c.SetDeletionTimestamp(&metav1.Time{Time: deleted})
+		return c
+	}
+	deleting := func(name string) *v1alpha1.ClusterServiceVersion {
+		return deletingSince(name, now)
+	}
+
+	tests := []struct {
+		description  string
+		csv          *v1alpha1.ClusterServiceVersion
+		others       []runtime.Object
+		blockDeletes bool
+		terminating  bool
+		cleanedUp    bool
+		rbacDeleted  bool
+		finalized    bool
+		message      string
+	}{
+		{
+			description: "DeletesOperandsCRDsAndRBAC",
+			csv:         deleting("csv1"),
+			cleanedUp:   true,
+			rbacDeleted: true,
+			finalized:   true,
+		},
+		{
+			description:  "WaitsForOperands",
+			csv:          deleting("csv1"),
+			blockDeletes: true,
+			message:      "waiting for custom resources to be deleted: Widget ns1/w1, Widget ns2/w2",
+		},
+		{
+			description:  "OrphansOperandsAfterTimeout",
+			csv:          deletingSince("csv1", now.Add(-cleanupTimeout)),
+			blockDeletes: true,
+			rbacDeleted:  true,
+			finalized:    true,
+		},
+		{
+			description:  "OrphansOperandsInTerminatingNamespace",
+			csv:          deleting("csv1"),
+			blockDeletes: true,
+			terminating:  true,
+			rbacDeleted:  true,
+			finalized:    true,
+		},
+		{
+			description: "KeepsCRDRequiredByOtherCSV",
+			csv:         deleting("csv1"),
+			others:      []runtime.Object{csv("csv2", "ns", "", "", v1alpha1.NamedInstallStrategy{}, nil, []*apiextensionsv1.CustomResourceDefinition{widgets}, v1alpha1.CSVPhaseSucceeded)},
+			rbacDeleted: true,
+			finalized:   true,
+		},
+		{
+			description: "KeepsCRDOwnedByOtherCSV",
+			csv:         deleting("csv1"),
+			others:      []runtime.Object{csv("csv2", "other", "", "", v1alpha1.NamedInstallStrategy{}, []*apiextensionsv1.CustomResourceDefinition{widgets}, nil, v1alpha1.CSVPhaseSucceeded)},
+			rbacDeleted: true,
+			finalized:   true,
+		},
+		{
+			description: "KeepsCRDOwnedBySameCSVInOtherNamespace",
+			csv:         deleting("csv1"),
+			others:      []runtime.Object{csv("csv1", "other", "", "", v1alpha1.NamedInstallStrategy{}, []*apiextensionsv1.CustomResourceDefinition{widgets}, nil, v1alpha1.CSVPhaseSucceeded)},
+			rbacDeleted: true,
+			finalized:   true,
+		},
+		{
+			description: "IgnoresCopiedCSV",
+			csv:         deleting("csv1"),
+			others: []runtime.Object{func() *v1alpha1.ClusterServiceVersion {
+				copied := csv("csv1", "other", "", "", v1alpha1.NamedInstallStrategy{}, []*apiextensionsv1.CustomResourceDefinition{widgets}, nil, v1alpha1.CSVPhaseSucceeded)
+				copied.Status.Reason = v1alpha1.CSVReasonCopied
+				return copied
+			}()},
+			cleanedUp:   true,
+			rbacDeleted: true,
+			finalized:   true,
+		},
+		{
+			description: "SkipsReplacedCSV",
+			csv:         deleting("csv1"),
+			others:      []runtime.Object{csv("csv2", "ns", "", "csv1", v1alpha1.NamedInstallStrategy{}, []*apiextensionsv1.CustomResourceDefinition{widgets}, nil, v1alpha1.CSVPhaseSucceeded)},
+			finalized:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "csv1-role"}}
+			ownerutil.AddOwnerLabels(clusterRole, tt.csv)
+			clusterRoleBinding := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "csv1-binding"}}
+			ownerutil.AddOwnerLabels(clusterRoleBinding, tt.csv)
+
+			namespaces := []string{"ns", "other"}
+			k8sObjs := []runtime.Object{clusterRole, clusterRoleBinding}
+			if tt.terminating {
+				namespaces = []string{"other"}
+				k8sObjs = append(k8sObjs, &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{Name: "ns"},
+					Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+				})
+			}
+			op, err := NewFakeOperator(
+				ctx,
+				withNamespaces(namespaces...),
+				withClientObjs(append(tt.others, tt.csv)...),
+				withK8sObjs(k8sObjs...),
+				withExtObjs(widgets),
+				withClock(utilclock.NewFakeClock(now)),
+			)
+			require.NoError(t, err)
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{gvr: "WidgetList"},
+				widget("ns1", "w1"), widget("ns2", "w2"),
+			)
+			if tt.blockDeletes {
+				dynamicClient.PrependReactor("delete", "widgets", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+			}
+			op.dynamicClient = dynamicClient
+
+			require.NoError(t, op.syncCleanup(tt.csv, op.logger.WithField("csv", tt.csv.GetName())))
+
+			crs, err := dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+			require.NoError(t, err)
+			_, crdErr := op.opClient.ApiextensionsInterface().ApiextensionsV1().CustomResourceDefinitions().Get(ctx, widgets.GetName(), metav1.GetOptions{})
+			_, crErr := op.opClient.KubernetesInterface().RbacV1().ClusterRoles().Get(ctx, clusterRole.GetName(), metav1.GetOptions{})
+			_, crbErr := op.opClient.KubernetesInterface().RbacV1().ClusterRoleBindings().Get(ctx, clusterRoleBinding.GetName(), metav1.GetOptions{})
+			if tt.cleanedUp {
+				require.Empty(t, crs.Items)
+				require.True(t, k8serrors.IsNotFound(crdErr))
+			} else {
+				require.Len(t, crs.Items, 2)
+				require.NoError(t, crdErr)
+			}
+			if tt.rbacDeleted {
+				require.True(t, k8serrors.IsNotFound(crErr))
+				require.True(t, k8serrors.IsNotFound(crbErr))
+			} else {
+				require.NoError(t, crErr)
+				require.NoError(t, crbErr)
+			}
+
+			got, err := op.client.OperatorsV1alpha1().ClusterServiceVersions("ns").Get(ctx, tt.csv.GetName(), metav1.GetOptions{})
+			require.NoError(t, err)
+			if tt.finalized {
+				require.Empty(t, got.GetFinalizers())
+			} else {
+				require.Equal(t, []string{CleanupFinalizer}, got.GetFinalizers())
+				require.Equal(t, v1alpha1.CSVPhaseDeleting, got.Status.Phase)
+				require.Equal(t, CSVReasonCleanupPending, got.Status.Reason)
+				require.Equal(t, tt.message, got.Status.Message)
+			}
+		})
+	}
+}
@@ -22,18 +22,22 @@ import (
 type OperatorOption func(*operatorConfig)
 
 type operatorConfig struct {
-	resyncPeriod      func() time.Duration
-	operatorNamespace string
-	watchedNamespaces []string
-	clock             utilclock.Clock
-	logger            *logrus.Logger
-	operatorClient    operatorclient.ClientInterface
-	externalClient    versioned.Interface
-	strategyResolver  install.StrategyResolverInterface
-	apiReconciler     resolver.APIIntersectionReconciler
-	apiLabeler        labeler.Labeler
-	restConfig        *rest.Config
-	configClient      configv1client.Interface
+	resyncPeriod        func() time.Duration
+	operatorNamespace   string
+	watchedNamespaces   []string
+	clock               utilclock.Clock
+	logger              *logrus.Logger
+	operatorClient      operatorclient.ClientInterface
+	externalClient      versioned.Interface
+	strategyResolver    install.StrategyResolverInterface
+	apiReconciler       resolver.APIIntersectionReconciler
+	apiLabeler          labeler.Labeler
+	restConfig          *rest.Config
+	configClient        configv1client.Interface
+	certPolicy          install.CertPolicy
+	copiedCSVMode       CopiedCSVMode
+	requirementCheckers []RequirementChecker
+	requirementChecks   []string
 }
 
 func (o *operatorConfig) apply(options []OperatorOption) {
@@ -71,6 +75,12 @@ func (o *operatorConfig) validate() (err error) {
 		err = newInvalidConfigError("api labeler", "must not be nil")
 	case o.restConfig == nil:
 		err = newInvalidConfigError("rest config", "must not be nil")
+	case o.certPolicy.Validate() != nil:
+		err = newInvalidConfigError("cert policy", o.certPolicy.Validate().Error())
+	case o.copiedCSVMode.validate() != nil:
+		err = newInvalidConfigError("copied csv mode", o.copiedCSVMode.validate().Error())
+	case validateRequirementChecks(o.requirementChecks) != nil:
+		err = newInvalidConfigError("requirement checks", validateRequirementChecks(o.requirementChecks).Error())
 	}
 
 	return
@@ -160,3 +170,33 @@ func WithConfigClient(configClient configv1client.Interface) OperatorOption {
 		config.configClient = configClient
 	}
 }
+
+// WithCertPolicy sets the policy under which certs are issued for the APIServices and webhooks of
+// ClusterServiceVersions.
+func WithCertPolicy(policy install.CertPolicy) OperatorOption {
+	return func(config *operatorConfig) {
+		config.certPolicy = policy
+	}
+}
+
+// WithCopiedCSVMode sets how the operators of AllNamespaces OperatorGroups are advertised in the namespaces that they
+// target.
+func WithCopiedCSVMode(mode CopiedCSVMode) OperatorOption {
+	return func(config *operatorConfig) {
+		config.copiedCSVMode = mode
+	}
+}
+
+// WithRequirementCheckers adds checkers for requirements that CSVs must meet before they're installed.
+func WithRequirementCheckers(checkers ...RequirementChecker) OperatorOption {
+	return func(config *operatorConfig) {
+		config.requirementCheckers = append(config.requirementCheckers, checkers...)
+	}
+}
+
+// WithRequirementChecks adds the built-in requirement checkers with the given names, such as RequirementCheckPlatform.
+func WithRequirementChecks(names ...string) OperatorOption {
+	return func(config *operatorConfig) {
+		config.requirementChecks = append(config.requirementChecks, names...)
+	}
+}
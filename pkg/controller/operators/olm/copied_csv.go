@@ -0,0 +1,194 @@
+package olm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	v1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+// CopiedCSVMode selects how the operators of AllNamespaces OperatorGroups are advertised in the namespaces that they
+// target.
+type CopiedCSVMode string
+
+const (
+	// CopiedCSVModeFull copies each CSV into every target namespace. It is the default. The
+	// OperatorReferencesConfigMapName ConfigMaps left by an earlier run in Reference mode aren't watched, and so
+	// aren't removed.
+	CopiedCSVModeFull CopiedCSVMode = "Full"
+
+	// CopiedCSVModeReference adds a small OperatorReference to the OperatorReferencesConfigMapName ConfigMap of every
+	// target namespace instead of a copy of the CSV. OperatorGroups that target specific namespaces still get copied
+	// CSVs, which own the RBAC that OLM grants in those namespaces.
+	CopiedCSVModeReference CopiedCSVMode = "Reference"
+
+	// OperatorReferencesConfigMapName is the ConfigMap in each namespace that lists the operators of AllNamespaces
+	// OperatorGroups that are available in the namespace, keyed by "<namespace>.<csv name>".
+	OperatorReferencesConfigMapName = "olm-operators"
+
+	// OperatorReferencesLabelKey labels the ConfigMaps holding OperatorReferences. OLM doesn't modify
+	// OperatorReferencesConfigMapName ConfigMaps without it.
+	OperatorReferencesLabelKey = "olm.operator-references"
+)
+
+// OperatorReference is the entry of an operator in the OperatorReferencesConfigMapName ConfigMap of a namespace.
+type OperatorReference struct {
+	Namespace     string                              `json:"namespace"`
+	Name          string                              `json:"name"`
+	OperatorGroup string                              `json:"operatorGroup"`
+	DisplayName   string                              `json:"displayName,omitempty"`
+	Version       string                              `json:"version,omitempty"`
+	Phase         v1alpha1.ClusterServiceVersionPhase `json:"phase,omitempty"`
+}
+
+func (m CopiedCSVMode) validate() error {
+	switch m {
+	case "", CopiedCSVModeFull, CopiedCSVModeReference:
+		return nil
+	}
+	return fmt.Errorf("unknown copied CSV mode %q", m)
+}
+
+func operatorReferenceKey(csv *v1alpha1.ClusterServiceVersion) string {
+	return csv.GetNamespace() + "." + csv.GetName()
+}
+
+func operatorReference(csv *v1alpha1.ClusterServiceVersion, operatorGroup *v1.OperatorGroup) (string, error) {
+	ref := OperatorReference{
+		Namespace:     csv.GetNamespace(),
+		Name:          csv.GetName(),
+		OperatorGroup: operatorGroup.GetName(),
+		DisplayName:   csv.Spec.DisplayName,
+		Version:       csv.Spec.Version.String(),
+		Phase:         csv.Status.Phase,
+	}
+	data, err := json.Marshal(ref)
+	return string(data), err
+}
+
+// ensureOperatorReference adds or updates the reference to a CSV in a namespace.
+func (a *Operator) ensureOperatorReference(namespace string, csv *v1alpha1.ClusterServiceVersion, operatorGroup *v1.OperatorGroup) error {
+	key := operatorReferenceKey(csv)
+	ref, err := operatorReference(csv, operatorGroup)
+	if err != nil {
+		return err
+	}
+
+	cm, err := a.lister.CoreV1().ConfigMapLister().ConfigMaps(namespace).Get(OperatorReferencesConfigMapName)
+	if err == nil && cm.Data[key] == ref {
+		return nil
+	}
+	if k8serrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      OperatorReferencesConfigMapName,
+				Namespace: namespace,
+				Labels:    map[string]string{OperatorReferencesLabelKey: "true"},
+			},
+			Data: map[string]string{key: ref},
+		}
+		_, err = a.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+		if !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		// Only labeled ConfigMaps are cached, so one that exists may belong to someone else.
+		existing, err := a.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Get(context.TODO(), OperatorReferencesConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if existing.GetLabels()[OperatorReferencesLabelKey] != "true" {
+			return fmt.Errorf("ConfigMap %s/%s exists but isn't labeled %s=true, not adding operator reference", namespace, OperatorReferencesConfigMapName, OperatorReferencesLabelKey)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// Merge patches touch only this operator's entry, so concurrent syncs of other operators don't conflict.
+	return a.patchOperatorReference(namespace, key, ref)
+}
+
+// removeOperatorReference removes the reference to a CSV from a namespace, if there is one. References are only
+// removed in Reference mode, in which the ConfigMaps holding them are cached.
+func (a *Operator) removeOperatorReference(namespace string, csv *v1alpha1.ClusterServiceVersion) error {
+	if a.copiedCSVMode != CopiedCSVModeReference {
+		return nil
+	}
+	key := operatorReferenceKey(csv)
+	cm, err := a.lister.CoreV1().ConfigMapLister().ConfigMaps(namespace).Get(OperatorReferencesConfigMapName)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := cm.Data[key]; !ok {
+		return nil
+	}
+	return a.patchOperatorReference(namespace, key, nil)
+}
+
+// removeOperatorGroupReferences removes the references to the CSVs of an OperatorGroup from a namespace that it no
+// longer targets. Like removeOperatorReference, it does nothing outside of Reference mode.
+func (a *Operator) removeOperatorGroupReferences(namespace string, operatorGroup *v1.OperatorGroup) error {
+	if a.copiedCSVMode != CopiedCSVModeReference {
+		return nil
+	}
+	cm, err := a.lister.CoreV1().ConfigMapLister().ConfigMaps(namespace).Get(OperatorReferencesConfigMapName)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for key, data := range cm.Data {
+		var ref OperatorReference
+		if err := json.Unmarshal([]byte(data), &ref); err != nil {
+			continue
+		}
+		if ref.Namespace != operatorGroup.GetNamespace() || ref.OperatorGroup != operatorGroup.GetName() {
+			continue
+		}
+		if err := a.patchOperatorReference(namespace, key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Operator) patchOperatorReference(namespace, key string, ref interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{"data": map[string]interface{}{key: ref}})
+	if err != nil {
+		return err
+	}
+	_, err = a.opClient.KubernetesInterface().CoreV1().ConfigMaps(namespace).Patch(context.TODO(), OperatorReferencesConfigMapName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if ref == nil && k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// deleteCopiedCSV deletes the copy of a CSV from a namespace that now references it instead.
+func (a *Operator) deleteCopiedCSV(namespace string, csv *v1alpha1.ClusterServiceVersion) error {
+	copied, err := a.lister.OperatorsV1alpha1().ClusterServiceVersionLister().ClusterServiceVersions(namespace).Get(csv.GetName())
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !copied.IsCopied() || copied.GetAnnotations()[v1.OperatorGroupNamespaceAnnotationKey] != csv.GetNamespace() {
+		return nil
+	}
+	err = a.client.OperatorsV1alpha1().ClusterServiceVersions(namespace).Delete(context.TODO(), copied.GetName(), metav1.DeleteOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
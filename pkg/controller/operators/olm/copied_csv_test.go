@@ -0,0 +1,143 @@
+package olm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/operator-framework/api/pkg/operators/v1"
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+)
+
+func TestEnsureCSVsInNamespacesReferenceMode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	operatorGroup := &v1.OperatorGroup{
+		ObjectMeta: metav1.ObjectMeta{Name: "global", Namespace: "operators"},
+		Status:     v1.OperatorGroupStatus{Namespaces: []string{corev1.NamespaceAll}},
+	}
+	parent := csv("csv1", "operators", "", "", installStrategy("dep", nil, nil), nil, nil, v1alpha1.CSVPhaseSucceeded)
+	parent.Spec.DisplayName = "Operator One"
+	parent.SetAnnotations(map[string]string{
+		v1.OperatorGroupNamespaceAnnotationKey: operatorGroup.GetNamespace(),
+		v1.OperatorGroupAnnotationKey:          operatorGroup.GetName(),
+	})
+
+	// A copy left over from full mode, which the reference replaces.
+	copied := parent.DeepCopy()
+	copied.SetNamespace("app1")
+	copied.Status.Reason = v1alpha1.CSVReasonCopied
+
+	// A reference to an operator of another group, which is left alone.
+	other, err := json.Marshal(OperatorReference{Namespace: "other", Name: "csv2", OperatorGroup: "other"})
+	require.NoError(t, err)
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      OperatorReferencesConfigMapName,
+			Namespace: "app2",
+			Labels:    map[string]string{OperatorReferencesLabelKey: "true"},
+		},
+		Data: map[string]string{"other.csv2": string(other)},
+	}
+
+	op, err := NewFakeOperator(
+		ctx,
+		withNamespaces("operators", "app1", "app2"),
+		withClientObjs(parent, copied),
+		withK8sObjs(existing),
+		withCopiedCSVMode(CopiedCSVModeReference),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, op.ensureCSVsInNamespaces(parent, operatorGroup, resolver.NewNamespaceSet(operatorGroup.Status.Namespaces)))
+
+	want := OperatorReference{
+		Namespace:     "operators",
+		Name:          "csv1",
+		OperatorGroup: "global",
+		DisplayName:   "Operator One",
+		Version:       "0.0.0",
+		Phase:         v1alpha1.CSVPhaseSucceeded,
+	}
+	for _, ns := range []string{"app1", "app2"} {
+		cm, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps(ns).Get(ctx, OperatorReferencesConfigMapName, metav1.GetOptions{})
+		require.NoError(t, err)
+		var got OperatorReference
+		require.NoError(t, json.Unmarshal([]byte(cm.Data["operators.csv1"]), &got))
+		require.Equal(t, want, got)
+	}
+
+	cm, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps("app2").Get(ctx, OperatorReferencesConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Contains(t, cm.Data, "other.csv2")
+
+	_, err = op.opClient.KubernetesInterface().CoreV1().ConfigMaps("operators").Get(ctx, OperatorReferencesConfigMapName, metav1.GetOptions{})
+	require.True(t, k8serrors.IsNotFound(err))
+
+	_, err = op.client.OperatorsV1alpha1().ClusterServiceVersions("app1").Get(ctx, "csv1", metav1.GetOptions{})
+	require.True(t, k8serrors.IsNotFound(err), "copied csv should be replaced by a reference")
+}
+
+func TestPruneFromNamespaceRemovesReferences(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	operatorGroup := &v1.OperatorGroup{ObjectMeta: metav1.ObjectMeta{Name: "og", Namespace: "operators"}}
+
+	data := map[string]string{}
+	for key, ref := range map[string]OperatorReference{
+		"operators.csv1": {Namespace: "operators", Name: "csv1", OperatorGroup: "og"},
+		"operators.csv2": {Namespace: "operators", Name: "csv2", OperatorGroup: "og"},
+		"other.csv3":     {Namespace: "other", Name: "csv3", OperatorGroup: "og"},
+	} {
+		raw, err := json.Marshal(ref)
+		require.NoError(t, err)
+		data[key] = string(raw)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      OperatorReferencesConfigMapName,
+			Namespace: "app1",
+			Labels:    map[string]string{OperatorReferencesLabelKey: "true"},
+		},
+		Data: data,
+	}
+
+	op, err := NewFakeOperator(ctx, withNamespaces("operators", "app1"), withK8sObjs(cm), withCopiedCSVMode(CopiedCSVModeReference))
+	require.NoError(t, err)
+
+	require.NoError(t, op.pruneFromNamespace(operatorGroup, "app1"))
+
+	got, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps("app1").Get(ctx, OperatorReferencesConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, got.Data, 1)
+	require.Contains(t, got.Data, "other.csv3")
+}
+
+func TestEnsureOperatorReferenceLeavesUnlabeledConfigMaps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	operatorGroup := &v1.OperatorGroup{ObjectMeta: metav1.ObjectMeta{Name: "global", Namespace: "operators"}}
+	parent := csv("csv1", "operators", "", "", installStrategy("dep", nil, nil), nil, nil, v1alpha1.CSVPhaseSucceeded)
+	unlabeled := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: OperatorReferencesConfigMapName, Namespace: "app1"},
+		Data:       map[string]string{"key": "value"},
+	}
+
+	op, err := NewFakeOperator(ctx, withNamespaces("operators", "app1"), withK8sObjs(unlabeled), withCopiedCSVMode(CopiedCSVModeReference))
+	require.NoError(t, err)
+
+	require.Error(t, op.ensureOperatorReference("app1", parent, operatorGroup))
+
+	got, err := op.opClient.KubernetesInterface().CoreV1().ConfigMaps("app1").Get(ctx, OperatorReferencesConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, unlabeled.Data, got.Data)
+}
@@ -22,6 +22,7 @@ import (
 	utilclock "k8s.io/apimachinery/pkg/util/clock"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	k8sscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/cache"
@@ -65,6 +66,7 @@ type Operator struct {
 	logger                *logrus.Logger
 	opClient              operatorclient.ClientInterface
 	client                versioned.Interface
+	dynamicClient         dynamic.Interface
 	lister                operatorlister.OperatorLister
 	ogQueueSet            *queueinformer.ResourceQueueSet
 	csvQueueSet           *queueinformer.ResourceQueueSet
@@ -76,6 +78,9 @@ type Operator struct {
 	csvIndexers           map[string]cache.Indexer
 	recorder              record.EventRecorder
 	resolver              install.StrategyResolverInterface
+	certPolicy            install.CertPolicy
+	copiedCSVMode         CopiedCSVMode
+	requirementCheckers   []RequirementChecker
 	apiReconciler         resolver.APIIntersectionReconciler
 	apiLabeler            labeler.Labeler
 	csvSetGenerator       csvutility.SetGenerator
@@ -116,12 +121,19 @@ func newOperatorWithConfig(ctx context.Context, config *operatorConfig) (*Operat
 		return nil, err
 	}
 
+	// Create a new client for dynamic types (CRs)
+	dynamicClient, err := dynamic.NewForConfig(config.restConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	op := &Operator{
 		Operator:              queueOperator,
 		clock:                 config.clock,
 		logger:                config.logger,
 		opClient:              config.operatorClient,
 		client:                config.externalClient,
+		dynamicClient:         dynamicClient,
 		ogQueueSet:            queueinformer.NewEmptyResourceQueueSet(),
 		csvQueueSet:           queueinformer.NewEmptyResourceQueueSet(),
 		csvCopyQueueSet:       queueinformer.NewEmptyResourceQueueSet(),
@@ -129,6 +141,9 @@ func newOperatorWithConfig(ctx context.Context, config *operatorConfig) (*Operat
 		objGCQueueSet:         queueinformer.NewEmptyResourceQueueSet(),
 		apiServiceQueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "apiservice"),
 		resolver:              config.strategyResolver,
+		certPolicy:            config.certPolicy,
+		copiedCSVMode:         config.copiedCSVMode,
+		requirementCheckers:   config.requirementCheckers,
 		apiReconciler:         config.apiReconciler,
 		lister:                lister,
 		recorder:              eventRecorder,
@@ -165,7 +180,10 @@ func newOperatorWithConfig(ctx context.Context, config *operatorConfig) (*Operat
 		if err := op.RegisterQueueInformer(csvQueueInformer); err != nil {
 			return nil, err
 		}
-		if err := csvInformer.Informer().AddIndexers(cache.Indexers{index.MetaLabelIndexFuncKey: index.MetaLabelIndexFunc}); err != nil {
+		if err := csvInformer.Informer().AddIndexers(cache.Indexers{
+			index.MetaLabelIndexFuncKey:    index.MetaLabelIndexFunc,
+			index.ProvidedAPIsIndexFuncKey: index.ProvidedAPIsIndexFunc,
+		}); err != nil {
 			return nil, err
 		}
 		csvIndexer := csvInformer.Informer().GetIndexer()
@@ -321,6 +339,26 @@ func newOperatorWithConfig(ctx context.Context, config *operatorConfig) (*Operat
 			return nil, err
 		}
 
+		// Register ConfigMap QueueInformer for the operator references of copied CSV reference mode
+		if config.copiedCSVMode == CopiedCSVModeReference {
+			configMapInformer := informers.NewSharedInformerFactoryWithOptions(op.opClient.KubernetesInterface(), config.resyncPeriod(), informers.WithNamespace(namespace), informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.LabelSelector = labels.SelectorFromValidatedSet(map[string]string{OperatorReferencesLabelKey: "true"}).String()
+			})).Core().V1().ConfigMaps()
+			op.lister.CoreV1().RegisterConfigMapLister(namespace, configMapInformer.Lister())
+			configMapQueueInformer, err := queueinformer.NewQueueInformer(
+				ctx,
+				queueinformer.WithLogger(op.logger),
+				queueinformer.WithInformer(configMapInformer.Informer()),
+				queueinformer.WithSyncer(k8sSyncer),
+			)
+			if err != nil {
+				return nil, err
+			}
+			if err := op.RegisterQueueInformer(configMapQueueInformer); err != nil {
+				return nil, err
+			}
+		}
+
 		// Register Service QueueInformer
 		serviceInformer := k8sInformerFactory.Core().V1().Services()
 		op.lister.CoreV1().RegisterServiceLister(namespace, serviceInformer.Lister())
@@ -512,7 +550,14 @@ func newOperatorWithConfig(ctx context.Context, config *operatorConfig) (*Operat
 	overridesBuilderFunc := overrides.NewDeploymentInitializer(op.logger, proxyQuerierInUse, op.lister)
 	op.resolver = &install.StrategyResolver{
 		OverridesBuilderFunc: overridesBuilderFunc.GetDeploymentInitializer,
+		CertPolicy:           config.certPolicy,
+	}
+
+	checkers, err := op.newRequirementCheckers(k8sInformerFactory, config.resyncPeriod(), config.requirementChecks...)
+	if err != nil {
+		return nil, err
 	}
+	op.requirementCheckers = append(op.requirementCheckers, checkers...)
 
 	return op, nil
 }
@@ -981,6 +1026,9 @@ func (a *Operator) handleClusterServiceVersionDeletion(obj interface{}) {
 			if err := a.csvGCQueueSet.Requeue(namespace, clusterServiceVersion.GetName()); err != nil {
 				logger.WithError(err).Warn("unable to requeue")
 			}
+			if err := a.removeOperatorReference(namespace, clusterServiceVersion); err != nil {
+				logger.WithField("targetNamespace", namespace).WithError(err).Warn("unable to remove operator reference")
+			}
 		}
 	}
 
@@ -1131,6 +1179,13 @@ func (a *Operator) syncClusterServiceVersion(obj interface{}) (syncError error)
 		return
 	}
 
+	if clusterServiceVersion.GetDeletionTimestamp() != nil && hasCleanupFinalizer(clusterServiceVersion) {
+		return a.syncCleanup(clusterServiceVersion, logger)
+	}
+	if updated, err := a.ensureCleanupFinalizer(clusterServiceVersion); updated || err != nil {
+		return err
+	}
+
 	outCSV, syncError := a.transitionCSVState(*clusterServiceVersion)
 
 	if outCSV == nil {
@@ -1580,8 +1635,10 @@ func (a *Operator) transitionCSVState(in v1alpha1.ClusterServiceVersion) (out *v
 
 		if out.HasCAResources() {
 			now := metav1.Now()
-			expiration := now.Add(install.DefaultCertValidFor)
-			rotateAt := expiration.Add(-1 * install.DefaultCertMinFresh)
+			rotateAt := a.certPolicy.RotateAt(now.Time)
+			if rotator, ok := installer.(install.CertRotator); ok && !rotator.CertsRotateAt().IsZero() {
+				rotateAt = rotator.CertsRotateAt()
+			}
 			rotateTime := metav1.NewTime(rotateAt)
 			out.Status.CertsLastUpdated = &now
 			out.Status.CertsRotateAt = &rotateTime
@@ -1622,6 +1679,16 @@ func (a *Operator) transitionCSVState(in v1alpha1.ClusterServiceVersion) (out *v
 		}
 		logger.WithField("strategy", out.Spec.InstallStrategy.StrategyName).Infof("install strategy successful")
 
+		// Canaries soak alongside the CSV they replace before succeeding
+		if out.Status.Phase == v1alpha1.CSVPhaseSucceeded {
+			if prev := a.canaryReplacing(out, logger); prev != nil {
+				a.startCanary(out, prev, logger)
+			}
+		}
+
+	case CSVPhaseCanary:
+		syncError = a.syncCanary(out, logger)
+
 	case v1alpha1.CSVPhaseSucceeded:
 		// Check if the current CSV is being replaced, return with replacing status if so
 		if err := a.checkReplacementsAndUpdateStatus(out); err != nil {
@@ -1642,7 +1709,7 @@ func (a *Operator) transitionCSVState(in v1alpha1.ClusterServiceVersion) (out *v
 		}
 
 		// Check if it's time to refresh owned APIService certs
-		if install.ShouldRotateCerts(out) {
+		if a.certPolicy.ShouldRotate(out) {
 			logger.Debug("CSV owns resources that require a cert refresh")
 			out.SetPhaseWithEvent(v1alpha1.CSVPhasePending, v1alpha1.CSVReasonNeedsCertRotation, "CSV owns resources that require a cert refresh", now, a.recorder)
 			return
@@ -1687,6 +1754,17 @@ func (a *Operator) transitionCSVState(in v1alpha1.ClusterServiceVersion) (out *v
 		}
 
 	case v1alpha1.CSVPhaseFailed:
+		// Aborted canaries stay failed, and failed canaries are aborted, so that the CSV they replace is reinstated
+		if out.Status.Reason == CSVReasonCanaryFailed {
+			return
+		}
+		if _, ok := canaryFailureReasons[out.Status.Reason]; ok {
+			if prev := a.canaryReplacing(out, logger); prev != nil {
+				syncError = a.abortCanary(out, prev, fmt.Sprintf("canary failed: %s", out.Status.Message), logger)
+				return
+			}
+		}
+
 		installer, strategy := a.parseStrategiesAndUpdateStatus(out)
 		if strategy == nil {
 			return
@@ -1743,7 +1821,7 @@ func (a *Operator) transitionCSVState(in v1alpha1.ClusterServiceVersion) (out *v
 		}
 
 		// Check if it's time to refresh owned APIService certs
-		if install.ShouldRotateCerts(out) {
+		if a.certPolicy.ShouldRotate(out) {
 			logger.Debug("CSV owns resources that require a cert refresh")
 			out.SetPhaseWithEvent(v1alpha1.CSVPhasePending, v1alpha1.CSVReasonNeedsCertRotation, "owned APIServices need cert refresh", now, a.recorder)
 			return
@@ -1779,6 +1857,11 @@ func (a *Operator) transitionCSVState(in v1alpha1.ClusterServiceVersion) (out *v
 
 		// If there is a succeeded replacement, mark this for deletion
 		if next := a.isBeingReplaced(out, a.csvSet(out.GetNamespace(), v1alpha1.CSVPhaseAny)); next != nil {
+			if canaryAborted(next) {
+				// the replacement was a canary that failed, reinstate this csv
+				out.SetPhaseWithEvent(v1alpha1.CSVPhasePending, CSVReasonCanaryAborted, fmt.Sprintf("upgrade to %s was aborted: %s", next.GetName(), next.Status.Message), now, a.recorder)
+				return
+			}
 			if next.Status.Phase == v1alpha1.CSVPhaseSucceeded {
 				out.SetPhaseWithEvent(v1alpha1.CSVPhaseDeleting, v1alpha1.CSVReasonReplaced, "has been replaced by a newer ClusterServiceVersion that has successfully installed.", now, a.recorder)
 			} else {
@@ -1813,7 +1896,7 @@ func (a *Operator) checkReplacementsAndUpdateStatus(csv *v1alpha1.ClusterService
 	if csv.Status.Phase == v1alpha1.CSVPhaseReplacing || csv.Status.Phase == v1alpha1.CSVPhaseDeleting {
 		return nil
 	}
-	if replacement := a.isBeingReplaced(csv, a.csvSet(csv.GetNamespace(), v1alpha1.CSVPhaseAny)); replacement != nil {
+	if replacement := a.isBeingReplaced(csv, a.csvSet(csv.GetNamespace(), v1alpha1.CSVPhaseAny)); replacement != nil && !canaryAborted(replacement) {
 		a.logger.Infof("newer csv replacing %s, no-op", csv.GetName())
 		msg := fmt.Sprintf("being replaced by csv: %s", replacement.GetName())
 		csv.SetPhaseWithEvent(v1alpha1.CSVPhaseReplacing, v1alpha1.CSVReasonBeingReplaced, msg, a.now(), a.recorder)
@@ -46,6 +46,7 @@ import (
 	"k8s.io/client-go/tools/record"
 	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	apiregistrationfake "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/fake"
+	"k8s.io/utils/pointer"
 
 	v1 "github.com/operator-framework/api/pkg/operators/v1"
 	"github.com/operator-framework/api/pkg/operators/v1alpha1"
@@ -221,6 +222,18 @@ func withEventRecorder(rec record.EventRecorder) fakeOperatorOption {
 	}
 }
 
+func withRequirementChecks(names ...string) fakeOperatorOption {
+	return func(config *fakeOperatorConfig) {
+		config.requirementChecks = names
+	}
+}
+
+func withCopiedCSVMode(mode CopiedCSVMode) fakeOperatorOption {
+	return func(config *fakeOperatorConfig) {
+		config.copiedCSVMode = mode
+	}
+}
+
 func withNamespaces(namespaces ...string) fakeOperatorOption {
 	return func(config *fakeOperatorConfig) {
 		config.namespaces = namespaces
@@ -896,6 +909,25 @@ func TestTransitionCSV(t *testing.T) {
 		v1.OperatorGroupAnnotationKey:          defaultOperatorGroup.GetName(),
 	}
 
+	canaryTemplateAnnotations := map[string]string{
+		v1.OperatorGroupTargetsAnnotationKey:   namespace,
+		v1.OperatorGroupNamespaceAnnotationKey: namespace,
+		v1.OperatorGroupAnnotationKey:          defaultOperatorGroup.GetName(),
+		UpgradeStrategyAnnotationKey:           UpgradeStrategyCanary,
+		CanarySoakPeriodAnnotationKey:          "1m",
+	}
+
+	// The deployment of a canary csv2 as installed, with the spec hash that OLM calculates
+	canaryDeployment := func() runtime.Object {
+		strategy := withTemplateAnnotations(installStrategy("csv2-dep1", nil, nil), canaryTemplateAnnotations)
+		spec := strategy.StrategySpec.DeploymentSpecs[0].Spec
+		spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "OPERATOR_CONDITION_NAME", Value: "csv2"}}
+		spec.RevisionHistoryLimit = pointer.Int32Ptr(1)
+		labels := ownerLabelFromCSV("csv2", namespace)
+		labels[install.DeploymentSpecHashLabelKey] = install.HashDeploymentSpec(spec)
+		return withLabels(deployment("csv2-dep1", namespace, "sa", canaryTemplateAnnotations), labels)
+	}
+
 	// Generate valid and expired CA fixtures
 	validCA, err := generateCA(time.Now().Add(10*365*24*time.Hour), install.Organization)
 	require.NoError(t, err)
@@ -2513,6 +2545,256 @@ func TestTransitionCSV(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "CSVInstallingToCanary",
+			initial: initial{
+				csvs: []runtime.Object{
+					csvWithAnnotations(csv("csv1",
+						namespace,
+						"0.0.0",
+						"",
+						installStrategy("csv1-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseReplacing,
+					), defaultTemplateAnnotations),
+					csvWithAnnotations(csv("csv2",
+						namespace,
+						"0.0.0",
+						"csv1",
+						installStrategy("csv2-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseInstalling,
+					), canaryTemplateAnnotations),
+				},
+				clientObjs: []runtime.Object{defaultOperatorGroup},
+				crds: []runtime.Object{
+					crd("c1", "v1", "g1"),
+				},
+				objs: []runtime.Object{
+					withLabels(
+						deployment("csv1-dep1", namespace, "sa", defaultTemplateAnnotations),
+						addDepSpecHashLabel(ownerLabelFromCSV("csv1", namespace), withTemplateAnnotations(installStrategy("csv1-dep1", nil, nil), defaultTemplateAnnotations)),
+					),
+					canaryDeployment(),
+				},
+			},
+			expected: expected{
+				csvStates: map[string]csvState{
+					"csv1": {exists: true, phase: v1alpha1.CSVPhaseReplacing},
+					"csv2": {exists: true, phase: CSVPhaseCanary, reason: CSVReasonCanarySoaking},
+				},
+			},
+		},
+		{
+			name: "CSVCanaryToCanary/Soaking",
+			initial: initial{
+				csvs: []runtime.Object{
+					csvWithAnnotations(csv("csv1",
+						namespace,
+						"0.0.0",
+						"",
+						installStrategy("csv1-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseReplacing,
+					), defaultTemplateAnnotations),
+					withPhase(csvWithAnnotations(csv("csv2",
+						namespace,
+						"0.0.0",
+						"csv1",
+						installStrategy("csv2-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseNone,
+					), canaryTemplateAnnotations), CSVPhaseCanary, CSVReasonCanarySoaking, "", metav1.NewTime(time.Now().Add(-30*time.Second))),
+				},
+				clientObjs: []runtime.Object{defaultOperatorGroup},
+				crds: []runtime.Object{
+					crd("c1", "v1", "g1"),
+				},
+				objs: []runtime.Object{
+					withLabels(
+						deployment("csv1-dep1", namespace, "sa", defaultTemplateAnnotations),
+						addDepSpecHashLabel(ownerLabelFromCSV("csv1", namespace), withTemplateAnnotations(installStrategy("csv1-dep1", nil, nil), defaultTemplateAnnotations)),
+					),
+					canaryDeployment(),
+				},
+			},
+			expected: expected{
+				csvStates: map[string]csvState{
+					"csv1": {exists: true, phase: v1alpha1.CSVPhaseReplacing},
+					"csv2": {exists: true, phase: CSVPhaseCanary, reason: CSVReasonCanarySoaking},
+				},
+			},
+		},
+		{
+			name: "CSVCanaryToSucceeded/Soaked",
+			initial: initial{
+				csvs: []runtime.Object{
+					csvWithAnnotations(csv("csv1",
+						namespace,
+						"0.0.0",
+						"",
+						installStrategy("csv1-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseReplacing,
+					), defaultTemplateAnnotations),
+					withPhase(csvWithAnnotations(csv("csv2",
+						namespace,
+						"0.0.0",
+						"csv1",
+						installStrategy("csv2-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseNone,
+					), canaryTemplateAnnotations), CSVPhaseCanary, CSVReasonCanarySoaking, "", metav1.NewTime(time.Now().Add(-2*time.Minute))),
+				},
+				clientObjs: []runtime.Object{defaultOperatorGroup},
+				crds: []runtime.Object{
+					crd("c1", "v1", "g1"),
+				},
+				objs: []runtime.Object{
+					withLabels(
+						deployment("csv1-dep1", namespace, "sa", defaultTemplateAnnotations),
+						addDepSpecHashLabel(ownerLabelFromCSV("csv1", namespace), withTemplateAnnotations(installStrategy("csv1-dep1", nil, nil), defaultTemplateAnnotations)),
+					),
+					canaryDeployment(),
+				},
+			},
+			expected: expected{
+				csvStates: map[string]csvState{
+					"csv1": {exists: true, phase: v1alpha1.CSVPhaseReplacing},
+					"csv2": {exists: true, phase: v1alpha1.CSVPhaseSucceeded, reason: v1alpha1.CSVReasonInstallSuccessful},
+				},
+			},
+		},
+		{
+			name: "CSVCanaryToFailed/Unhealthy",
+			initial: initial{
+				csvs: []runtime.Object{
+					csvWithAnnotations(csv("csv1",
+						namespace,
+						"0.0.0",
+						"",
+						installStrategy("csv1-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseReplacing,
+					), defaultTemplateAnnotations),
+					withPhase(csvWithAnnotations(csv("csv2",
+						namespace,
+						"0.0.0",
+						"csv1",
+						installStrategy("csv2-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseNone,
+					), canaryTemplateAnnotations), CSVPhaseCanary, CSVReasonCanarySoaking, "", metav1.NewTime(time.Now().Add(-30*time.Second))),
+				},
+				clientObjs: []runtime.Object{defaultOperatorGroup},
+				crds: []runtime.Object{
+					crd("c1", "v1", "g1"),
+				},
+				objs: []runtime.Object{
+					withLabels(
+						deployment("csv1-dep1", namespace, "sa", defaultTemplateAnnotations),
+						addDepSpecHashLabel(ownerLabelFromCSV("csv1", namespace), withTemplateAnnotations(installStrategy("csv1-dep1", nil, nil), defaultTemplateAnnotations)),
+					),
+				},
+			},
+			expected: expected{
+				csvStates: map[string]csvState{
+					"csv1": {exists: true, phase: v1alpha1.CSVPhaseReplacing},
+					"csv2": {exists: true, phase: v1alpha1.CSVPhaseFailed, reason: CSVReasonCanaryFailed},
+				},
+			},
+		},
+		{
+			name: "CSVFailedToFailed/CanaryAborted",
+			initial: initial{
+				csvs: []runtime.Object{
+					csvWithAnnotations(csv("csv1",
+						namespace,
+						"0.0.0",
+						"",
+						installStrategy("csv1-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseReplacing,
+					), defaultTemplateAnnotations),
+					withPhase(csvWithAnnotations(csv("csv2",
+						namespace,
+						"0.0.0",
+						"csv1",
+						installStrategy("csv2-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseNone,
+					), canaryTemplateAnnotations), v1alpha1.CSVPhaseFailed, v1alpha1.CSVReasonComponentUnhealthy, "", metav1.NewTime(time.Now().Add(0))),
+				},
+				clientObjs: []runtime.Object{defaultOperatorGroup},
+				crds: []runtime.Object{
+					crd("c1", "v1", "g1"),
+				},
+				objs: []runtime.Object{
+					withLabels(
+						deployment("csv1-dep1", namespace, "sa", defaultTemplateAnnotations),
+						addDepSpecHashLabel(ownerLabelFromCSV("csv1", namespace), withTemplateAnnotations(installStrategy("csv1-dep1", nil, nil), defaultTemplateAnnotations)),
+					),
+					canaryDeployment(),
+				},
+			},
+			expected: expected{
+				csvStates: map[string]csvState{
+					"csv1": {exists: true, phase: v1alpha1.CSVPhaseReplacing},
+					"csv2": {exists: true, phase: v1alpha1.CSVPhaseFailed, reason: CSVReasonCanaryFailed},
+				},
+			},
+		},
+		{
+			name: "CSVReplacingToPending/CanaryAborted",
+			initial: initial{
+				csvs: []runtime.Object{
+					csvWithAnnotations(csv("csv1",
+						namespace,
+						"0.0.0",
+						"",
+						installStrategy("csv1-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseReplacing,
+					), defaultTemplateAnnotations),
+					withPhase(csvWithAnnotations(csv("csv2",
+						namespace,
+						"0.0.0",
+						"csv1",
+						installStrategy("csv2-dep1", nil, nil),
+						[]*apiextensionsv1.CustomResourceDefinition{crd("c1", "v1", "g1")},
+						[]*apiextensionsv1.CustomResourceDefinition{},
+						v1alpha1.CSVPhaseNone,
+					), canaryTemplateAnnotations), v1alpha1.CSVPhaseFailed, CSVReasonCanaryFailed, "", metav1.NewTime(time.Now().Add(0))),
+				},
+				clientObjs: []runtime.Object{defaultOperatorGroup},
+				crds: []runtime.Object{
+					crd("c1", "v1", "g1"),
+				},
+				objs: []runtime.Object{
+					withLabels(
+						deployment("csv1-dep1", namespace, "sa", defaultTemplateAnnotations),
+						addDepSpecHashLabel(ownerLabelFromCSV("csv1", namespace), withTemplateAnnotations(installStrategy("csv1-dep1", nil, nil), defaultTemplateAnnotations)),
+					),
+				},
+			},
+			expected: expected{
+				csvStates: map[string]csvState{
+					"csv1": {exists: true, phase: v1alpha1.CSVPhasePending, reason: CSVReasonCanaryAborted},
+					"csv2": {exists: true, phase: v1alpha1.CSVPhaseFailed, reason: CSVReasonCanaryFailed},
+				},
+			},
+		},
 		{
 			name: "CSVDeletedToGone",
 			initial: initial{
@@ -640,6 +640,23 @@ func (a *Operator) ensureCSVsInNamespaces(csv *v1alpha1.ClusterServiceVersion, o
 
 	logger := a.logger.WithField("opgroup", operatorGroup.GetName()).WithField("csv", csv.GetName())
 
+	if a.copiedCSVMode == CopiedCSVModeReference && targets.IsAllNamespaces() {
+		// global operator group RBAC is handled by ensureRBACInTargetNamespace, so a reference is all a namespace needs
+		for _, ns := range namespaces {
+			if ns.GetName() == operatorGroup.Namespace {
+				continue
+			}
+			if err := a.ensureOperatorReference(ns.GetName(), csv, operatorGroup); err != nil {
+				logger.WithError(err).Debug("error adding operator reference to target")
+				continue
+			}
+			if err := a.deleteCopiedCSV(ns.GetName(), csv); err != nil {
+				logger.WithError(err).Debug("error deleting copied csv replaced by operator reference")
+			}
+		}
+		return nil
+	}
+
 	targetCSVs := make(map[string]*v1alpha1.ClusterServiceVersion)
 	for _, ns := range namespaces {
 		if ns.GetName() == operatorGroup.Namespace {
@@ -652,8 +669,11 @@ func (a *Operator) ensureCSVsInNamespaces(csv *v1alpha1.ClusterServiceVersion, o
 				continue
 			}
 			targetCSVs[ns.GetName()] = targetCSV
+			if err := a.removeOperatorReference(ns.GetName(), csv); err != nil {
+				logger.WithError(err).Debug("error removing operator reference replaced by copied csv")
+			}
 		} else {
-			if err := a.pruneFromNamespace(operatorGroup.GetName(), ns.GetName()); err != nil {
+			if err := a.pruneFromNamespace(operatorGroup, ns.GetName()); err != nil {
 				a.logger.WithError(err).Debug("error pruning from old target")
 			}
 		}
@@ -707,6 +727,8 @@ func (a *Operator) copyToNamespace(csv *v1alpha1.ClusterServiceVersion, namespac
 	logger := a.logger.WithField("operator-ns", csv.GetNamespace()).WithField("target-ns", namespace).WithField("csv", csv.GetName())
 	newCSV := csv.DeepCopy()
 	delete(newCSV.Annotations, v1.OperatorGroupTargetsAnnotationKey)
+	// Copies are cleaned up along with their parent, so they don't hold its cleanup finalizer.
+	newCSV.SetFinalizers(removeFinalizer(newCSV.GetFinalizers(), CleanupFinalizer))
 
 	fetchedCSV, err := a.lister.OperatorsV1alpha1().ClusterServiceVersionLister().ClusterServiceVersions(namespace).Get(newCSV.GetName())
 	if fetchedCSV != nil {
@@ -785,14 +807,18 @@ func (a *Operator) copyToNamespace(csv *v1alpha1.ClusterServiceVersion, namespac
 	return nil, fmt.Errorf("unhandled code path")
 }
 
-func (a *Operator) pruneFromNamespace(operatorGroupName, namespace string) error {
+func (a *Operator) pruneFromNamespace(operatorGroup *v1.OperatorGroup, namespace string) error {
+	if err := a.removeOperatorGroupReferences(namespace, operatorGroup); err != nil {
+		return err
+	}
+
 	fetchedCSVs, err := a.lister.OperatorsV1alpha1().ClusterServiceVersionLister().ClusterServiceVersions(namespace).List(labels.Everything())
 	if err != nil {
 		return err
 	}
 
 	for _, csv := range fetchedCSVs {
-		if csv.IsCopied() && csv.GetAnnotations()[v1.OperatorGroupAnnotationKey] == operatorGroupName {
+		if csv.IsCopied() && csv.GetAnnotations()[v1.OperatorGroupAnnotationKey] == operatorGroup.GetName() {
 			a.logger.Debugf("Found CSV '%v' in namespace %v to delete", csv.GetName(), namespace)
 			if err := a.csvGCQueueSet.Requeue(csv.GetNamespace(), csv.GetName()); err != nil {
 				return err
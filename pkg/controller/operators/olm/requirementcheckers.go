@@ -0,0 +1,473 @@
+package olm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	v1alpha1listers "github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/listers/operators/v1alpha1"
+)
+
+// RequirementChecker checks a requirement of a CSV beyond the APIs and permissions that it needs, reporting each
+// requirement that it checks as a RequirementStatus on the CSV. A CSV isn't installed until all of its requirements
+// are met.
+type RequirementChecker interface {
+	RequirementStatus(csv *v1alpha1.ClusterServiceVersion, strategy *v1alpha1.StrategyDetailsDeployment) (met bool, statuses []v1alpha1.RequirementStatus)
+}
+
+type RequirementCheckerFunc func(csv *v1alpha1.ClusterServiceVersion, strategy *v1alpha1.StrategyDetailsDeployment) (bool, []v1alpha1.RequirementStatus)
+
+func (f RequirementCheckerFunc) RequirementStatus(csv *v1alpha1.ClusterServiceVersion, strategy *v1alpha1.StrategyDetailsDeployment) (bool, []v1alpha1.RequirementStatus) {
+	return f(csv, strategy)
+}
+
+const (
+	// RequirementCheckPlatform names the checker that requires a schedulable node with an OS and architecture that the
+	// CSV supports.
+	RequirementCheckPlatform = "platform"
+
+	// RequirementCheckMaxKubeVersion names the checker that requires the server version to be at most the
+	// MaxKubeVersionAnnotationKey of the CSV.
+	RequirementCheckMaxKubeVersion = "max-kube-version"
+
+	// RequirementCheckCapacity names the checker that requires enough schedulable capacity for the resource requests
+	// of the deployments of the CSV.
+	RequirementCheckCapacity = "capacity"
+
+	// ArchLabelPrefix prefixes the labels of CSVs, such as operatorframework.io/arch.arm64=supported, that list the
+	// architectures that they support. CSVs without any support amd64.
+	ArchLabelPrefix = "operatorframework.io/arch."
+
+	// OSLabelPrefix prefixes the labels of CSVs, such as operatorframework.io/os.linux=supported, that list the
+	// operating systems that they support. CSVs without any support linux.
+	OSLabelPrefix = "operatorframework.io/os."
+
+	platformSupportedLabelValue = "supported"
+
+	// MaxKubeVersionAnnotationKey on a CSV is the latest server version that it supports, such as "1.22" or "1.22.3".
+	MaxKubeVersionAnnotationKey = "operatorframework.io/max-kube-version"
+)
+
+var knownRequirementChecks = map[string]struct{}{
+	RequirementCheckPlatform:       {},
+	RequirementCheckMaxKubeVersion: {},
+	RequirementCheckCapacity:       {},
+}
+
+func validateRequirementChecks(names []string) error {
+	for _, name := range names {
+		if _, ok := knownRequirementChecks[name]; !ok {
+			return fmt.Errorf("unknown requirement check %q", name)
+		}
+	}
+	return nil
+}
+
+// activePodsSelector selects the pods that take up the capacity of their nodes.
+var activePodsSelector = fields.AndSelectors(
+	fields.OneTermNotEqualSelector("status.phase", string(corev1.PodSucceeded)),
+	fields.OneTermNotEqualSelector("status.phase", string(corev1.PodFailed)),
+).String()
+
+// newRequirementCheckers returns the named requirement checkers, and registers the informers that they read the
+// cluster from. Pods in all namespaces are only watched if the capacity check is enabled.
+func (a *Operator) newRequirementCheckers(informerFactory informers.SharedInformerFactory, resyncPeriod time.Duration, names ...string) ([]RequirementChecker, error) {
+	state := &installState{
+		deployments: a.lister.AppsV1().DeploymentLister(),
+		csvs:        a.lister.OperatorsV1alpha1().ClusterServiceVersionLister(),
+	}
+	var nodes corev1listers.NodeLister
+	nodeLister := func() (corev1listers.NodeLister, error) {
+		if nodes == nil {
+			informer := informerFactory.Core().V1().Nodes()
+			if err := a.RegisterInformer(informer.Informer()); err != nil {
+				return nil, err
+			}
+			nodes = informer.Lister()
+		}
+		return nodes, nil
+	}
+
+	var checkers []RequirementChecker
+	for _, name := range names {
+		switch name {
+		case RequirementCheckPlatform:
+			nodes, err := nodeLister()
+			if err != nil {
+				return nil, err
+			}
+			checkers = append(checkers, &platformChecker{nodes: nodes, state: state})
+		case RequirementCheckMaxKubeVersion:
+			checkers = append(checkers, &maxKubeVersionChecker{client: a.opClient.KubernetesInterface()})
+		case RequirementCheckCapacity:
+			nodes, err := nodeLister()
+			if err != nil {
+				return nil, err
+			}
+			podInformer := informers.NewSharedInformerFactoryWithOptions(a.opClient.KubernetesInterface(), resyncPeriod, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = activePodsSelector
+			})).Core().V1().Pods()
+			if err := a.RegisterInformer(podInformer.Informer()); err != nil {
+				return nil, err
+			}
+			checkers = append(checkers, &capacityChecker{nodes: nodes, pods: podInformer.Lister(), state: state})
+		default:
+			return nil, fmt.Errorf("unknown requirement check %q", name)
+		}
+	}
+	return checkers, nil
+}
+
+// installState tells whether a CSV is being installed for the first time. The nodes that a CSV can run on are only
+// checked then: once it's installed, cordoned nodes don't stop its pods, and its own pods, or those of the CSV that it
+// replaces, already take up the capacity that it needs. CSVs also return to Pending to rotate their certificates or to
+// recover from failures, which these checks must not hold up.
+type installState struct {
+	deployments appsv1listers.DeploymentLister
+	csvs        v1alpha1listers.ClusterServiceVersionLister
+}
+
+func (s *installState) firstInstall(csv *v1alpha1.ClusterServiceVersion, strategy *v1alpha1.StrategyDetailsDeployment) (bool, error) {
+	if csv.Status.Phase != v1alpha1.CSVPhaseNone && csv.Status.Phase != v1alpha1.CSVPhasePending {
+		return false, nil
+	}
+	if replaces := csv.Spec.Replaces; replaces != "" {
+		_, err := s.csvs.ClusterServiceVersions(csv.GetNamespace()).Get(replaces)
+		if err == nil {
+			return false, nil
+		}
+		if !k8serrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	for _, spec := range strategy.DeploymentSpecs {
+		_, err := s.deployments.Deployments(csv.GetNamespace()).Get(spec.Name)
+		if err == nil {
+			return false, nil
+		}
+		if !k8serrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// platformChecker requires a schedulable node whose kubernetes.io/os and kubernetes.io/arch labels match a platform
+// that the CSV supports. Platforms are only checked before a CSV is first installed.
+type platformChecker struct {
+	nodes corev1listers.NodeLister
+	state *installState
+}
+
+func supportedPlatforms(csv *v1alpha1.ClusterServiceVersion) (oses, archs []string) {
+	for key, value := range csv.GetLabels() {
+		if value != platformSupportedLabelValue {
+			continue
+		}
+		if strings.HasPrefix(key, OSLabelPrefix) {
+			oses = append(oses, strings.TrimPrefix(key, OSLabelPrefix))
+		}
+		if strings.HasPrefix(key, ArchLabelPrefix) {
+			archs = append(archs, strings.TrimPrefix(key, ArchLabelPrefix))
+		}
+	}
+	if len(oses) == 0 {
+		oses = []string{"linux"}
+	}
+	if len(archs) == 0 {
+		archs = []string{"amd64"}
+	}
+	sort.Strings(oses)
+	sort.Strings(archs)
+	return
+}
+
+func (c *platformChecker) RequirementStatus(csv *v1alpha1.ClusterServiceVersion, strategy *v1alpha1.StrategyDetailsDeployment) (bool, []v1alpha1.RequirementStatus) {
+	oses, archs := supportedPlatforms(csv)
+	var platforms []string
+	supported := map[string]struct{}{}
+	for _, os := range oses {
+		for _, arch := range archs {
+			platforms = append(platforms, os+"/"+arch)
+			supported[os+"/"+arch] = struct{}{}
+		}
+	}
+
+	status := v1alpha1.RequirementStatus{
+		Group:   "",
+		Version: "v1",
+		Kind:    "Node",
+		Name:    strings.Join(platforms, ","),
+	}
+
+	first, err := c.state.firstInstall(csv, strategy)
+	if err != nil {
+		status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+		status.Message = fmt.Sprintf("Install state discovery error: %v", err)
+		return false, []v1alpha1.RequirementStatus{status}
+	}
+	if !first {
+		return true, nil
+	}
+
+	nodes, err := c.nodes.List(labels.Everything())
+	if err != nil {
+		status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+		status.Message = fmt.Sprintf("Node discovery error: %v", err)
+		return false, []v1alpha1.RequirementStatus{status}
+	}
+
+	matching := 0
+	for _, node := range nodes {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		if _, ok := supported[node.Labels[corev1.LabelOSStable]+"/"+node.Labels[corev1.LabelArchStable]]; ok {
+			matching++
+		}
+	}
+	if matching == 0 {
+		status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+		status.Message = fmt.Sprintf("no schedulable node supports any of %s", status.Name)
+		return false, []v1alpha1.RequirementStatus{status}
+	}
+
+	status.Status = v1alpha1.RequirementStatusReasonPresent
+	status.Message = fmt.Sprintf("%d schedulable node(s) support %s", matching, status.Name)
+	return true, []v1alpha1.RequirementStatus{status}
+}
+
+// maxKubeVersionChecker requires the server version to be at most the MaxKubeVersionAnnotationKey of the CSV.
+type maxKubeVersionChecker struct {
+	client kubernetes.Interface
+}
+
+func (c *maxKubeVersionChecker) RequirementStatus(csv *v1alpha1.ClusterServiceVersion, _ *v1alpha1.StrategyDetailsDeployment) (bool, []v1alpha1.RequirementStatus) {
+	maxKubeVersion, ok := csv.GetAnnotations()[MaxKubeVersionAnnotationKey]
+	if !ok {
+		return true, nil
+	}
+
+	status := v1alpha1.RequirementStatus{
+		Group:   "operators.coreos.com",
+		Version: "v1alpha1",
+		Kind:    "ClusterServiceVersion",
+		Name:    csv.GetName(),
+	}
+
+	serverVersionInfo, err := c.client.Discovery().ServerVersion()
+	if err != nil {
+		status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+		status.Message = "Server version discovery error"
+		return false, []v1alpha1.RequirementStatus{status}
+	}
+
+	serverVersion, err := semver.NewVersion(strings.Split(strings.TrimPrefix(serverVersionInfo.String(), "v"), "-")[0])
+	if err != nil {
+		status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+		status.Message = "Server version parsing error"
+		return false, []v1alpha1.RequirementStatus{status}
+	}
+
+	// A version without a patch number allows all of its patch releases
+	maxVersion := strings.TrimPrefix(maxKubeVersion, "v")
+	if strings.Count(maxVersion, ".") == 1 {
+		maxVersion += ".0"
+		serverVersion.Patch = 0
+	}
+	csvVersionInfo, err := semver.NewVersion(maxVersion)
+	if err != nil {
+		status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+		status.Message = "CSV max version parsing error"
+		return false, []v1alpha1.RequirementStatus{status}
+	}
+
+	if serverVersion.Compare(*csvVersionInfo) > 0 {
+		status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+		status.Message = fmt.Sprintf("CSV version requirement not met: maxKubeVersion (%s) < server version (%s)", maxKubeVersion, serverVersionInfo.String())
+		return false, []v1alpha1.RequirementStatus{status}
+	}
+
+	status.Status = v1alpha1.RequirementStatusReasonPresent
+	status.Message = fmt.Sprintf("CSV maxKubeVersion (%s) not less than server version (%s)", maxKubeVersion, serverVersionInfo.String())
+	return true, []v1alpha1.RequirementStatus{status}
+}
+
+// capacityChecker requires enough unrequested allocatable resources on schedulable nodes to run every replica of the
+// deployments of the CSV. Capacity is only checked before a CSV is first installed.
+type capacityChecker struct {
+	nodes corev1listers.NodeLister
+	pods  corev1listers.PodLister
+	state *installState
+}
+
+// podRequests returns the resources requested by a pod, which are those of its containers, or those of its largest
+// init container if that's more.
+func podRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			total := requests[name]
+			total.Add(quantity)
+			requests[name] = total
+		}
+	}
+	for _, container := range spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if total, ok := requests[name]; !ok || quantity.Cmp(total) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
+
+func fits(free, requests corev1.ResourceList) bool {
+	for name, quantity := range requests {
+		available, ok := free[name]
+		if !ok || available.Cmp(quantity) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func subtract(free, requests corev1.ResourceList) {
+	for name, quantity := range requests {
+		if available, ok := free[name]; ok {
+			available.Sub(quantity)
+			free[name] = available
+		}
+	}
+}
+
+func formatResources(resources corev1.ResourceList) string {
+	var parts []string
+	for name, quantity := range resources {
+		parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	if len(parts) == 0 {
+		return "no resources"
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// freeCapacity returns the allocatable resources of each schedulable node that aren't requested by its pods.
+func (c *capacityChecker) freeCapacity() (map[string]corev1.ResourceList, error) {
+	nodes, err := c.nodes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	free := map[string]corev1.ResourceList{}
+	for _, node := range nodes {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		free[node.GetName()] = node.Status.Allocatable.DeepCopy()
+	}
+
+	pods, err := c.pods.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		allocatable, ok := free[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+		// Each pod also takes up one of the allocatable pods of its node
+		requests := podRequests(&pod.Spec)
+		requests[corev1.ResourcePods] = *resource.NewQuantity(1, resource.DecimalSI)
+		subtract(allocatable, requests)
+	}
+	return free, nil
+}
+
+func (c *capacityChecker) RequirementStatus(csv *v1alpha1.ClusterServiceVersion, strategy *v1alpha1.StrategyDetailsDeployment) (bool, []v1alpha1.RequirementStatus) {
+	first, err := c.state.firstInstall(csv, strategy)
+	if err == nil && !first {
+		return true, nil
+	}
+
+	var free map[string]corev1.ResourceList
+	if err == nil {
+		free, err = c.freeCapacity()
+	}
+	if err != nil {
+		var statuses []v1alpha1.RequirementStatus
+		for _, spec := range strategy.DeploymentSpecs {
+			statuses = append(statuses, v1alpha1.RequirementStatus{
+				Group:   "apps",
+				Version: "v1",
+				Kind:    "Deployment",
+				Name:    spec.Name,
+				Status:  v1alpha1.RequirementStatusReasonPresentNotSatisfied,
+				Message: fmt.Sprintf("Capacity discovery error: %v", err),
+			})
+		}
+		return false, statuses
+	}
+
+	nodeNames := make([]string, 0, len(free))
+	for name := range free {
+		nodeNames = append(nodeNames, name)
+	}
+	sort.Strings(nodeNames)
+
+	met := true
+	var statuses []v1alpha1.RequirementStatus
+	for _, spec := range strategy.DeploymentSpecs {
+		status := v1alpha1.RequirementStatus{
+			Group:   "apps",
+			Version: "v1",
+			Kind:    "Deployment",
+			Name:    spec.Name,
+		}
+
+		replicas := int32(1)
+		if spec.Spec.Replicas != nil {
+			replicas = *spec.Spec.Replicas
+		}
+		requests := podRequests(&spec.Spec.Template.Spec)
+		display := formatResources(requests)
+		requests[corev1.ResourcePods] = *resource.NewQuantity(1, resource.DecimalSI)
+
+		// Place replicas on the first nodes they fit, so that the capacity they take isn't counted for later deployments
+		placed := int32(0)
+		for _, name := range nodeNames {
+			for placed < replicas && fits(free[name], requests) {
+				subtract(free[name], requests)
+				placed++
+			}
+		}
+
+		if placed < replicas {
+			met = false
+			status.Status = v1alpha1.RequirementStatusReasonPresentNotSatisfied
+			status.Message = fmt.Sprintf("insufficient schedulable capacity for %d of %d replica(s) requesting %s", replicas-placed, replicas, display)
+		} else {
+			status.Status = v1alpha1.RequirementStatusReasonPresent
+			status.Message = fmt.Sprintf("schedulable capacity for %d replica(s) requesting %s", replicas, display)
+		}
+		statuses = append(statuses, status)
+	}
+	return met, statuses
+}
@@ -0,0 +1,362 @@
+package olm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/pointer"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	v1alpha1listers "github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/listers/operators/v1alpha1"
+)
+
+func node(name, os, arch string, unschedulable bool, allocatable corev1.ResourceList) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{corev1.LabelOSStable: os, corev1.LabelArchStable: arch},
+		},
+		Spec:   corev1.NodeSpec{Unschedulable: unschedulable},
+		Status: corev1.NodeStatus{Allocatable: allocatable},
+	}
+}
+
+// newInstallState returns the install state of CSVs in a cluster with the given deployments and CSVs.
+func newInstallState(objs ...runtime.Object) *installState {
+	deployments := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	csvs := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objs {
+		switch obj.(type) {
+		case *appsv1.Deployment:
+			deployments.Add(obj)
+		case *v1alpha1.ClusterServiceVersion:
+			csvs.Add(obj)
+		}
+	}
+	return &installState{
+		deployments: appsv1listers.NewDeploymentLister(deployments),
+		csvs:        v1alpha1listers.NewClusterServiceVersionLister(csvs),
+	}
+}
+
+// newNodeAndPodListers returns listers of the given nodes and pods.
+func newNodeAndPodListers(objs ...runtime.Object) (corev1listers.NodeLister, corev1listers.PodLister) {
+	nodes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	pods := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objs {
+		switch obj.(type) {
+		case *corev1.Node:
+			nodes.Add(obj)
+		case *corev1.Pod:
+			pods.Add(obj)
+		}
+	}
+	return corev1listers.NewNodeLister(nodes), corev1listers.NewPodLister(pods)
+}
+
+func resources(cpu, memory, pods string) corev1.ResourceList {
+	list := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+	if pods != "" {
+		list[corev1.ResourcePods] = resource.MustParse(pods)
+	}
+	return list
+}
+
+func TestPlatformChecker(t *testing.T) {
+	tests := []struct {
+		description string
+		labels      map[string]string
+		phase       v1alpha1.ClusterServiceVersionPhase
+		nodes       []runtime.Object
+		met         bool
+		name        string
+	}{
+		{
+			description: "DefaultPlatformMatches",
+			nodes:       []runtime.Object{node("n1", "linux", "amd64", false, nil)},
+			met:         true,
+			name:        "linux/amd64",
+		},
+		{
+			description: "DefaultPlatformMissing",
+			nodes:       []runtime.Object{node("n1", "linux", "arm64", false, nil)},
+			met:         false,
+			name:        "linux/amd64",
+		},
+		{
+			description: "LabeledArchMatches",
+			labels: map[string]string{
+				ArchLabelPrefix + "amd64": "supported",
+				ArchLabelPrefix + "arm64": "supported",
+			},
+			nodes: []runtime.Object{node("n1", "linux", "arm64", false, nil)},
+			met:   true,
+			name:  "linux/amd64,linux/arm64",
+		},
+		{
+			description: "OnlyUnschedulableNodeMatches",
+			labels:      map[string]string{OSLabelPrefix + "windows": "supported"},
+			nodes: []runtime.Object{
+				node("n1", "windows", "amd64", true, nil),
+				node("n2", "linux", "amd64", false, nil),
+			},
+			met:  false,
+			name: "windows/amd64",
+		},
+		{
+			description: "NotCheckedOnceInstalled",
+			phase:       v1alpha1.CSVPhaseSucceeded,
+			nodes:       []runtime.Object{node("n1", "linux", "amd64", true, nil)},
+			met:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			phase := tt.phase
+			if phase == "" {
+				phase = v1alpha1.CSVPhasePending
+			}
+			csv := csv("csv1", "ns", "", "", v1alpha1.NamedInstallStrategy{}, nil, nil, phase)
+			csv.SetLabels(tt.labels)
+			nodes, _ := newNodeAndPodListers(tt.nodes...)
+			checker := &platformChecker{nodes: nodes, state: newInstallState()}
+
+			met, statuses := checker.RequirementStatus(csv, &v1alpha1.StrategyDetailsDeployment{})
+			require.Equal(t, tt.met, met)
+			if tt.name == "" {
+				require.Empty(t, statuses)
+				return
+			}
+			require.Len(t, statuses, 1)
+			require.Equal(t, "Node", statuses[0].Kind)
+			require.Equal(t, tt.name, statuses[0].Name)
+			if tt.met {
+				require.Equal(t, v1alpha1.RequirementStatusReasonPresent, statuses[0].Status)
+			} else {
+				require.Equal(t, v1alpha1.RequirementStatusReasonPresentNotSatisfied, statuses[0].Status)
+			}
+		})
+	}
+}
+
+func TestMaxKubeVersionChecker(t *testing.T) {
+	tests := []struct {
+		description    string
+		maxKubeVersion string
+		met            bool
+		statuses       int
+		message        string
+	}{
+		{description: "NotSpecified", met: true},
+		{description: "Met", maxKubeVersion: "1.21.0", met: true, statuses: 1, message: "CSV maxKubeVersion (1.21.0) not less than server version"},
+		{description: "MetWithinMinor", maxKubeVersion: "v1.20", met: true, statuses: 1, message: "CSV maxKubeVersion (v1.20) not less than server version"},
+		{description: "Unmet", maxKubeVersion: "1.20.3", met: false, statuses: 1, message: "CSV version requirement not met: maxKubeVersion (1.20.3) < server version (v1.20.4)"},
+		{description: "UnmetMinor", maxKubeVersion: "1.19", met: false, statuses: 1, message: "CSV version requirement not met: maxKubeVersion (1.19) < server version (v1.20.4)"},
+		{description: "Invalid", maxKubeVersion: "a.b.c", met: false, statuses: 1, message: "CSV max version parsing error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			csv := csv("csv1", "ns", "", "", v1alpha1.NamedInstallStrategy{}, nil, nil, v1alpha1.CSVPhasePending)
+			if tt.maxKubeVersion != "" {
+				csv.SetAnnotations(map[string]string{MaxKubeVersionAnnotationKey: tt.maxKubeVersion})
+			}
+			client := k8sfake.NewSimpleClientset()
+			client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.20.4"}
+			checker := &maxKubeVersionChecker{client: client}
+
+			met, statuses := checker.RequirementStatus(csv, &v1alpha1.StrategyDetailsDeployment{})
+			require.Equal(t, tt.met, met)
+			require.Len(t, statuses, tt.statuses)
+			if tt.statuses > 0 {
+				require.Equal(t, "csv1", statuses[0].Name)
+				require.Contains(t, statuses[0].Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestCapacityChecker(t *testing.T) {
+	deploymentSpec := func(name string, replicas int32, requests corev1.ResourceList) v1alpha1.StrategyDeploymentSpec {
+		return v1alpha1.StrategyDeploymentSpec{
+			Name: name,
+			Spec: appsv1.DeploymentSpec{
+				Replicas: pointer.Int32Ptr(replicas),
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "c", Resources: corev1.ResourceRequirements{Requests: requests}}},
+					},
+				},
+			},
+		}
+	}
+	runningPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "other"},
+		Spec: corev1.PodSpec{
+			NodeName:   "n1",
+			Containers: []corev1.Container{{Name: "c", Resources: corev1.ResourceRequirements{Requests: resources("1", "1Gi", "")}}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	tests := []struct {
+		description string
+		phase       v1alpha1.ClusterServiceVersionPhase
+		objs        []runtime.Object
+		deployments []v1alpha1.StrategyDeploymentSpec
+		met         bool
+		statuses    []v1alpha1.StatusReason
+	}{
+		{
+			description: "Fits",
+			phase:       v1alpha1.CSVPhasePending,
+			objs:        []runtime.Object{node("n1", "linux", "amd64", false, resources("2", "2Gi", "10"))},
+			deployments: []v1alpha1.StrategyDeploymentSpec{deploymentSpec("d1", 2, resources("1", "1Gi", ""))},
+			met:         true,
+			statuses:    []v1alpha1.StatusReason{v1alpha1.RequirementStatusReasonPresent},
+		},
+		{
+			description: "RequestedByRunningPods",
+			phase:       v1alpha1.CSVPhasePending,
+			objs:        []runtime.Object{node("n1", "linux", "amd64", false, resources("2", "2Gi", "10")), runningPod},
+			deployments: []v1alpha1.StrategyDeploymentSpec{deploymentSpec("d1", 2, resources("1", "1Gi", ""))},
+			met:         false,
+			statuses:    []v1alpha1.StatusReason{v1alpha1.RequirementStatusReasonPresentNotSatisfied},
+		},
+		{
+			description: "SpreadAcrossNodesAndDeployments",
+			phase:       v1alpha1.CSVPhasePending,
+			objs: []runtime.Object{
+				node("n1", "linux", "amd64", false, resources("1", "1Gi", "10")),
+				node("n2", "linux", "amd64", false, resources("1", "1Gi", "10")),
+				node("n3", "linux", "amd64", true, resources("8", "8Gi", "10")),
+			},
+			deployments: []v1alpha1.StrategyDeploymentSpec{
+				deploymentSpec("d1", 1, resources("1", "1Gi", "")),
+				deploymentSpec("d2", 1, resources("1", "1Gi", "")),
+				deploymentSpec("d3", 1, resources("1", "1Gi", "")),
+			},
+			met: false,
+			statuses: []v1alpha1.StatusReason{
+				v1alpha1.RequirementStatusReasonPresent,
+				v1alpha1.RequirementStatusReasonPresent,
+				v1alpha1.RequirementStatusReasonPresentNotSatisfied,
+			},
+		},
+		{
+			description: "NoAllocatablePods",
+			phase:       v1alpha1.CSVPhasePending,
+			objs:        []runtime.Object{node("n1", "linux", "amd64", false, resources("2", "2Gi", "0"))},
+			deployments: []v1alpha1.StrategyDeploymentSpec{deploymentSpec("d1", 1, nil)},
+			met:         false,
+			statuses:    []v1alpha1.StatusReason{v1alpha1.RequirementStatusReasonPresentNotSatisfied},
+		},
+		{
+			description: "NotCheckedOnceInstalled",
+			phase:       v1alpha1.CSVPhaseSucceeded,
+			deployments: []v1alpha1.StrategyDeploymentSpec{deploymentSpec("d1", 1, resources("1", "1Gi", ""))},
+			met:         true,
+		},
+		{
+			description: "NotCheckedWhenPendingAgainWithOwnPods",
+			phase:       v1alpha1.CSVPhasePending,
+			objs: []runtime.Object{
+				node("n1", "linux", "amd64", false, resources("1", "1Gi", "10")),
+				runningPod,
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "d1", Namespace: "ns"}},
+			},
+			deployments: []v1alpha1.StrategyDeploymentSpec{deploymentSpec("d1", 1, resources("1", "1Gi", ""))},
+			met:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			csv := csv("csv1", "ns", "", "", v1alpha1.NamedInstallStrategy{}, nil, nil, tt.phase)
+			nodes, pods := newNodeAndPodListers(tt.objs...)
+			checker := &capacityChecker{nodes: nodes, pods: pods, state: newInstallState(tt.objs...)}
+
+			met, statuses := checker.RequirementStatus(csv, &v1alpha1.StrategyDetailsDeployment{DeploymentSpecs: tt.deployments})
+			require.Equal(t, tt.met, met)
+			require.Len(t, statuses, len(tt.statuses))
+			for i, status := range statuses {
+				require.Equal(t, "Deployment", status.Kind)
+				require.Equal(t, tt.deployments[i].Name, status.Name)
+				require.Equal(t, tt.statuses[i], status.Status, status.Message)
+			}
+		})
+	}
+}
+
+func TestInstallStateFirstInstall(t *testing.T) {
+	strategy := &v1alpha1.StrategyDetailsDeployment{DeploymentSpecs: []v1alpha1.StrategyDeploymentSpec{{Name: "d1"}}}
+
+	tests := []struct {
+		description string
+		phase       v1alpha1.ClusterServiceVersionPhase
+		replaces    string
+		objs        []runtime.Object
+		first       bool
+	}{
+		{
+			description: "Pending",
+			phase:       v1alpha1.CSVPhasePending,
+			objs:        []runtime.Object{&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "d1", Namespace: "other"}}},
+			first:       true,
+		},
+		{
+			description: "Installing",
+			phase:       v1alpha1.CSVPhaseInstalling,
+		},
+		{
+			description: "DeploymentExists",
+			phase:       v1alpha1.CSVPhasePending,
+			objs:        []runtime.Object{&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "d1", Namespace: "ns"}}},
+		},
+		{
+			description: "ReplacedCSVExists",
+			phase:       v1alpha1.CSVPhasePending,
+			replaces:    "csv0",
+			objs:        []runtime.Object{csv("csv0", "ns", "", "", v1alpha1.NamedInstallStrategy{}, nil, nil, v1alpha1.CSVPhaseReplacing)},
+		},
+		{
+			description: "ReplacedCSVGone",
+			phase:       v1alpha1.CSVPhasePending,
+			replaces:    "csv0",
+			first:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			csv := csv("csv1", "ns", "", tt.replaces, v1alpha1.NamedInstallStrategy{}, nil, nil, tt.phase)
+			first, err := newInstallState(tt.objs...).firstInstall(csv, strategy)
+			require.NoError(t, err)
+			require.Equal(t, tt.first, first)
+		})
+	}
+}
+
+func TestNewRequirementCheckers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	op, err := NewFakeOperator(ctx, withNamespaces("ns"), withRequirementChecks(RequirementCheckPlatform, RequirementCheckMaxKubeVersion, RequirementCheckCapacity))
+	require.NoError(t, err)
+	require.Len(t, op.requirementCheckers, 3)
+
+	_, err = NewFakeOperator(ctx, withNamespaces("ns"), withRequirementChecks("gpu"))
+	require.EqualError(t, err, `requirement checks config invalid: unknown requirement check "gpu"`)
+}
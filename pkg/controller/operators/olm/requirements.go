@@ -382,6 +382,14 @@ func (a *Operator) requirementAndPermissionStatus(csv *v1alpha1.ClusterServiceVe
 	reqMet, reqStatuses := a.requirementStatus(strategyDetailsDeployment, csv)
 	allReqStatuses = append(allReqStatuses, reqStatuses...)
 
+	// Check requirements of configured checkers
+	checkersMet := true
+	for _, checker := range a.requirementCheckers {
+		met, statuses := checker.RequirementStatus(csv, strategyDetailsDeployment)
+		checkersMet = checkersMet && met
+		allReqStatuses = append(allReqStatuses, statuses...)
+	}
+
 	rbacLister := a.lister.RbacV1()
 	roleLister := rbacLister.RoleLister()
 	roleBindingLister := rbacLister.RoleBindingLister()
@@ -396,9 +404,9 @@ func (a *Operator) requirementAndPermissionStatus(csv *v1alpha1.ClusterServiceVe
 
 	// Aggregate requirement and permissions statuses
 	statuses := append(allReqStatuses, permStatuses...)
-	met := minKubeMet && reqMet && permMet
+	met := minKubeMet && reqMet && checkersMet && permMet
 	if !met {
-		a.logger.WithField("minKubeMet", minKubeMet).WithField("reqMet", reqMet).WithField("permMet", permMet).Debug("permissions/requirements not met")
+		a.logger.WithField("minKubeMet", minKubeMet).WithField("reqMet", reqMet).WithField("checkersMet", checkersMet).WithField("permMet", permMet).Debug("permissions/requirements not met")
 	}
 
 	return met, statuses, nil
@@ -13,6 +13,7 @@ import (
 
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/listers/operators/v1alpha1"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/metrics"
 	"github.com/operator-framework/operator-registry/pkg/api"
 	"github.com/operator-framework/operator-registry/pkg/client"
 	opregistry "github.com/operator-framework/operator-registry/pkg/registry"
@@ -55,6 +56,14 @@ type OperatorCache struct {
 
 const defaultCatalogSourcePriority int = 0
 
+const (
+	// defaultChannelFetchers bounds the number of concurrent package metadata requests made by a snapshot lookup.
+	defaultChannelFetchers = 8
+
+	// defaultChannelTimeout bounds the time a snapshot lookup spends fetching package metadata.
+	defaultChannelTimeout = time.Minute
+)
+
 type catalogSourcePriority int
 
 var _ OperatorCacheProvider = &OperatorCache{}
@@ -160,18 +169,21 @@ func (c *OperatorCache) Namespaced(namespaces ...string) MultiCatalogOperatorFin
 		ctx, cancel := context.WithTimeout(context.Background(), CachePopulateTimeout)
 
 		catsrcPriority := defaultCatalogSourcePriority
+		var catsrcPublisher string
 		// Ignoring error and treat catsrc priority as 0 if not found.
 		catsrc, err := c.catsrcLister.CatalogSources(miss.Namespace).Get(miss.Name)
 		if err == nil {
 			catsrcPriority = catsrc.Spec.Priority
+			catsrcPublisher = catsrc.Spec.Publisher
 		}
 
 		s := CatalogSnapshot{
-			logger:   c.logger.WithField("catalog", miss),
-			key:      miss,
-			expiry:   now.Add(c.ttl),
-			pop:      cancel,
-			priority: catalogSourcePriority(catsrcPriority),
+			logger:    c.logger.WithField("catalog", miss),
+			key:       miss,
+			expiry:    now.Add(c.ttl),
+			pop:       cancel,
+			priority:  catalogSourcePriority(catsrcPriority),
+			publisher: catsrcPublisher,
 		}
 		s.m.Lock()
 		c.snapshots[miss] = &s
@@ -188,30 +200,20 @@ func (c *OperatorCache) populate(ctx context.Context, snapshot *CatalogSnapshot,
 	c.sem <- struct{}{}
 	defer func() { <-c.sem }()
 
-	// Fetching default channels this way makes many round trips
-	// -- may need to either add a new API to fetch all at once,
-	// or embed the information into Bundle.
-	defaultChannels := make(map[string]string)
-
+	start := time.Now()
 	it, err := registry.ListBundles(ctx)
 	if err != nil {
 		snapshot.logger.Errorf("failed to list bundles: %s", err.Error())
 		return
 	}
 	c.logger.WithField("catalog", snapshot.key.String()).Debug("updating cache")
+
+	// The registry has no bulk call for package metadata, so the default channel of each package is only fetched
+	// once one of its operators is looked up, rather than for every package of the catalog.
 	var operators []*Operator
+	packages := make(map[string]*packageMetadata)
 	for b := it.Next(); b != nil; b = it.Next() {
-		defaultChannel, ok := defaultChannels[b.PackageName]
-		if !ok {
-			if p, err := registry.GetPackage(ctx, b.PackageName); err != nil {
-				snapshot.logger.Warnf("failed to retrieve default channel for bundle, continuing: %v", err)
-				continue
-			} else {
-				defaultChannels[b.PackageName] = p.DefaultChannelName
-				defaultChannel = p.DefaultChannelName
-			}
-		}
-		o, err := NewOperatorFromBundle(b, "", snapshot.key, defaultChannel)
+		o, err := NewOperatorFromBundle(b, "", snapshot.key, "")
 		if err != nil {
 			snapshot.logger.Warnf("failed to construct operator from bundle, continuing: %v", err)
 			continue
@@ -221,11 +223,93 @@ func (c *OperatorCache) populate(ctx context.Context, snapshot *CatalogSnapshot,
 		o.replaces = b.Replaces
 		ensurePackageProperty(o, b.PackageName, b.Version)
 		operators = append(operators, o)
+
+		md, ok := packages[b.PackageName]
+		if !ok {
+			md = &packageMetadata{name: b.PackageName}
+			packages[b.PackageName] = md
+		}
+		md.operators = append(md.operators, o)
 	}
 	if err := it.Error(); err != nil {
 		snapshot.logger.Warnf("error encountered while listing bundles: %s", err.Error())
 	}
 	snapshot.operators = operators
+	snapshot.packages = packages
+	snapshot.registry = registry
+
+	metrics.EmitCatalogSnapshotPopulation(snapshot.key.Name, snapshot.key.Namespace, time.Since(start), len(operators))
+}
+
+// packageMetadata is the metadata of a package of a catalog snapshot, which is fetched the first time one of the
+// package's operators is looked up.
+type packageMetadata struct {
+	name      string
+	once      sync.Once
+	operators []*Operator
+}
+
+// fetchDefaultChannels marks the operators of the packages of the given operators that are in their package's default
+// channel, fetching the metadata of up to defaultChannelFetchers packages at a time. The metadata of each package is
+// only fetched once. Operators of packages whose metadata can't be fetched are kept, but none of their channels are
+// preferred as the default.
+func (s *CatalogSnapshot) fetchDefaultChannels(operators []*Operator) {
+	if s.packages == nil {
+		return
+	}
+	var pending []*packageMetadata
+	seen := make(map[*packageMetadata]struct{})
+	for _, o := range operators {
+		md, ok := s.packages[o.Package()]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[md]; !ok {
+			seen[md] = struct{}{}
+			pending = append(pending, md)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultChannelTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	packages := make(chan *packageMetadata)
+	workers := defaultChannelFetchers
+	if len(pending) < workers {
+		workers = len(pending)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for md := range packages {
+				md.once.Do(func() {
+					s.fetchDefaultChannel(ctx, md)
+				})
+			}
+		}()
+	}
+	for _, md := range pending {
+		packages <- md
+	}
+	close(packages)
+	wg.Wait()
+}
+
+func (s *CatalogSnapshot) fetchDefaultChannel(ctx context.Context, md *packageMetadata) {
+	p, err := s.registry.GetPackage(ctx, md.name)
+	if err != nil {
+		s.logger.Warnf("failed to retrieve default channel of package %s, continuing without it: %v", md.name, err)
+		metrics.IncrementCatalogSnapshotPackageErrors(s.key.Name, s.key.Namespace)
+		return
+	}
+	for _, o := range md.operators {
+		o.sourceInfo.DefaultChannel = o.sourceInfo.Channel == p.GetDefaultChannelName()
+	}
 }
 
 func ensurePackageProperty(o *Operator, name, version string) {
@@ -282,6 +366,14 @@ func (c *NamespacedOperatorCache) WithExistingOperators(snapshot *CatalogSnapsho
 	return o
 }
 
+// Publisher returns the publisher declared by the CatalogSource of the given catalog, if any.
+func (c *NamespacedOperatorCache) Publisher(k registry.CatalogKey) string {
+	if snapshot, ok := c.snapshots[k]; ok {
+		return snapshot.publisher
+	}
+	return ""
+}
+
 func (c *NamespacedOperatorCache) Find(p ...OperatorPredicate) []*Operator {
 	return c.FindPreferred(nil, p...)
 }
@@ -294,6 +386,26 @@ type CatalogSnapshot struct {
 	m         sync.RWMutex
 	pop       context.CancelFunc
 	priority  catalogSourcePriority
+	publisher string
+
+	// registry and packages are used to fetch the metadata of the snapshot's packages on demand. Lookups only
+	// return an operator once the metadata of its package has been fetched.
+	registry client.Interface
+	packages map[string]*packageMetadata
+
+	// Snapshots are immutable once populated, so lookups that are repeated by every resolution are memoized here.
+	// Resolutions reuse the memos of unchanged snapshots and only recompute them for snapshots that were replaced.
+	memo         sync.Mutex
+	indexed      bool
+	positions    map[*Operator]int
+	byName       map[string][]*Operator
+	byChannel    map[packageChannel][]*Operator
+	dependencies map[string][]*Operator
+}
+
+type packageChannel struct {
+	pkg     string
+	channel string
 }
 
 func (s *CatalogSnapshot) Cancel() {
@@ -400,7 +512,104 @@ type OperatorPredicate interface {
 func (s *CatalogSnapshot) Find(p ...OperatorPredicate) []*Operator {
 	s.m.RLock()
 	defer s.m.RUnlock()
-	return Filter(s.operators, p...)
+	operators := s.find(p...)
+	s.fetchDefaultChannels(operators)
+	return operators
+}
+
+// find returns the operators of the snapshot that satisfy the predicates. The caller must hold s.m.
+func (s *CatalogSnapshot) find(p ...OperatorPredicate) []*Operator {
+	if len(p) == 1 {
+		switch predicate := p[0].(type) {
+		case dependencyPredicate:
+			return s.findDependency(predicate)
+		case channelsPredicate:
+			return s.findInChannels(predicate)
+		}
+	}
+	operators := s.operators
+	for _, predicate := range p {
+		if name, ok := predicate.(csvNamePredicate); ok {
+			operators = s.named(string(name))
+			break
+		}
+	}
+	return Filter(operators, p...)
+}
+
+// index builds the name and channel indexes of the snapshot. The caller must hold s.memo.
+func (s *CatalogSnapshot) index() {
+	if s.indexed {
+		return
+	}
+	s.positions = make(map[*Operator]int, len(s.operators))
+	s.byName = make(map[string][]*Operator, len(s.operators))
+	s.byChannel = make(map[packageChannel][]*Operator)
+	for i, o := range s.operators {
+		s.positions[o] = i
+		s.byName[o.name] = append(s.byName[o.name], o)
+		for _, pkg := range packageNames(o) {
+			key := packageChannel{pkg: pkg, channel: o.Channel()}
+			s.byChannel[key] = append(s.byChannel[key], o)
+		}
+	}
+	s.indexed = true
+}
+
+// named returns the operators with the given name.
+func (s *CatalogSnapshot) named(name string) []*Operator {
+	s.memo.Lock()
+	defer s.memo.Unlock()
+	s.index()
+	return s.byName[name]
+}
+
+// findInChannels returns the operators of the channels matched by the predicate, in snapshot order, using the
+// indexes of the snapshot to avoid decoding the properties of every operator.
+func (s *CatalogSnapshot) findInChannels(p channelsPredicate) []*Operator {
+	s.memo.Lock()
+	defer s.memo.Unlock()
+	s.index()
+
+	seen := make(map[*Operator]struct{})
+	var candidates []*Operator
+	for _, c := range p.channels {
+		var operators []*Operator
+		switch {
+		case c.name != "":
+			operators = s.byName[c.name]
+		case c.channel == "":
+			// Every operator matches the empty channel.
+			return Filter(s.operators, p)
+		default:
+			operators = s.byChannel[packageChannel{pkg: c.pkg, channel: c.channel}]
+		}
+		for _, o := range operators {
+			if _, ok := seen[o]; !ok {
+				seen[o] = struct{}{}
+				candidates = append(candidates, o)
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return s.positions[candidates[i]] < s.positions[candidates[j]]
+	})
+	return Filter(candidates, p)
+}
+
+// findDependency returns the operators that satisfy a dependency, memoized by the property that declares it.
+func (s *CatalogSnapshot) findDependency(d dependencyPredicate) []*Operator {
+	s.memo.Lock()
+	defer s.memo.Unlock()
+	matches, ok := s.dependencies[d.key]
+	if !ok {
+		matches = Filter(s.operators, d)
+		if s.dependencies == nil {
+			s.dependencies = make(map[string][]*Operator)
+		}
+		s.dependencies[d.key] = matches
+	}
+	return append([]*Operator(nil), matches...)
 }
 
 type OperatorFinder interface {
@@ -420,10 +629,14 @@ func (f EmptyOperatorFinder) Find(...OperatorPredicate) []*Operator {
 	return nil
 }
 
+type csvNamePredicate string
+
+func (p csvNamePredicate) Test(o *Operator) bool {
+	return o.name == string(p)
+}
+
 func WithCSVName(name string) OperatorPredicate {
-	return OperatorPredicateFunc(func(o *Operator) bool {
-		return o.name == name
-	})
+	return csvNamePredicate(name)
 }
 
 func WithChannel(channel string) OperatorPredicate {
@@ -441,23 +654,62 @@ func WithChannel(channel string) OperatorPredicate {
 
 func WithPackage(pkg string) OperatorPredicate {
 	return OperatorPredicateFunc(func(o *Operator) bool {
-		for _, p := range o.Properties() {
-			if p.Type != opregistry.PackageType {
-				continue
-			}
-			var prop opregistry.PackageProperty
-			err := json.Unmarshal([]byte(p.Value), &prop)
-			if err != nil {
-				continue
-			}
-			if prop.PackageName == pkg {
+		for _, name := range packageNames(o) {
+			if name == pkg {
 				return true
 			}
 		}
-		return o.Package() == pkg
+		return false
 	})
 }
 
+// packageNames returns the names of the packages that an operator declares with package properties, followed by
+// the name of the package that it was resolved from.
+func packageNames(o *Operator) []string {
+	var names []string
+	for _, p := range o.Properties() {
+		if p.Type != opregistry.PackageType {
+			continue
+		}
+		var prop opregistry.PackageProperty
+		err := json.Unmarshal([]byte(p.Value), &prop)
+		if err != nil {
+			continue
+		}
+		names = append(names, prop.PackageName)
+	}
+	return append(names, o.Package())
+}
+
+// channelRef identifies either a channel of a package in a catalog or, when name is set, a single operator in a
+// catalog.
+type channelRef struct {
+	catalog registry.CatalogKey
+	pkg     string
+	channel string
+	name    string
+}
+
+// channelsPredicate matches the operators of any of a set of channels. Catalog snapshots serve it from their
+// indexes instead of testing every operator.
+type channelsPredicate struct {
+	OperatorPredicate
+	channels []channelRef
+}
+
+// inChannels returns a predicate matching the operators of any of the given channels.
+func inChannels(channels ...channelRef) OperatorPredicate {
+	p := False()
+	for _, c := range channels {
+		if c.name != "" {
+			p = Or(p, And(WithCSVName(c.name), WithCatalog(c.catalog)))
+		} else {
+			p = Or(p, And(WithPackage(c.pkg), WithChannel(c.channel), WithCatalog(c.catalog)))
+		}
+	}
+	return channelsPredicate{OperatorPredicate: p, channels: channels}
+}
+
 func WithVersionInRange(r semver.Range) OperatorPredicate {
 	return OperatorPredicateFunc(func(o *Operator) bool {
 		for _, p := range o.Properties() {
@@ -569,6 +821,12 @@ func Or(p ...OperatorPredicate) OperatorPredicate {
 	})
 }
 
+func Not(p OperatorPredicate) OperatorPredicate {
+	return OperatorPredicateFunc(func(o *Operator) bool {
+		return !p.Test(o)
+	})
+}
+
 func AtLeast(n int, operators []*Operator) ([]*Operator, error) {
 	if len(operators) < n {
 		return nil, fmt.Errorf("expected at least %d operator(s), got %d", n, len(operators))
@@ -6,6 +6,7 @@ import (
 	"io"
 	"math/rand"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -35,6 +36,12 @@ func (s *BundleStreamStub) Recv() (*api.Bundle, error) {
 
 type RegistryClientStub struct {
 	BundleIterator *client.BundleIterator
+
+	// Packages, if not nil, holds the only packages that GetPackage finds.
+	Packages map[string]*api.Package
+
+	m               sync.Mutex
+	packageRequests map[string]int
 }
 
 func (s *RegistryClientStub) Get() (client.Interface, error) {
@@ -62,7 +69,20 @@ func (s *RegistryClientStub) ListBundles(ctx context.Context) (*client.BundleIte
 }
 
 func (s *RegistryClientStub) GetPackage(ctx context.Context, packageName string) (*api.Package, error) {
-	return &api.Package{Name: packageName}, nil
+	s.m.Lock()
+	if s.packageRequests == nil {
+		s.packageRequests = make(map[string]int)
+	}
+	s.packageRequests[packageName]++
+	s.m.Unlock()
+
+	if s.Packages == nil {
+		return &api.Package{Name: packageName}, nil
+	}
+	if p, ok := s.Packages[packageName]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("package %s not found", packageName)
 }
 
 func (s *RegistryClientStub) HealthCheck(ctx context.Context, reconnectTimeout time.Duration) (bool, error) {
@@ -185,6 +205,78 @@ func TestOperatorCacheReuse(t *testing.T) {
 	require.Len(t, c.Namespaced("dummynamespace").Catalog(key).Find(WithCSVName("csvname")), 1)
 }
 
+func TestOperatorCachePackageFailure(t *testing.T) {
+	gvks := []*api.GroupVersionKind{{Group: "g", Version: "v1", Kind: "K", Plural: "ks"}}
+	rcp := RegistryClientProviderStub{}
+	catsrcLister := operatorlister.NewLister().OperatorsV1alpha1().CatalogSourceLister()
+	key := registry.CatalogKey{Namespace: "dummynamespace", Name: "dummyname"}
+	rcp[key] = &RegistryClientStub{
+		BundleIterator: client.NewBundleIterator(&BundleStreamStub{
+			Bundles: []*api.Bundle{
+				{CsvName: "a.v1", PackageName: "a", ChannelName: "stable", ProvidedApis: gvks},
+				{CsvName: "b.v1", PackageName: "b", ChannelName: "stable", ProvidedApis: gvks},
+				{CsvName: "a.v2", PackageName: "a", ChannelName: "stable", Replaces: "a.v1", ProvidedApis: gvks},
+			},
+		}),
+		Packages: map[string]*api.Package{
+			"a": {Name: "a", DefaultChannelName: "stable"},
+		},
+	}
+
+	c := NewOperatorCache(rcp, logrus.New(), catsrcLister)
+	catalog := c.Namespaced("dummynamespace").Catalog(key)
+
+	a := catalog.Find(WithPackage("a"))
+	require.Len(t, a, 2)
+	for _, o := range a {
+		assert.True(t, o.SourceInfo().DefaultChannel)
+	}
+
+	// Bundles of a package whose metadata can't be fetched are kept.
+	b := catalog.Find(WithPackage("b"))
+	require.Len(t, b, 1)
+	assert.False(t, b[0].SourceInfo().DefaultChannel)
+}
+
+func TestOperatorCacheFetchesReferencedPackages(t *testing.T) {
+	gvks := []*api.GroupVersionKind{{Group: "g", Version: "v1", Kind: "K", Plural: "ks"}}
+	rcp := RegistryClientProviderStub{}
+	catsrcLister := operatorlister.NewLister().OperatorsV1alpha1().CatalogSourceLister()
+	key := registry.CatalogKey{Namespace: "dummynamespace", Name: "dummyname"}
+	stub := &RegistryClientStub{
+		BundleIterator: client.NewBundleIterator(&BundleStreamStub{
+			Bundles: []*api.Bundle{
+				{CsvName: "a.v1", PackageName: "a", ChannelName: "stable", ProvidedApis: gvks},
+				{CsvName: "b.v1", PackageName: "b", ChannelName: "stable", ProvidedApis: gvks},
+				{CsvName: "c.v1", PackageName: "c", ChannelName: "stable", ProvidedApis: gvks},
+				{CsvName: "a.v2", PackageName: "a", ChannelName: "stable", Replaces: "a.v1", ProvidedApis: gvks},
+			},
+		}),
+		Packages: map[string]*api.Package{
+			"a": {Name: "a", DefaultChannelName: "stable"},
+			"b": {Name: "b", DefaultChannelName: "stable"},
+			"c": {Name: "c", DefaultChannelName: "stable"},
+		},
+	}
+	rcp[key] = stub
+
+	c := NewOperatorCache(rcp, logrus.New(), catsrcLister)
+	catalog := c.Namespaced("dummynamespace").Catalog(key)
+
+	for i := 0; i < 2; i++ {
+		a := catalog.Find(WithPackage("a"))
+		require.Len(t, a, 2)
+		for _, o := range a {
+			assert.True(t, o.SourceInfo().DefaultChannel)
+		}
+	}
+	b := catalog.Find(WithCSVName("b.v1"))
+	require.Len(t, b, 1)
+	assert.True(t, b[0].SourceInfo().DefaultChannel)
+
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, stub.packageRequests)
+}
+
 func TestCatalogSnapshotExpired(t *testing.T) {
 	type tc struct {
 		Name     string
@@ -290,6 +382,53 @@ func TestCatalogSnapshotFind(t *testing.T) {
 
 }
 
+func TestCatalogSnapshotFindIndexed(t *testing.T) {
+	catalog := registry.CatalogKey{Name: "catalog", Namespace: "ns"}
+	other := registry.CatalogKey{Name: "other", Namespace: "ns"}
+	s := CatalogSnapshot{key: catalog, operators: []*Operator{
+		genOperator("a.v1", "1.0.0", "", "a", "alpha", catalog.Name, catalog.Namespace, nil, nil, nil, "alpha", false),
+		genOperator("b.v1", "1.0.0", "", "b", "stable", catalog.Name, catalog.Namespace, nil, nil, nil, "stable", false),
+		genOperator("a.v2", "2.0.0", "a.v1", "a", "alpha", catalog.Name, catalog.Namespace, nil, nil, nil, "alpha", false),
+		genOperator("a.v1", "1.0.0", "", "a", "beta", catalog.Name, catalog.Namespace, nil, nil, nil, "alpha", false),
+	}}
+
+	for _, tt := range []struct {
+		Name      string
+		Predicate OperatorPredicate
+	}{
+		{
+			Name:      "by name",
+			Predicate: WithCSVName("a.v1"),
+		},
+		{
+			Name:      "by name and channel",
+			Predicate: And(WithCSVName("a.v1"), WithChannel("beta")),
+		},
+		{
+			Name: "in channels",
+			Predicate: inChannels(
+				channelRef{catalog: catalog, pkg: "b", channel: "stable"},
+				channelRef{catalog: catalog, pkg: "a", channel: "alpha"},
+				channelRef{catalog: other, pkg: "a", channel: "beta"},
+			),
+		},
+		{
+			Name:      "in any channel",
+			Predicate: inChannels(channelRef{catalog: catalog, pkg: "a"}),
+		},
+		{
+			Name:      "in channels by name",
+			Predicate: inChannels(channelRef{catalog: catalog, name: "b.v1"}),
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			expected := Filter(s.operators, tt.Predicate)
+			require.NotEmpty(t, expected)
+			assert.Equal(t, expected, s.Find(tt.Predicate))
+		})
+	}
+}
+
 func TestStripPluralRequiredAndProvidedAPIKeys(t *testing.T) {
 	rcp := RegistryClientProviderStub{}
 	catsrcLister := operatorlister.NewLister().OperatorsV1alpha1().CatalogSourceLister()
@@ -0,0 +1,205 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	v1alpha1listers "github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/listers/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+// ClusterOperatorSource provides the operators installed, or being installed, in namespaces other than the one being
+// resolved, for cluster-wide resolution.
+type ClusterOperatorSource interface {
+	// ForeignOperators returns the ClusterServiceVersions, not counting copies, and the Subscriptions of every
+	// namespace except the given one.
+	ForeignOperators(namespace string) ([]*v1alpha1.ClusterServiceVersion, []*v1alpha1.Subscription, error)
+}
+
+type listerClusterOperatorSource struct {
+	csvLister v1alpha1listers.ClusterServiceVersionLister
+	subLister v1alpha1listers.SubscriptionLister
+}
+
+// NewListerClusterOperatorSource returns a ClusterOperatorSource backed by the given listers, which must list
+// objects in every namespace.
+func NewListerClusterOperatorSource(csvLister v1alpha1listers.ClusterServiceVersionLister, subLister v1alpha1listers.SubscriptionLister) ClusterOperatorSource {
+	return &listerClusterOperatorSource{
+		csvLister: csvLister,
+		subLister: subLister,
+	}
+}
+
+func (s *listerClusterOperatorSource) ForeignOperators(namespace string) ([]*v1alpha1.ClusterServiceVersion, []*v1alpha1.Subscription, error) {
+	allCSVs, err := s.csvLister.List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+	var csvs []*v1alpha1.ClusterServiceVersion
+	for _, csv := range allCSVs {
+		if csv.GetNamespace() != namespace && !csv.IsCopied() {
+			csvs = append(csvs, csv)
+		}
+	}
+
+	allSubs, err := s.subLister.List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+	var subs []*v1alpha1.Subscription
+	for _, sub := range allSubs {
+		if sub.GetNamespace() != namespace {
+			subs = append(subs, sub)
+		}
+	}
+	return csvs, subs, nil
+}
+
+// apiOwner is an operator in another namespace that provides a cluster-scoped API.
+type apiOwner struct {
+	namespace string
+	csv       string
+	pkg       string
+}
+
+func (o apiOwner) String() string {
+	if o.pkg == "" {
+		return fmt.Sprintf("%s/%s", o.namespace, o.csv)
+	}
+	return fmt.Sprintf("%s/%s (package %s)", o.namespace, o.csv, o.pkg)
+}
+
+// conflictsWith returns true if an operator with the given name and package may not provide the same API as the
+// owner. Operators from the same package are expected to share APIs across namespaces.
+func (o apiOwner) conflictsWith(csv, pkg string) bool {
+	if o.csv == csv {
+		return false
+	}
+	return o.pkg == "" || o.pkg != pkg
+}
+
+// foreignAPIOwners returns the owners outside the given namespace of each cluster-scoped API, identified by group
+// and kind. Owners are both installed operators and operators that Subscriptions are in the process of installing.
+func (r *SatResolver) foreignAPIOwners(namespace string) (map[schema.GroupKind][]apiOwner, error) {
+	csvs, subs, err := r.clusterOperators.ForeignOperators(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[schema.GroupKind][]apiOwner)
+	add := func(owner apiOwner, apis APISet) {
+		for api := range apis {
+			gk := schema.GroupKind{Group: api.Group, Kind: api.Kind}
+			owners[gk] = append(owners[gk], owner)
+		}
+	}
+
+	installed := make(map[string]struct{})
+	for _, csv := range csvs {
+		op, err := NewOperatorFromV1Alpha1CSV(csv)
+		if err != nil {
+			r.log.WithError(err).Debugf("ignoring apis of clusterserviceversion %s/%s", csv.GetNamespace(), csv.GetName())
+			continue
+		}
+		owner := apiOwner{namespace: csv.GetNamespace(), csv: csv.GetName()}
+		for _, sub := range subs {
+			if sub.GetNamespace() == owner.namespace && sub.Status.InstalledCSV == owner.csv {
+				owner.pkg = sub.Spec.Package
+				break
+			}
+		}
+		installed[fmt.Sprintf("%s/%s", owner.namespace, owner.csv)] = struct{}{}
+		add(owner, op.ProvidedAPIs())
+	}
+
+	// Subscriptions whose current CSV isn't installed yet will install it, so it owns its APIs already.
+	for _, sub := range subs {
+		current := sub.Status.CurrentCSV
+		if current == "" {
+			continue
+		}
+		if _, ok := installed[fmt.Sprintf("%s/%s", sub.GetNamespace(), current)]; ok {
+			continue
+		}
+		catalog := registry.CatalogKey{Name: sub.Spec.CatalogSource, Namespace: sub.Spec.CatalogSourceNamespace}
+		op, err := ExactlyOne(r.cache.Namespaced(sub.Spec.CatalogSourceNamespace).Catalog(catalog).Find(WithCSVName(current), WithPackage(sub.Spec.Package)))
+		if err != nil {
+			continue
+		}
+		add(apiOwner{namespace: sub.GetNamespace(), csv: current, pkg: sub.Spec.Package}, op.ProvidedAPIs())
+	}
+
+	for _, o := range owners {
+		sort.Slice(o, func(i, j int) bool {
+			return o[i].String() < o[j].String()
+		})
+	}
+	return owners, nil
+}
+
+// ForeignOwnerConstraint returns a Constraint that prohibits an installable because APIs that it provides are owned
+// by operators of other packages in other namespaces. APIs are cluster-scoped, so they may only have one owner.
+func ForeignOwnerConstraint(id solver.Identifier, apis []string, owners []string) solver.Constraint {
+	return foreignOwnerConstraint{
+		prettyConstraint: prettyConstraint{
+			Constraint: solver.Prohibited(),
+			msg:        fmt.Sprintf("bundle %s provides %s, which %s already owned in another namespace by %s", id, strings.Join(apis, ", "), pluralize(len(apis), "is", "are"), strings.Join(owners, ", ")),
+		},
+		owners: owners,
+	}
+}
+
+type foreignOwnerConstraint struct {
+	prettyConstraint
+	owners []string
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// addClusterInvariants prohibits every bundle installable from a catalog that provides an API already owned in
+// another namespace by an operator of a different package.
+func (r *SatResolver) addClusterInvariants(owners map[schema.GroupKind][]apiOwner, namespacedCache MultiCatalogOperatorFinder, installables map[solver.Identifier]solver.Installable) {
+	if len(owners) == 0 {
+		return
+	}
+	for _, installable := range installables {
+		bundleInstallable, ok := installable.(*BundleInstallable)
+		if !ok {
+			continue
+		}
+		csvName, channel, catalog, err := bundleInstallable.BundleSourceInfo()
+		if err != nil || catalog.Virtual() {
+			continue
+		}
+		op, err := ExactlyOne(namespacedCache.Catalog(catalog).Find(WithCSVName(csvName), WithChannel(channel)))
+		if err != nil {
+			continue
+		}
+
+		apis := make(map[string]struct{})
+		conflicting := make(map[string]struct{})
+		for api := range op.ProvidedAPIs() {
+			for _, owner := range owners[schema.GroupKind{Group: api.Group, Kind: api.Kind}] {
+				if owner.conflictsWith(op.Identifier(), op.Package()) {
+					apis[fmt.Sprintf("%s (%s)", api.Kind, api.Group)] = struct{}{}
+					conflicting[owner.String()] = struct{}{}
+				}
+			}
+		}
+		if len(conflicting) == 0 {
+			continue
+		}
+		bundleInstallable.constraints = append(bundleInstallable.constraints, ForeignOwnerConstraint(bundleInstallable.Identifier(), sortedKeys(apis), sortedKeys(conflicting)))
+	}
+}
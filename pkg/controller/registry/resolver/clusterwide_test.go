@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+	opregistry "github.com/operator-framework/operator-registry/pkg/registry"
+)
+
+type clusterOperatorSourceFunc func(string) ([]*v1alpha1.ClusterServiceVersion, []*v1alpha1.Subscription, error)
+
+func (f clusterOperatorSourceFunc) ForeignOperators(namespace string) ([]*v1alpha1.ClusterServiceVersion, []*v1alpha1.Subscription, error) {
+	return f(namespace)
+}
+
+func TestSolveOperators_ClusterWide(t *testing.T) {
+	namespace := "olm"
+	otherNamespace := "other"
+	catalog := registry.CatalogKey{"community", namespace}
+	provided := APISet{opregistry.APIKey{Group: "g", Version: "v1", Kind: "k", Plural: "ks"}: struct{}{}}
+	otherVersion := APISet{opregistry.APIKey{Group: "g", Version: "v2", Kind: "k", Plural: "ks"}: struct{}{}}
+
+	operators := []*Operator{
+		genOperator("packageA.v1", "1.0.0", "", "packageA", "alpha", catalog.Name, namespace, nil, provided, nil, "", false),
+		genOperator("packageB.v1", "1.0.0", "", "packageB", "alpha", catalog.Name, namespace, nil, otherVersion, nil, "", false),
+	}
+
+	for _, tt := range []struct {
+		name          string
+		csvs          []*v1alpha1.ClusterServiceVersion
+		subs          []*v1alpha1.Subscription
+		foreignOwners []string
+	}{
+		{
+			name: "NoForeignOperators",
+		},
+		{
+			name: "SamePackageInstalledElsewhere",
+			csvs: []*v1alpha1.ClusterServiceVersion{existingOperator(otherNamespace, "packageA.v0", "packageA", "alpha", "", provided, nil, nil, nil)},
+			subs: []*v1alpha1.Subscription{existingSub(otherNamespace, "packageA.v0", "packageA", "alpha", catalog)},
+		},
+		{
+			name:          "OtherPackageInstalledElsewhere",
+			csvs:          []*v1alpha1.ClusterServiceVersion{existingOperator(otherNamespace, "packageC.v1", "packageC", "alpha", "", provided, nil, nil, nil)},
+			subs:          []*v1alpha1.Subscription{existingSub(otherNamespace, "packageC.v1", "packageC", "alpha", catalog)},
+			foreignOwners: []string{"other/packageC.v1 (package packageC)"},
+		},
+		{
+			name:          "UnsubscribedOwnerElsewhere",
+			csvs:          []*v1alpha1.ClusterServiceVersion{existingOperator(otherNamespace, "packageC.v1", "packageC", "alpha", "", provided, nil, nil, nil)},
+			foreignOwners: []string{"other/packageC.v1"},
+		},
+		{
+			name: "OtherPackageBeingInstalledElsewhere",
+			subs: []*v1alpha1.Subscription{func() *v1alpha1.Subscription {
+				sub := newSub(otherNamespace, "packageB", "alpha", catalog)
+				sub.Status.CurrentCSV = "packageB.v1"
+				return sub
+			}()},
+			foreignOwners: []string{"other/packageB.v1 (package packageB)"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			satResolver := SatResolver{
+				cache: getFakeOperatorCache(NamespacedOperatorCache{
+					snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+						catalog: {
+							key:       catalog,
+							operators: operators,
+						},
+					},
+				}),
+				clusterOperators: clusterOperatorSourceFunc(func(ns string) ([]*v1alpha1.ClusterServiceVersion, []*v1alpha1.Subscription, error) {
+					require.Equal(t, namespace, ns)
+					return tt.csvs, tt.subs, nil
+				}),
+				log: logrus.New(),
+			}
+
+			result, err := satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{newSub(namespace, "packageA", "alpha", catalog)})
+			if len(tt.foreignOwners) == 0 {
+				require.NoError(t, err)
+				assert.Contains(t, result, "packageA.v1")
+				return
+			}
+			require.IsType(t, solver.NotSatisfiable{}, err)
+			explanation := ExplainNotSatisfiable(err.(solver.NotSatisfiable))
+			assert.Equal(t, tt.foreignOwners, explanation.ForeignOwners)
+			assert.True(t, explanation.Involves("packageA-alpha"))
+		})
+	}
+}
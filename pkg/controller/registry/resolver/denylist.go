@@ -0,0 +1,190 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+const (
+	// DenyListLabelKey is the label that identifies ConfigMaps in the global catalog namespace as deny-lists.
+	// Deny-lists apply to resolution in every namespace.
+	DenyListLabelKey = "olm.deny-list"
+
+	// DenyListDataKey is the ConfigMap data key holding the YAML or JSON list of DenyListEntry values.
+	DenyListDataKey = "entries"
+)
+
+// DenyListEntry identifies bundles that may not be chosen during resolution. An entry must name a package, a
+// bundle, or both. If a version range is given, only bundles with a version in the range are denied.
+type DenyListEntry struct {
+	// Package is the name of the package whose bundles are denied.
+	Package string `json:"package,omitempty"`
+
+	// Bundle is the name of a single denied bundle (its CSV name).
+	Bundle string `json:"bundle,omitempty"`
+
+	// VersionRange restricts the entry to bundles whose version is in the given semver range.
+	VersionRange string `json:"versionRange,omitempty"`
+
+	// Reason is a human-readable justification, e.g. an advisory identifier.
+	Reason string `json:"reason,omitempty"`
+
+	versionRange semver.Range
+}
+
+// DenyList is a named set of entries.
+type DenyList struct {
+	// Name identifies the policy that the entries came from.
+	Name string
+
+	Entries []DenyListEntry
+}
+
+// DenyListSource provides the deny-lists that apply to resolution.
+type DenyListSource interface {
+	DenyLists() ([]DenyList, error)
+}
+
+type configMapDenyListSource struct {
+	lister    corev1listers.ConfigMapLister
+	namespace string
+}
+
+// NewConfigMapDenyListSource returns a DenyListSource that reads deny-lists from the ConfigMaps in the given
+// namespace that carry the DenyListLabelKey label.
+func NewConfigMapDenyListSource(lister corev1listers.ConfigMapLister, namespace string) DenyListSource {
+	return &configMapDenyListSource{
+		lister:    lister,
+		namespace: namespace,
+	}
+}
+
+func (s *configMapDenyListSource) DenyLists() ([]DenyList, error) {
+	cms, err := listLabeledConfigMaps(s.lister, s.namespace, DenyListLabelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var lists []DenyList
+	for _, cm := range cms {
+		name := fmt.Sprintf("%s/%s", cm.GetNamespace(), cm.GetName())
+		list, err := ParseDenyList(name, cm.Data[DenyListDataKey])
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// listLabeledConfigMaps returns the ConfigMaps in a namespace that carry the given label, in name order so that
+// policies read from them are applied deterministically.
+func listLabeledConfigMaps(lister corev1listers.ConfigMapLister, namespace, labelKey string) ([]*corev1.ConfigMap, error) {
+	selector, err := labels.Parse(labelKey)
+	if err != nil {
+		return nil, err
+	}
+	cms, err := lister.ConfigMaps(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cms, func(i, j int) bool {
+		return cms[i].GetName() < cms[j].GetName()
+	})
+	return cms, nil
+}
+
+// ParseDenyList parses a YAML or JSON list of DenyListEntry values. An invalid entry fails the whole list, so that
+// a typo cannot silently allow a denied bundle.
+func ParseDenyList(name, data string) (DenyList, error) {
+	list := DenyList{Name: name}
+	if err := yaml.Unmarshal([]byte(data), &list.Entries); err != nil {
+		return DenyList{}, fmt.Errorf("failed to parse deny-list %s: %w", name, err)
+	}
+	for i, entry := range list.Entries {
+		if entry.Package == "" && entry.Bundle == "" {
+			return DenyList{}, fmt.Errorf("entry %d of deny-list %s names neither a package nor a bundle", i, name)
+		}
+		if entry.VersionRange != "" {
+			r, err := semver.ParseRange(entry.VersionRange)
+			if err != nil {
+				return DenyList{}, fmt.Errorf("entry %d of deny-list %s has an invalid version range: %w", i, name, err)
+			}
+			list.Entries[i].versionRange = r
+		}
+	}
+	return list, nil
+}
+
+// Denies returns the first entry of the list that matches the given operator, if any.
+func (l DenyList) Denies(o *Operator) (DenyListEntry, bool) {
+	for _, entry := range l.Entries {
+		if entry.Package != "" && entry.Package != o.Package() {
+			continue
+		}
+		if entry.Bundle != "" && entry.Bundle != o.Identifier() {
+			continue
+		}
+		if entry.versionRange != nil && !WithVersionInRange(entry.versionRange).Test(o) {
+			continue
+		}
+		return entry, true
+	}
+	return DenyListEntry{}, false
+}
+
+// DeniedConstraint returns a Constraint that prohibits an installable because of a deny-list policy.
+func DeniedConstraint(id solver.Identifier, policy string, entry DenyListEntry) solver.Constraint {
+	msg := fmt.Sprintf("bundle %s is denied by policy %s", id, policy)
+	if entry.Reason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, entry.Reason)
+	}
+	return deniedConstraint{
+		prettyConstraint: prettyConstraint{
+			Constraint: solver.Prohibited(),
+			msg:        msg,
+		},
+		policy: policy,
+	}
+}
+
+type deniedConstraint struct {
+	prettyConstraint
+	policy string
+}
+
+// applyDenyLists prohibits every bundle installable from a catalog that any of the given deny-lists match.
+// Installed operators are left alone: a deny-list only prevents bundles from being newly chosen.
+func (r *SatResolver) applyDenyLists(lists []DenyList, namespacedCache MultiCatalogOperatorFinder, installables map[solver.Identifier]solver.Installable) {
+	if len(lists) == 0 {
+		return
+	}
+	for _, installable := range installables {
+		bundleInstallable, ok := installable.(*BundleInstallable)
+		if !ok {
+			continue
+		}
+		csvName, channel, catalog, err := bundleInstallable.BundleSourceInfo()
+		if err != nil || catalog.Virtual() {
+			continue
+		}
+		op, err := ExactlyOne(namespacedCache.Catalog(catalog).Find(WithCSVName(csvName), WithChannel(channel)))
+		if err != nil {
+			continue
+		}
+		for _, list := range lists {
+			if entry, ok := list.Denies(op); ok {
+				bundleInstallable.constraints = append(bundleInstallable.constraints, DeniedConstraint(bundleInstallable.Identifier(), list.Name, entry))
+				break
+			}
+		}
+	}
+}
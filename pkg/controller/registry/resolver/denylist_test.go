@@ -0,0 +1,113 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDenyList(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		entries int
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name:    "YAML",
+			data:    "- package: a\n- bundle: b.v1\n  reason: CVE-1\n",
+			entries: 2,
+		},
+		{
+			name:    "JSON",
+			data:    `[{"package": "a", "versionRange": "<1.0.0"}]`,
+			entries: 1,
+		},
+		{
+			name:    "Malformed",
+			data:    "{",
+			wantErr: true,
+		},
+		{
+			name:    "NoPackageOrBundle",
+			data:    `[{"reason": "CVE-1"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "InvalidVersionRange",
+			data:    `[{"package": "a", "versionRange": "not-a-range"}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ParseDenyList("ns/name", tt.data)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "ns/name", list.Name)
+			assert.Len(t, list.Entries, tt.entries)
+		})
+	}
+}
+
+func TestDenyListDenies(t *testing.T) {
+	op := genOperator("a.v1.0.0", "1.0.0", "", "a", "alpha", "catsrc", "ns", nil, nil, nil, "", false)
+
+	tests := []struct {
+		name   string
+		data   string
+		denied bool
+		reason string
+	}{
+		{
+			name:   "Package",
+			data:   `[{"package": "a", "reason": "r"}]`,
+			denied: true,
+			reason: "r",
+		},
+		{
+			name: "OtherPackage",
+			data: `[{"package": "b"}]`,
+		},
+		{
+			name:   "Bundle",
+			data:   `[{"bundle": "a.v1.0.0"}]`,
+			denied: true,
+		},
+		{
+			name: "OtherBundleInPackage",
+			data: `[{"package": "a", "bundle": "a.v2.0.0"}]`,
+		},
+		{
+			name:   "InVersionRange",
+			data:   `[{"package": "a", "versionRange": "<2.0.0"}]`,
+			denied: true,
+		},
+		{
+			name: "OutOfVersionRange",
+			data: `[{"package": "a", "versionRange": ">=2.0.0"}]`,
+		},
+		{
+			name:   "FirstMatchingEntry",
+			data:   `[{"package": "b", "reason": "b"}, {"package": "a", "reason": "first"}, {"bundle": "a.v1.0.0", "reason": "second"}]`,
+			denied: true,
+			reason: "first",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ParseDenyList("ns/name", tt.data)
+			require.NoError(t, err)
+			entry, denied := list.Denies(op)
+			assert.Equal(t, tt.denied, denied)
+			assert.Equal(t, tt.reason, entry.Reason)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+const (
+	// SubscriptionResolutionFailed indicates that the most recent resolution of a Subscription's namespace failed
+	// due to constraints that involve the Subscription.
+	SubscriptionResolutionFailed v1alpha1.SubscriptionConditionType = "ResolutionFailed"
+
+	// ConstraintsNotSatisfiable is the reason given for a SubscriptionResolutionFailed condition when there is no
+	// combination of bundles that satisfies every constraint.
+	ConstraintsNotSatisfiable = "ConstraintsNotSatisfiable"
+)
+
+// ResolutionExplanation is a structured interpretation of a solver.NotSatisfiable error.
+type ResolutionExplanation struct {
+	// Subscriptions are the names of the subscriptions whose constraints are in conflict.
+	Subscriptions []string
+
+	// Bundles are the identifiers of the bundles, both installed and available from catalogs, whose
+	// constraints are in conflict.
+	Bundles []string
+
+	// APIs are the APIs that more than one of the conflicting bundles provide.
+	APIs []string
+
+	// Packages are the packages from which more than one of the conflicting bundles originate.
+	Packages []string
+
+	// Policies are the deny-list policies that prohibit one or more of the conflicting bundles.
+	Policies []string
+
+	// ForeignOwners are the operators in other namespaces that own APIs provided by one or more of the conflicting
+	// bundles.
+	ForeignOwners []string
+
+	// Constraints are the human-readable descriptions of the conflicting constraints.
+	Constraints []string
+}
+
+// ExplainNotSatisfiable interprets the minimal set of conflicting constraints in a solver.NotSatisfiable error in
+// terms of the subscriptions, bundles, APIs and packages involved.
+func ExplainNotSatisfiable(err solver.NotSatisfiable) ResolutionExplanation {
+	var e ResolutionExplanation
+	subscriptions := make(map[string]struct{})
+	bundles := make(map[string]struct{})
+	apis := make(map[string]struct{})
+	packages := make(map[string]struct{})
+	policies := make(map[string]struct{})
+	foreignOwners := make(map[string]struct{})
+	constraints := make(map[string]struct{})
+
+	for _, applied := range err {
+		constraints[applied.String()] = struct{}{}
+		if denied, ok := applied.Constraint.(deniedConstraint); ok {
+			policies[denied.policy] = struct{}{}
+		}
+		if foreign, ok := applied.Constraint.(foreignOwnerConstraint); ok {
+			for _, owner := range foreign.owners {
+				foreignOwners[owner] = struct{}{}
+			}
+		}
+		switch i := applied.Installable.(type) {
+		case *BundleInstallable:
+			bundles[i.Identifier().String()] = struct{}{}
+		case BundleInstallable:
+			bundles[i.Identifier().String()] = struct{}{}
+		case GenericInstallable:
+			switch i.kind {
+			case subscriptionInstallableKind:
+				subscriptions[i.subject] = struct{}{}
+			case apiProviderInstallableKind:
+				apis[i.subject] = struct{}{}
+			case packageInstanceInstallableKind:
+				packages[i.subject] = struct{}{}
+			}
+		}
+	}
+
+	e.Subscriptions = sortedKeys(subscriptions)
+	e.Bundles = sortedKeys(bundles)
+	e.APIs = sortedKeys(apis)
+	e.Packages = sortedKeys(packages)
+	e.Policies = sortedKeys(policies)
+	e.ForeignOwners = sortedKeys(foreignOwners)
+	e.Constraints = sortedKeys(constraints)
+	return e
+}
+
+// Involves returns true if the named subscription is one of those whose constraints are in conflict.
+func (e ResolutionExplanation) Involves(subscription string) bool {
+	for _, name := range e.Subscriptions {
+		if name == subscription {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a human-readable summary of the explanation.
+func (e ResolutionExplanation) String() string {
+	var parts []string
+	if len(e.Subscriptions) > 0 {
+		parts = append(parts, fmt.Sprintf("subscriptions: %s", strings.Join(e.Subscriptions, ", ")))
+	}
+	if len(e.Bundles) > 0 {
+		parts = append(parts, fmt.Sprintf("bundles: %s", strings.Join(e.Bundles, ", ")))
+	}
+	if len(e.APIs) > 0 {
+		parts = append(parts, fmt.Sprintf("apis with multiple providers: %s", strings.Join(e.APIs, ", ")))
+	}
+	if len(e.Packages) > 0 {
+		parts = append(parts, fmt.Sprintf("packages with multiple candidates: %s", strings.Join(e.Packages, ", ")))
+	}
+	if len(e.Policies) > 0 {
+		parts = append(parts, fmt.Sprintf("denied by policies: %s", strings.Join(e.Policies, ", ")))
+	}
+	if len(e.ForeignOwners) > 0 {
+		parts = append(parts, fmt.Sprintf("apis owned in other namespaces by: %s", strings.Join(e.ForeignOwners, ", ")))
+	}
+	msg := "constraints not satisfiable"
+	if len(e.Constraints) > 0 {
+		msg = fmt.Sprintf("%s: %s", msg, strings.Join(e.Constraints, ", "))
+	}
+	if len(parts) > 0 {
+		msg = fmt.Sprintf("%s (%s)", msg, strings.Join(parts, "; "))
+	}
+	return msg
+}
+
+// Condition returns a SubscriptionResolutionFailed condition describing the explanation.
+func (e ResolutionExplanation) Condition(now *metav1.Time) v1alpha1.SubscriptionCondition {
+	return v1alpha1.SubscriptionCondition{
+		Type:               SubscriptionResolutionFailed,
+		Status:             corev1.ConditionTrue,
+		Reason:             ConstraintsNotSatisfiable,
+		Message:            e.String(),
+		LastTransitionTime: now,
+	}
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,92 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+func TestExplainNotSatisfiable(t *testing.T) {
+	a := &BundleInstallable{identifier: "catsrc/ns/alpha/a.v1"}
+	b := &BundleInstallable{identifier: "catsrc/ns/beta/b.v1"}
+	gvk := NewSingleAPIProviderInstallable("g", "v", "k", []solver.Identifier{a.Identifier(), b.Identifier()})
+	pkg := NewSinglePackageInstanceInstallable("pkg", []solver.Identifier{a.Identifier(), b.Identifier()})
+	subA := NewSubscriptionInstallable("sub-a", []solver.Identifier{a.Identifier()})
+	subB := NewSubscriptionInstallable("sub-b", []solver.Identifier{b.Identifier()})
+
+	tests := []struct {
+		name     string
+		err      solver.NotSatisfiable
+		expected ResolutionExplanation
+		message  string
+	}{
+		{
+			name:     "Empty",
+			message:  "constraints not satisfiable",
+			expected: ResolutionExplanation{},
+		},
+		{
+			name: "InvalidSubscription",
+			err: solver.NotSatisfiable{
+				{
+					Installable: NewInvalidSubscriptionInstallable("sub-a", "no operators found"),
+					Constraint:  PrettyConstraint(solver.Prohibited(), "no operators found"),
+				},
+			},
+			expected: ResolutionExplanation{
+				Subscriptions: []string{"sub-a"},
+				Constraints:   []string{"no operators found"},
+			},
+			message: "constraints not satisfiable: no operators found (subscriptions: sub-a)",
+		},
+		{
+			name: "APIAndPackageConflict",
+			err: solver.NotSatisfiable{
+				{Installable: subB, Constraint: PrettyConstraint(solver.Mandatory(), "subscription sub-b exists")},
+				{Installable: subA, Constraint: PrettyConstraint(solver.Mandatory(), "subscription sub-a exists")},
+				{Installable: subA, Constraint: PrettyConstraint(solver.Dependency(a.Identifier()), "subscription sub-a requires catsrc/ns/alpha/a.v1")},
+				{Installable: subB, Constraint: PrettyConstraint(solver.Dependency(b.Identifier()), "subscription sub-b requires catsrc/ns/beta/b.v1")},
+				{Installable: gvk, Constraint: PrettyConstraint(solver.AtMost(1, a.Identifier(), b.Identifier()), "catsrc/ns/alpha/a.v1 and catsrc/ns/beta/b.v1 provide k (g/v)")},
+				{Installable: pkg, Constraint: PrettyConstraint(solver.AtMost(1, a.Identifier(), b.Identifier()), "catsrc/ns/alpha/a.v1 and catsrc/ns/beta/b.v1 originate from package pkg")},
+				{Installable: a, Constraint: solver.Conflict(b.Identifier())},
+			},
+			expected: ResolutionExplanation{
+				Subscriptions: []string{"sub-a", "sub-b"},
+				Bundles:       []string{"catsrc/ns/alpha/a.v1"},
+				APIs:          []string{"k (g/v)"},
+				Packages:      []string{"pkg"},
+				Constraints: []string{
+					"catsrc/ns/alpha/a.v1 and catsrc/ns/beta/b.v1 originate from package pkg",
+					"catsrc/ns/alpha/a.v1 and catsrc/ns/beta/b.v1 provide k (g/v)",
+					"catsrc/ns/alpha/a.v1 conflicts with catsrc/ns/beta/b.v1",
+					"subscription sub-a exists",
+					"subscription sub-a requires catsrc/ns/alpha/a.v1",
+					"subscription sub-b exists",
+					"subscription sub-b requires catsrc/ns/beta/b.v1",
+				},
+			},
+			message: "constraints not satisfiable: " +
+				"catsrc/ns/alpha/a.v1 and catsrc/ns/beta/b.v1 originate from package pkg, " +
+				"catsrc/ns/alpha/a.v1 and catsrc/ns/beta/b.v1 provide k (g/v), " +
+				"catsrc/ns/alpha/a.v1 conflicts with catsrc/ns/beta/b.v1, " +
+				"subscription sub-a exists, " +
+				"subscription sub-a requires catsrc/ns/alpha/a.v1, " +
+				"subscription sub-b exists, " +
+				"subscription sub-b requires catsrc/ns/beta/b.v1 " +
+				"(subscriptions: sub-a, sub-b; bundles: catsrc/ns/alpha/a.v1; apis with multiple providers: k (g/v); packages with multiple candidates: pkg)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explanation := ExplainNotSatisfiable(tt.err)
+			assert.Equal(t, tt.expected, explanation)
+			assert.Equal(t, tt.message, explanation.String())
+			for _, sub := range tt.expected.Subscriptions {
+				assert.True(t, explanation.Involves(sub))
+			}
+			assert.False(t, explanation.Involves("other"))
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+// installableMemo remembers the bundle installables built by the previous resolution of each set of namespaces,
+// together with the candidates found for their dependencies. Finding those candidates searches and sorts every
+// catalog, so a resolution reuses the installable of a bundle unless a catalog that held, or now holds, a candidate
+// for one of its dependencies has changed since.
+type installableMemo struct {
+	m           sync.Mutex
+	resolutions map[string]*memoizedResolution
+}
+
+func newInstallableMemo() *installableMemo {
+	return &installableMemo{
+		resolutions: make(map[string]*memoizedResolution),
+	}
+}
+
+// memoizedResolution holds the installables built by a single resolution and the snapshots they were built from.
+type memoizedResolution struct {
+	snapshots map[registry.CatalogKey]*CatalogSnapshot
+	existing  registry.CatalogKey
+	bundles   map[*Operator]*memoizedBundle
+}
+
+// memoizedBundle is the installable of a catalog bundle, including its dependency constraints.
+type memoizedBundle struct {
+	installable BundleInstallable
+	predicates  []OperatorPredicate
+	// dependencies holds the sorted candidates of each dependency, in the order of the bundle's predicates.
+	dependencies [][]*Operator
+	// catalogs are the catalogs that held a candidate for any of the dependencies.
+	catalogs map[registry.CatalogKey]struct{}
+	// existing describes the installed operators that satisfied any of the dependencies.
+	existing string
+}
+
+// installableReuse is the view of the memo used by a single resolution: it finds the installables that can be reused
+// from the previous resolution and records every installable of this one.
+type installableReuse struct {
+	previous *memoizedResolution
+	next     *memoizedResolution
+}
+
+func memoKey(namespaces []string) string {
+	return strings.Join(namespaces, ",")
+}
+
+// begin starts a resolution against the given cache. It returns nil, disabling reuse, if the memo is nil or the cache
+// does not expose its snapshots.
+func (m *installableMemo) begin(namespaces []string, cache MultiCatalogOperatorFinder) *installableReuse {
+	if m == nil {
+		return nil
+	}
+	c, ok := cache.(*NamespacedOperatorCache)
+	if !ok || c.existing == nil {
+		return nil
+	}
+
+	m.m.Lock()
+	previous := m.resolutions[memoKey(namespaces)]
+	m.m.Unlock()
+
+	snapshots := make(map[registry.CatalogKey]*CatalogSnapshot, len(c.snapshots))
+	for key, snapshot := range c.snapshots {
+		snapshots[key] = snapshot
+	}
+	return &installableReuse{
+		previous: previous,
+		next: &memoizedResolution{
+			snapshots: snapshots,
+			existing:  *c.existing,
+			bundles:   make(map[*Operator]*memoizedBundle),
+		},
+	}
+}
+
+// commit makes the installables recorded by a resolution available to the next resolution of the same namespaces.
+func (m *installableMemo) commit(namespaces []string, reuse *installableReuse) {
+	if m == nil || reuse == nil {
+		return
+	}
+	m.m.Lock()
+	defer m.m.Unlock()
+	m.resolutions[memoKey(namespaces)] = reuse.next
+}
+
+// lookup returns the memoized installable of a bundle and the current candidates of its dependencies, or false if
+// the bundle must be rebuilt.
+func (u *installableReuse) lookup(bundle *Operator) (*memoizedBundle, [][]*Operator, bool) {
+	if u == nil || u.previous == nil || u.previous.existing != u.next.existing {
+		return nil, nil, false
+	}
+	memoized, ok := u.previous.bundles[bundle]
+	if !ok {
+		return nil, nil, false
+	}
+
+	for key, snapshot := range u.next.snapshots {
+		if key == u.next.existing || u.previous.snapshots[key] == snapshot {
+			continue
+		}
+		if _, ok := memoized.catalogs[key]; ok {
+			return nil, nil, false
+		}
+		for _, p := range memoized.predicates {
+			if len(snapshot.Find(p)) > 0 {
+				return nil, nil, false
+			}
+		}
+	}
+	for key := range memoized.catalogs {
+		if _, ok := u.next.snapshots[key]; !ok {
+			return nil, nil, false
+		}
+	}
+
+	// The snapshot of installed operators is rebuilt by every resolution, so its candidates are compared by value
+	// and replaced by their counterparts in the current snapshot.
+	existing := u.next.snapshots[u.next.existing]
+	if describeCandidates(existing, memoized.predicates) != memoized.existing {
+		return nil, nil, false
+	}
+	dependencies := make([][]*Operator, len(memoized.dependencies))
+	for i, candidates := range memoized.dependencies {
+		dependencies[i] = make([]*Operator, len(candidates))
+		for j, o := range candidates {
+			if o.sourceInfo.Catalog.Virtual() {
+				current, err := ExactlyOne(existing.Find(WithCSVName(o.Identifier()), WithChannel(o.Channel())))
+				if err != nil {
+					return nil, nil, false
+				}
+				o = current
+			}
+			dependencies[i][j] = o
+		}
+	}
+	return memoized, dependencies, true
+}
+
+// record memoizes the installable built for a bundle.
+func (u *installableReuse) record(bundle *Operator, installable BundleInstallable, predicates []OperatorPredicate, dependencies [][]*Operator, catalogs map[registry.CatalogKey]struct{}) {
+	if u == nil || bundle.sourceInfo.Catalog.Virtual() {
+		return
+	}
+	u.next.bundles[bundle] = &memoizedBundle{
+		installable:  installable.clone(),
+		predicates:   predicates,
+		dependencies: dependencies,
+		catalogs:     catalogs,
+		existing:     describeCandidates(u.next.snapshots[u.next.existing], predicates),
+	}
+}
+
+// describeCandidates describes the operators of a snapshot that satisfy each of the predicates by the fields that
+// determine their order and identity as dependency candidates.
+func describeCandidates(snapshot *CatalogSnapshot, predicates []OperatorPredicate) string {
+	if snapshot == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, p := range predicates {
+		for _, o := range snapshot.Find(p) {
+			var defaultChannel bool
+			if o.sourceInfo != nil {
+				defaultChannel = o.sourceInfo.DefaultChannel
+			}
+			fmt.Fprintf(&b, "%s/%s/%s/%t/%s/%s,", o.Identifier(), o.Package(), o.Channel(), defaultChannel, o.replaces, strings.Join(o.skips, "+"))
+		}
+		b.WriteString(";")
+	}
+	return b.String()
+}
+
+// clone returns a copy of the installable that can be modified without affecting the original.
+func (i BundleInstallable) clone() BundleInstallable {
+	i.constraints = append([]solver.Constraint(nil), i.constraints...)
+	i.preferences = append([]solver.Preference(nil), i.preferences...)
+	return i
+}
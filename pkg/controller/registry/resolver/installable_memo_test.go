@@ -0,0 +1,82 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	opregistry "github.com/operator-framework/operator-registry/pkg/registry"
+)
+
+func TestSolveOperatorsReusesInstallables(t *testing.T) {
+	namespace := "olm"
+	a := registry.CatalogKey{Name: "a", Namespace: namespace}
+	b := registry.CatalogKey{Name: "b", Namespace: namespace}
+	c := registry.CatalogKey{Name: "c", Namespace: namespace}
+	required := APISet{opregistry.APIKey{Group: "g", Version: "v", Kind: "K", Plural: "ks"}: struct{}{}}
+	unrelated := APISet{opregistry.APIKey{Group: "other", Version: "v", Kind: "K", Plural: "ks"}: struct{}{}}
+
+	snapshot := func(key registry.CatalogKey, operators ...*Operator) *CatalogSnapshot {
+		return &CatalogSnapshot{key: key, operators: operators}
+	}
+	cache := NamespacedOperatorCache{
+		namespaces: []string{namespace},
+		snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+			a: snapshot(a, genOperator("a.v1", "1.0.0", "", "a", "stable", a.Name, a.Namespace, required, nil, nil, "", false)),
+			b: snapshot(b, genOperator("b.v1", "1.0.0", "", "b", "stable", b.Name, b.Namespace, nil, required, nil, "", false)),
+			c: snapshot(c, genOperator("c.v1", "1.0.0", "", "c", "stable", c.Name, c.Namespace, nil, unrelated, nil, "", false)),
+		},
+	}
+	resolver := SatResolver{
+		cache:        getFakeOperatorCache(cache),
+		log:          logrus.New(),
+		installables: newInstallableMemo(),
+	}
+	subs := []*v1alpha1.Subscription{newSub(namespace, "a", "stable", a)}
+
+	resolve := func() []string {
+		operators, err := resolver.SolveOperators([]string{namespace}, nil, subs)
+		require.NoError(t, err)
+		var names []string
+		for name := range operators {
+			names = append(names, name)
+		}
+		return names
+	}
+	// reused reports whether the next resolution would reuse the installable built for a bundle by the last one.
+	reused := func(key registry.CatalogKey, name string) bool {
+		existing := NewRunningOperatorSnapshot(logrus.New(), registry.NewVirtualCatalogKey(namespace), nil)
+		reuse := resolver.installables.begin([]string{namespace}, resolver.cache.Namespaced(namespace).WithExistingOperators(existing))
+		bundle, err := ExactlyOne(cache.snapshots[key].Find(WithCSVName(name)))
+		require.NoError(t, err)
+		_, _, ok := reuse.lookup(bundle)
+		return ok
+	}
+
+	require.ElementsMatch(t, []string{"a.v1", "b.v1"}, resolve())
+	require.True(t, reused(a, "a.v1"), "nothing changed")
+	require.True(t, reused(b, "b.v1"), "nothing changed")
+
+	// A catalog without candidates for the dependencies of a bundle doesn't affect its installable.
+	cache.snapshots[c] = snapshot(c, genOperator("c.v2", "2.0.0", "c.v1", "c", "stable", c.Name, c.Namespace, nil, unrelated, nil, "", false))
+	require.True(t, reused(a, "a.v1"), "an unrelated catalog changed")
+	require.ElementsMatch(t, []string{"a.v1", "b.v1"}, resolve())
+
+	// A catalog that now holds a candidate does.
+	cache.snapshots[c] = snapshot(c, genOperator("c.v3", "3.0.0", "", "c", "stable", c.Name, c.Namespace, nil, required, nil, "", false))
+	require.False(t, reused(a, "a.v1"), "a catalog gained a candidate")
+	require.True(t, reused(b, "b.v1"), "a bundle without dependencies is unaffected")
+	require.ElementsMatch(t, []string{"a.v1", "b.v1"}, resolve())
+
+	// So does a catalog that held one, and the installable is rebuilt from its new contents.
+	cache.snapshots[b] = snapshot(b,
+		genOperator("b.v1", "1.0.0", "", "b", "stable", b.Name, b.Namespace, nil, required, nil, "", false),
+		genOperator("b.v2", "2.0.0", "b.v1", "b", "stable", b.Name, b.Namespace, nil, required, nil, "", false),
+	)
+	require.False(t, reused(a, "a.v1"), "a catalog that held a candidate changed")
+	require.ElementsMatch(t, []string{"a.v1", "b.v2"}, resolve())
+	require.True(t, reused(a, "a.v1"), "nothing changed")
+}
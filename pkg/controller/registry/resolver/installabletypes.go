@@ -12,6 +12,7 @@ import (
 type BundleInstallable struct {
 	identifier  solver.Identifier
 	constraints []solver.Constraint
+	preferences []solver.Preference
 
 	Replaces string
 }
@@ -24,6 +25,14 @@ func (i BundleInstallable) Constraints() []solver.Constraint {
 	return i.constraints
 }
 
+func (i BundleInstallable) Preferences() []solver.Preference {
+	return i.preferences
+}
+
+func (i *BundleInstallable) AddPreference(name string, weight int) {
+	i.preferences = append(i.preferences, solver.Preference{Name: name, Weight: weight})
+}
+
 func (i *BundleInstallable) MakeProhibited() {
 	i.constraints = append(i.constraints, solver.Prohibited())
 }
@@ -86,9 +95,21 @@ func NewBundleInstallableFromOperator(o *Operator) (BundleInstallable, error) {
 	}, nil
 }
 
+// genericInstallableKind records what a GenericInstallable represents, so that failed resolutions can be explained
+// in terms of subscriptions, APIs and packages rather than solver identifiers.
+type genericInstallableKind int
+
+const (
+	subscriptionInstallableKind genericInstallableKind = iota + 1
+	apiProviderInstallableKind
+	packageInstanceInstallableKind
+)
+
 type GenericInstallable struct {
 	identifier  solver.Identifier
 	constraints []solver.Constraint
+	kind        genericInstallableKind
+	subject     string
 }
 
 func (i GenericInstallable) Identifier() solver.Identifier {
@@ -102,6 +123,8 @@ func (i GenericInstallable) Constraints() []solver.Constraint {
 func NewInvalidSubscriptionInstallable(name string, reason string) solver.Installable {
 	return GenericInstallable{
 		identifier: solver.IdentifierFromString(fmt.Sprintf("subscription:%s", name)),
+		kind:       subscriptionInstallableKind,
+		subject:    name,
 		constraints: []solver.Constraint{
 			PrettyConstraint(solver.Mandatory(), fmt.Sprintf("subscription %s exists", name)),
 			PrettyConstraint(solver.Prohibited(), reason),
@@ -112,6 +135,8 @@ func NewInvalidSubscriptionInstallable(name string, reason string) solver.Instal
 func NewSubscriptionInstallable(name string, dependencies []solver.Identifier) solver.Installable {
 	result := GenericInstallable{
 		identifier: solver.IdentifierFromString(fmt.Sprintf("subscription:%s", name)),
+		kind:       subscriptionInstallableKind,
+		subject:    name,
 		constraints: []solver.Constraint{
 			PrettyConstraint(solver.Mandatory(), fmt.Sprintf("subscription %s exists", name)),
 		},
@@ -141,6 +166,8 @@ func NewSingleAPIProviderInstallable(group, version, kind string, providers []so
 	gvk := fmt.Sprintf("%s (%s/%s)", kind, group, version)
 	result := GenericInstallable{
 		identifier: solver.IdentifierFromString(gvk),
+		kind:       apiProviderInstallableKind,
+		subject:    gvk,
 	}
 	if len(providers) <= 1 {
 		// The constraints are pointless without more than one provider.
@@ -161,6 +188,8 @@ func NewSingleAPIProviderInstallable(group, version, kind string, providers []so
 func NewSinglePackageInstanceInstallable(pkg string, providers []solver.Identifier) solver.Installable {
 	result := GenericInstallable{
 		identifier: solver.IdentifierFromString(pkg),
+		kind:       packageInstanceInstallableKind,
+		subject:    pkg,
 	}
 	if len(providers) <= 1 {
 		// The constraints are pointless without more than one provider.
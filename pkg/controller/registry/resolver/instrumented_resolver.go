@@ -24,15 +24,15 @@ func NewInstrumentedResolver(resolver StepResolver, successMetricsEmitter, failu
 	}
 }
 
-func (ir *InstrumentedResolver) ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
+func (ir *InstrumentedResolver) ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, Dependencies, error) {
 	start := time.Now()
-	steps, lookups, subs, err := ir.resolver.ResolveSteps(namespace, sourceQuerier)
+	steps, lookups, subs, dependencies, err := ir.resolver.ResolveSteps(namespace, sourceQuerier)
 	if err != nil {
 		ir.failureMetricsEmitter(time.Now().Sub(start))
 	} else {
 		ir.successMetricsEmitter(time.Now().Sub(start))
 	}
-	return steps, lookups, subs, err
+	return steps, lookups, subs, dependencies, err
 }
 
 func (ir *InstrumentedResolver) Expire(key registry.CatalogKey) {
@@ -19,15 +19,15 @@ const (
 type fakeResolverWithError struct{}
 type fakeResolverWithoutError struct{}
 
-func (r *fakeResolverWithError) ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
-	return nil, nil, nil, errors.New("Fake error")
+func (r *fakeResolverWithError) ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, Dependencies, error) {
+	return nil, nil, nil, nil, errors.New("Fake error")
 }
 
 func (r *fakeResolverWithError) Expire(key registry.CatalogKey) {
 }
 
-func (r *fakeResolverWithoutError) ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
-	return nil, nil, nil, nil
+func (r *fakeResolverWithoutError) ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, Dependencies, error) {
+	return nil, nil, nil, nil, nil
 }
 
 func (r *fakeResolverWithoutError) Expire(key registry.CatalogKey) {
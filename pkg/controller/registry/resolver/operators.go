@@ -234,6 +234,16 @@ type Operator struct {
 
 var _ OperatorSurface = &Operator{}
 
+// copy returns a copy of the operator whose name, replaces and source can be modified without affecting the original.
+func (o *Operator) copy() *Operator {
+	c := *o
+	if o.sourceInfo != nil {
+		si := *o.sourceInfo
+		c.sourceInfo = &si
+	}
+	return &c
+}
+
 func NewOperatorFromBundle(bundle *api.Bundle, startingCSV string, sourceKey registry.CatalogKey, defaultChannel string) (*Operator, error) {
 	parsedVersion, err := semver.ParseTolerant(bundle.Version)
 	version := &parsedVersion
@@ -429,28 +439,50 @@ func (o *Operator) DependencyPredicates() (predicates []OperatorPredicate, err e
 		if predicate == nil {
 			continue
 		}
+		predicates = append(predicates, dependencyPredicate{
+			OperatorPredicate: predicate,
+			key:               property.Type + "/" + property.Value,
+		})
+	}
+	return
+}
+
+// ConflictPredicates returns the predicates matching the operators that the operator's properties prohibit from being
+// installed alongside it.
+func (o *Operator) ConflictPredicates() (predicates []OperatorPredicate, err error) {
+	for _, property := range o.Properties() {
+		predicate, err := ConflictPredicateForProperty(property)
+		if err != nil {
+			return nil, err
+		}
+		if predicate == nil {
+			continue
+		}
 		predicates = append(predicates, predicate)
 	}
 	return
 }
 
+// dependencyPredicate is the predicate of a dependency property, keyed by the property so that catalog snapshots
+// can memoize the operators that satisfy it.
+type dependencyPredicate struct {
+	OperatorPredicate
+	key string
+}
+
+// PredicateForProperty returns the OperatorPredicate that a dependency property translates to, or nil if no
+// translator is registered for the property's type.
 func PredicateForProperty(property *api.Property) (OperatorPredicate, error) {
 	if property == nil {
 		return nil, nil
 	}
-	p, ok := predicates[property.Type]
+	p, ok := propertyPredicates.get(property.Type)
 	if !ok {
 		return nil, nil
 	}
 	return p(property.Value)
 }
 
-var predicates = map[string]func(string) (OperatorPredicate, error){
-	"olm.gvk.required":     predicateForRequiredGVKProperty,
-	"olm.package.required": predicateForRequiredPackageProperty,
-	"olm.label.required":   predicateForRequiredLabelProperty,
-}
-
 func predicateForRequiredGVKProperty(value string) (OperatorPredicate, error) {
 	var gvk struct {
 		Group   string `json:"group"`
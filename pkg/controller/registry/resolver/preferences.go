@@ -0,0 +1,218 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	v1alpha1listers "github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/listers/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+const (
+	// PreferencePolicyLabelKey is the label that identifies ConfigMaps in the global catalog namespace as preference
+	// policies. Preference policies apply to resolution in every namespace.
+	PreferencePolicyLabelKey = "olm.preference-policy"
+
+	// PreferencePolicyDataKey is the ConfigMap data key holding the YAML or JSON list of PreferenceRule values.
+	PreferencePolicyDataKey = "preferences"
+)
+
+// PreferenceType identifies the property of a bundle that a PreferenceRule prefers.
+type PreferenceType string
+
+const (
+	// PreferNewestVersion prefers bundles with the highest version of their package among all candidates.
+	PreferNewestVersion PreferenceType = "NewestVersion"
+
+	// PreferInstalledElsewhere prefers bundles that are already installed in some namespace of the cluster.
+	PreferInstalledElsewhere PreferenceType = "InstalledElsewhere"
+
+	// PreferPublisher prefers bundles from catalogs whose CatalogSource declares a given publisher.
+	PreferPublisher PreferenceType = "Publisher"
+)
+
+// PreferenceRule adds a weight to every candidate bundle with a given property. When more than one bundle could
+// satisfy a subscription or dependency, bundles with a greater total weight are chosen first, ahead of catalog
+// priority and channel order, which decide only between bundles of equal weight.
+type PreferenceRule struct {
+	Type PreferenceType `json:"type"`
+
+	// Weight is added to the weight of each matching bundle. A negative weight expresses a reason to avoid a bundle.
+	Weight int `json:"weight"`
+
+	// Publisher is the publisher preferred by a rule of type PreferPublisher.
+	Publisher string `json:"publisher,omitempty"`
+}
+
+func (r PreferenceRule) String() string {
+	if r.Type == PreferPublisher {
+		return fmt.Sprintf("%s(%s)", r.Type, r.Publisher)
+	}
+	return string(r.Type)
+}
+
+// PreferencePolicy is a named set of rules.
+type PreferencePolicy struct {
+	// Name identifies the policy that the rules came from.
+	Name string
+
+	Rules []PreferenceRule
+}
+
+// PreferenceSource provides the preference policies that apply to resolution, as well as the cluster state that
+// they refer to.
+type PreferenceSource interface {
+	PreferencePolicies() ([]PreferencePolicy, error)
+
+	// InstalledCSVNames returns the names of the ClusterServiceVersions installed in any namespace, not counting
+	// copies made for OperatorGroup target namespaces.
+	InstalledCSVNames() (map[string]struct{}, error)
+}
+
+type clusterPreferenceSource struct {
+	configMapLister corev1listers.ConfigMapLister
+	csvLister       v1alpha1listers.ClusterServiceVersionLister
+	namespace       string
+}
+
+// NewClusterPreferenceSource returns a PreferenceSource that reads preference policies from the ConfigMaps in the
+// given namespace that carry the PreferencePolicyLabelKey label.
+func NewClusterPreferenceSource(configMapLister corev1listers.ConfigMapLister, csvLister v1alpha1listers.ClusterServiceVersionLister, namespace string) PreferenceSource {
+	return &clusterPreferenceSource{
+		configMapLister: configMapLister,
+		csvLister:       csvLister,
+		namespace:       namespace,
+	}
+}
+
+func (s *clusterPreferenceSource) PreferencePolicies() ([]PreferencePolicy, error) {
+	cms, err := listLabeledConfigMaps(s.configMapLister, s.namespace, PreferencePolicyLabelKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []PreferencePolicy
+	for _, cm := range cms {
+		name := fmt.Sprintf("%s/%s", cm.GetNamespace(), cm.GetName())
+		policy, err := ParsePreferencePolicy(name, cm.Data[PreferencePolicyDataKey])
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (s *clusterPreferenceSource) InstalledCSVNames() (map[string]struct{}, error) {
+	csvs, err := s.csvLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(csvs))
+	for _, csv := range csvs {
+		if csv.IsCopied() {
+			continue
+		}
+		names[csv.GetName()] = struct{}{}
+	}
+	return names, nil
+}
+
+// ParsePreferencePolicy parses a YAML or JSON list of PreferenceRule values.
+func ParsePreferencePolicy(name, data string) (PreferencePolicy, error) {
+	policy := PreferencePolicy{Name: name}
+	if err := yaml.Unmarshal([]byte(data), &policy.Rules); err != nil {
+		return PreferencePolicy{}, fmt.Errorf("failed to parse preference policy %s: %w", name, err)
+	}
+	for i, rule := range policy.Rules {
+		switch rule.Type {
+		case PreferNewestVersion, PreferInstalledElsewhere:
+		case PreferPublisher:
+			if rule.Publisher == "" {
+				return PreferencePolicy{}, fmt.Errorf("rule %d of preference policy %s has type %s but no publisher", i, name, rule.Type)
+			}
+		default:
+			return PreferencePolicy{}, fmt.Errorf("rule %d of preference policy %s has unknown type %q", i, name, rule.Type)
+		}
+	}
+	return policy, nil
+}
+
+// publisherFinder is implemented by caches that know the publishers of their catalogs.
+type publisherFinder interface {
+	Publisher(registry.CatalogKey) string
+}
+
+// applyPreferences adds the weights of every matching rule to the bundle installables from catalogs. Installed
+// operators carry no preferences, so that preferences never hold back an upgrade.
+func (r *SatResolver) applyPreferences(policies []PreferencePolicy, namespacedCache MultiCatalogOperatorFinder, installables map[solver.Identifier]solver.Installable) error {
+	var rules int
+	var wantInstalled bool
+	for _, policy := range policies {
+		rules += len(policy.Rules)
+		for _, rule := range policy.Rules {
+			wantInstalled = wantInstalled || rule.Type == PreferInstalledElsewhere
+		}
+	}
+	if rules == 0 {
+		return nil
+	}
+
+	var installed map[string]struct{}
+	if wantInstalled {
+		var err error
+		if installed, err = r.preferences.InstalledCSVNames(); err != nil {
+			return err
+		}
+	}
+	publishers, _ := namespacedCache.(publisherFinder)
+
+	candidates := make(map[*BundleInstallable]*Operator)
+	newest := make(map[string]semver.Version)
+	for _, installable := range installables {
+		bundleInstallable, ok := installable.(*BundleInstallable)
+		if !ok {
+			continue
+		}
+		csvName, channel, catalog, err := bundleInstallable.BundleSourceInfo()
+		if err != nil || catalog.Virtual() {
+			continue
+		}
+		op, err := ExactlyOne(namespacedCache.Catalog(catalog).Find(WithCSVName(csvName), WithChannel(channel)))
+		if err != nil {
+			continue
+		}
+		candidates[bundleInstallable] = op
+		if v := op.Version(); v != nil {
+			if n, ok := newest[op.Package()]; !ok || v.GT(n) {
+				newest[op.Package()] = *v
+			}
+		}
+	}
+
+	for bundleInstallable, op := range candidates {
+		for _, policy := range policies {
+			for _, rule := range policy.Rules {
+				var matches bool
+				switch rule.Type {
+				case PreferNewestVersion:
+					n, ok := newest[op.Package()]
+					matches = ok && op.Version() != nil && op.Version().EQ(n)
+				case PreferInstalledElsewhere:
+					_, matches = installed[op.Identifier()]
+				case PreferPublisher:
+					matches = publishers != nil && publishers.Publisher(op.SourceInfo().Catalog) == rule.Publisher
+				}
+				if matches {
+					bundleInstallable.AddPreference(fmt.Sprintf("%s from policy %s", rule, policy.Name), rule.Weight)
+				}
+			}
+		}
+	}
+	return nil
+}
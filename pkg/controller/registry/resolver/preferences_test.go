@@ -0,0 +1,207 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+	"github.com/operator-framework/operator-registry/pkg/api"
+)
+
+type fakePreferenceSource struct {
+	policies  []PreferencePolicy
+	installed map[string]struct{}
+}
+
+func (s fakePreferenceSource) PreferencePolicies() ([]PreferencePolicy, error) {
+	return s.policies, nil
+}
+
+func (s fakePreferenceSource) InstalledCSVNames() (map[string]struct{}, error) {
+	return s.installed, nil
+}
+
+func TestParsePreferencePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []PreferenceRule
+		wantErr  bool
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "AllTypes",
+			data: "- type: NewestVersion\n  weight: 1\n- type: InstalledElsewhere\n  weight: 2\n- type: Publisher\n  publisher: Acme\n  weight: -3\n",
+			expected: []PreferenceRule{
+				{Type: PreferNewestVersion, Weight: 1},
+				{Type: PreferInstalledElsewhere, Weight: 2},
+				{Type: PreferPublisher, Publisher: "Acme", Weight: -3},
+			},
+		},
+		{
+			name:    "UnknownType",
+			data:    `[{"type": "Cheapest", "weight": 1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "PublisherWithoutName",
+			data:    `[{"type": "Publisher", "weight": 1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Malformed",
+			data:    "{",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePreferencePolicy("ns/name", tt.data)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "ns/name", policy.Name)
+			assert.Equal(t, tt.expected, policy.Rules)
+		})
+	}
+}
+
+func TestApplyPreferences(t *testing.T) {
+	namespace := "olm"
+	community := registry.CatalogKey{"community", namespace}
+	vendor := registry.CatalogKey{"vendor", namespace}
+
+	operators := map[registry.CatalogKey][]*Operator{
+		community: {
+			genOperator("packageB.v1", "1.0.0", "", "packageB", "alpha", community.Name, namespace, nil, nil, nil, "", false),
+			genOperator("packageB.v2", "2.0.0", "packageB.v1", "packageB", "alpha", community.Name, namespace, nil, nil, nil, "", false),
+		},
+		vendor: {
+			genOperator("packageC.v1", "1.0.0", "", "packageC", "alpha", vendor.Name, namespace, nil, nil, nil, "", false),
+		},
+	}
+	cache := &NamespacedOperatorCache{
+		snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+			community: {key: community, operators: operators[community]},
+			vendor:    {key: vendor, operators: operators[vendor], publisher: "Acme"},
+		},
+	}
+
+	installables := make(map[solver.Identifier]solver.Installable)
+	bundles := make(map[string]*BundleInstallable)
+	for _, ops := range operators {
+		for _, op := range ops {
+			i, err := NewBundleInstallableFromOperator(op)
+			require.NoError(t, err)
+			installables[i.Identifier()] = &i
+			bundles[op.Identifier()] = &i
+		}
+	}
+	installed := BundleInstallable{identifier: bundleId("packageB.v0", "alpha", registry.NewVirtualCatalogKey(namespace))}
+	installables[installed.Identifier()] = &installed
+
+	satResolver := SatResolver{
+		preferences: fakePreferenceSource{installed: map[string]struct{}{"packageB.v1": {}, "packageB.v0": {}}},
+		log:         logrus.New(),
+	}
+	require.NoError(t, satResolver.applyPreferences([]PreferencePolicy{
+		{
+			Name: "ns/policy",
+			Rules: []PreferenceRule{
+				{Type: PreferNewestVersion, Weight: 1},
+				{Type: PreferInstalledElsewhere, Weight: 2},
+				{Type: PreferPublisher, Publisher: "Acme", Weight: 3},
+			},
+		},
+	}, cache, installables))
+
+	assert.Equal(t, []solver.Preference{
+		{Name: "InstalledElsewhere from policy ns/policy", Weight: 2},
+	}, bundles["packageB.v1"].Preferences())
+	assert.Equal(t, []solver.Preference{
+		{Name: "NewestVersion from policy ns/policy", Weight: 1},
+	}, bundles["packageB.v2"].Preferences())
+	assert.Equal(t, []solver.Preference{
+		{Name: "NewestVersion from policy ns/policy", Weight: 1},
+		{Name: "Publisher(Acme) from policy ns/policy", Weight: 3},
+	}, bundles["packageC.v1"].Preferences())
+	assert.Empty(t, installed.Preferences())
+}
+
+func TestSolveOperators_WithPreferences(t *testing.T) {
+	namespace := "olm"
+	catalog := registry.CatalogKey{"community", namespace}
+	dependencies := []*api.Dependency{
+		{
+			Type:  "olm.package",
+			Value: `{"packageName":"packageB","version":"1.0.0"}`,
+		},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		rules    []PreferenceRule
+		expected registry.CatalogKey
+	}{
+		{
+			name:     "CatalogPriority",
+			expected: registry.CatalogKey{"high-priority", namespace},
+		},
+		{
+			name:     "PreferredPublisher",
+			rules:    []PreferenceRule{{Type: PreferPublisher, Publisher: "Acme", Weight: 10}},
+			expected: registry.CatalogKey{"low-priority", namespace},
+		},
+		{
+			name:     "AvoidedPublisher",
+			rules:    []PreferenceRule{{Type: PreferPublisher, Publisher: "Initech", Weight: -10}},
+			expected: registry.CatalogKey{"low-priority", namespace},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			satResolver := SatResolver{
+				cache: getFakeOperatorCache(NamespacedOperatorCache{
+					snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+						catalog: {
+							key: catalog,
+							operators: []*Operator{
+								genOperator("packageA.v1", "1.0.0", "", "packageA", "alpha", catalog.Name, namespace, nil, nil, dependencies, "", false),
+							},
+						},
+						{"low-priority", namespace}: {
+							key:       registry.CatalogKey{"low-priority", namespace},
+							publisher: "Acme",
+							operators: []*Operator{
+								genOperator("packageB.v1", "1.0.0", "", "packageB", "alpha", "low-priority", namespace, nil, nil, nil, "", false),
+							},
+						},
+						{"high-priority", namespace}: {
+							key:       registry.CatalogKey{"high-priority", namespace},
+							publisher: "Initech",
+							priority:  catalogSourcePriority(100),
+							operators: []*Operator{
+								genOperator("packageB.v1", "1.0.0", "", "packageB", "alpha", "high-priority", namespace, nil, nil, nil, "", false),
+							},
+						},
+					},
+				}),
+				preferences: fakePreferenceSource{policies: []PreferencePolicy{{Name: "olm/policy", Rules: tt.rules}}},
+				log:         logrus.New(),
+			}
+
+			result, err := satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{newSub(namespace, "packageA", "alpha", catalog)})
+			require.NoError(t, err)
+			require.Contains(t, result, "packageB.v1")
+			assert.Equal(t, tt.expected, result["packageB.v1"].SourceInfo().Catalog)
+		})
+	}
+}
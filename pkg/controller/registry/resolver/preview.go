@@ -0,0 +1,85 @@
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+)
+
+// ResolutionPreview is the read-only result of a dry-run resolution. It contains everything an InstallPlan would
+// be generated from, but nothing in it has been persisted to the cluster.
+type ResolutionPreview struct {
+	// Namespace is the namespace that was resolved.
+	Namespace string `json:"namespace"`
+
+	// Steps are the steps that would be written to the InstallPlan.
+	Steps []*v1alpha1.Step `json:"steps,omitempty"`
+
+	// BundleLookups are the bundles that would need to be unpacked before their steps are known.
+	BundleLookups []v1alpha1.BundleLookup `json:"bundleLookups,omitempty"`
+
+	// Subscriptions are the existing and candidate subscriptions whose status would change as a result of the resolution.
+	Subscriptions []*v1alpha1.Subscription `json:"subscriptions,omitempty"`
+}
+
+// StepPreviewer resolves a namespace without persisting the result.
+type StepPreviewer interface {
+	PreviewSteps(namespace string, candidates []*v1alpha1.Subscription) (*ResolutionPreview, error)
+}
+
+var _ StepPreviewer = &OperatorStepResolver{}
+
+// PreviewSteps runs a resolution for the given namespace as if the candidate subscriptions existed alongside the
+// namespace's live subscriptions and CSVs. A candidate replaces a live subscription of the same name.
+// Neither the candidates nor any cached objects are modified.
+func (r *OperatorStepResolver) PreviewSteps(namespace string, candidates []*v1alpha1.Subscription) (*ResolutionPreview, error) {
+	csvs, err := r.listCSVs(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := r.listSubscriptions(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := make(map[string]struct{}, len(candidates))
+	var subs []*v1alpha1.Subscription
+	for _, candidate := range candidates {
+		if candidate == nil {
+			continue
+		}
+		if candidate.GetName() == "" {
+			return nil, fmt.Errorf("candidate subscription for package %q has no name", candidate.Spec.Package)
+		}
+		if ns := candidate.GetNamespace(); ns != "" && ns != namespace {
+			return nil, fmt.Errorf("candidate subscription %s is in namespace %s, not %s", candidate.GetName(), ns, namespace)
+		}
+		if _, ok := replaced[candidate.GetName()]; ok {
+			return nil, fmt.Errorf("duplicate candidate subscription %s", candidate.GetName())
+		}
+		replaced[candidate.GetName()] = struct{}{}
+
+		sub := candidate.DeepCopy()
+		sub.SetNamespace(namespace)
+		subs = append(subs, sub)
+	}
+	for _, sub := range live {
+		if _, ok := replaced[sub.GetName()]; ok {
+			continue
+		}
+		subs = append(subs, sub.DeepCopy())
+	}
+
+	steps, bundleLookups, updatedSubs, _, err := r.resolveSteps(namespace, csvs, subs, resolutionOptions{preview: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResolutionPreview{
+		Namespace:     namespace,
+		Steps:         steps,
+		BundleLookups: bundleLookups,
+		Subscriptions: updatedSubs,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/pkg/api"
+
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/operatorlister"
+)
+
+func TestPreviewSteps(t *testing.T) {
+	const namespace = "catsrc-namespace"
+	catalog := registry.CatalogKey{Name: "catsrc", Namespace: namespace}
+
+	a := bundle("a.v1", "a", "alpha", "", Provides1, nil, nil, nil)
+	b := bundle("b.v1", "b", "beta", "", nil, Requires1, nil, nil)
+
+	type out struct {
+		steps [][]*v1alpha1.Step
+		subs  []*v1alpha1.Subscription
+		err   bool
+	}
+	tests := []struct {
+		name         string
+		clusterState []runtime.Object
+		candidates   []*v1alpha1.Subscription
+		out          out
+	}{
+		{
+			name:       "CandidateOnly",
+			candidates: []*v1alpha1.Subscription{newSub(namespace, "a", "alpha", catalog)},
+			out: out{
+				steps: [][]*v1alpha1.Step{
+					bundleSteps(a, namespace, "", catalog),
+				},
+				subs: []*v1alpha1.Subscription{
+					updatedSub(namespace, "a.v1", "", "a", "alpha", catalog),
+				},
+			},
+		},
+		{
+			name: "CandidateWithLiveSubscription",
+			clusterState: []runtime.Object{
+				newSub(namespace, "a", "alpha", catalog),
+			},
+			candidates: []*v1alpha1.Subscription{newSub(namespace, "b", "beta", catalog)},
+			out: out{
+				steps: [][]*v1alpha1.Step{
+					bundleSteps(a, namespace, "", catalog),
+					bundleSteps(b, namespace, "", catalog),
+				},
+				subs: []*v1alpha1.Subscription{
+					updatedSub(namespace, "a.v1", "", "a", "alpha", catalog),
+					updatedSub(namespace, "b.v1", "", "b", "beta", catalog),
+				},
+			},
+		},
+		{
+			name: "CandidateInOtherNamespace/Error",
+			candidates: []*v1alpha1.Subscription{
+				newSub("other", "a", "alpha", catalog),
+			},
+			out: out{err: true},
+		},
+		{
+			name: "DuplicateCandidates/Error",
+			candidates: []*v1alpha1.Subscription{
+				newSub(namespace, "a", "alpha", catalog),
+				newSub(namespace, "a", "alpha", catalog),
+			},
+			out: out{err: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stopc := make(chan struct{})
+			defer func() {
+				stopc <- struct{}{}
+			}()
+			expectedSteps := []*v1alpha1.Step{}
+			for _, steps := range tt.out.steps {
+				expectedSteps = append(expectedSteps, steps...)
+			}
+			clientFake, informerFactory, _ := StartResolverInformers(namespace, stopc, tt.clusterState...)
+			lister := operatorlister.NewLister()
+			lister.OperatorsV1alpha1().RegisterSubscriptionLister(namespace, informerFactory.Operators().V1alpha1().Subscriptions().Lister())
+			lister.OperatorsV1alpha1().RegisterClusterServiceVersionLister(namespace, informerFactory.Operators().V1alpha1().ClusterServiceVersions().Lister())
+
+			stubSnapshot := &CatalogSnapshot{}
+			for _, bundle := range []*api.Bundle{a, b} {
+				op, err := NewOperatorFromBundle(bundle, "", catalog, "")
+				require.NoError(t, err)
+				stubSnapshot.operators = append(stubSnapshot.operators, op)
+			}
+			log := logrus.New()
+			resolver := NewOperatorStepResolver(lister, clientFake, k8sfake.NewSimpleClientset(), "", nil, log)
+			resolver.satResolver = &SatResolver{
+				cache: &stubOperatorCacheProvider{
+					noc: &NamespacedOperatorCache{
+						snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+							catalog: stubSnapshot,
+						},
+					},
+				},
+				log: log,
+				traces: traceRecorderFunc(func(*ResolutionTrace) error {
+					t.Error("a preview recorded a resolution trace")
+					return nil
+				}),
+				installables: newInstallableMemo(),
+			}
+
+			candidates := make([]*v1alpha1.Subscription, len(tt.candidates))
+			for i, c := range tt.candidates {
+				candidates[i] = c.DeepCopy()
+			}
+
+			preview, err := resolver.PreviewSteps(namespace, candidates)
+			require.Equal(t, tt.candidates, candidates, "candidates must not be modified")
+			require.Empty(t, resolver.satResolver.installables.resolutions, "a preview must not be reused by later resolutions")
+			if tt.out.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, namespace, preview.Namespace)
+			RequireStepsEqual(t, expectedSteps, preview.Steps)
+			require.ElementsMatch(t, tt.out.subs, preview.Subscriptions)
+
+			// nothing should have been written to the cluster
+			subs, err := clientFake.OperatorsV1alpha1().Subscriptions(namespace).List(context.TODO(), metav1.ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, subs.Items, len(tt.clusterState))
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/operator-framework/operator-registry/pkg/api"
+)
+
+const (
+	// AllRequiredPropertyType is the type of a compound dependency property that is satisfied by an operator
+	// satisfying every one of its nested properties.
+	AllRequiredPropertyType = "olm.all.required"
+
+	// AnyRequiredPropertyType is the type of a compound dependency property that is satisfied by an operator
+	// satisfying at least one of its nested properties.
+	AnyRequiredPropertyType = "olm.any.required"
+
+	// NotRequiredPropertyType is the type of a compound property that prohibits the operators satisfying its nested
+	// property from being installed alongside the bundle declaring it. Nested in an olm.all.required property, it
+	// instead excludes those operators from the ones satisfying the other nested properties.
+	NotRequiredPropertyType = "olm.not.required"
+)
+
+// PropertyPredicateFunc translates the value of a dependency property into an OperatorPredicate that matches the
+// operators satisfying the dependency.
+type PropertyPredicateFunc func(value string) (OperatorPredicate, error)
+
+type propertyPredicateRegistry struct {
+	mu    sync.RWMutex
+	funcs map[string]PropertyPredicateFunc
+}
+
+func (r *propertyPredicateRegistry) get(propertyType string) (PropertyPredicateFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.funcs[propertyType]
+	return f, ok
+}
+
+func (r *propertyPredicateRegistry) register(propertyType string, f PropertyPredicateFunc) error {
+	if propertyType == "" {
+		return fmt.Errorf("property type must not be empty")
+	}
+	if f == nil {
+		return fmt.Errorf("predicate translator for property type %s must not be nil", propertyType)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.funcs[propertyType]; ok {
+		return fmt.Errorf("a predicate translator is already registered for property type %s", propertyType)
+	}
+	r.funcs[propertyType] = f
+	return nil
+}
+
+func (r *propertyPredicateRegistry) unregister(propertyType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.funcs, propertyType)
+}
+
+var propertyPredicates = &propertyPredicateRegistry{
+	funcs: map[string]PropertyPredicateFunc{
+		"olm.gvk.required":     predicateForRequiredGVKProperty,
+		"olm.package.required": predicateForRequiredPackageProperty,
+		"olm.label.required":   predicateForRequiredLabelProperty,
+	},
+}
+
+func init() {
+	// The compound translators refer back to the registry for their nested properties, so they can't be part of
+	// its initializer.
+	for t, f := range map[string]PropertyPredicateFunc{
+		AllRequiredPropertyType: predicateForAllRequiredProperty,
+		AnyRequiredPropertyType: predicateForAnyRequiredProperty,
+	} {
+		if err := propertyPredicates.register(t, f); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterPropertyPredicate registers the translator for dependency properties of the given type, so that bundles
+// declaring such properties depend on the operators matching the translated predicate. Registering a type that
+// already has a translator, including the built-in types, is an error.
+func RegisterPropertyPredicate(propertyType string, f PropertyPredicateFunc) error {
+	if propertyType == NotRequiredPropertyType {
+		return fmt.Errorf("property type %s does not declare a dependency", propertyType)
+	}
+	return propertyPredicates.register(propertyType, f)
+}
+
+// ConflictPredicateForProperty returns the OperatorPredicate matching the operators that a property prohibits from
+// being installed alongside the bundle declaring it, or nil if the property doesn't prohibit any.
+func ConflictPredicateForProperty(property *api.Property) (OperatorPredicate, error) {
+	if property == nil || property.Type != NotRequiredPropertyType {
+		return nil, nil
+	}
+	return predicateForNotRequiredProperty(property.Value)
+}
+
+// nestedProperty is a property embedded in the value of a compound property. Its value may be given either as a
+// JSON-encoded string, like the value of a top-level property, or as the JSON value itself.
+type nestedProperty struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (n nestedProperty) value() string {
+	var s string
+	if err := json.Unmarshal(n.Value, &s); err == nil {
+		return s
+	}
+	return string(n.Value)
+}
+
+func (n nestedProperty) predicate() (OperatorPredicate, error) {
+	if n.Type == NotRequiredPropertyType {
+		return nil, fmt.Errorf("nested property of type %q is only allowed directly in %s", n.Type, AllRequiredPropertyType)
+	}
+	if _, ok := propertyPredicates.get(n.Type); !ok {
+		return nil, fmt.Errorf("unknown nested dependency property type %q", n.Type)
+	}
+	p, err := PredicateForProperty(&api.Property{Type: n.Type, Value: n.value()})
+	if err != nil {
+		return nil, fmt.Errorf("invalid nested dependency property of type %q: %w", n.Type, err)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("nested dependency property of type %q does not translate to a predicate", n.Type)
+	}
+	return p, nil
+}
+
+func nestedProperties(value string) ([]nestedProperty, error) {
+	var compound struct {
+		Properties []nestedProperty `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(value), &compound); err != nil {
+		return nil, err
+	}
+	if len(compound.Properties) == 0 {
+		return nil, fmt.Errorf("compound dependency property has no nested properties")
+	}
+	return compound.Properties, nil
+}
+
+func predicateForAllRequiredProperty(value string) (OperatorPredicate, error) {
+	properties, err := nestedProperties(value)
+	if err != nil {
+		return nil, err
+	}
+	var required, excluded []OperatorPredicate
+	for _, n := range properties {
+		if n.Type == NotRequiredPropertyType {
+			p, err := predicateForNotRequiredProperty(n.value())
+			if err != nil {
+				return nil, err
+			}
+			excluded = append(excluded, Not(p))
+			continue
+		}
+		p, err := n.predicate()
+		if err != nil {
+			return nil, err
+		}
+		required = append(required, p)
+	}
+	// Exclusions only narrow a dependency: on their own they'd be satisfied by any unrelated operator.
+	if len(required) == 0 {
+		return nil, fmt.Errorf("compound dependency property has no nested properties other than %s", NotRequiredPropertyType)
+	}
+	return And(append(required, excluded...)...), nil
+}
+
+func predicateForAnyRequiredProperty(value string) (OperatorPredicate, error) {
+	properties, err := nestedProperties(value)
+	if err != nil {
+		return nil, err
+	}
+	predicates := make([]OperatorPredicate, 0, len(properties))
+	for _, n := range properties {
+		p, err := n.predicate()
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, p)
+	}
+	return Or(predicates...), nil
+}
+
+// predicateForNotRequiredProperty returns the predicate of the property nested in an olm.not.required property, which
+// matches the operators it excludes.
+func predicateForNotRequiredProperty(value string) (OperatorPredicate, error) {
+	var compound struct {
+		Property *nestedProperty `json:"property"`
+	}
+	if err := json.Unmarshal([]byte(value), &compound); err != nil {
+		return nil, err
+	}
+	if compound.Property == nil {
+		return nil, fmt.Errorf("compound dependency property has no nested property")
+	}
+	return compound.Property.predicate()
+}
@@ -0,0 +1,228 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-registry/pkg/api"
+)
+
+func TestPredicateForProperty_Compound(t *testing.T) {
+	a1 := genOperator("a.v1.0.0", "1.0.0", "", "a", "alpha", "catsrc", "ns", nil, nil, nil, "", false)
+	a2 := genOperator("a.v2.0.0", "2.0.0", "", "a", "alpha", "catsrc", "ns", nil, nil, nil, "", false)
+	b1 := genOperator("b.v1.0.0", "1.0.0", "", "b", "alpha", "catsrc", "ns", nil, nil, nil, "", false)
+	c1 := genOperator("c.v1.0.0", "1.0.0", "", "c", "alpha", "catsrc", "ns", nil, nil, nil, "", false)
+	operators := []*Operator{a1, a2, b1, c1}
+
+	tests := []struct {
+		name     string
+		property *api.Property
+		expected []*Operator
+		wantErr  bool
+	}{
+		{
+			name: "AnyPackage",
+			property: &api.Property{
+				Type:  AnyRequiredPropertyType,
+				Value: `{"properties": [{"type": "olm.package.required", "value": {"packageName": "a", "versionRange": ">=2.0.0"}}, {"type": "olm.package.required", "value": {"packageName": "b", "versionRange": ">=1.0.0"}}]}`,
+			},
+			expected: []*Operator{a2, b1},
+		},
+		{
+			name: "AllWithNot",
+			property: &api.Property{
+				Type:  AllRequiredPropertyType,
+				Value: `{"properties": [{"type": "olm.package.required", "value": {"packageName": "a", "versionRange": ">=1.0.0"}}, {"type": "olm.not.required", "value": {"property": {"type": "olm.package.required", "value": {"packageName": "a", "versionRange": "2.0.0"}}}}]}`,
+			},
+			expected: []*Operator{a1},
+		},
+		{
+			name: "StringEncodedNestedValue",
+			property: &api.Property{
+				Type:  AllRequiredPropertyType,
+				Value: `{"properties": [{"type": "olm.package.required", "value": "{\"packageName\": \"a\", \"versionRange\": \">=1.0.0\"}"}]}`,
+			},
+			expected: []*Operator{a1, a2},
+		},
+		{
+			name: "OnlyNot",
+			property: &api.Property{
+				Type:  AllRequiredPropertyType,
+				Value: `{"properties": [{"type": "olm.not.required", "value": {"property": {"type": "olm.package.required", "value": {"packageName": "a", "versionRange": ">=1.0.0"}}}}]}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "NotInAny",
+			property: &api.Property{
+				Type:  AnyRequiredPropertyType,
+				Value: `{"properties": [{"type": "olm.package.required", "value": {"packageName": "b", "versionRange": ">=1.0.0"}}, {"type": "olm.not.required", "value": {"property": {"type": "olm.package.required", "value": {"packageName": "a", "versionRange": ">=1.0.0"}}}}]}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "NoNestedProperties",
+			property: &api.Property{
+				Type:  AnyRequiredPropertyType,
+				Value: `{"properties": []}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "UnknownNestedType",
+			property: &api.Property{
+				Type:  AllRequiredPropertyType,
+				Value: `{"properties": [{"type": "olm.unknown", "value": {}}]}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "InvalidNestedValue",
+			property: &api.Property{
+				Type:  AllRequiredPropertyType,
+				Value: `{"properties": [{"type": "olm.package.required", "value": {"packageName": "a", "versionRange": "not-a-range"}}]}`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := PredicateForProperty(tt.property)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, Filter(operators, p))
+		})
+	}
+}
+
+func TestConflictPredicateForProperty(t *testing.T) {
+	a1 := genOperator("a.v1.0.0", "1.0.0", "", "a", "alpha", "catsrc", "ns", nil, nil, nil, "", false)
+	b1 := genOperator("b.v1.0.0", "1.0.0", "", "b", "alpha", "catsrc", "ns", nil, nil, nil, "", false)
+	operators := []*Operator{a1, b1}
+
+	not := &api.Property{
+		Type:  NotRequiredPropertyType,
+		Value: `{"property": {"type": "olm.package.required", "value": "{\"packageName\": \"a\", \"versionRange\": \">=1.0.0\"}"}}`,
+	}
+	p, err := PredicateForProperty(not)
+	require.NoError(t, err)
+	require.Nil(t, p, "olm.not.required must not declare a dependency")
+
+	p, err = ConflictPredicateForProperty(not)
+	require.NoError(t, err)
+	assert.Equal(t, []*Operator{a1}, Filter(operators, p))
+
+	p, err = ConflictPredicateForProperty(&api.Property{Type: "olm.package.required", Value: `{"packageName": "a", "versionRange": ">=1.0.0"}`})
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	_, err = ConflictPredicateForProperty(&api.Property{Type: NotRequiredPropertyType, Value: `{}`})
+	require.Error(t, err)
+	_, err = ConflictPredicateForProperty(&api.Property{
+		Type:  NotRequiredPropertyType,
+		Value: `{"property": {"type": "olm.package.required", "value": {"packageName": "a", "versionRange": "not-a-range"}}}`,
+	})
+	require.Error(t, err)
+}
+
+func TestSolveOperators_NotRequired(t *testing.T) {
+	namespace := "olm"
+	catalog := registry.CatalogKey{"community", namespace}
+	dependent := genOperator("packageA.v1", "1.0.0", "", "packageA", "alpha", "community", namespace, nil, nil, nil, "", false)
+	dependent.properties = append(dependent.properties, &api.Property{
+		Type:  NotRequiredPropertyType,
+		Value: `{"property": {"type": "olm.package.required", "value": {"packageName": "packageB", "versionRange": ">=1.0.0"}}}`,
+	})
+	satResolver := SatResolver{
+		cache: getFakeOperatorCache(NamespacedOperatorCache{
+			snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+				catalog: {
+					key: catalog,
+					operators: []*Operator{
+						dependent,
+						genOperator("packageB.v1", "1.0.0", "", "packageB", "alpha", "community", namespace, nil, nil, nil, "", false),
+						genOperator("packageC.v1", "1.0.0", "", "packageC", "alpha", "community", namespace, nil, nil, nil, "", false),
+					},
+				},
+			},
+		}),
+		log: logrus.New(),
+	}
+
+	// An unrelated bundle doesn't satisfy the property, so it isn't pulled in as a dependency.
+	result, err := satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{newSub(namespace, "packageA", "alpha", catalog)})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Contains(t, result, "packageA.v1")
+
+	result, err = satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{
+		newSub(namespace, "packageA", "alpha", catalog),
+		newSub(namespace, "packageC", "alpha", catalog),
+	})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+
+	// The excluded bundle can't be installed alongside the bundle declaring the property.
+	_, err = satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{
+		newSub(namespace, "packageA", "alpha", catalog),
+		newSub(namespace, "packageB", "alpha", catalog),
+	})
+	require.Error(t, err)
+}
+
+func TestRegisterPropertyPredicate(t *testing.T) {
+	const propertyType = "test.channel.required"
+	defer propertyPredicates.unregister(propertyType)
+
+	require.Error(t, RegisterPropertyPredicate("olm.gvk.required", predicateForRequiredGVKProperty))
+	require.Error(t, RegisterPropertyPredicate("", predicateForRequiredGVKProperty))
+	require.Error(t, RegisterPropertyPredicate(NotRequiredPropertyType, predicateForRequiredGVKProperty))
+	require.Error(t, RegisterPropertyPredicate(propertyType, nil))
+
+	p, err := PredicateForProperty(&api.Property{Type: propertyType, Value: "stable"})
+	require.NoError(t, err)
+	require.Nil(t, p)
+
+	require.NoError(t, RegisterPropertyPredicate(propertyType, func(value string) (OperatorPredicate, error) {
+		return WithChannel(value), nil
+	}))
+	require.Error(t, RegisterPropertyPredicate(propertyType, predicateForRequiredGVKProperty))
+
+	namespace := "olm"
+	catalog := registry.CatalogKey{"community", namespace}
+	dependent := genOperator("packageA.v1", "1.0.0", "", "packageA", "alpha", "community", namespace, nil, nil, nil, "", false)
+	dependent.properties = append(dependent.properties, &api.Property{
+		Type:  AllRequiredPropertyType,
+		Value: `{"properties": [{"type": "olm.package.required", "value": {"packageName": "packageB", "versionRange": ">=1.0.0"}}, {"type": "test.channel.required", "value": "stable"}]}`,
+	})
+
+	satResolver := SatResolver{
+		cache: getFakeOperatorCache(NamespacedOperatorCache{
+			snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+				catalog: {
+					key: catalog,
+					operators: []*Operator{
+						dependent,
+						genOperator("packageB.v2", "2.0.0", "", "packageB", "alpha", "community", namespace, nil, nil, nil, "", false),
+						genOperator("packageB.v1", "1.0.0", "", "packageB", "stable", "community", namespace, nil, nil, nil, "", false),
+					},
+				},
+			},
+		}),
+		log: logrus.New(),
+	}
+
+	result, err := satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{newSub(namespace, "packageA", "alpha", catalog)})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Contains(t, result, "packageA.v1")
+	assert.Contains(t, result, "packageB.v1")
+}
@@ -25,14 +25,26 @@ type OperatorResolver interface {
 }
 
 type SatResolver struct {
-	cache OperatorCacheProvider
-	log   logrus.FieldLogger
+	cache       OperatorCacheProvider
+	denyLists   DenyListSource
+	preferences PreferenceSource
+	traces      ResolutionTraceRecorder
+	traceLimit  int
+	log         logrus.FieldLogger
+
+	// installables is nil unless installables are reused across resolutions.
+	installables *installableMemo
+
+	// clusterOperators is set for cluster-wide resolution, in which APIs provided by operators in other namespaces
+	// constrain resolution.
+	clusterOperators ClusterOperatorSource
 }
 
 func NewDefaultSatResolver(rcp RegistryClientProvider, catsrcLister v1alpha1listers.CatalogSourceLister, log logrus.FieldLogger) *SatResolver {
 	return &SatResolver{
-		cache: NewOperatorCache(rcp, log, catsrcLister),
-		log:   log,
+		cache:        NewOperatorCache(rcp, log, catsrcLister),
+		log:          log,
+		installables: newInstallableMemo(),
 	}
 }
 
@@ -46,7 +58,19 @@ func (w *debugWriter) Write(b []byte) (int, error) {
 	return n, nil
 }
 
+// resolutionOptions adjust a single resolution.
+type resolutionOptions struct {
+	// preview marks a hypothetical resolution, such as a dry-run preview. Its trace isn't recorded, so that it doesn't
+	// replace the trace of the namespace's last actual resolution, and its installables aren't reused by later
+	// resolutions.
+	preview bool
+}
+
 func (r *SatResolver) SolveOperators(namespaces []string, csvs []*v1alpha1.ClusterServiceVersion, subs []*v1alpha1.Subscription) (OperatorSet, error) {
+	return r.solveOperators(namespaces, csvs, subs, resolutionOptions{})
+}
+
+func (r *SatResolver) solveOperators(namespaces []string, csvs []*v1alpha1.ClusterServiceVersion, subs []*v1alpha1.Subscription, opts resolutionOptions) (OperatorSet, error) {
 	var errs []error
 
 	installables := make(map[solver.Identifier]solver.Installable, 0)
@@ -61,8 +85,9 @@ func (r *SatResolver) SolveOperators(namespaces []string, csvs []*v1alpha1.Clust
 		return nil, err
 	}
 	namespacedCache := r.cache.Namespaced(namespaces...).WithExistingOperators(existingSnapshot)
+	reuse := r.installables.begin(namespaces, namespacedCache)
 
-	_, existingInstallables, err := r.getBundleInstallables(registry.NewVirtualCatalogKey(namespaces[0]), existingSnapshot.Find(), namespacedCache, visited)
+	_, existingInstallables, err := r.getBundleInstallables(registry.NewVirtualCatalogKey(namespaces[0]), existingSnapshot.Find(), namespacedCache, visited, reuse)
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +115,7 @@ func (r *SatResolver) SolveOperators(namespaces []string, csvs []*v1alpha1.Clust
 		}
 
 		// find operators, in channel order, that can skip from the current version or list the current in "replaces"
-		subInstallables, err := r.getSubscriptionInstallables(sub, current, namespacedCache, visited)
+		subInstallables, err := r.getSubscriptionInstallables(sub, current, namespacedCache, visited, reuse)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -100,6 +125,39 @@ func (r *SatResolver) SolveOperators(namespaces []string, csvs []*v1alpha1.Clust
 			installables[i.Identifier()] = i
 		}
 	}
+	if len(errs) == 0 && !opts.preview {
+		r.installables.commit(namespaces, reuse)
+	}
+
+	if r.denyLists != nil {
+		lists, err := r.denyLists.DenyLists()
+		if err != nil {
+			return nil, fmt.Errorf("error reading deny-lists: %w", err)
+		}
+		r.applyDenyLists(lists, namespacedCache, installables)
+	}
+
+	if r.preferences != nil {
+		policies, err := r.preferences.PreferencePolicies()
+		if err != nil {
+			return nil, fmt.Errorf("error reading preference policies: %w", err)
+		}
+		if err := r.applyPreferences(policies, namespacedCache, installables); err != nil {
+			return nil, fmt.Errorf("error applying preference policies: %w", err)
+		}
+	}
+
+	if r.clusterOperators != nil {
+		owners, err := r.foreignAPIOwners(namespaces[0])
+		if err != nil {
+			return nil, fmt.Errorf("error listing operators in other namespaces: %w", err)
+		}
+		r.addClusterInvariants(owners, namespacedCache, installables)
+	}
+
+	if err := r.addPropertyConflicts(namespacedCache, installables); err != nil {
+		return nil, err
+	}
 
 	r.addInvariants(namespacedCache, installables)
 
@@ -107,15 +165,31 @@ func (r *SatResolver) SolveOperators(namespaces []string, csvs []*v1alpha1.Clust
 	for _, i := range installables {
 		input = append(input, i)
 	}
+	// order the input deterministically so that repeated resolutions of the same
+	// problem report the same conflicts when they fail
+	sort.Slice(input, func(i, j int) bool {
+		return input[i].Identifier() < input[j].Identifier()
+	})
 
 	if len(errs) > 0 {
 		return nil, utilerrors.NewAggregate(errs)
 	}
-	s, err := solver.New(solver.WithInput(input), solver.WithTracer(solver.LoggingTracer{Writer: &debugWriter{r.log}}))
+	var tracer solver.Tracer = solver.LoggingTracer{Writer: &debugWriter{r.log}}
+	var recording *solver.RecordingTracer
+	if r.traces != nil && !opts.preview {
+		recording = &solver.RecordingTracer{Limit: r.traceLimit}
+		tracer = solver.MultiTracer{tracer, recording}
+	}
+	s, err := solver.New(solver.WithInput(input), solver.WithTracer(tracer))
 	if err != nil {
 		return nil, err
 	}
 	solvedInstallables, err := s.Solve(context.TODO())
+	if recording != nil {
+		if terr := r.traces.RecordResolutionTrace(newResolutionTrace(namespaces[0], recording, err)); terr != nil {
+			r.log.WithError(terr).Warn("failed to record resolution trace")
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -144,11 +218,14 @@ func (r *SatResolver) SolveOperators(namespaces []string, csvs []*v1alpha1.Clust
 			continue
 		}
 
-		op, err := ExactlyOne(namespacedCache.Catalog(catalog).Find(WithCSVName(csvName), WithChannel(channel)))
+		found, err := ExactlyOne(namespacedCache.Catalog(catalog).Find(WithCSVName(csvName), WithChannel(channel)))
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
+		// Catalog snapshots and the installables built from them are reused by later resolutions, so the result is
+		// a copy of the operator in the snapshot.
+		op := found.copy()
 		if len(installableOperator.Replaces) > 0 {
 			op.replaces = installableOperator.Replaces
 		}
@@ -168,7 +245,7 @@ func (r *SatResolver) SolveOperators(namespaces []string, csvs []*v1alpha1.Clust
 	return operators, nil
 }
 
-func (r *SatResolver) getSubscriptionInstallables(sub *v1alpha1.Subscription, current *Operator, namespacedCache MultiCatalogOperatorFinder, visited map[OperatorSurface]*BundleInstallable) (map[solver.Identifier]solver.Installable, error) {
+func (r *SatResolver) getSubscriptionInstallables(sub *v1alpha1.Subscription, current *Operator, namespacedCache MultiCatalogOperatorFinder, visited map[OperatorSurface]*BundleInstallable, reuse *installableReuse) (map[solver.Identifier]solver.Installable, error) {
 	var cachePredicates, channelPredicates []OperatorPredicate
 	installables := make(map[solver.Identifier]solver.Installable, 0)
 
@@ -181,6 +258,20 @@ func (r *SatResolver) getSubscriptionInstallables(sub *v1alpha1.Subscription, cu
 	{
 		var nall, npkg, nch, ncsv int
 
+		versionRange, hasVersionRange, err := SubscriptionVersionRange(sub)
+		if err != nil {
+			si := NewInvalidSubscriptionInstallable(sub.GetName(), err.Error())
+			installables[si.Identifier()] = si
+			return installables, nil
+		}
+		versionPredicate := True()
+		if hasVersionRange {
+			// the range is applied after the channel has been sorted, since it may exclude bundles in the
+			// middle of the replacement chain
+			versionPredicate = WithVersionInRange(versionRange)
+			channelPredicates = append(channelPredicates, versionPredicate)
+		}
+
 		csvPredicate := True()
 		if current != nil {
 			// if we found an existing installed operator, we should filter the channel by operators that can replace it
@@ -208,6 +299,8 @@ func (r *SatResolver) getSubscriptionInstallables(sub *v1alpha1.Subscription, cu
 			si = NewInvalidSubscriptionInstallable(sub.GetName(), fmt.Sprintf("no operators found in channel %s of package %s in the catalog referenced by subscription %s", sub.Spec.Channel, sub.Spec.Package, sub.GetName()))
 		case ncsv == 0:
 			si = NewInvalidSubscriptionInstallable(sub.GetName(), fmt.Sprintf("no operators found with name %s in channel %s of package %s in the catalog referenced by subscription %s", sub.Spec.StartingCSV, sub.Spec.Channel, sub.Spec.Package, sub.GetName()))
+		case len(Filter(bundles, versionPredicate)) == 0:
+			si = NewInvalidSubscriptionInstallable(sub.GetName(), fmt.Sprintf("no operators found in version range %q in channel %s of package %s in the catalog referenced by subscription %s", sub.GetAnnotations()[SubscriptionVersionRangeAnnotationKey], sub.Spec.Channel, sub.Spec.Package, sub.GetName()))
 		}
 
 		if si != nil {
@@ -254,7 +347,7 @@ func (r *SatResolver) getSubscriptionInstallables(sub *v1alpha1.Subscription, cu
 	for _, o := range Filter(sortedBundles, channelPredicates...) {
 		predicates := append(cachePredicates, WithCSVName(o.Identifier()))
 		stack := namespacedCache.Catalog(catalog).Find(predicates...)
-		id, installable, err := r.getBundleInstallables(catalog, stack, namespacedCache, visited)
+		id, installable, err := r.getBundleInstallables(catalog, stack, namespacedCache, visited, reuse)
 		if err != nil {
 			return nil, err
 		}
@@ -298,7 +391,7 @@ func (r *SatResolver) getSubscriptionInstallables(sub *v1alpha1.Subscription, cu
 	return installables, nil
 }
 
-func (r *SatResolver) getBundleInstallables(catalog registry.CatalogKey, bundleStack []*Operator, namespacedCache MultiCatalogOperatorFinder, visited map[OperatorSurface]*BundleInstallable) (map[solver.Identifier]struct{}, map[solver.Identifier]*BundleInstallable, error) {
+func (r *SatResolver) getBundleInstallables(catalog registry.CatalogKey, bundleStack []*Operator, namespacedCache MultiCatalogOperatorFinder, visited map[OperatorSurface]*BundleInstallable, reuse *installableReuse) (map[solver.Identifier]struct{}, map[solver.Identifier]*BundleInstallable, error) {
 	errs := make([]error, 0)
 	installables := make(map[solver.Identifier]*BundleInstallable, 0) // all installables, including dependencies
 
@@ -321,60 +414,37 @@ func (r *SatResolver) getBundleInstallables(catalog registry.CatalogKey, bundleS
 			continue
 		}
 
-		bundleInstallable, err := NewBundleInstallableFromOperator(bundle)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-
-		visited[bundle] = &bundleInstallable
-
-		dependencyPredicates, err := bundle.DependencyPredicates()
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-
-		for _, d := range dependencyPredicates {
-			sourcePredicate := False()
-			// Build a filter matching all (catalog,
-			// package, channel) combinations that contain
-			// at least one candidate bundle, even if only
-			// a subset of those bundles actually satisfy
-			// the dependency.
-			sources := map[OperatorSourceInfo]struct{}{}
-			for _, b := range namespacedCache.Find(d) {
-				si := b.SourceInfo()
-
-				if _, ok := sources[*si]; ok {
-					// Predicate already covers this source.
-					continue
-				}
-				sources[*si] = struct{}{}
-
-				if si.Catalog.Virtual() {
-					sourcePredicate = Or(sourcePredicate, And(
-						WithCSVName(b.Identifier()),
-						WithCatalog(si.Catalog),
-					))
-				} else {
-					sourcePredicate = Or(sourcePredicate, And(
-						WithPackage(si.Package),
-						WithChannel(si.Channel),
-						WithCatalog(si.Catalog),
-					))
-				}
+		var bundleInstallable BundleInstallable
+		var dependencyPredicates []OperatorPredicate
+		var catalogs map[registry.CatalogKey]struct{}
+		memoized, dependencies, reused := reuse.lookup(bundle)
+		if reused {
+			bundleInstallable = memoized.installable.clone()
+			dependencyPredicates, catalogs = memoized.predicates, memoized.catalogs
+		} else {
+			var err error
+			bundleInstallable, err = NewBundleInstallableFromOperator(bundle)
+			if err != nil {
+				errs = append(errs, err)
+				continue
 			}
-			sortedBundles, err := r.sortBundles(namespacedCache.FindPreferred(&bundle.sourceInfo.Catalog, sourcePredicate))
+			dependencyPredicates, err = bundle.DependencyPredicates()
 			if err != nil {
 				errs = append(errs, err)
 				continue
 			}
+			dependencies, catalogs, err = r.findDependencies(bundle, dependencyPredicates, namespacedCache)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		visited[bundle] = &bundleInstallable
+
+		for _, candidates := range dependencies {
 			bundleDependencies := make([]solver.Identifier, 0)
-			// The dependency predicate is applied here
-			// (after sorting) to remove all bundles that
-			// don't satisfy the dependency.
-			for _, b := range Filter(sortedBundles, d) {
+			for _, b := range candidates {
 				i, err := NewBundleInstallableFromOperator(b)
 				if err != nil {
 					errs = append(errs, err)
@@ -384,10 +454,13 @@ func (r *SatResolver) getBundleInstallables(catalog registry.CatalogKey, bundleS
 				bundleDependencies = append(bundleDependencies, i.Identifier())
 				bundleStack = append(bundleStack, b)
 			}
-			bundleInstallable.AddDependency(bundleDependencies)
+			if !reused {
+				bundleInstallable.AddDependency(bundleDependencies)
+			}
 		}
 
 		installables[bundleInstallable.Identifier()] = &bundleInstallable
+		reuse.record(bundle, bundleInstallable, dependencyPredicates, dependencies, catalogs)
 	}
 
 	if len(errs) > 0 {
@@ -402,6 +475,47 @@ func (r *SatResolver) getBundleInstallables(catalog registry.CatalogKey, bundleS
 	return ids, installables, nil
 }
 
+// findDependencies returns the sorted candidates that satisfy each of the dependencies of a bundle, along with the
+// catalogs, other than the catalog of installed operators, that held any of them.
+func (r *SatResolver) findDependencies(bundle *Operator, dependencyPredicates []OperatorPredicate, namespacedCache MultiCatalogOperatorFinder) ([][]*Operator, map[registry.CatalogKey]struct{}, error) {
+	dependencies := make([][]*Operator, 0, len(dependencyPredicates))
+	catalogs := make(map[registry.CatalogKey]struct{})
+	for _, d := range dependencyPredicates {
+		var channels []channelRef
+		// Build a filter matching all (catalog,
+		// package, channel) combinations that contain
+		// at least one candidate bundle, even if only
+		// a subset of those bundles actually satisfy
+		// the dependency.
+		sources := map[OperatorSourceInfo]struct{}{}
+		for _, b := range namespacedCache.Find(d) {
+			si := b.SourceInfo()
+
+			if _, ok := sources[*si]; ok {
+				// Predicate already covers this source.
+				continue
+			}
+			sources[*si] = struct{}{}
+
+			if si.Catalog.Virtual() {
+				channels = append(channels, channelRef{catalog: si.Catalog, name: b.Identifier()})
+			} else {
+				catalogs[si.Catalog] = struct{}{}
+				channels = append(channels, channelRef{catalog: si.Catalog, pkg: si.Package, channel: si.Channel})
+			}
+		}
+		sortedBundles, err := r.sortBundles(namespacedCache.FindPreferred(&bundle.sourceInfo.Catalog, inChannels(channels...)))
+		if err != nil {
+			return nil, nil, err
+		}
+		// The dependency predicate is applied here
+		// (after sorting) to remove all bundles that
+		// don't satisfy the dependency.
+		dependencies = append(dependencies, Filter(sortedBundles, d))
+	}
+	return dependencies, catalogs, nil
+}
+
 func (r *SatResolver) inferProperties(csv *v1alpha1.ClusterServiceVersion, subs []*v1alpha1.Subscription) ([]*api.Property, error) {
 	var properties []*api.Property
 
@@ -569,6 +683,53 @@ func (r *SatResolver) addInvariants(namespacedCache MultiCatalogOperatorFinder,
 	}
 }
 
+// addPropertyConflicts makes every bundle installable conflict with the other bundle installables that its
+// olm.not.required properties prohibit.
+func (r *SatResolver) addPropertyConflicts(namespacedCache MultiCatalogOperatorFinder, installables map[solver.Identifier]solver.Installable) error {
+	operators := make(map[*BundleInstallable]*Operator)
+	for _, installable := range installables {
+		bundleInstallable, ok := installable.(*BundleInstallable)
+		if !ok {
+			continue
+		}
+		csvName, channel, catalog, err := bundleInstallable.BundleSourceInfo()
+		if err != nil {
+			continue
+		}
+		op, err := ExactlyOne(namespacedCache.Catalog(catalog).Find(WithCSVName(csvName), WithChannel(channel)))
+		if err != nil {
+			continue
+		}
+		operators[bundleInstallable] = op
+	}
+
+	var errs []error
+	for bundleInstallable, op := range operators {
+		predicates, err := op.ConflictPredicates()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bundle %s: %w", bundleInstallable.Identifier(), err))
+			continue
+		}
+		if len(predicates) == 0 {
+			continue
+		}
+		var conflicts []solver.Identifier
+		for other, otherOp := range operators {
+			if other != bundleInstallable && Or(predicates...).Test(otherOp) {
+				conflicts = append(conflicts, other.Identifier())
+			}
+		}
+		// order the conflicts deterministically, like the rest of the input
+		sort.Slice(conflicts, func(i, j int) bool {
+			return conflicts[i] < conflicts[j]
+		})
+		for _, id := range conflicts {
+			bundleInstallable.AddConflict(id)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 func (r *SatResolver) sortBundles(bundles []*Operator) ([]*Operator, error) {
 	// assume bundles have been passed in sorted by catalog already
 	catalogOrder := make([]registry.CatalogKey, 0)
@@ -191,6 +191,162 @@ func TestSolveOperators_FindLatestVersion(t *testing.T) {
 	}
 }
 
+func TestSolveOperators_WithVersionRange(t *testing.T) {
+	namespace := "olm"
+	catalog := registry.CatalogKey{"community", namespace}
+
+	operators := []*Operator{
+		genOperator("packageB.v0.9.0", "0.9.0", "", "packageB", "alpha", "community", "olm", nil, nil, nil, "", false),
+		genOperator("packageB.v1.0.0", "1.0.0", "packageB.v0.9.0", "packageB", "alpha", "community", "olm", nil, nil, nil, "", false),
+		genOperator("packageB.v1.0.1", "1.0.1", "packageB.v1.0.0", "packageB", "alpha", "community", "olm", nil, nil, nil, "", false),
+	}
+
+	for _, tt := range []struct {
+		name         string
+		versionRange string
+		expected     string
+		notSatisfied bool
+	}{
+		{
+			name:         "ExcludesHead",
+			versionRange: "<1.0.1",
+			expected:     "packageB.v1.0.0",
+		},
+		{
+			name:         "ExcludesMiddleOfChain",
+			versionRange: "<1.0.1 !1.0.0",
+			expected:     "packageB.v0.9.0",
+		},
+		{
+			name:         "Unbounded",
+			versionRange: ">=0.9.0",
+			expected:     "packageB.v1.0.1",
+		},
+		{
+			name:         "NoneInRange",
+			versionRange: ">2.0.0",
+			notSatisfied: true,
+		},
+		{
+			name:         "InvalidRange",
+			versionRange: "not-a-range",
+			notSatisfied: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := newSub(namespace, "packageB", "alpha", catalog)
+			sub.SetAnnotations(map[string]string{SubscriptionVersionRangeAnnotationKey: tt.versionRange})
+
+			satResolver := SatResolver{
+				cache: getFakeOperatorCache(NamespacedOperatorCache{
+					snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+						catalog: {
+							key:       catalog,
+							operators: operators,
+						},
+					},
+				}),
+				log: logrus.New(),
+			}
+
+			result, err := satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{sub})
+			if tt.notSatisfied {
+				assert.IsType(t, solver.NotSatisfiable{}, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			assert.Contains(t, result, tt.expected)
+		})
+	}
+}
+
+type denyListSourceFunc func() ([]DenyList, error)
+
+func (f denyListSourceFunc) DenyLists() ([]DenyList, error) {
+	return f()
+}
+
+func TestSolveOperators_WithDenyList(t *testing.T) {
+	namespace := "olm"
+	catalog := registry.CatalogKey{"community", namespace}
+
+	operators := []*Operator{
+		genOperator("packageB.v0.9.0", "0.9.0", "", "packageB", "alpha", "community", "olm", nil, nil, nil, "", false),
+		genOperator("packageB.v1.0.0", "1.0.0", "packageB.v0.9.0", "packageB", "alpha", "community", "olm", nil, nil, nil, "", false),
+		genOperator("packageB.v1.0.1", "1.0.1", "packageB.v1.0.0", "packageB", "alpha", "community", "olm", nil, nil, nil, "", false),
+	}
+
+	for _, tt := range []struct {
+		name         string
+		entries      string
+		err          error
+		expected     string
+		notSatisfied bool
+		policies     []string
+	}{
+		{
+			name:     "NoEntries",
+			expected: "packageB.v1.0.1",
+		},
+		{
+			name:     "DeniesHead",
+			entries:  `[{"bundle": "packageB.v1.0.1", "reason": "CVE-1"}]`,
+			expected: "packageB.v1.0.0",
+		},
+		{
+			name:     "DeniesVersionRange",
+			entries:  `[{"package": "packageB", "versionRange": ">=1.0.0"}]`,
+			expected: "packageB.v0.9.0",
+		},
+		{
+			name:         "DeniesPackage",
+			entries:      `[{"package": "packageB"}]`,
+			notSatisfied: true,
+			policies:     []string{"olm/deny"},
+		},
+		{
+			name: "SourceError",
+			err:  fmt.Errorf("boom"),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			satResolver := SatResolver{
+				cache: getFakeOperatorCache(NamespacedOperatorCache{
+					snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+						catalog: {
+							key:       catalog,
+							operators: operators,
+						},
+					},
+				}),
+				denyLists: denyListSourceFunc(func() ([]DenyList, error) {
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					list, err := ParseDenyList("olm/deny", tt.entries)
+					return []DenyList{list}, err
+				}),
+				log: logrus.New(),
+			}
+
+			result, err := satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{newSub(namespace, "packageB", "alpha", catalog)})
+			if tt.err != nil {
+				require.Error(t, err)
+				return
+			}
+			if tt.notSatisfied {
+				require.IsType(t, solver.NotSatisfiable{}, err)
+				assert.Equal(t, tt.policies, ExplainNotSatisfiable(err.(solver.NotSatisfiable)).Policies)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			assert.Contains(t, result, tt.expected)
+		})
+	}
+}
+
 func TestSolveOperators_FindLatestVersionWithDependencies(t *testing.T) {
 	APISet := APISet{opregistry.APIKey{"g", "v", "k", "ks"}: struct{}{}}
 	Provides := APISet
@@ -38,3 +38,23 @@ func (zeroInstallable) Identifier() Identifier {
 func (zeroInstallable) Constraints() []Constraint {
 	return nil
 }
+
+// Preference is a named reason to prefer an Installable over the
+// alternatives that could satisfy the same dependency. Its weight may
+// be negative to express a reason to avoid an Installable.
+type Preference struct {
+	Name   string
+	Weight int
+}
+
+// WeightedInstallable is implemented by Installables that carry
+// preferences. When more than one Installable could satisfy a
+// dependency, those with a greater total preference weight are tried
+// first, and Installables of equal weight are tried in the order given
+// by the dependency.
+type WeightedInstallable interface {
+	Installable
+	// Preferences returns the preferences that apply to this
+	// Installable.
+	Preferences() []Preference
+}
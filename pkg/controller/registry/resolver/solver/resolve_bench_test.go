@@ -0,0 +1,172 @@
+package solver_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/fake"
+	v1alpha1listers "github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/listers/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/operatorlister"
+	"github.com/operator-framework/operator-registry/pkg/api"
+	"github.com/operator-framework/operator-registry/pkg/client"
+)
+
+const (
+	catalogNamespace = "olm"
+	catalogs         = 2
+	packages         = 250
+	bundles          = 10
+	subscriptions    = 10
+)
+
+// catalogBundles returns a synthetic catalog in which every package has a single channel of replacing bundles,
+// provides an API of its own and requires, with some probability, the API of another package. The APIs of different
+// catalogs are disjoint.
+func catalogBundles(name string) []*api.Bundle {
+	rand.Seed(int64(len(name)))
+	gvk := func(p int) []*api.GroupVersionKind {
+		return []*api.GroupVersionKind{{Group: fmt.Sprintf("%s.g%d", name, p), Version: "v1", Kind: "K", Plural: "ks"}}
+	}
+
+	var result []*api.Bundle
+	for p := 0; p < packages; p++ {
+		pkg := fmt.Sprintf("package%d", p)
+		var required []*api.GroupVersionKind
+		if p > 0 && rand.Float64() < .5 {
+			required = gvk(rand.Intn(p))
+		}
+		var replaces string
+		for v := 0; v < bundles; v++ {
+			csv := fmt.Sprintf("%s.v%d.0.0", pkg, v)
+			result = append(result, &api.Bundle{
+				CsvName:      csv,
+				PackageName:  pkg,
+				ChannelName:  "stable",
+				Version:      fmt.Sprintf("%d.0.0", v),
+				Replaces:     replaces,
+				BundlePath:   fmt.Sprintf("quay.io/%s/%s:v%d", name, pkg, v),
+				ProvidedApis: gvk(p),
+				RequiredApis: required,
+			})
+			replaces = csv
+		}
+	}
+	return result
+}
+
+// bundleStream streams a fixed list of bundles.
+type bundleStream []*api.Bundle
+
+func (s *bundleStream) Recv() (*api.Bundle, error) {
+	if len(*s) == 0 {
+		return nil, io.EOF
+	}
+	b := (*s)[0]
+	*s = (*s)[1:]
+	return b, nil
+}
+
+// registryClient serves a synthetic catalog.
+type registryClient struct {
+	client.Interface
+	bundles []*api.Bundle
+}
+
+func (c *registryClient) ListBundles(context.Context) (*client.BundleIterator, error) {
+	stream := bundleStream(c.bundles)
+	return client.NewBundleIterator(&stream), nil
+}
+
+func (c *registryClient) GetPackage(_ context.Context, name string) (*api.Package, error) {
+	return &api.Package{Name: name, DefaultChannelName: "stable"}, nil
+}
+
+type registryClientProvider map[registry.CatalogKey]client.Interface
+
+func (p registryClientProvider) ClientsForNamespaces(...string) map[registry.CatalogKey]client.Interface {
+	return p
+}
+
+func namespaceIndexer() cache.Indexer {
+	return cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// BenchmarkResolveSteps resolves subscriptions against large synthetic catalogs. Cold replaces every catalog
+// snapshot before each resolution, Warm reuses them all, and OneCatalogChanged replaces one of them, as happens when
+// a single CatalogSource is updated.
+func BenchmarkResolveSteps(b *testing.B) {
+	var keys []registry.CatalogKey
+	provider := make(registryClientProvider)
+	for i := 0; i < catalogs; i++ {
+		key := registry.CatalogKey{Name: fmt.Sprintf("catalog%d", i), Namespace: catalogNamespace}
+		keys = append(keys, key)
+		provider[key] = &registryClient{bundles: catalogBundles(key.Name)}
+	}
+
+	var subs []runtime.Object
+	for i := 0; i < subscriptions; i++ {
+		pkg := fmt.Sprintf("package%d", packages-1-i*7)
+		subs = append(subs, &v1alpha1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{Name: pkg, Namespace: catalogNamespace},
+			Spec: &v1alpha1.SubscriptionSpec{
+				CatalogSource:          keys[0].Name,
+				CatalogSourceNamespace: keys[0].Namespace,
+				Package:                pkg,
+				Channel:                "stable",
+			},
+		})
+	}
+
+	lister := operatorlister.NewLister()
+	lister.OperatorsV1alpha1().RegisterSubscriptionLister(catalogNamespace, v1alpha1listers.NewSubscriptionLister(namespaceIndexer()))
+	lister.OperatorsV1alpha1().RegisterClusterServiceVersionLister(catalogNamespace, v1alpha1listers.NewClusterServiceVersionLister(namespaceIndexer()))
+	lister.OperatorsV1alpha1().RegisterCatalogSourceLister(catalogNamespace, v1alpha1listers.NewCatalogSourceLister(namespaceIndexer()))
+	lister.CoreV1().RegisterConfigMapLister(catalogNamespace, corev1listers.NewConfigMapLister(namespaceIndexer()))
+
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+
+	for _, bb := range []struct {
+		name    string
+		expired []registry.CatalogKey
+	}{
+		{name: "Cold", expired: keys},
+		{name: "Warm"},
+		{name: "OneCatalogChanged", expired: keys[1:]},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			r := resolver.NewOperatorStepResolver(lister, fake.NewSimpleClientset(subs...), k8sfake.NewSimpleClientset(), catalogNamespace, provider, log)
+			resolve := func() {
+				steps, lookups, _, _, err := r.ResolveSteps(catalogNamespace, nil)
+				if err != nil {
+					b.Fatalf("failed to resolve: %s", err)
+				}
+				if len(steps)+len(lookups) == 0 {
+					b.Fatal("resolved nothing")
+				}
+			}
+			resolve()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, key := range bb.expired {
+					r.Expire(key)
+				}
+				resolve()
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package solver
 
 import (
 	"context"
+	"sort"
 
 	"github.com/irifrance/gini/inter"
 	"github.com/irifrance/gini/z"
@@ -18,6 +19,7 @@ type guess struct {
 	index      int   // index of guessed literal in candidates
 	children   int   // number of choices introduced by making this guess
 	candidates []z.Lit
+	decision   *PreferenceDecision // set if preferences ordered the guessed literal ahead of the next candidate
 }
 
 type search struct {
@@ -31,6 +33,7 @@ type search struct {
 	tracer                 Tracer
 	result                 int
 	buffer                 []z.Lit
+	weights                map[z.Lit]int
 }
 
 func (h *search) PushGuess() {
@@ -53,6 +56,10 @@ func (h *search) PushGuess() {
 		}
 	}
 
+	if g.m != z.LitNull && g.index+1 < len(g.candidates) {
+		g.decision = h.decide(g.m, g.candidates[g.index+1])
+	}
+
 	h.guesses = append(h.guesses, g)
 	if g.m == z.LitNull {
 		return
@@ -65,6 +72,7 @@ func (h *search) PushGuess() {
 			ms = append(ms, h.lits.LitOf(dependency))
 		}
 		if len(ms) > 0 {
+			h.prefer(ms)
 			h.guesses[len(h.guesses)-1].children++
 			h.PushChoiceBack(choice{candidates: ms})
 		}
@@ -189,6 +197,10 @@ func (h *search) Do(ctx context.Context, anchors []z.Lit) (int, []z.Lit, map[z.L
 		h.PushGuess()
 	}
 
+	if h.result == satisfiable && len(h.Decisions()) > 0 {
+		h.tracer.Trace(h)
+	}
+
 	lits := h.Lits()
 	set := make(map[z.Lit]struct{}, len(lits))
 	for _, m := range lits {
@@ -217,3 +229,78 @@ func (h *search) Installables() []Installable {
 func (h *search) Conflicts() []AppliedConstraint {
 	return h.lits.Conflicts(h.s)
 }
+
+func (h *search) Decisions() []PreferenceDecision {
+	var result []PreferenceDecision
+	for _, g := range h.guesses {
+		if g.decision != nil {
+			result = append(result, *g.decision)
+		}
+	}
+	return result
+}
+
+// preferencesOf returns the preferences of the Installable
+// corresponding to the given literal, if it has any.
+func (h *search) preferencesOf(m z.Lit) []Preference {
+	if w, ok := h.lits.installables[m].(WeightedInstallable); ok {
+		return w.Preferences()
+	}
+	return nil
+}
+
+func (h *search) weightOf(m z.Lit) int {
+	if w, ok := h.weights[m]; ok {
+		return w
+	}
+	var w int
+	for _, p := range h.preferencesOf(m) {
+		w += p.Weight
+	}
+	if h.weights == nil {
+		h.weights = make(map[z.Lit]int)
+	}
+	h.weights[m] = w
+	return w
+}
+
+// prefer sorts candidate literals in decreasing order of preference
+// weight, preserving the given order among candidates of equal
+// weight.
+func (h *search) prefer(ms []z.Lit) {
+	sort.SliceStable(ms, func(i, j int) bool {
+		return h.weightOf(ms[i]) > h.weightOf(ms[j])
+	})
+}
+
+// decide returns a PreferenceDecision if the chosen literal was
+// ordered ahead of the alternative because of preference weight, and
+// nil if it was ordered ahead because of input order.
+func (h *search) decide(chosen, alternative z.Lit) *PreferenceDecision {
+	if h.weightOf(chosen) <= h.weightOf(alternative) {
+		return nil
+	}
+	margins := make(map[string]int)
+	for _, p := range h.preferencesOf(chosen) {
+		margins[p.Name] += p.Weight
+	}
+	for _, p := range h.preferencesOf(alternative) {
+		margins[p.Name] -= p.Weight
+	}
+	var names []string
+	for name := range margins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var decisive string
+	for _, name := range names {
+		if decisive == "" || margins[name] > margins[decisive] {
+			decisive = name
+		}
+	}
+	return &PreferenceDecision{
+		Chosen:     h.lits.InstallableOf(chosen).Identifier(),
+		Over:       h.lits.InstallableOf(alternative).Identifier(),
+		Preference: decisive,
+	}
+}
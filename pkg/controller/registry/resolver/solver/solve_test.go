@@ -361,3 +361,145 @@ func TestDuplicateIdentifier(t *testing.T) {
 	}))
 	assert.Equal(t, DuplicateIdentifier("a"), err)
 }
+
+type weightedTestInstallable struct {
+	TestInstallable
+	preferences []Preference
+}
+
+func (i weightedTestInstallable) Preferences() []Preference {
+	return i.preferences
+}
+
+func weighted(id Identifier, preferences []Preference, constraints ...Constraint) Installable {
+	return weightedTestInstallable{
+		TestInstallable: TestInstallable{
+			identifier:  id,
+			constraints: constraints,
+		},
+		preferences: preferences,
+	}
+}
+
+func TestSolveWithPreferences(t *testing.T) {
+	for _, tt := range []struct {
+		Name         string
+		Installables []Installable
+		Installed    []Identifier
+		Trace        string
+	}{
+		{
+			Name: "equal weights fall back to input order",
+			Installables: []Installable{
+				installable("a", Mandatory(), Dependency("x", "y")),
+				weighted("x", []Preference{{Name: "p", Weight: 1}}),
+				weighted("y", []Preference{{Name: "p", Weight: 1}}),
+			},
+			Installed: []Identifier{"a", "x"},
+		},
+		{
+			Name: "greater weight preferred over input order",
+			Installables: []Installable{
+				installable("a", Mandatory(), Dependency("x", "y", "z")),
+				installable("x"),
+				weighted("y", []Preference{{Name: "newest", Weight: 1}}),
+				weighted("z", []Preference{{Name: "newest", Weight: 1}, {Name: "publisher", Weight: 5}}),
+			},
+			Installed: []Identifier{"a", "z"},
+			Trace:     "- z preferred over y by publisher\n",
+		},
+		{
+			Name: "negative weight avoided",
+			Installables: []Installable{
+				installable("a", Mandatory(), Dependency("x", "y")),
+				weighted("x", []Preference{{Name: "deprecated", Weight: -1}}),
+				installable("y"),
+			},
+			Installed: []Identifier{"a", "y"},
+			Trace:     "- y preferred over x by deprecated\n",
+		},
+		{
+			Name: "preferred installable that fails falls back",
+			Installables: []Installable{
+				installable("a", Mandatory(), Dependency("x", "y"), Conflict("y")),
+				installable("x"),
+				weighted("y", []Preference{{Name: "p", Weight: 1}}),
+			},
+			Installed: []Identifier{"a", "x"},
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var traces bytes.Buffer
+			s, err := New(WithInput(tt.Installables), WithTracer(LoggingTracer{Writer: &traces}))
+			if err != nil {
+				t.Fatalf("failed to initialize solver: %s", err)
+			}
+
+			installed, err := s.Solve(context.TODO())
+			assert.NoError(t, err)
+
+			var ids []Identifier
+			for _, installable := range installed {
+				ids = append(ids, installable.Identifier())
+			}
+			sort.Slice(ids, func(i, j int) bool {
+				return ids[i] < ids[j]
+			})
+			assert.Equal(t, tt.Installed, ids)
+			if tt.Trace != "" {
+				assert.Contains(t, traces.String(), tt.Trace)
+			} else {
+				assert.NotContains(t, traces.String(), "Preferences:")
+			}
+		})
+	}
+}
+
+func TestRecordingTracer(t *testing.T) {
+	installables := []Installable{
+		installable("a", Mandatory(), Dependency("a1", "a2")),
+		installable("a1", Conflict("c1"), Conflict("c2")),
+		installable("a2", Conflict("c1")),
+		installable("b", Mandatory(), Dependency("b1", "b2")),
+		installable("b1", Conflict("c1"), Conflict("c2")),
+		installable("b2", Conflict("c1")),
+		installable("c", Mandatory(), Dependency("c1", "c2")),
+		installable("c1"),
+		installable("c2"),
+	}
+
+	for _, tt := range []struct {
+		Name      string
+		Limit     int
+		Positions int
+		Dropped   int
+	}{
+		{
+			Name:      "unbounded",
+			Positions: 3,
+		},
+		{
+			Name:      "bounded",
+			Limit:     1,
+			Positions: 1,
+			Dropped:   2,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			tracer := &RecordingTracer{Limit: tt.Limit}
+			var traces bytes.Buffer
+			s, err := New(WithInput(installables), WithTracer(MultiTracer{tracer, LoggingTracer{Writer: &traces}}))
+			assert.NoError(t, err)
+
+			_, err = s.Solve(context.TODO())
+			assert.NoError(t, err)
+			assert.Len(t, tracer.Positions, tt.Positions)
+			assert.Equal(t, tt.Dropped, tracer.Dropped)
+			assert.NotEmpty(t, traces.String())
+
+			last := tracer.Positions[len(tracer.Positions)-1]
+			assert.Equal(t, []Identifier{"a", "b", "c", "a2", "b2", "c1"}, last.Assumptions)
+			assert.Contains(t, last.Conflicts, "a2 conflicts with c1")
+		})
+	}
+}
@@ -8,6 +8,21 @@ import (
 type SearchPosition interface {
 	Installables() []Installable
 	Conflicts() []AppliedConstraint
+	Decisions() []PreferenceDecision
+}
+
+// PreferenceDecision records that an Installable was tried ahead of
+// an alternative because of its preferences.
+type PreferenceDecision struct {
+	Chosen Identifier
+	Over   Identifier
+	// Preference is the name of the preference that most favored
+	// Chosen over Over.
+	Preference string
+}
+
+func (d PreferenceDecision) String() string {
+	return fmt.Sprintf("%s preferred over %s by %s", d.Chosen, d.Over, d.Preference)
 }
 
 type Tracer interface {
@@ -32,4 +47,55 @@ func (t LoggingTracer) Trace(p SearchPosition) {
 	for _, a := range p.Conflicts() {
 		fmt.Fprintf(t.Writer, "- %s\n", a)
 	}
+	if decisions := p.Decisions(); len(decisions) > 0 {
+		fmt.Fprintf(t.Writer, "Preferences:\n")
+		for _, d := range decisions {
+			fmt.Fprintf(t.Writer, "- %s\n", d)
+		}
+	}
+}
+
+// MultiTracer passes each search position to every one of its
+// Tracers in turn.
+type MultiTracer []Tracer
+
+func (t MultiTracer) Trace(p SearchPosition) {
+	for _, tracer := range t {
+		tracer.Trace(p)
+	}
+}
+
+// TracePosition is a snapshot of a search position.
+type TracePosition struct {
+	Assumptions []Identifier `json:"assumptions,omitempty"`
+	Conflicts   []string     `json:"conflicts,omitempty"`
+	Preferences []string     `json:"preferences,omitempty"`
+}
+
+// RecordingTracer keeps snapshots of the most recent search
+// positions it is given, up to Limit. Older positions are discarded
+// and counted in Dropped. A Limit less than one keeps every position.
+type RecordingTracer struct {
+	Limit     int
+	Positions []TracePosition
+	Dropped   int
+}
+
+func (t *RecordingTracer) Trace(p SearchPosition) {
+	var position TracePosition
+	for _, i := range p.Installables() {
+		position.Assumptions = append(position.Assumptions, i.Identifier())
+	}
+	for _, a := range p.Conflicts() {
+		position.Conflicts = append(position.Conflicts, a.String())
+	}
+	for _, d := range p.Decisions() {
+		position.Preferences = append(position.Preferences, d.String())
+	}
+	if t.Limit > 0 && len(t.Positions) >= t.Limit {
+		n := len(t.Positions) - t.Limit + 1
+		t.Positions = append(t.Positions[:0], t.Positions[n:]...)
+		t.Dropped += n
+	}
+	t.Positions = append(t.Positions, position)
 }
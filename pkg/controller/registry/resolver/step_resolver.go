@@ -5,6 +5,7 @@ package resolver
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -29,8 +30,12 @@ const (
 
 var timeNow = func() metav1.Time { return metav1.NewTime(time.Now().UTC()) }
 
+// Dependencies maps the name of each bundle in a resolution to the names of the bundles in the same resolution that
+// satisfy its dependencies.
+type Dependencies map[string][]string
+
 type StepResolver interface {
-	ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error)
+	ResolveSteps(namespace string, sourceQuerier SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, Dependencies, error)
 	Expire(key registry.CatalogKey)
 }
 
@@ -49,7 +54,7 @@ var _ StepResolver = &OperatorStepResolver{}
 
 func NewOperatorStepResolver(lister operatorlister.OperatorLister, client versioned.Interface, kubeclient kubernetes.Interface,
 	globalCatalogNamespace string, provider RegistryClientProvider, log logrus.FieldLogger) *OperatorStepResolver {
-	return &OperatorStepResolver{
+	r := &OperatorStepResolver{
 		subLister:              lister.OperatorsV1alpha1().SubscriptionLister(),
 		csvLister:              lister.OperatorsV1alpha1().ClusterServiceVersionLister(),
 		ipLister:               lister.OperatorsV1alpha1().InstallPlanLister(),
@@ -59,38 +64,58 @@ func NewOperatorStepResolver(lister operatorlister.OperatorLister, client versio
 		satResolver:            NewDefaultSatResolver(NewDefaultRegistryClientProvider(log, provider), lister.OperatorsV1alpha1().CatalogSourceLister(), log),
 		log:                    log,
 	}
+	r.satResolver.denyLists = NewConfigMapDenyListSource(lister.CoreV1().ConfigMapLister(), globalCatalogNamespace)
+	r.satResolver.preferences = NewClusterPreferenceSource(lister.CoreV1().ConfigMapLister(), r.csvLister, globalCatalogNamespace)
+	return r
+}
+
+// RecordResolutionTraces configures the resolver to pass a trace of every resolution, bounded to the given number of
+// search positions, to the given recorder.
+func (r *OperatorStepResolver) RecordResolutionTraces(recorder ResolutionTraceRecorder, limit int) {
+	r.satResolver.traces = recorder
+	r.satResolver.traceLimit = limit
+}
+
+// EnableClusterWideResolution configures the resolver to consider the operators installed and being installed in
+// every other namespace, so that no API is provided by operators of different packages in different namespaces.
+func (r *OperatorStepResolver) EnableClusterWideResolution() {
+	r.satResolver.clusterOperators = NewListerClusterOperatorSource(r.csvLister, r.subLister)
 }
 
 func (r *OperatorStepResolver) Expire(key registry.CatalogKey) {
 	r.satResolver.cache.Expire(key)
 }
 
-func (r *OperatorStepResolver) ResolveSteps(namespace string, _ SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
+func (r *OperatorStepResolver) ResolveSteps(namespace string, _ SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, Dependencies, error) {
 	// create a generation - a representation of the current set of installed operators and their provided/required apis
-	allCSVs, err := r.csvLister.ClusterServiceVersions(namespace).List(labels.Everything())
+	csvs, err := r.listCSVs(namespace)
 	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	// TODO: build this index ahead of time
-	// omit copied csvs from generation - they indicate that apis are provided to the namespace, not by the namespace
-	var csvs []*v1alpha1.ClusterServiceVersion
-	for i := range allCSVs {
-		if !allCSVs[i].IsCopied() {
-			csvs = append(csvs, allCSVs[i])
-		}
+		return nil, nil, nil, nil, err
 	}
 
 	subs, err := r.listSubscriptions(namespace)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
+	return r.resolveSteps(namespace, csvs, subs, resolutionOptions{})
+}
+
+// resolveSteps computes the steps, bundle lookups and subscription updates required to reconcile the given
+// subscriptions against the given set of CSVs, along with the dependencies between the resolved bundles. It does not
+// write anything to the cluster other than the resolution trace, which previews don't record, but it does update the
+// status of the subscriptions it returns.
+func (r *OperatorStepResolver) resolveSteps(namespace string, csvs []*v1alpha1.ClusterServiceVersion, subs []*v1alpha1.Subscription, opts resolutionOptions) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, Dependencies, error) {
 	var operators OperatorSet
+	var err error
 	namespaces := []string{namespace, r.globalCatalogNamespace}
-	operators, err = r.satResolver.SolveOperators(namespaces, csvs, subs)
+	operators, err = r.satResolver.solveOperators(namespaces, csvs, subs, opts)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+	dependencies, err := dependenciesOf(operators)
+	if err != nil {
+		return nil, nil, nil, nil, err
 	}
 
 	// if there's no error, we were able to satisfy all constraints in the subscription set, so we calculate what
@@ -118,7 +143,7 @@ func (r *OperatorStepResolver) ResolveSteps(namespace string, _ SourceQuerier) (
 			}
 			alreadyExists, err := r.hasExistingCurrentCSV(sub)
 			if err != nil {
-				return nil, nil, nil, fmt.Errorf("unable to determine whether subscription %s has a preexisting CSV", sub.GetName())
+				return nil, nil, nil, nil, fmt.Errorf("unable to determine whether subscription %s has a preexisting CSV", sub.GetName())
 			}
 			existingSubscriptions[sub] = alreadyExists
 		}
@@ -141,7 +166,7 @@ func (r *OperatorStepResolver) ResolveSteps(namespace string, _ SourceQuerier) (
 			if op.Inline() {
 				bundleSteps, err := NewStepsFromBundle(op.Bundle(), namespace, op.Replaces(), op.SourceInfo().Catalog.Name, op.SourceInfo().Catalog.Namespace)
 				if err != nil {
-					return nil, nil, nil, fmt.Errorf("failed to turn bundle into steps: %s", err.Error())
+					return nil, nil, nil, nil, fmt.Errorf("failed to turn bundle into steps: %s", err.Error())
 				}
 				steps = append(steps, bundleSteps...)
 			} else {
@@ -169,7 +194,7 @@ func (r *OperatorStepResolver) ResolveSteps(namespace string, _ SourceQuerier) (
 					},
 				}
 				if anno, err := projection.PropertiesAnnotationFromPropertyList(op.Properties()); err != nil {
-					return nil, nil, nil, fmt.Errorf("failed to serialize operator properties for %q: %w", op.Identifier(), err)
+					return nil, nil, nil, nil, fmt.Errorf("failed to serialize operator properties for %q: %w", op.Identifier(), err)
 				} else {
 					lookup.Properties = anno
 				}
@@ -181,7 +206,7 @@ func (r *OperatorStepResolver) ResolveSteps(namespace string, _ SourceQuerier) (
 				op.SourceInfo().StartingCSV = op.Identifier()
 				subStep, err := NewSubscriptionStepResource(namespace, *op.SourceInfo())
 				if err != nil {
-					return nil, nil, nil, err
+					return nil, nil, nil, nil, err
 				}
 				steps = append(steps, &v1alpha1.Step{
 					Resolving: name,
@@ -204,7 +229,37 @@ func (r *OperatorStepResolver) ResolveSteps(namespace string, _ SourceQuerier) (
 
 	// Order Steps
 	steps = v1alpha1.OrderSteps(steps)
-	return steps, bundleLookups, updatedSubs, nil
+	return steps, bundleLookups, updatedSubs, dependencies, nil
+}
+
+// dependenciesOf returns the dependencies of each operator in a resolution that are satisfied by other operators in
+// the same resolution.
+func dependenciesOf(operators OperatorSet) (Dependencies, error) {
+	dependencies := make(Dependencies)
+	for name, op := range operators {
+		o, ok := op.(*Operator)
+		if !ok {
+			continue
+		}
+		predicates, err := o.DependencyPredicates()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine the dependencies of %q: %w", name, err)
+		}
+		for depName, dep := range operators {
+			d, ok := dep.(*Operator)
+			if !ok || depName == name {
+				continue
+			}
+			for _, p := range predicates {
+				if p.Test(d) {
+					dependencies[name] = append(dependencies[name], depName)
+					break
+				}
+			}
+		}
+		sort.Strings(dependencies[name])
+	}
+	return dependencies, nil
 }
 
 func (r *OperatorStepResolver) hasExistingCurrentCSV(sub *v1alpha1.Subscription) (bool, error) {
@@ -221,6 +276,24 @@ func (r *OperatorStepResolver) hasExistingCurrentCSV(sub *v1alpha1.Subscription)
 	return false, err // Can't answer this question right now.
 }
 
+func (r *OperatorStepResolver) listCSVs(namespace string) ([]*v1alpha1.ClusterServiceVersion, error) {
+	allCSVs, err := r.csvLister.ClusterServiceVersions(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO: build this index ahead of time
+	// omit copied csvs from generation - they indicate that apis are provided to the namespace, not by the namespace
+	var csvs []*v1alpha1.ClusterServiceVersion
+	for i := range allCSVs {
+		if !allCSVs[i].IsCopied() {
+			csvs = append(csvs, allCSVs[i])
+		}
+	}
+
+	return csvs, nil
+}
+
 func (r *OperatorStepResolver) listSubscriptions(namespace string) ([]*v1alpha1.Subscription, error) {
 	list, err := r.client.OperatorsV1alpha1().Subscriptions(namespace).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
@@ -852,7 +852,7 @@ func TestResolver(t *testing.T) {
 			resolver := NewOperatorStepResolver(lister, clientFake, kClientFake, "", nil, log)
 			resolver.satResolver = satresolver
 
-			steps, lookups, subs, err := resolver.ResolveSteps(namespace, nil)
+			steps, lookups, subs, _, err := resolver.ResolveSteps(namespace, nil)
 			if tt.out.solverError == nil {
 				if tt.out.errAssert == nil {
 					assert.NoError(t, err)
@@ -1001,7 +1001,7 @@ func TestNamespaceResolverRBAC(t *testing.T) {
 			resolver := NewOperatorStepResolver(lister, clientFake, kClientFake, "", nil, logrus.New())
 			resolver.satResolver = satresolver
 			querier := NewFakeSourceQuerier(map[registry.CatalogKey][]*api.Bundle{catalog: tt.bundlesInCatalog})
-			steps, _, subs, err := resolver.ResolveSteps(namespace, querier)
+			steps, _, subs, _, err := resolver.ResolveSteps(namespace, querier)
 			require.Equal(t, tt.out.err, err)
 			RequireStepsEqual(t, expectedSteps, steps)
 			require.ElementsMatch(t, tt.out.subs, subs)
@@ -1170,3 +1170,25 @@ func subSteps(namespace, operatorName, pkgName, channelName string, catalog regi
 		Status:    v1alpha1.StepStatusUnknown,
 	}}
 }
+
+func TestDependenciesOf(t *testing.T) {
+	widgets := APISet{opregistry.APIKey{Group: "g", Version: "v", Kind: "Widget", Plural: "widgets"}: struct{}{}}
+	packageDeps := []*api.Dependency{{
+		Type:  "olm.package",
+		Value: `{"packageName":"packageC","version":"1.0.0"}`,
+	}}
+
+	operators := OperatorSet{
+		"packageA.v1": genOperator("packageA.v1", "1.0.0", "", "packageA", "alpha", "community", "olm", widgets, nil, nil, "", false),
+		"packageB.v1": genOperator("packageB.v1", "1.0.0", "", "packageB", "alpha", "community", "olm", nil, widgets, packageDeps, "", false),
+		"packageC.v1": genOperator("packageC.v1", "1.0.0", "", "packageC", "alpha", "community", "olm", nil, nil, nil, "", false),
+		"packageD.v1": genOperator("packageD.v1", "1.0.0", "", "packageD", "alpha", "community", "olm", nil, nil, nil, "", false),
+	}
+
+	dependencies, err := dependenciesOf(operators)
+	require.NoError(t, err)
+	require.Equal(t, Dependencies{
+		"packageA.v1": {"packageB.v1"},
+		"packageB.v1": {"packageC.v1"},
+	}, dependencies)
+}
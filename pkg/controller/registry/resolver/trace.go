@@ -0,0 +1,152 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+const (
+	// ResolutionTraceConfigMapName is the name of the ConfigMap, in each resolved namespace, holding the trace of the
+	// namespace's most recent resolution.
+	ResolutionTraceConfigMapName = "olm-resolution-trace"
+
+	// ResolutionTraceLabelKey is the label that identifies resolution trace ConfigMaps.
+	ResolutionTraceLabelKey = "olm.resolution-trace"
+
+	// ResolutionTraceDataKey is the ConfigMap data key holding the JSON-encoded ResolutionTrace.
+	ResolutionTraceDataKey = "trace"
+
+	// ResolutionTraceRecordedAnnotationKey is the annotation holding the time at which the trace last changed.
+	ResolutionTraceRecordedAnnotationKey = "olm.resolution-trace/recorded"
+
+	// maxResolutionTraceBytes bounds the size of an encoded trace well below the size limit of a ConfigMap.
+	maxResolutionTraceBytes = 512 * 1024
+)
+
+// ResolutionOutcome summarizes the result of a traced resolution.
+type ResolutionOutcome string
+
+const (
+	ResolutionSucceeded      ResolutionOutcome = "Succeeded"
+	ResolutionNotSatisfiable ResolutionOutcome = "NotSatisfiable"
+	ResolutionFailed         ResolutionOutcome = "Failed"
+)
+
+// ResolutionTrace is a structured record of the search positions that the solver visited while resolving a
+// namespace. Only positions at which the search backtracked, or at which preferences ordered a choice, are recorded.
+type ResolutionTrace struct {
+	Namespace string            `json:"namespace"`
+	Outcome   ResolutionOutcome `json:"outcome"`
+	Message   string            `json:"message,omitempty"`
+
+	// Positions are the most recent search positions, oldest first.
+	Positions []solver.TracePosition `json:"positions,omitempty"`
+
+	// DroppedPositions counts the earlier search positions that were discarded to bound the trace.
+	DroppedPositions int `json:"droppedPositions,omitempty"`
+}
+
+func newResolutionTrace(namespace string, tracer *solver.RecordingTracer, err error) *ResolutionTrace {
+	trace := &ResolutionTrace{
+		Namespace:        namespace,
+		Outcome:          ResolutionSucceeded,
+		Positions:        tracer.Positions,
+		DroppedPositions: tracer.Dropped,
+	}
+	if err != nil {
+		trace.Outcome = ResolutionFailed
+		if _, ok := err.(solver.NotSatisfiable); ok {
+			trace.Outcome = ResolutionNotSatisfiable
+		}
+		trace.Message = err.Error()
+	}
+	return trace
+}
+
+// Encode returns the JSON encoding of the trace, dropping its oldest positions as necessary to fit within
+// maxResolutionTraceBytes.
+func (t *ResolutionTrace) Encode() ([]byte, error) {
+	for {
+		data, err := json.Marshal(t)
+		if err != nil || len(data) <= maxResolutionTraceBytes || len(t.Positions) == 0 {
+			return data, err
+		}
+		t.Positions = t.Positions[1:]
+		t.DroppedPositions++
+	}
+}
+
+// ResolutionTraceRecorder persists resolution traces.
+type ResolutionTraceRecorder interface {
+	RecordResolutionTrace(trace *ResolutionTrace) error
+}
+
+type configMapTraceRecorder struct {
+	client kubernetes.Interface
+	lister corev1listers.ConfigMapLister
+	now    func() metav1.Time
+}
+
+// NewConfigMapTraceRecorder returns a ResolutionTraceRecorder that keeps the most recent trace of each namespace in
+// the ResolutionTraceConfigMapName ConfigMap of that namespace. The ConfigMap is only written when the trace changes.
+func NewConfigMapTraceRecorder(client kubernetes.Interface, lister corev1listers.ConfigMapLister) ResolutionTraceRecorder {
+	return &configMapTraceRecorder{
+		client: client,
+		lister: lister,
+		now:    metav1.Now,
+	}
+}
+
+func (r *configMapTraceRecorder) RecordResolutionTrace(trace *ResolutionTrace) error {
+	data, err := trace.Encode()
+	if err != nil {
+		return err
+	}
+
+	existing, err := r.lister.ConfigMaps(trace.Namespace).Get(ResolutionTraceConfigMapName)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && existing.Data[ResolutionTraceDataKey] == string(data) {
+		return nil
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ResolutionTraceConfigMapName,
+			Namespace: trace.Namespace,
+		},
+	}
+	if existing != nil {
+		cm = existing.DeepCopy()
+	}
+	if cm.Labels == nil {
+		cm.Labels = map[string]string{}
+	}
+	cm.Labels[ResolutionTraceLabelKey] = "true"
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[ResolutionTraceRecordedAnnotationKey] = r.now().UTC().Format(time.RFC3339)
+	cm.Data = map[string]string{ResolutionTraceDataKey: string(data)}
+
+	if existing == nil {
+		_, err = r.client.CoreV1().ConfigMaps(trace.Namespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+	} else {
+		_, err = r.client.CoreV1().ConfigMaps(trace.Namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("error recording resolution trace for namespace %s: %w", trace.Namespace, err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry"
+	"github.com/operator-framework/operator-lifecycle-manager/pkg/controller/registry/resolver/solver"
+)
+
+type traceRecorderFunc func(*ResolutionTrace) error
+
+func (f traceRecorderFunc) RecordResolutionTrace(trace *ResolutionTrace) error {
+	return f(trace)
+}
+
+func TestConfigMapTraceRecorder(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	now := metav1.Now()
+	recorder := &configMapTraceRecorder{
+		client: client,
+		lister: corev1listers.NewConfigMapLister(indexer),
+		now:    func() metav1.Time { return now },
+	}
+
+	record := func(trace *ResolutionTrace) {
+		require.NoError(t, recorder.RecordResolutionTrace(trace))
+		cm, err := client.CoreV1().ConfigMaps("ns").Get(context.TODO(), ResolutionTraceConfigMapName, metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NoError(t, indexer.Update(cm))
+	}
+	actions := func() int {
+		var n int
+		for _, a := range client.Actions() {
+			if a.GetVerb() == "create" || a.GetVerb() == "update" {
+				n++
+			}
+		}
+		return n
+	}
+
+	first := &ResolutionTrace{
+		Namespace: "ns",
+		Outcome:   ResolutionNotSatisfiable,
+		Message:   "constraints not satisfiable",
+		Positions: []solver.TracePosition{{Assumptions: []solver.Identifier{"a"}, Conflicts: []string{"a is prohibited"}}},
+	}
+	record(first)
+	assert.Equal(t, 1, actions())
+
+	cm, err := client.CoreV1().ConfigMaps("ns").Get(context.TODO(), ResolutionTraceConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", cm.GetLabels()[ResolutionTraceLabelKey])
+	assert.NotEmpty(t, cm.GetAnnotations()[ResolutionTraceRecordedAnnotationKey])
+	var decoded ResolutionTrace
+	require.NoError(t, json.Unmarshal([]byte(cm.Data[ResolutionTraceDataKey]), &decoded))
+	assert.Equal(t, *first, decoded)
+
+	// An unchanged trace isn't written again.
+	record(first)
+	assert.Equal(t, 1, actions())
+
+	record(&ResolutionTrace{Namespace: "ns", Outcome: ResolutionSucceeded})
+	assert.Equal(t, 2, actions())
+}
+
+func TestResolutionTraceEncode(t *testing.T) {
+	position := solver.TracePosition{Conflicts: []string{strings.Repeat("x", 200*1024)}}
+	trace := &ResolutionTrace{
+		Namespace: "ns",
+		Outcome:   ResolutionFailed,
+		Positions: []solver.TracePosition{position, position, position, position},
+	}
+	data, err := trace.Encode()
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), maxResolutionTraceBytes)
+	assert.Len(t, trace.Positions, 2)
+	assert.Equal(t, 2, trace.DroppedPositions)
+}
+
+func TestSolveOperators_RecordsTrace(t *testing.T) {
+	namespace := "olm"
+	catalog := registry.CatalogKey{"community", namespace}
+
+	for _, tt := range []struct {
+		name    string
+		sub     *v1alpha1.Subscription
+		outcome ResolutionOutcome
+	}{
+		{
+			name:    "Succeeded",
+			sub:     newSub(namespace, "packageA", "alpha", catalog),
+			outcome: ResolutionSucceeded,
+		},
+		{
+			name:    "NotSatisfiable",
+			sub:     newSub(namespace, "packageMissing", "alpha", catalog),
+			outcome: ResolutionNotSatisfiable,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var traces []*ResolutionTrace
+			satResolver := SatResolver{
+				cache: getFakeOperatorCache(NamespacedOperatorCache{
+					snapshots: map[registry.CatalogKey]*CatalogSnapshot{
+						catalog: {
+							key: catalog,
+							operators: []*Operator{
+								genOperator("packageA.v1", "1.0.0", "", "packageA", "alpha", catalog.Name, namespace, nil, nil, nil, "", false),
+							},
+						},
+					},
+				}),
+				traces: traceRecorderFunc(func(trace *ResolutionTrace) error {
+					traces = append(traces, trace)
+					return fmt.Errorf("recording failures don't fail resolution")
+				}),
+				traceLimit: 10,
+				log:        logrus.New(),
+			}
+
+			_, err := satResolver.SolveOperators([]string{namespace}, nil, []*v1alpha1.Subscription{tt.sub})
+			require.Len(t, traces, 1)
+			assert.Equal(t, namespace, traces[0].Namespace)
+			assert.Equal(t, tt.outcome, traces[0].Outcome)
+			if tt.outcome == ResolutionSucceeded {
+				assert.NoError(t, err)
+				assert.Empty(t, traces[0].Message)
+			} else {
+				assert.Equal(t, err.Error(), traces[0].Message)
+			}
+		})
+	}
+}
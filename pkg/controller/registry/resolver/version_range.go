@@ -0,0 +1,69 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/pkg/api"
+)
+
+const (
+	// SubscriptionVersionRangeAnnotationKey is the annotation on a Subscription that restricts resolution to
+	// bundles whose version is within a semver range, e.g. ">=1.4.0 <1.6.0".
+	SubscriptionVersionRangeAnnotationKey = "olm.versionRange"
+
+	// SubscriptionVersionRangeExceeded indicates that the head of a Subscription's channel is outside the
+	// Subscription's version range, so automatic upgrades have stopped short of it.
+	SubscriptionVersionRangeExceeded v1alpha1.SubscriptionConditionType = "VersionRangeExceeded"
+
+	// ChannelHeadOutOfRange is the reason given for a SubscriptionVersionRangeExceeded condition.
+	ChannelHeadOutOfRange = "ChannelHeadOutOfRange"
+)
+
+// SubscriptionVersionRange returns the version range declared by a Subscription's
+// SubscriptionVersionRangeAnnotationKey annotation, and false if it declares none.
+func SubscriptionVersionRange(sub *v1alpha1.Subscription) (semver.Range, bool, error) {
+	raw, ok := sub.GetAnnotations()[SubscriptionVersionRangeAnnotationKey]
+	if !ok || strings.TrimSpace(raw) == "" {
+		return nil, false, nil
+	}
+	r, err := semver.ParseRange(raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid version range %q in annotation %s of subscription %s: %w", raw, SubscriptionVersionRangeAnnotationKey, sub.GetName(), err)
+	}
+	return r, true, nil
+}
+
+// BundleInVersionRange returns true if the Subscription declares no version range, or if the given bundle's
+// version is within it. A bundle without a parseable version is never within a declared range.
+func BundleInVersionRange(sub *v1alpha1.Subscription, bundle *api.Bundle) (bool, error) {
+	r, ok, err := SubscriptionVersionRange(sub)
+	if err != nil || !ok {
+		return !ok, err
+	}
+	if bundle == nil {
+		return false, nil
+	}
+	v, err := semver.Parse(bundle.GetVersion())
+	if err != nil {
+		return false, nil
+	}
+	return r(v), nil
+}
+
+// VersionRangeExceededCondition returns a SubscriptionVersionRangeExceeded condition reporting that the named
+// channel head is outside the Subscription's version range.
+func VersionRangeExceededCondition(sub *v1alpha1.Subscription, head *api.Bundle, now *metav1.Time) v1alpha1.SubscriptionCondition {
+	return v1alpha1.SubscriptionCondition{
+		Type:               SubscriptionVersionRangeExceeded,
+		Status:             corev1.ConditionTrue,
+		Reason:             ChannelHeadOutOfRange,
+		Message:            fmt.Sprintf("head of channel %s of package %s is %s (version %s), which is outside the version range %q", sub.Spec.Channel, sub.Spec.Package, head.GetCsvName(), head.GetVersion(), sub.GetAnnotations()[SubscriptionVersionRangeAnnotationKey]),
+		LastTransitionTime: now,
+	}
+}
@@ -0,0 +1,76 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/pkg/api"
+)
+
+func TestBundleInVersionRange(t *testing.T) {
+	withRange := func(r string) *v1alpha1.Subscription {
+		return &v1alpha1.Subscription{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "sub",
+				Annotations: map[string]string{SubscriptionVersionRangeAnnotationKey: r},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		sub      *v1alpha1.Subscription
+		bundle   *api.Bundle
+		expected bool
+		err      bool
+	}{
+		{
+			name:     "NoRange",
+			sub:      &v1alpha1.Subscription{},
+			bundle:   &api.Bundle{Version: "2.0.0"},
+			expected: true,
+		},
+		{
+			name:     "EmptyRange",
+			sub:      withRange(" "),
+			bundle:   &api.Bundle{Version: "2.0.0"},
+			expected: true,
+		},
+		{
+			name:     "InRange",
+			sub:      withRange(">=1.4.0 <1.6.0"),
+			bundle:   &api.Bundle{Version: "1.5.3"},
+			expected: true,
+		},
+		{
+			name:   "OutOfRange",
+			sub:    withRange(">=1.4.0 <1.6.0"),
+			bundle: &api.Bundle{Version: "1.6.0"},
+		},
+		{
+			name:   "UnparseableVersion",
+			sub:    withRange(">=1.4.0 <1.6.0"),
+			bundle: &api.Bundle{Version: "latest"},
+		},
+		{
+			name:   "InvalidRange",
+			sub:    withRange("one point four"),
+			bundle: &api.Bundle{Version: "1.5.0"},
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := BundleInVersionRange(tt.sub, tt.bundle)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -15,7 +15,7 @@ type FakeStepResolver struct {
 	expireArgsForCall []struct {
 		arg1 registry.CatalogKey
 	}
-	ResolveStepsStub        func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error)
+	ResolveStepsStub        func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, resolver.Dependencies, error)
 	resolveStepsMutex       sync.RWMutex
 	resolveStepsArgsForCall []struct {
 		arg1 string
@@ -25,13 +25,15 @@ type FakeStepResolver struct {
 		result1 []*v1alpha1.Step
 		result2 []v1alpha1.BundleLookup
 		result3 []*v1alpha1.Subscription
-		result4 error
+		result4 resolver.Dependencies
+		result5 error
 	}
 	resolveStepsReturnsOnCall map[int]struct {
 		result1 []*v1alpha1.Step
 		result2 []v1alpha1.BundleLookup
 		result3 []*v1alpha1.Subscription
-		result4 error
+		result4 resolver.Dependencies
+		result5 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
@@ -68,7 +70,7 @@ func (fake *FakeStepResolver) ExpireArgsForCall(i int) registry.CatalogKey {
 	return argsForCall.arg1
 }
 
-func (fake *FakeStepResolver) ResolveSteps(arg1 string, arg2 resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error) {
+func (fake *FakeStepResolver) ResolveSteps(arg1 string, arg2 resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, resolver.Dependencies, error) {
 	fake.resolveStepsMutex.Lock()
 	ret, specificReturn := fake.resolveStepsReturnsOnCall[len(fake.resolveStepsArgsForCall)]
 	fake.resolveStepsArgsForCall = append(fake.resolveStepsArgsForCall, struct {
@@ -81,10 +83,10 @@ func (fake *FakeStepResolver) ResolveSteps(arg1 string, arg2 resolver.SourceQuer
 		return fake.ResolveStepsStub(arg1, arg2)
 	}
 	if specificReturn {
-		return ret.result1, ret.result2, ret.result3, ret.result4
+		return ret.result1, ret.result2, ret.result3, ret.result4, ret.result5
 	}
 	fakeReturns := fake.resolveStepsReturns
-	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3, fakeReturns.result4
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3, fakeReturns.result4, fakeReturns.result5
 }
 
 func (fake *FakeStepResolver) ResolveStepsCallCount() int {
@@ -93,7 +95,7 @@ func (fake *FakeStepResolver) ResolveStepsCallCount() int {
 	return len(fake.resolveStepsArgsForCall)
 }
 
-func (fake *FakeStepResolver) ResolveStepsCalls(stub func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, error)) {
+func (fake *FakeStepResolver) ResolveStepsCalls(stub func(string, resolver.SourceQuerier) ([]*v1alpha1.Step, []v1alpha1.BundleLookup, []*v1alpha1.Subscription, resolver.Dependencies, error)) {
 	fake.resolveStepsMutex.Lock()
 	defer fake.resolveStepsMutex.Unlock()
 	fake.ResolveStepsStub = stub
@@ -106,7 +108,7 @@ func (fake *FakeStepResolver) ResolveStepsArgsForCall(i int) (string, resolver.S
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *FakeStepResolver) ResolveStepsReturns(result1 []*v1alpha1.Step, result2 []v1alpha1.BundleLookup, result3 []*v1alpha1.Subscription, result4 error) {
+func (fake *FakeStepResolver) ResolveStepsReturns(result1 []*v1alpha1.Step, result2 []v1alpha1.BundleLookup, result3 []*v1alpha1.Subscription, result4 resolver.Dependencies, result5 error) {
 	fake.resolveStepsMutex.Lock()
 	defer fake.resolveStepsMutex.Unlock()
 	fake.ResolveStepsStub = nil
@@ -114,11 +116,12 @@ func (fake *FakeStepResolver) ResolveStepsReturns(result1 []*v1alpha1.Step, resu
 		result1 []*v1alpha1.Step
 		result2 []v1alpha1.BundleLookup
 		result3 []*v1alpha1.Subscription
-		result4 error
-	}{result1, result2, result3, result4}
+		result4 resolver.Dependencies
+		result5 error
+	}{result1, result2, result3, result4, result5}
 }
 
-func (fake *FakeStepResolver) ResolveStepsReturnsOnCall(i int, result1 []*v1alpha1.Step, result2 []v1alpha1.BundleLookup, result3 []*v1alpha1.Subscription, result4 error) {
+func (fake *FakeStepResolver) ResolveStepsReturnsOnCall(i int, result1 []*v1alpha1.Step, result2 []v1alpha1.BundleLookup, result3 []*v1alpha1.Subscription, result4 resolver.Dependencies, result5 error) {
 	fake.resolveStepsMutex.Lock()
 	defer fake.resolveStepsMutex.Unlock()
 	fake.ResolveStepsStub = nil
@@ -127,15 +130,17 @@ func (fake *FakeStepResolver) ResolveStepsReturnsOnCall(i int, result1 []*v1alph
 			result1 []*v1alpha1.Step
 			result2 []v1alpha1.BundleLookup
 			result3 []*v1alpha1.Subscription
-			result4 error
+			result4 resolver.Dependencies
+			result5 error
 		})
 	}
 	fake.resolveStepsReturnsOnCall[i] = struct {
 		result1 []*v1alpha1.Step
 		result2 []v1alpha1.BundleLookup
 		result3 []*v1alpha1.Subscription
-		result4 error
-	}{result1, result2, result3, result4}
+		result4 resolver.Dependencies
+		result5 error
+	}{result1, result2, result3, result4, result5}
 }
 
 func (fake *FakeStepResolver) Invocations() map[string][][]interface{} {
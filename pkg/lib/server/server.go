@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"path/filepath"
+	"sync"
 
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/filemonitor"
 	"github.com/operator-framework/operator-lifecycle-manager/pkg/lib/profile"
@@ -13,13 +14,51 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func GetListenAndServeFunc(logger *logrus.Logger, tlsCertPath, tlsKeyPath, clientCAPath *string) (func() error, error) {
+// Option configures the server returned by GetListenAndServeFunc.
+type Option func(mux *http.ServeMux)
+
+// WithHandler registers an additional handler for the given pattern.
+func WithHandler(pattern string, handler http.Handler) Option {
+	return func(mux *http.ServeMux) {
+		mux.Handle(pattern, handler)
+	}
+}
+
+// DeferredHandler serves 503 Service Unavailable until its handler is set. It lets handlers that depend on components
+// created after the server starts be registered up front.
+type DeferredHandler struct {
+	mu      sync.RWMutex
+	handler http.Handler
+}
+
+// Set sets the handler that serves subsequent requests.
+func (d *DeferredHandler) Set(handler http.Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handler = handler
+}
+
+func (d *DeferredHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	handler := d.handler
+	d.mu.RUnlock()
+	if handler == nil {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+func GetListenAndServeFunc(logger *logrus.Logger, tlsCertPath, tlsKeyPath, clientCAPath *string, options ...Option) (func() error, error) {
 	mux := http.NewServeMux()
 	profile.RegisterHandlers(mux)
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
+	for _, option := range options {
+		option(mux)
+	}
 
 	s := http.Server{
 		Handler: mux,
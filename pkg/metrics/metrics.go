@@ -193,6 +193,31 @@ var (
 		[]string{Outcome},
 	)
 
+	catalogSnapshotPopulationSummary = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Name:       "olm_catalog_snapshot_population_duration_seconds",
+			Help:       "The duration of populating the resolver's snapshot of a catalog",
+			Objectives: map[float64]float64{0.95: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{NAMESPACE_LABEL, NAME_LABEL},
+	)
+
+	catalogSnapshotBundles = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "olm_catalog_snapshot_bundles",
+			Help: "Number of bundles in the resolver's most recent snapshot of a catalog",
+		},
+		[]string{NAMESPACE_LABEL, NAME_LABEL},
+	)
+
+	catalogSnapshotPackageErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "olm_catalog_snapshot_package_errors_total",
+			Help: "monotonic count of failures to fetch the metadata of a package for the resolver's snapshots of a catalog",
+		},
+		[]string{NAMESPACE_LABEL, NAME_LABEL},
+	)
+
 	installPlanWarningCount = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "installplan_warnings_total",
@@ -227,6 +252,9 @@ func RegisterCatalog() {
 	prometheus.MustRegister(catalogSourceReady)
 	prometheus.MustRegister(SubscriptionSyncCount)
 	prometheus.MustRegister(dependencyResolutionSummary)
+	prometheus.MustRegister(catalogSnapshotPopulationSummary)
+	prometheus.MustRegister(catalogSnapshotBundles)
+	prometheus.MustRegister(catalogSnapshotPackageErrors)
 	prometheus.MustRegister(installPlanWarningCount)
 }
 
@@ -245,6 +273,18 @@ func RegisterCatalogSourceState(name, namespace string, state connectivity.State
 
 func DeleteCatalogSourceStateMetric(name, namespace string) {
 	catalogSourceReady.DeleteLabelValues(namespace, name)
+	catalogSnapshotPopulationSummary.DeleteLabelValues(namespace, name)
+	catalogSnapshotBundles.DeleteLabelValues(namespace, name)
+	catalogSnapshotPackageErrors.DeleteLabelValues(namespace, name)
+}
+
+func EmitCatalogSnapshotPopulation(name, namespace string, duration time.Duration, bundles int) {
+	catalogSnapshotPopulationSummary.WithLabelValues(namespace, name).Observe(duration.Seconds())
+	catalogSnapshotBundles.WithLabelValues(namespace, name).Set(float64(bundles))
+}
+
+func IncrementCatalogSnapshotPackageErrors(name, namespace string) {
+	catalogSnapshotPackageErrors.WithLabelValues(namespace, name).Inc()
 }
 
 func DeleteCSVMetric(oldCSV *olmv1alpha1.ClusterServiceVersion) {